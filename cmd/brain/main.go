@@ -8,15 +8,27 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/alex/koji/internal/api"
 	"github.com/alex/koji/internal/brain"
+	"github.com/alex/koji/internal/mqtt"
+	"github.com/alex/koji/internal/vision"
+	"github.com/alex/koji/internal/wire"
 )
 
 func main() {
 	// Flags
 	apiAddr := flag.String("addr", ":8080", "API server address")
+	mqttBroker := flag.String("mqtt", "", "MQTT broker address (host:port), empty to disable")
+	mqttPrefix := flag.String("mqtt-prefix", "koji", "MQTT topic prefix")
+	mqttUser := flag.String("mqtt-user", "", "MQTT username")
+	mqttPassFile := flag.String("mqtt-pass-file", "", "File holding the MQTT password (default: $"+mqtt.PasswordEnv+")")
+	mqttDiscovery := flag.Bool("mqtt-discovery", true, "Publish Home Assistant discovery payloads")
+	udpTargets := flag.String("udp", "", "Comma-separated UDP targets for binary state frames (e.g. 255.255.255.255:4210)")
+	udpEvents := flag.String("udp-events", "", "UDP address to listen on for binary sensor events (e.g. :4211)")
+	visionURL := flag.String("vision", "", "Vision server URL to tell about petting etc. (e.g. http://localhost:8081), empty to disable")
 	flag.Parse()
 
 	log.Println("=== Koji Brain Server ===")
@@ -25,13 +37,27 @@ func main() {
 	cfg := brain.DefaultConfig()
 	b := brain.New(cfg)
 
-	// Create and wire up the API server
-	server := api.NewServer(*apiAddr, b, b)
-
 	// Context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Events reach the brain directly, or through a forwarder that also
+	// tells vision about interactions so strangers can become acquaintances
+	var handler api.EventHandler = b
+	if *visionURL != "" {
+		forwarder := vision.NewInteractionForwarder(b, *visionURL)
+		handler = forwarder
+
+		go func() {
+			if err := forwarder.Run(ctx); err != nil && err != context.Canceled {
+				log.Printf("Vision forwarder error: %v", err)
+			}
+		}()
+	}
+
+	// Create and wire up the API server
+	server := api.NewServer(*apiAddr, b, handler)
+
 	// Handle signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -51,12 +77,68 @@ func main() {
 		}
 	}()
 
+	// Start MQTT bridge in background (optional)
+	if *mqttBroker != "" {
+		mqttCfg := mqtt.DefaultConfig()
+		mqttCfg.Broker = *mqttBroker
+		mqttCfg.TopicPrefix = *mqttPrefix
+		mqttCfg.Username = *mqttUser
+		pass, err := mqtt.LoadPassword(*mqttPassFile)
+		if err != nil {
+			log.Fatalf("MQTT password: %v", err)
+		}
+		mqttCfg.Password = pass
+		mqttCfg.Discovery = *mqttDiscovery
+		bridge := mqtt.NewBridge(mqttCfg, b, handler)
+
+		go func() {
+			if err := bridge.Run(ctx); err != nil && err != context.Canceled {
+				log.Printf("MQTT bridge error: %v", err)
+			}
+		}()
+	}
+
+	// Start UDP state broadcaster and event listener in background (optional)
+	if *udpTargets != "" {
+		broadcaster := wire.NewBroadcaster(wire.BroadcasterConfig{
+			Targets: strings.Split(*udpTargets, ","),
+		}, b)
+
+		go func() {
+			if err := broadcaster.Run(ctx); err != nil && err != context.Canceled {
+				log.Printf("UDP broadcaster error: %v", err)
+			}
+		}()
+	}
+	if *udpEvents != "" {
+		listener := wire.NewListener(*udpEvents, handler)
+
+		go func() {
+			if err := listener.Run(ctx); err != nil && err != context.Canceled {
+				log.Printf("UDP listener error: %v", err)
+			}
+		}()
+	}
+
 	log.Printf("Koji brain ready. Listening on %s", *apiAddr)
 	log.Println()
 	log.Println("Endpoints:")
 	log.Println("  GET  /api/state  - get current emotional state")
 	log.Println("  POST /api/event  - send sensor event")
+	log.Println("  GET  /api/face   - get face animation cues")
 	log.Println("  GET  /health     - health check")
+	if *udpTargets != "" {
+		log.Printf("UDP state frames: %s", *udpTargets)
+	}
+	if *udpEvents != "" {
+		log.Printf("UDP events: %s", *udpEvents)
+	}
+	if *visionURL != "" {
+		log.Printf("Interactions forwarded to vision: %s", *visionURL)
+	}
+	if *mqttBroker != "" {
+		log.Printf("MQTT: %s (topics under %s/)", *mqttBroker, *mqttPrefix)
+	}
 	log.Println()
 
 	// Wait for signal
@@ -0,0 +1,264 @@
+// Command calibrate measures how well face embeddings tell people apart and
+// recommends recognition thresholds: overall, per relationship and, with
+// -per-person, for each person with enough samples.
+//
+// From the enrolled database:
+//
+//	go run ./cmd/calibrate -from-db data/faces.json -out data/thresholds.json
+//
+// From labeled embeddings, a JSON array of {"person", "relationship", "embedding"}:
+//
+//	go run ./cmd/calibrate -in labeled.json -far 0.0001 -roc roc.csv
+//
+// From photos in one directory per person, embedded by the detector sidecar:
+//
+//	go run ./cmd/calibrate -images photos/ -detector http://localhost:5000 -relationships alex=owner,jordan=family
+//
+// The vision server reads the result with -thresholds. Calibrate with the
+// model it runs: thresholds for one model mean nothing for another.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/alex/koji/internal/vision"
+)
+
+func main() {
+	inPath := flag.String("in", "", "Labeled embeddings (JSON)")
+	dbPath := flag.String("from-db", "", "Use the samples enrolled in this face database")
+	keyFile := flag.String("key-file", "", "Key for -from-db (default: $"+vision.KeyEnv+", or plaintext)")
+	imagesDir := flag.String("images", "", "Directory of photos, one subdirectory per person")
+	detectorURL := flag.String("detector", "http://localhost:5000", "Detector sidecar URL for -images")
+	relationships := flag.String("relationships", "", "Relationships for -images, as name=relationship,... (default: friend)")
+	far := flag.Float64("far", 0.001, "Acceptable false accept rate")
+	ownerFAR := flag.Float64("owner-far", 0, "Acceptable false accept rate for the owner (default: -far/10)")
+	perPerson := flag.Bool("per-person", false, "Also recommend a threshold for each person with enough samples")
+	minSamples := flag.Int("min-samples", 10, "Genuine comparisons a person needs for their own threshold")
+	rocPath := flag.String("roc", "", "Write the overall ROC/DET points here (CSV)")
+	outPath := flag.String("out", "", "Write the recommended thresholds here (JSON)")
+	currentPath := flag.String("thresholds", "", "Thresholds in use now, to compare with (default: the built-in ones)")
+	flag.Parse()
+
+	var samples []vision.LabeledEmbedding
+	var err error
+	switch {
+	case *inPath != "":
+		samples, err = loadLabeled(*inPath)
+	case *dbPath != "":
+		samples, err = loadDB(*dbPath, *keyFile)
+	case *imagesDir != "":
+		samples, err = embedImages(*imagesDir, *detectorURL, *relationships)
+	default:
+		fatalf("One of -in, -from-db or -images is required")
+	}
+	if err != nil {
+		fatalf("Loading samples: %v", err)
+	}
+
+	c, err := vision.Calibrate(samples, vision.CalibrationConfig{
+		TargetFAR:  *far,
+		OwnerFAR:   *ownerFAR,
+		PerPerson:  *perPerson,
+		MinSamples: *minSamples,
+	})
+	if err != nil {
+		fatalf("Calibrating: %v", err)
+	}
+	current := vision.DefaultThresholds()
+	if *currentPath != "" {
+		if current, err = vision.LoadThresholds(*currentPath); err != nil {
+			fatalf("Loading thresholds: %v", err)
+		}
+	}
+	printReport(c, len(samples), current)
+
+	if *rocPath != "" {
+		if err := writeROC(*rocPath, c.Overall); err != nil {
+			fatalf("Writing ROC: %v", err)
+		}
+		fmt.Printf("ROC/DET points saved to %s\n", *rocPath)
+	}
+	if *outPath != "" {
+		if err := c.Thresholds().Save(*outPath); err != nil {
+			fatalf("Writing thresholds: %v", err)
+		}
+		fmt.Printf("Thresholds saved to %s (use with: go run ./cmd/vision -thresholds %s)\n", *outPath, *outPath)
+	}
+}
+
+func loadLabeled(path string) ([]vision.LabeledEmbedding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var samples []vision.LabeledEmbedding
+	if err := json.Unmarshal(data, &samples); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return samples, nil
+}
+
+func loadDB(path, keyFile string) ([]vision.LabeledEmbedding, error) {
+	key, err := vision.LoadKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	people, err := vision.ReadPeople(path, key) // read-only: vision may be running
+	if err != nil {
+		return nil, err
+	}
+	return vision.LabeledFromPeople(people), nil
+}
+
+// embedImages embeds every photo under dir/<person>/. Photos without
+// exactly one face are skipped with a warning.
+func embedImages(dir, detectorURL, relationships string) ([]vision.LabeledEmbedding, error) {
+	rels := make(map[string]vision.Relationship)
+	for pair := range strings.SplitSeq(relationships, ",") {
+		if pair == "" {
+			continue
+		}
+		name, rel, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("-relationships: %q is not name=relationship", pair)
+		}
+		rels[name] = vision.Relationship(rel)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	detector := vision.NewRemoteDetector(vision.RemoteDetectorConfig{URL: detectorURL})
+	ctx := context.Background()
+
+	var samples []vision.LabeledEmbedding
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		person := entry.Name()
+		photos, err := os.ReadDir(filepath.Join(dir, person))
+		if err != nil {
+			return nil, err
+		}
+		for _, photo := range photos {
+			if photo.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, person, photo.Name())
+			image, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			embedding, err := detector.ExtractEmbedding(ctx, image)
+			if err != nil {
+				if errors.Is(err, vision.ErrDetectorUnavailable) {
+					return nil, fmt.Errorf("%s: %w", path, err)
+				}
+				fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", path, err)
+				continue
+			}
+			samples = append(samples, vision.LabeledEmbedding{Person: person, Relationship: rels[person], Embedding: embedding})
+		}
+	}
+	return samples, nil
+}
+
+func printReport(c *vision.Calibration, samples int, current vision.Thresholds) {
+	fmt.Printf("Samples: %d  Comparisons: %d genuine, %d impostor\n\n",
+		samples, c.Overall.Genuine.Count, c.Overall.Impostor.Count)
+
+	fmt.Println("Similarity distributions (overall)")
+	fmt.Printf("  %-9s %6s %6s %6s %6s\n", "", "mean", "std", "min", "max")
+	for _, d := range []struct {
+		name string
+		d    vision.Distribution
+	}{{"genuine", c.Overall.Genuine}, {"impostor", c.Overall.Impostor}} {
+		fmt.Printf("  %-9s %6.3f %6.3f %6.3f %6.3f\n", d.name, d.d.Mean, d.d.StdDev, d.d.Min, d.d.Max)
+	}
+	fmt.Println()
+	printHistogram(c.Overall)
+
+	fmt.Printf("%-24s %9s %8s %8s %8s %8s %7s\n", "threshold for", "recommend", "FAR", "FRR", "EER", "current", "FRR now")
+	row := func(name string, a *vision.Analysis, now float64) {
+		r := a.Recommended
+		note := ""
+		switch {
+		case a.Unreachable:
+			note = "  (unreachable: the data can't support the target FAR at any threshold)"
+		case a.Uncertain:
+			note = "  (extrapolated: too few impostors to measure the target FAR)"
+		}
+		fmt.Printf("%-24s %9.3f %7.3f%% %7.2f%% %7.2f%% %8.3f %6.2f%%%s\n",
+			name, r.Threshold, r.FAR*100, r.FRR*100, a.EER*100, now, a.At(now).FRR*100, note)
+	}
+	row("default", c.Overall, current.Default)
+	for _, r := range sortedKeys(c.Relationships) {
+		row(string(r), c.Relationships[r], current.For(&vision.Person{Relationship: r}))
+	}
+	relationships := make(map[string]vision.Relationship)
+	for _, s := range c.Scores {
+		relationships[s.Claimed] = s.Relationship
+	}
+	for _, name := range sortedKeys(c.People) {
+		row("  "+name, c.People[name], current.For(&vision.Person{Name: name, Relationship: relationships[name]}))
+	}
+	fmt.Println()
+}
+
+// printHistogram draws both distributions on one axis, so the overlap
+// (or the gap) between them shows.
+func printHistogram(a *vision.Analysis) {
+	scale := func(n, total int) int {
+		if n == 0 {
+			return 0
+		}
+		return max(1, n*40/total)
+	}
+	for i := range a.Genuine.Histogram {
+		g, imp := a.Genuine.Histogram[i], a.Impostor.Histogram[i]
+		if g == 0 && imp == 0 {
+			continue
+		}
+		fmt.Printf("  %.2f %-40s %s\n", float64(i)*0.05,
+			strings.Repeat("-", scale(imp, a.Impostor.Count)),
+			strings.Repeat("+", scale(g, a.Genuine.Count)))
+	}
+	fmt.Println("  (- impostor, + genuine)")
+	fmt.Println()
+}
+
+// writeROC writes one row per threshold. Plot TAR against FAR for the ROC
+// curve, FRR against FAR on log axes for the DET curve.
+func writeROC(path string, a *vision.Analysis) error {
+	var b strings.Builder
+	b.WriteString("threshold,far,frr,tar\n")
+	for _, p := range a.ROC {
+		fmt.Fprintf(&b, "%.3f,%.6f,%.6f,%.6f\n", p.Threshold, p.FAR, p.FRR, p.TAR())
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/alex/koji/internal/llm"
+	"github.com/alex/koji/internal/personality"
+)
+
+//go:embed scenarios.json
+var defaultSuite []byte
+
+// Suite is a set of situations with the answers considered in character.
+type Suite struct {
+	Scenarios []Scenario `json:"scenarios"`
+}
+
+// Scenario is one situation Koji is put in. Mood and intensity are the state
+// after the event has been processed, as the LLM would see it.
+type Scenario struct {
+	Name           string            `json:"name"`
+	Mood           personality.Mood  `json:"mood"`
+	Intensity      float64           `json:"intensity"`
+	Event          personality.Event `json:"event"`
+	EventIntensity float64           `json:"event_intensity"`
+	Recent         []string          `json:"recent,omitempty"` // earlier events, oldest first
+	Person         string            `json:"person,omitempty"`
+	Relationship   string            `json:"relationship,omitempty"`
+
+	// Acceptable lists the in-character values per field (movement,
+	// expression, sound, modifier). A field without a list accepts any
+	// value valid for the mood.
+	Acceptable map[string][]string `json:"acceptable"`
+}
+
+// LoadSuite reads a scenario suite, or returns the built-in one if path is empty.
+func LoadSuite(path string) (Suite, error) {
+	data := defaultSuite
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return Suite{}, err
+		}
+	}
+
+	var suite Suite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return Suite{}, fmt.Errorf("parsing suite: %w", err)
+	}
+	if len(suite.Scenarios) == 0 {
+		return Suite{}, fmt.Errorf("suite has no scenarios")
+	}
+	return suite, nil
+}
+
+// request builds the action request the personality engine sees for s.
+func (s Scenario) request() llm.ActionRequest {
+	state := personality.NewEmotionalState()
+	state.SetMood(s.Mood, personality.Intensity(s.Intensity))
+
+	event := personality.NewEventContext(s.Event)
+	if s.EventIntensity != 0 {
+		event = event.WithIntensity(s.EventIntensity)
+	}
+	if s.Person != "" {
+		event.Metadata[personality.MetaPersonName] = s.Person
+		event.Metadata[personality.MetaRelationship] = s.Relationship
+	}
+
+	req := llm.ActionRequest{EmotionalState: state, Event: event}
+	for _, e := range s.Recent {
+		req.RecentEvents = append(req.RecentEvents, personality.Event(e))
+	}
+	return req
+}
+
+// inappropriate returns the fields of resp that aren't in the scenario's
+// acceptable lists. Fields the LLM got wrong never count as acceptable, even
+// when the mood default that replaced them would.
+func (s Scenario) inappropriate(resp *llm.ActionResponse) []string {
+	values := map[string]string{
+		"movement":   string(resp.Movement),
+		"expression": string(resp.Expression),
+		"sound":      string(resp.Sound),
+		"modifier":   string(resp.Modifier),
+	}
+
+	var bad []string
+	for _, field := range []string{"movement", "expression", "sound", "modifier"} {
+		if slices.Contains(resp.Fallbacks, field) {
+			bad = append(bad, field)
+			continue
+		}
+		if ok := s.Acceptable[field]; len(ok) > 0 && !slices.Contains(ok, values[field]) {
+			bad = append(bad, field)
+		}
+	}
+	return bad
+}
+
+// Recording holds raw LLM answers per scenario, so a run against a real
+// backend can be replayed and re-scored without it.
+type Recording struct {
+	Model         string              `json:"model"`
+	PromptVersion string              `json:"prompt_version"`
+	PromptHash    string              `json:"prompt_hash"`
+	Note          string              `json:"note,omitempty"` // where the answers came from, if not a -record run
+	Answers       map[string][]string `json:"answers"`        // scenario name -> answers in order
+}
+
+// ErrStaleRecording means a recording was made with a different prompt than
+// the one being evaluated, so replaying it says nothing about the prompt.
+var ErrStaleRecording = errors.New("recording was made with a different prompt")
+
+// CheckPrompt returns ErrStaleRecording unless the recording was made with
+// the given prompt version and, if it has one, hash.
+func (r *Recording) CheckPrompt(version, hash string) error {
+	if r.PromptVersion != version {
+		return fmt.Errorf("%w: recorded with %s, evaluating %s; re-record with -record", ErrStaleRecording, r.PromptVersion, version)
+	}
+	if r.PromptHash != "" && r.PromptHash != hash {
+		return fmt.Errorf("%w: %s changed since it was recorded (%s, now %s); re-record with -record", ErrStaleRecording, version, r.PromptHash, hash)
+	}
+	return nil
+}
+
+// LoadRecording reads a recording written by a previous -record run.
+func LoadRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("parsing recording: %w", err)
+	}
+	return &rec, nil
+}
+
+// Save writes the recording as JSON.
+func (r *Recording) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// recordingGenerator passes calls through to a real backend and keeps each
+// answer under the scenario being run.
+type recordingGenerator struct {
+	llm.Generator
+	rec      *Recording
+	scenario string
+}
+
+func (g *recordingGenerator) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	response, err := g.Generator.Generate(ctx, prompt, opts)
+	if err == nil {
+		g.rec.Answers[g.scenario] = append(g.rec.Answers[g.scenario], response)
+	}
+	return response, err
+}
+
+// Capabilities hides streaming so every answer goes through Generate.
+func (g *recordingGenerator) Capabilities() llm.Capabilities {
+	caps := g.Generator.Capabilities()
+	caps.Streaming = false
+	return caps
+}
+
+// replayGenerator answers with a scenario's recorded answers, cycling
+// through them in order.
+type replayGenerator struct {
+	rec      *Recording
+	scenario string
+	next     int
+}
+
+func (g *replayGenerator) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	answers := g.rec.Answers[g.scenario]
+	if len(answers) == 0 {
+		return "", fmt.Errorf("no recorded answer for scenario %q", g.scenario)
+	}
+	response := answers[g.next%len(answers)]
+	g.next++
+	return response, nil
+}
+
+func (g *replayGenerator) Capabilities() llm.Capabilities {
+	return llm.Capabilities{JSONMode: true}
+}
+
+func (g *replayGenerator) Health(ctx context.Context) (llm.Health, error) {
+	return llm.Health{Backend: "replay", Model: g.Model(), ModelAvailable: true}, nil
+}
+
+func (g *replayGenerator) Model() string {
+	return g.rec.Model
+}
+
+// Result is the output of one evaluation run.
+type Result struct {
+	Time          time.Time        `json:"time"`
+	Backend       string           `json:"backend"`
+	Model         string           `json:"model"`
+	PromptVersion string           `json:"prompt_version"`
+	PromptHash    string           `json:"prompt_hash"`
+	Repeats       int              `json:"repeats"`
+	Summary       Summary          `json:"summary"`
+	Scenarios     []ScenarioResult `json:"scenarios"`
+}
+
+// Summary aggregates every run of every scenario.
+type Summary struct {
+	Runs            int     `json:"runs"`
+	Validity        float64 `json:"validity"`        // share of runs with every field valid
+	Appropriateness float64 `json:"appropriateness"` // share of runs with every field acceptable
+	FallbackRate    float64 `json:"fallback_rate"`   // share of fields replaced by mood defaults
+	ErrorRate       float64 `json:"error_rate"`      // share of runs with no usable answer at all
+	Diversity       float64 `json:"diversity"`       // mean scenario diversity
+	ToActionP50Ms   float64 `json:"to_action_p50_ms"`
+	ToActionP95Ms   float64 `json:"to_action_p95_ms"`
+	TotalP50Ms      float64 `json:"total_p50_ms"`
+	TotalP95Ms      float64 `json:"total_p95_ms"`
+}
+
+// ScenarioResult is every run of one scenario.
+type ScenarioResult struct {
+	Name        string  `json:"name"`
+	Runs        int     `json:"runs"`
+	Valid       int     `json:"valid"`
+	Appropriate int     `json:"appropriate"`
+	Fallbacks   int     `json:"fallbacks"` // fields replaced, errors count as all of them
+	Errors      int     `json:"errors"`
+	Distinct    int     `json:"distinct"`  // different answers (action set + modifier)
+	Diversity   float64 `json:"diversity"` // 0 = always the same answer, 1 = never repeated
+	Answers     []Run   `json:"answers"`
+}
+
+// Run is a single answer.
+type Run struct {
+	Movement      personality.Action         `json:"movement,omitempty"`
+	Expression    personality.Action         `json:"expression,omitempty"`
+	Sound         personality.Action         `json:"sound,omitempty"`
+	Modifier      personality.ActionModifier `json:"modifier,omitempty"`
+	Reason        string                     `json:"reason,omitempty"`
+	Fallbacks     []string                   `json:"fallbacks,omitempty"`
+	Inappropriate []string                   `json:"inappropriate,omitempty"`
+	Error         string                     `json:"error,omitempty"`
+	ToActionMs    float64                    `json:"to_action_ms"`
+	TotalMs       float64                    `json:"total_ms"`
+}
+
+// Evaluator runs a suite against a backend.
+type Evaluator struct {
+	Profile llm.Profile
+	Prompts *llm.PromptLibrary
+	Repeats int           // runs per scenario (default: 1)
+	Timeout time.Duration // per decision (default: 30s)
+
+	// GeneratorFor returns the backend to use for a scenario.
+	GeneratorFor func(scenario string) llm.Generator
+}
+
+// Run evaluates every scenario and returns the scored result.
+func (ev *Evaluator) Run(ctx context.Context, suite Suite) (*Result, error) {
+	repeats := max(ev.Repeats, 1)
+	timeout := ev.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	result := &Result{Time: time.Now(), Repeats: repeats}
+	var toAction, total []float64
+	var fallbacks int
+
+	for _, s := range suite.Scenarios {
+		gen := ev.GeneratorFor(s.Name)
+		engine := llm.NewPersonalityEngine(gen)
+		if err := engine.SetProfile(ev.Profile, ev.Prompts); err != nil {
+			return nil, err
+		}
+		result.Model = gen.Model()
+		result.PromptVersion = engine.Prompt().Version
+		result.PromptHash = engine.Prompt().Hash
+
+		sr := ScenarioResult{Name: s.Name}
+		seen := make(map[string]bool)
+		for range repeats {
+			runCtx, cancel := context.WithTimeout(ctx, timeout)
+			resp, err := engine.SelectAction(runCtx, s.request())
+			cancel()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			sr.Runs++
+			if err != nil {
+				sr.Errors++
+				sr.Fallbacks += 4
+				sr.Answers = append(sr.Answers, Run{Error: err.Error()})
+				continue
+			}
+
+			run := Run{
+				Movement:      resp.Movement,
+				Expression:    resp.Expression,
+				Sound:         resp.Sound,
+				Modifier:      resp.Modifier,
+				Reason:        resp.Reason,
+				Fallbacks:     resp.Fallbacks,
+				Inappropriate: s.inappropriate(resp),
+				ToActionMs:    ms(resp.Timing.ToAction),
+				TotalMs:       ms(resp.Timing.Total),
+			}
+			sr.Answers = append(sr.Answers, run)
+			sr.Fallbacks += len(resp.Fallbacks)
+			if len(resp.Fallbacks) == 0 {
+				sr.Valid++
+			}
+			if len(run.Inappropriate) == 0 {
+				sr.Appropriate++
+			}
+			seen[fmt.Sprintf("%s/%s/%s/%s", resp.Movement, resp.Expression, resp.Sound, resp.Modifier)] = true
+			toAction = append(toAction, run.ToActionMs)
+			total = append(total, run.TotalMs)
+		}
+
+		sr.Distinct = len(seen)
+		if sr.Runs > 1 && sr.Distinct > 0 {
+			sr.Diversity = float64(sr.Distinct-1) / float64(sr.Runs-1)
+		}
+
+		result.Summary.Runs += sr.Runs
+		result.Summary.Validity += float64(sr.Valid)
+		result.Summary.Appropriateness += float64(sr.Appropriate)
+		result.Summary.ErrorRate += float64(sr.Errors)
+		result.Summary.Diversity += sr.Diversity
+		fallbacks += sr.Fallbacks
+		result.Scenarios = append(result.Scenarios, sr)
+	}
+
+	sum := &result.Summary
+	runs := float64(sum.Runs)
+	sum.Validity /= runs
+	sum.Appropriateness /= runs
+	sum.ErrorRate /= runs
+	sum.FallbackRate = float64(fallbacks) / (runs * 4)
+	sum.Diversity /= float64(len(result.Scenarios))
+	sum.ToActionP50Ms = percentile(toAction, 0.50)
+	sum.ToActionP95Ms = percentile(toAction, 0.95)
+	sum.TotalP50Ms = percentile(total, 0.50)
+	sum.TotalP95Ms = percentile(total, 0.95)
+	return result, nil
+}
+
+// percentile returns the nearest-rank p-th percentile of values, or 0 if
+// there are none.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
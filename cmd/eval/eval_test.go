@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/alex/koji/internal/llm"
+)
+
+func replayEvaluator(t *testing.T, rec *Recording, repeats int) *Evaluator {
+	t.Helper()
+	return &Evaluator{
+		Profile: llm.DefaultProfile(),
+		Prompts: llm.NewPromptLibrary(),
+		Repeats: repeats,
+		GeneratorFor: func(scenario string) llm.Generator {
+			return &replayGenerator{rec: rec, scenario: scenario}
+		},
+	}
+}
+
+// The recorded answers double as a regression check on the built-in suite,
+// the prompt plumbing and the scoring.
+func TestEvaluator_RecordedSuite(t *testing.T) {
+	suite, err := LoadSuite("")
+	if err != nil {
+		t.Fatalf("LoadSuite() error = %v", err)
+	}
+	rec, err := LoadRecording("testdata/recorded.json")
+	if err != nil {
+		t.Fatalf("LoadRecording() error = %v", err)
+	}
+
+	result, err := replayEvaluator(t, rec, 3).Run(context.Background(), suite)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if err := rec.CheckPrompt(result.PromptVersion, result.PromptHash); err != nil {
+		t.Errorf("CheckPrompt() error = %v", err)
+	}
+
+	sum := result.Summary
+	if sum.Runs != 3*len(suite.Scenarios) {
+		t.Errorf("runs = %d, want %d", sum.Runs, 3*len(suite.Scenarios))
+	}
+	for _, s := range result.Scenarios {
+		for _, run := range s.Answers {
+			if strings.HasPrefix(run.Error, "generating") {
+				t.Errorf("%s: %s", s.Name, run.Error)
+			}
+		}
+	}
+	if sum.Validity < 0.9 {
+		t.Errorf("validity = %.2f, want >= 0.9", sum.Validity)
+	}
+	if sum.Appropriateness < 0.8 {
+		t.Errorf("appropriateness = %.2f, want >= 0.8", sum.Appropriateness)
+	}
+	// The recording includes an invalid sound and a prose answer
+	if sum.FallbackRate == 0 || sum.ErrorRate == 0 {
+		t.Errorf("fallback rate = %.2f, error rate = %.2f, want both > 0", sum.FallbackRate, sum.ErrorRate)
+	}
+}
+
+func TestEvaluator_Scoring(t *testing.T) {
+	suite := Suite{Scenarios: []Scenario{{
+		Name: "stranger", Mood: "cautious", Intensity: 0.6, Event: "unknown_face",
+		Acceptable: map[string][]string{"movement": {"freeze", "stay"}},
+	}}}
+	rec := &Recording{Answers: map[string][]string{"stranger": {
+		`{"movement":"freeze","expression":"peek","sound":"growl","modifier":"slow","reason":"Who?"}`,
+		`{"movement":"retreat","expression":"peek","sound":"growl","modifier":"slow","reason":"Back off"}`,
+		`{"movement":"freeze","expression":"wag_tail","sound":"growl","modifier":"slow","reason":"Hi?"}`,
+		`no answer`,
+	}}}
+
+	result, err := replayEvaluator(t, rec, 4).Run(context.Background(), suite)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	s := result.Scenarios[0]
+	if s.Valid != 2 {
+		t.Errorf("valid = %d, want 2 (wag_tail isn't cautious)", s.Valid)
+	}
+	if s.Appropriate != 1 {
+		t.Errorf("appropriate = %d, want 1 (retreat isn't acceptable, wag_tail fell back)", s.Appropriate)
+	}
+	if s.Errors != 1 || s.Fallbacks != 5 {
+		t.Errorf("errors = %d, fallbacks = %d, want 1 and 5", s.Errors, s.Fallbacks)
+	}
+	if s.Distinct != 3 || s.Diversity != 2.0/3 {
+		t.Errorf("distinct = %d, diversity = %.2f, want 3 and 0.67", s.Distinct, s.Diversity)
+	}
+	if got := result.Summary.FallbackRate; math.Abs(got-5.0/16) > 1e-9 {
+		t.Errorf("fallback rate = %.3f, want %.3f", got, 5.0/16)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{5, 1, 4, 2, 3, 10, 9, 8, 7, 6}
+	if got := percentile(values, 0.5); got != 5 {
+		t.Errorf("p50 = %v, want 5", got)
+	}
+	if got := percentile(values, 0.95); got != 10 {
+		t.Errorf("p95 = %v, want 10", got)
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("p50 of nothing = %v, want 0", got)
+	}
+}
+
+func TestRecording_CheckPrompt(t *testing.T) {
+	rec := &Recording{PromptVersion: "v3", PromptHash: "abc"}
+	if err := rec.CheckPrompt("v3", "abc"); err != nil {
+		t.Errorf("CheckPrompt() same prompt error = %v", err)
+	}
+	if err := rec.CheckPrompt("v4", "abc"); !errors.Is(err, ErrStaleRecording) {
+		t.Errorf("CheckPrompt() other version error = %v, want ErrStaleRecording", err)
+	}
+	if err := rec.CheckPrompt("v3", "def"); !errors.Is(err, ErrStaleRecording) {
+		t.Errorf("CheckPrompt() edited template error = %v, want ErrStaleRecording", err)
+	}
+}
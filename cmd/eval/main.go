@@ -0,0 +1,165 @@
+// Command eval scores how well an LLM backend plays Koji: it runs a suite of
+// situations through the personality engine and reports how often the answers
+// are valid and in character, how fast they arrive and how much they vary.
+//
+// Against a live backend:
+//
+//	go run ./cmd/eval -model phi3:mini -repeats 5 -record data/eval_phi3.json
+//
+// Offline, replaying recorded answers (what CI does). This fails if the
+// prompt has changed since the answers were recorded:
+//
+//	go run ./cmd/eval -replay cmd/eval/testdata/recorded.json -min-validity 0.8
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/alex/koji/internal/llm"
+)
+
+func main() {
+	suitePath := flag.String("suite", "", "Scenario suite (JSON); default is the built-in suite")
+	llmConfig := flag.String("llm-config", "", "LLM backend config file (JSON)")
+	llmBackend := flag.String("llm-backend", "", "LLM backend: ollama or openai (overrides config file)")
+	llmURL := flag.String("llm-url", "", "LLM API URL (overrides config file)")
+	model := flag.String("model", "", "LLM model to use (overrides config file)")
+	profilePath := flag.String("profile", "", "Personality profile (JSON) with traits and prompt version")
+	promptDir := flag.String("prompts", "", "Directory of extra prompt templates (*.tmpl)")
+	replayPath := flag.String("replay", "", "Score recorded answers instead of calling a backend")
+	recordPath := flag.String("record", "", "Save the backend's raw answers here for later -replay")
+	repeats := flag.Int("repeats", 3, "Runs per scenario")
+	outPath := flag.String("out", "", "Write full results (JSON) here")
+	minValidity := flag.Float64("min-validity", 0, "Exit non-zero if validity is below this")
+	minAppropriate := flag.Float64("min-appropriate", 0, "Exit non-zero if appropriateness is below this")
+	flag.Parse()
+
+	suite, err := LoadSuite(*suitePath)
+	if err != nil {
+		fatalf("Loading suite: %v", err)
+	}
+
+	ev := &Evaluator{
+		Profile: llm.DefaultProfile(),
+		Prompts: llm.NewPromptLibrary(),
+		Repeats: *repeats,
+	}
+	if *profilePath != "" {
+		if ev.Profile, err = llm.LoadProfile(*profilePath); err != nil {
+			fatalf("Loading profile: %v", err)
+		}
+	}
+	if *promptDir != "" {
+		if err := ev.Prompts.LoadDir(*promptDir); err != nil {
+			fatalf("Loading prompts: %v", err)
+		}
+	}
+
+	backend := "replay"
+	var recording, replay *Recording
+	if *replayPath != "" {
+		rec, err := LoadRecording(*replayPath)
+		if err != nil {
+			fatalf("Loading recording: %v", err)
+		}
+		replay = rec
+		ev.GeneratorFor = func(scenario string) llm.Generator {
+			return &replayGenerator{rec: rec, scenario: scenario}
+		}
+	} else {
+		cfg, err := llm.ResolveConfig(*llmConfig, llm.Config{Backend: *llmBackend, BaseURL: *llmURL, Model: *model})
+		if err != nil {
+			fatalf("Invalid LLM config: %v", err)
+		}
+		gen, err := llm.New(cfg)
+		if err != nil {
+			fatalf("Invalid LLM config: %v", err)
+		}
+		backend = cfg.Backend
+		ev.Timeout = cfg.Timeout
+		ev.GeneratorFor = func(string) llm.Generator { return gen }
+
+		if *recordPath != "" {
+			recording = &Recording{Model: gen.Model(), Answers: make(map[string][]string)}
+			ev.GeneratorFor = func(scenario string) llm.Generator {
+				return &recordingGenerator{Generator: gen, rec: recording, scenario: scenario}
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := ev.Run(ctx, suite)
+	if err != nil {
+		fatalf("Evaluation failed: %v", err)
+	}
+	result.Backend = backend
+	printResult(result)
+	if replay != nil {
+		if replay.Note != "" {
+			fmt.Printf("\nRecording: %s\n", replay.Note)
+		}
+		if err := replay.CheckPrompt(result.PromptVersion, result.PromptHash); err != nil {
+			fatalf("Replaying %s: %v", *replayPath, err)
+		}
+	}
+
+	if recording != nil {
+		recording.PromptVersion = result.PromptVersion
+		recording.PromptHash = result.PromptHash
+		if err := recording.Save(*recordPath); err != nil {
+			fatalf("Saving recording: %v", err)
+		}
+		fmt.Printf("Recorded answers saved to %s\n", *recordPath)
+	}
+	if *outPath != "" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*outPath, data, 0644)
+		}
+		if err != nil {
+			fatalf("Saving results: %v", err)
+		}
+		fmt.Printf("Results saved to %s\n", *outPath)
+	}
+
+	if result.Summary.Validity < *minValidity {
+		fatalf("Validity %.2f is below %.2f", result.Summary.Validity, *minValidity)
+	}
+	if result.Summary.Appropriateness < *minAppropriate {
+		fatalf("Appropriateness %.2f is below %.2f", result.Summary.Appropriateness, *minAppropriate)
+	}
+}
+
+// printResult shows the per-scenario table and the summary.
+func printResult(r *Result) {
+	fmt.Printf("Backend: %s  Model: %s  Prompt: %s (%s)  Repeats: %d\n\n",
+		r.Backend, r.Model, r.PromptVersion, r.PromptHash, r.Repeats)
+
+	fmt.Printf("%-28s %6s %7s %6s %6s %9s\n", "scenario", "valid", "in-char", "falls", "errors", "diversity")
+	for _, s := range r.Scenarios {
+		fmt.Printf("%-28s %3d/%-2d %4d/%-2d %6d %6d %9.2f\n",
+			s.Name, s.Valid, s.Runs, s.Appropriate, s.Runs, s.Fallbacks, s.Errors, s.Diversity)
+	}
+
+	sum := r.Summary
+	fmt.Println()
+	fmt.Printf("Validity:        %5.1f%%\n", sum.Validity*100)
+	fmt.Printf("Appropriateness: %5.1f%%\n", sum.Appropriateness*100)
+	fmt.Printf("Fallback rate:   %5.1f%% of fields\n", sum.FallbackRate*100)
+	fmt.Printf("Error rate:      %5.1f%%\n", sum.ErrorRate*100)
+	fmt.Printf("Diversity:       %5.2f\n", sum.Diversity)
+	fmt.Printf("Latency to act:  p50 %.0fms  p95 %.0fms\n", sum.ToActionP50Ms, sum.ToActionP95Ms)
+	fmt.Printf("Latency total:   p50 %.0fms  p95 %.0fms\n", sum.TotalP50Ms, sum.TotalP95Ms)
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,109 @@
+// Command facedb manages the encryption of Koji's face database. Stop
+// anything using the database first: it holds the key in memory and would
+// write with the old one.
+//
+// Create a key:
+//
+//	go run ./cmd/facedb keygen -out data/face.key
+//
+// Encrypt an existing plaintext database (no old key):
+//
+//	go run ./cmd/facedb rotate-key -db data/faces.json -new-key-file data/face.key
+//
+// Rotate to a new key:
+//
+//	go run ./cmd/facedb rotate-key -db data/faces.json -key-file data/face.key -new-key-file data/face2.key
+//
+// Without -key-file the old key is read from KOJI_FACE_KEY, if set. The
+// stranger memory (-strangers) is sealed with the same key and rotates too.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/alex/koji/internal/vision"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "keygen":
+		keygen(os.Args[2:])
+	case "rotate-key":
+		rotateKey(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func keygen(args []string) {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := flags.String("out", "", "Write the key here (mode 0600) instead of printing it")
+	flags.Parse(args)
+
+	key, err := vision.GenerateKey()
+	if err != nil {
+		fatalf("Generating key: %v", err)
+	}
+	if *out == "" {
+		fmt.Println(key)
+		return
+	}
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		fatalf("Writing key: %v", err) // never overwrite a key that may still be needed
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, key); err != nil {
+		fatalf("Writing key: %v", err)
+	}
+	fmt.Printf("Wrote a new key to %s\n", *out)
+}
+
+func rotateKey(args []string) {
+	flags := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	dbPath := flags.String("db", "data/faces.json", "Face database")
+	strangersPath := flags.String("strangers", "data/strangers.json", "Stranger memory, sealed with the same key (empty = none)")
+	keyFile := flags.String("key-file", "", "Current key (default: $"+vision.KeyEnv+", or none for plaintext)")
+	newKeyFile := flags.String("new-key-file", "", "New key; empty decrypts the database")
+	flags.Parse(args)
+
+	oldKey, err := vision.LoadKey(*keyFile)
+	if err != nil {
+		fatalf("Loading current key: %v", err)
+	}
+	var newKey []byte
+	if *newKeyFile != "" {
+		if newKey, err = vision.LoadKey(*newKeyFile); err != nil {
+			fatalf("Loading new key: %v", err)
+		}
+	}
+
+	if err := vision.RotateKey(*dbPath, *strangersPath, oldKey, newKey); err != nil {
+		fatalf("Rotating key: %v", err)
+	}
+	switch {
+	case newKey == nil:
+		fmt.Printf("Decrypted %s\n", *dbPath)
+	case oldKey == nil:
+		fmt.Printf("Encrypted %s\n", *dbPath)
+	default:
+		fmt.Printf("Re-encrypted %s with the new key\n", *dbPath)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: facedb keygen [-out file]")
+	fmt.Fprintln(os.Stderr, "       facedb rotate-key [-db file] [-strangers file] [-key-file file] [-new-key-file file]")
+	os.Exit(2)
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alex/koji/internal/llm"
+	"github.com/alex/koji/internal/personality"
+)
+
+// decider runs LLM decisions in the background so the main loop never waits
+// on them. Every event bumps the situation; a result only applies if no
+// newer situation has started since it was requested.
+//
+// All methods except the request goroutines run on the main loop, so the
+// decider needs no locking of its own.
+type decider struct {
+	engine   llm.ActionSelector
+	timeout  time.Duration
+	results  chan decision
+	seq      uint64   // bumped by every event
+	inflight *pending // nil when no request is running
+}
+
+// pending is one in-flight LLM request.
+type pending struct {
+	key    string // situation the request was made for, for coalescing
+	mood   personality.Mood
+	seq    uint64 // latest situation this request answers for
+	cancel context.CancelFunc
+
+	acted bool // its action has been performed
+}
+
+// decision is a result posted back to the main loop. The LLM's action may
+// arrive twice: first as soon as it is committed, then with the full answer.
+type decision struct {
+	req   *pending
+	resp  *llm.ActionResponse // nil if the request failed
+	err   error
+	final bool
+}
+
+func newDecider(engine llm.ActionSelector, timeout time.Duration) *decider {
+	return &decider{
+		engine:  engine,
+		timeout: timeout,
+		results: make(chan decision, 8),
+	}
+}
+
+// decisionKey identifies a situation for coalescing: the same event while
+// in the same mood at the same intensity is the same question.
+func decisionKey(state *personality.EmotionalState, event personality.EventContext) string {
+	return fmt.Sprintf("%s/%.1f/%s/%.1f", state.CurrentMood, state.Intensity, event.Event, event.Intensity)
+}
+
+// Request starts a background decision for a new event, cancelling any
+// request for an older situation. If the same situation is already being
+// decided, that request is kept and answers for this event too.
+// It reports whether the request was coalesced.
+func (d *decider) Request(req llm.ActionRequest) bool {
+	d.seq++
+	key := decisionKey(req.EmotionalState, req.Event)
+	if d.inflight != nil {
+		if d.inflight.key == key {
+			d.inflight.seq = d.seq
+			return true
+		}
+		d.inflight.cancel()
+	}
+
+	// The main loop keeps changing these while the LLM is thinking
+	state := *req.EmotionalState
+	req.EmotionalState = &state
+	req.RecentEvents = append([]personality.Event(nil), req.RecentEvents...)
+	req.RecentActions = append([]string(nil), req.RecentActions...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	p := &pending{key: key, mood: state.CurrentMood, seq: d.seq, cancel: cancel}
+	d.inflight = p
+
+	req.OnAction = func(resp llm.ActionResponse) {
+		d.results <- decision{req: p, resp: &resp}
+	}
+	go func() {
+		defer cancel()
+		resp, err := d.engine.SelectAction(ctx, req)
+		d.results <- decision{req: p, resp: resp, err: err, final: true}
+	}()
+	return false
+}
+
+// Cancel drops the in-flight request, if any, e.g. when the LLM is turned off.
+func (d *decider) Cancel() {
+	d.seq++
+	if d.inflight != nil {
+		d.inflight.cancel()
+		d.inflight = nil
+	}
+}
+
+// Current reports whether dec still answers the present situation: no newer
+// event has arrived and the mood hasn't moved on (e.g. by decay).
+func (d *decider) Current(dec decision, mood personality.Mood) bool {
+	return dec.req.seq == d.seq && dec.req.mood == mood
+}
+
+// Done marks dec's request as finished once its final result is handled.
+func (d *decider) Done(dec decision) {
+	if dec.final && d.inflight == dec.req {
+		d.inflight = nil
+	}
+}
+
+// Busy reports whether a request is in flight.
+func (d *decider) Busy() bool {
+	return d.inflight != nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alex/koji/internal/llm"
+	"github.com/alex/koji/internal/personality"
+)
+
+// slowSelector answers with its action after release is closed, or fails
+// when the request is cancelled first.
+type slowSelector struct {
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (s *slowSelector) SelectAction(ctx context.Context, req llm.ActionRequest) (*llm.ActionResponse, error) {
+	s.calls.Add(1)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.release:
+	}
+	resp := llm.ActionResponse{Action: string(req.Event.Event), Movement: personality.ActionApproach}
+	if req.OnAction != nil {
+		req.OnAction(resp)
+	}
+	return &resp, nil
+}
+
+func (s *slowSelector) SelectActionWithFallback(ctx context.Context, req llm.ActionRequest) llm.ActionResponse {
+	resp, _ := s.SelectAction(ctx, req)
+	return *resp
+}
+
+func eventRequest(state *personality.EmotionalState, event personality.Event) llm.ActionRequest {
+	return llm.ActionRequest{EmotionalState: state, Event: personality.NewEventContext(event)}
+}
+
+func nextDecision(t *testing.T, d *decider) decision {
+	t.Helper()
+	select {
+	case dec := <-d.results:
+		return dec
+	case <-time.After(2 * time.Second):
+		t.Fatal("no decision arrived")
+		return decision{}
+	}
+}
+
+func TestDecider_NewerEventCancelsOlder(t *testing.T) {
+	sel := &slowSelector{release: make(chan struct{})}
+	d := newDecider(sel, time.Minute)
+	state := personality.NewEmotionalState()
+
+	d.Request(eventRequest(state, personality.EventMotionDetected))
+	if coalesced := d.Request(eventRequest(state, personality.EventUnknownObject)); coalesced {
+		t.Fatal("different event was coalesced")
+	}
+
+	// The first request is cancelled and its failure is stale
+	dec := nextDecision(t, d)
+	if dec.err == nil || !dec.final {
+		t.Fatalf("first decision = %+v, want a cancelled final result", dec)
+	}
+	if d.Current(dec, state.CurrentMood) {
+		t.Error("cancelled request still counts as current")
+	}
+	d.Done(dec)
+	if !d.Busy() {
+		t.Error("finishing the old request cleared the newer one")
+	}
+
+	close(sel.release)
+	dec = nextDecision(t, d) // early commit
+	if dec.final || !d.Current(dec, state.CurrentMood) || dec.resp.Action != string(personality.EventUnknownObject) {
+		t.Errorf("early commit = %+v, want current answer for unknown_object", dec)
+	}
+	dec = nextDecision(t, d)
+	if !dec.final || dec.err != nil {
+		t.Errorf("final = %+v, want success", dec)
+	}
+	d.Done(dec)
+	if d.Busy() {
+		t.Error("still busy after the final result")
+	}
+}
+
+func TestDecider_CoalescesDuplicates(t *testing.T) {
+	sel := &slowSelector{release: make(chan struct{})}
+	d := newDecider(sel, time.Minute)
+	state := personality.NewEmotionalState()
+
+	d.Request(eventRequest(state, personality.EventMusic))
+	if coalesced := d.Request(eventRequest(state, personality.EventMusic)); !coalesced {
+		t.Fatal("same situation wasn't coalesced")
+	}
+	close(sel.release)
+
+	dec := nextDecision(t, d)
+	if !d.Current(dec, state.CurrentMood) {
+		t.Error("coalesced request doesn't answer the latest event")
+	}
+	nextDecision(t, d)
+	if n := sel.calls.Load(); n != 1 {
+		t.Errorf("LLM called %d times, want 1", n)
+	}
+}
+
+func TestDecider_MoodChangeMakesResultStale(t *testing.T) {
+	sel := &slowSelector{release: make(chan struct{})}
+	close(sel.release)
+	d := newDecider(sel, time.Minute)
+	state := personality.NewEmotionalState()
+
+	d.Request(eventRequest(state, personality.EventSpeech))
+	state.SetMood(personality.MoodSleepy, personality.IntensityLow) // decay meanwhile
+
+	dec := nextDecision(t, d)
+	if d.Current(dec, state.CurrentMood) {
+		t.Error("result still current after the mood changed")
+	}
+}
@@ -7,22 +7,36 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/alex/koji/internal/api"
 	"github.com/alex/koji/internal/llm"
+	"github.com/alex/koji/internal/memory"
 	"github.com/alex/koji/internal/personality"
 )
 
 type app struct {
-	state        *personality.EmotionalState
-	variation    *personality.VariationEngine
-	llmClient    *llm.Client
-	engine       *llm.PersonalityEngine
-	apiServer    *api.Server
-	recentEvents []personality.Event
-	useLLM       bool
-	lastAction   string
+	state         *personality.EmotionalState
+	variation     *personality.VariationEngine
+	generator     llm.Generator
+	engine        llm.ActionSelector
+	router        *llm.Router        // nil unless escalation is configured
+	cache         *llm.DecisionCache // nil if the cache couldn't be loaded
+	breaker       *llm.Breaker       // skips the LLM while its backend is down
+	decider       *decider           // runs LLM decisions off the main loop
+	memory        *memory.Store      // nil if episodes couldn't be loaded
+	summarizer    memory.Summarizer  // LLM summaries for old episodes, nil for basic ones
+	compressing   atomic.Bool        // an episode compression is running
+	lastEventAt   time.Time
+	apiServer     *api.Server
+	recentEvents  []personality.Event
+	recentActions []string // LLM-chosen actions, for prompt history
+	useLLM        bool
+	lastAction    string
+	lastModifier  personality.ActionModifier
+	lookHint      *personality.LookHint // where the last event said to look
+	lookHintAt    time.Time
 }
 
 // GetState implements api.StateProvider
@@ -35,10 +49,41 @@ func (a *app) GetRecentAction() string {
 	return a.lastAction
 }
 
+// GetRecentModifier implements api.StateProvider
+func (a *app) GetRecentModifier() personality.ActionModifier {
+	return a.lastModifier
+}
+
+// GetLookHint implements api.StateProvider
+func (a *app) GetLookHint() *personality.LookHint {
+	if time.Since(a.lookHintAt) > personality.LookHintDuration {
+		return nil
+	}
+	return a.lookHint
+}
+
+// LLMStatus implements api.LLMStatusProvider
+func (a *app) LLMStatus() any {
+	if a.breaker == nil {
+		return llm.BreakerStatus{State: "disabled"}
+	}
+	return a.breaker.Status()
+}
+
 func main() {
 	// Flags
-	ollamaURL := flag.String("ollama", "http://localhost:11434", "Ollama API URL")
-	model := flag.String("model", "phi3:mini", "LLM model to use")
+	llmConfig := flag.String("llm-config", "", "LLM backend config file (JSON)")
+	llmBackend := flag.String("llm-backend", "", "LLM backend: ollama or openai (overrides config file)")
+	llmURL := flag.String("llm-url", "", "LLM API URL (overrides config file)")
+	model := flag.String("model", "", "LLM model to use (overrides config file)")
+	profilePath := flag.String("profile", "", "Personality profile (JSON) with traits and prompt version")
+	promptDir := flag.String("prompts", "", "Directory of extra prompt templates (*.tmpl)")
+	decisionLogPath := flag.String("decision-log", "data/decisions.jsonl", "Where to append LLM decisions (empty = off)")
+	cachePath := flag.String("llm-cache", "data/llm_cache.json", "Where to persist cached LLM decisions (empty = memory only)")
+	escalateConfig := flag.String("llm-escalate", "", "Config file (JSON) for a larger LLM to escalate novel situations to")
+	escalateBudget := flag.Int("llm-budget", 200, "Max escalations to the larger LLM per day")
+	escalateFilter := flag.String("llm-filter", "heuristic", "Escalation filter: heuristic or model (asks the local LLM)")
+	memoryPath := flag.String("memory", "data/episodes.json", "Where to persist episodic memory (empty = memory only)")
 	noLLM := flag.Bool("no-llm", false, "Disable LLM, use only deterministic actions")
 	apiAddr := flag.String("api", ":8080", "API server address for external displays")
 	flag.Parse()
@@ -48,6 +93,13 @@ func main() {
 		variation:    personality.NewVariationEngine(),
 		recentEvents: make([]personality.Event, 0, 10),
 		useLLM:       !*noLLM,
+		lastEventAt:  time.Now(),
+	}
+
+	if store, err := memory.NewStore(*memoryPath, memory.DefaultConfig()); err != nil {
+		fmt.Printf("Warning: Episodic memory disabled: %v\n", err)
+	} else {
+		app.memory = store
 	}
 
 	fmt.Println("=== Koji Emotional State Simulator ===")
@@ -65,48 +117,88 @@ func main() {
 
 	// Try to connect to LLM if enabled
 	if app.useLLM {
-		app.llmClient = llm.NewClient(llm.Config{
-			BaseURL: *ollamaURL,
-			Model:   *model,
-			Timeout: 30 * time.Second,
-		})
+		cfg, err := llm.ResolveConfig(*llmConfig, llm.Config{Backend: *llmBackend, BaseURL: *llmURL, Model: *model})
+		if err == nil {
+			app.generator, err = llm.New(cfg)
+		}
+		if err != nil {
+			fmt.Printf("Warning: Invalid LLM config: %v\n", err)
+			fmt.Println("Running in deterministic mode (no LLM).")
+			fmt.Println()
+			app.useLLM = false
+		}
+	}
+
+	if app.useLLM {
+		// A backend that's down now may come up later; the breaker keeps probing for it
+		app.breaker = llm.NewBreaker(app.generator, llm.DefaultBreakerConfig())
+		defer app.breaker.Close()
+		app.generator = app.breaker
+		app.apiServer.SetLLMStatus(app)
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		err := app.llmClient.Ping(ctx)
+		health, err := app.generator.Health(ctx)
 		cancel()
 
 		if err != nil {
-			fmt.Printf("Warning: Cannot connect to Ollama at %s: %v\n", *ollamaURL, err)
-			fmt.Println("Running in deterministic mode (no LLM).")
-			fmt.Println("Start Ollama with: ollama serve")
-			fmt.Println()
-			app.useLLM = false
-		} else {
-			// Check if the model is available
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			found, available, err := app.llmClient.CheckModel(ctx)
-			cancel()
-
-			if err != nil {
-				fmt.Printf("Warning: Could not check models: %v\n", err)
-				fmt.Println("Continuing anyway...")
-			} else if !found {
-				fmt.Printf("Warning: Model '%s' not found.\n", *model)
-				fmt.Printf("Available models: %v\n", available)
-				fmt.Printf("Install with: ollama pull %s\n", *model)
-				fmt.Println()
-				fmt.Println("Running with variation engine (weighted random + mood echoes)")
-				fmt.Println()
-				app.useLLM = false
+			fmt.Printf("Warning: Cannot connect to %s backend: %v\n", health.Backend, err)
+			if health.Backend == llm.BackendOllama {
+				fmt.Println("Start Ollama with: ollama serve")
+			}
+			app.breaker.Trip(err)
+		} else if !health.ModelAvailable {
+			fmt.Printf("Warning: Model '%s' not found.\n", health.Model)
+			fmt.Printf("Available models: %v\n", health.Models)
+			if health.Backend == llm.BackendOllama {
+				fmt.Printf("Install with: ollama pull %s\n", health.Model)
 			}
+			app.breaker.Trip(fmt.Errorf("model %q not available", health.Model))
+		}
+		if !app.breaker.Allow() {
+			fmt.Println("Using the variation engine until the LLM becomes available.")
+			fmt.Println()
+		}
 
-			if app.useLLM {
-				app.engine = llm.NewPersonalityEngine(app.llmClient)
-				fmt.Printf("Connected to Ollama (model: %s)\n", *model)
-				fmt.Println("LLM will select actions based on personality.")
-				fmt.Println()
+		var decisionLog *llm.DecisionLog
+		if *decisionLogPath != "" {
+			if log, err := llm.OpenDecisionLog(*decisionLogPath); err != nil {
+				fmt.Printf("Warning: Decision log disabled: %v\n", err)
+			} else {
+				defer log.Close()
+				decisionLog = log
+			}
+		}
+		// The local and escalation engines share one profile, prompt library
+		// and decision log, so an escalated answer speaks in the same voice.
+		newEngine := func(gen llm.Generator) *llm.PersonalityEngine {
+			engine := llm.NewPersonalityEngine(gen)
+			if err := setupPrompt(engine, *profilePath, *promptDir); err != nil {
+				fmt.Printf("Warning: %v (using default profile)\n", err)
 			}
+			if decisionLog != nil {
+				engine.SetDecisionLog(decisionLog)
+			}
+			return engine
+		}
+
+		local := newEngine(app.generator)
+		if cache, err := llm.NewDecisionCache(*cachePath, llm.DefaultCacheConfig()); err != nil {
+			fmt.Printf("Warning: Decision cache disabled: %v\n", err)
+		} else {
+			local.SetCache(cache)
+			app.cache = cache
 		}
+		app.engine = local
+		if app.breaker.Allow() {
+			fmt.Printf("Connected to %s backend (model: %s, prompt: %s)\n", health.Backend, health.Model, local.Prompt().Version)
+		}
+		if *escalateConfig != "" {
+			app.setupEscalation(local, newEngine, *escalateConfig, *escalateFilter, *escalateBudget)
+		}
+		app.decider = newDecider(app.engine, 30*time.Second)
+		app.summarizer = llm.NewEpisodeSummarizer(app.generator, local.Profile())
+		fmt.Println("LLM will select actions based on personality.")
+		fmt.Println()
 	} else {
 		fmt.Println("Running with variation engine (weighted random + mood echoes)")
 		fmt.Println()
@@ -123,10 +215,20 @@ func main() {
 	microTicker := time.NewTicker(3 * time.Second)
 	defer microTicker.Stop()
 
+	// Memory housekeeping during quiet periods
+	memoryTicker := time.NewTicker(time.Minute)
+	defer memoryTicker.Stop()
+
 	// Channel for user input
 	inputChan := make(chan string)
 	go readInput(inputChan)
 
+	// LLM results arrive here; nil (never ready) without an LLM
+	var decisions chan decision
+	if app.decider != nil {
+		decisions = app.decider.results
+	}
+
 	for {
 		select {
 		case <-decayTicker.C:
@@ -137,6 +239,9 @@ func main() {
 				fmt.Print("> ")
 			}
 
+		case <-memoryTicker.C:
+			app.maybeCompressMemory()
+
 		case <-microTicker.C:
 			// Occasional idle micro-behaviors make Koji feel alive
 			if !app.useLLM {
@@ -147,6 +252,9 @@ func main() {
 				}
 			}
 
+		case dec := <-decisions:
+			app.handleDecision(dec)
+
 		case input := <-inputChan:
 			if input == "" {
 				continue
@@ -162,6 +270,12 @@ func (a *app) handleInput(input string) {
 	switch input {
 	case "quit", "exit", "q":
 		fmt.Println("Bye!")
+		if a.memory != nil {
+			a.memory.Close() // write the last batch of episodes
+		}
+		if a.cache != nil {
+			a.cache.Close() // write the last batch of decisions
+		}
 		os.Exit(0)
 	case "help", "?":
 		a.printHelp()
@@ -200,6 +314,14 @@ func (a *app) handleInput(input string) {
 
 	oldMood := a.state.CurrentMood
 	changed := a.state.ProcessEvent(ctx)
+	a.lastEventAt = time.Now()
+	if look := personality.LookHintFromMetadata(ctx.Metadata); look != nil {
+		a.lookHint = look
+		a.lookHintAt = a.lastEventAt
+	}
+	if a.memory != nil {
+		a.memory.Record(ctx, a.state)
+	}
 
 	if changed {
 		fmt.Printf("\n[event] %s: %s -> %s\n", event, oldMood, a.state.CurrentMood)
@@ -215,40 +337,47 @@ func (a *app) handleInput(input string) {
 	a.selectAndPrintAction(ctx)
 }
 
+// selectAndPrintAction reacts to an event straight away with the variation
+// engine, then asks the LLM in the background for a considered action.
 func (a *app) selectAndPrintAction(eventCtx personality.EventContext) {
-	if a.useLLM && a.engine != nil {
-		fmt.Println("  [LLM thinking...]")
+	// Reflex: always instant
+	action := a.variation.SelectAction(a.state)
+	if reaction, ok := a.state.SuggestReaction(eventCtx.Event); ok {
+		action.Action = reaction.Primary() // e.g. nuzzle a sad owner rather than wag at them
+	}
+	fmt.Printf("  Koji chooses: %s (%s)\n", action.Action, action.Modifier)
+	a.lastAction = string(action.Action)
+	a.lastModifier = action.Modifier
+	a.apiServer.SetLastAction(string(action.Action))
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+	// Show any active mood echoes affecting behavior
+	echoes := a.variation.GetActiveEchoes()
+	if len(echoes) > 0 {
+		for _, echo := range echoes {
+			fmt.Printf("  [echo] still affected by %s (%.0f%% strength)\n",
+				echo.FromMood, echo.Strength*100)
+		}
+	}
 
-		req := llm.ActionRequest{
+	if a.useLLM && a.decider != nil && !a.breaker.Allow() {
+		fmt.Println("  [LLM unavailable, reflex only]")
+	} else if a.useLLM && a.decider != nil {
+		memories, memoryKey := a.recall(eventCtx)
+		coalesced := a.decider.Request(llm.ActionRequest{
 			EmotionalState: a.state,
 			Event:          eventCtx,
 			RecentEvents:   a.recentEvents,
+			RecentActions:  a.recentActions,
+			Echoes:         echoes,
+			Memories:       memories,
+			MemoryKey:      memoryKey,
+		})
+		if coalesced {
+			fmt.Println("  [LLM already thinking about this]")
+		} else {
+			fmt.Println("  [LLM thinking...]")
 		}
-
-		resp := a.engine.SelectActionWithFallback(ctx, req)
-		fmt.Printf("  Koji chooses: %s\n", resp.Action)
-		fmt.Printf("  Reason: %s\n", resp.Reason)
-		a.lastAction = resp.Action
-		a.apiServer.SetLastAction(resp.Action)
 	} else {
-		// Use variation engine for lifelike behavior
-		action := a.variation.SelectAction(a.state)
-		fmt.Printf("  Koji chooses: %s (%s)\n", action.Action, action.Modifier)
-		a.lastAction = string(action.Action)
-		a.apiServer.SetLastAction(string(action.Action))
-
-		// Show any active mood echoes affecting behavior
-		echoes := a.variation.GetActiveEchoes()
-		if len(echoes) > 0 {
-			for _, echo := range echoes {
-				fmt.Printf("  [echo] still affected by %s (%.0f%% strength)\n",
-					echo.FromMood, echo.Strength*100)
-			}
-		}
-
 		// Maybe do a micro-behavior too
 		micro := a.variation.SelectMicroBehavior(a.state.CurrentMood)
 		if micro != nil {
@@ -258,13 +387,164 @@ func (a *app) selectAndPrintAction(eventCtx personality.EventContext) {
 	fmt.Println()
 }
 
+// handleDecision applies an LLM result from the background, if the
+// situation it was asked about is still the current one.
+func (a *app) handleDecision(dec decision) {
+	defer a.decider.Done(dec)
+	if !a.decider.Current(dec, a.state.CurrentMood) {
+		// A newer event or a mood change made this answer stale
+		return
+	}
+
+	if dec.err != nil {
+		if dec.final && !dec.req.acted {
+			fmt.Printf("\n  [LLM] no answer, keeping reflex: %v\n", dec.err)
+			fmt.Print("> ")
+		}
+		return
+	}
+
+	if !dec.req.acted {
+		dec.req.acted = true
+		fmt.Println()
+		a.performLLMAction(*dec.resp)
+	}
+	if !dec.final {
+		return
+	}
+
+	resp := dec.resp
+	fmt.Printf("  Reason: %s\n", resp.Reason)
+	if resp.Timing.Cached {
+		fmt.Println("  [cached]")
+	} else if resp.Timing.ToAction > 0 {
+		fmt.Printf("  [latency] action %dms, total %dms\n",
+			resp.Timing.ToAction.Milliseconds(), resp.Timing.Total.Milliseconds())
+	}
+	fmt.Print("> ")
+}
+
+// recall fetches the past episodes relevant to an event for the prompt, and
+// the key that names them.
+func (a *app) recall(eventCtx personality.EventContext) ([]string, string) {
+	if a.memory == nil {
+		return nil, ""
+	}
+	return a.memory.Recall(memory.Query{
+		Person: memory.PersonLabel(eventCtx),
+		Event:  eventCtx.Event,
+		Mood:   a.state.CurrentMood,
+	})
+}
+
+// maybeCompressMemory summarizes old episodes while Koji is sleepy or has
+// been left alone, so the LLM isn't needed for anything else.
+func (a *app) maybeCompressMemory() {
+	if a.memory == nil {
+		return
+	}
+	idle := a.state.CurrentMood == personality.MoodSleepy || time.Since(a.lastEventAt) > 5*time.Minute
+	if !idle || (a.decider != nil && a.decider.Busy()) || !a.compressing.CompareAndSwap(false, true) {
+		return
+	}
+
+	var summarizer memory.Summarizer
+	if a.useLLM {
+		summarizer = a.summarizer
+	}
+	go func() {
+		defer a.compressing.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		// Episodes that fail stay as they are and are retried next quiet period
+		a.memory.Compress(ctx, summarizer)
+	}()
+}
+
+// setupPrompt applies a personality profile and any extra prompt templates.
+func setupPrompt(engine *llm.PersonalityEngine, profilePath, promptDir string) error {
+	if profilePath == "" && promptDir == "" {
+		return nil
+	}
+
+	profile := llm.DefaultProfile()
+	if profilePath != "" {
+		var err error
+		if profile, err = llm.LoadProfile(profilePath); err != nil {
+			return fmt.Errorf("loading profile: %w", err)
+		}
+	}
+
+	prompts := llm.NewPromptLibrary()
+	if promptDir != "" {
+		if err := prompts.LoadDir(promptDir); err != nil {
+			return fmt.Errorf("loading prompts: %w", err)
+		}
+	}
+	return engine.SetProfile(profile, prompts)
+}
+
+// setupEscalation routes novel situations to a second, larger backend.
+// newEngine builds the remote engine with the same prompts and decision log
+// as the local one. Failures are reported and leave the local engine in charge.
+func (a *app) setupEscalation(local *llm.PersonalityEngine, newEngine func(llm.Generator) *llm.PersonalityEngine, path, filterName string, budget int) {
+	cfg, err := llm.LoadConfig(path)
+	if err != nil {
+		fmt.Printf("Warning: Cannot load escalation config: %v\n", err)
+		return
+	}
+	remote, err := llm.New(cfg)
+	if err != nil {
+		fmt.Printf("Warning: Invalid escalation config: %v\n", err)
+		return
+	}
+	remote = llm.NewBreaker(remote, llm.DefaultBreakerConfig())
+
+	var filter llm.NoveltyFilter
+	switch filterName {
+	case "model":
+		filter = llm.NewModelFilter(a.generator)
+	case "heuristic":
+		filter = llm.NewHeuristicFilter()
+	default:
+		fmt.Printf("Warning: Unknown escalation filter %q, using heuristic\n", filterName)
+		filter = llm.NewHeuristicFilter()
+	}
+
+	routerCfg := llm.DefaultRouterConfig()
+	routerCfg.DailyBudget = budget
+	a.router = llm.NewRouter(local, newEngine(remote), filter, routerCfg)
+	a.engine = a.router
+	fmt.Printf("Escalating novel situations to %s (model: %s, budget %d/day)\n", cfg.Backend, remote.Model(), budget)
+}
+
+// performLLMAction shows and publishes an action set chosen by the LLM,
+// replacing the reflex action.
+func (a *app) performLLMAction(resp llm.ActionResponse) {
+	fmt.Printf("  [LLM] Koji refines: %s + %s + %s (%s)\n", resp.Movement, resp.Expression, resp.Sound, resp.Modifier)
+	if len(resp.Fallbacks) > 0 {
+		fmt.Printf("  [fallback] mood defaults used for: %s\n", strings.Join(resp.Fallbacks, ", "))
+	}
+	a.lastAction = resp.Action
+	a.lastModifier = resp.Modifier
+	a.apiServer.SetLastAction(resp.Action)
+
+	a.recentActions = append(a.recentActions, resp.Action)
+	if len(a.recentActions) > 5 {
+		a.recentActions = a.recentActions[1:]
+	}
+}
+
 func (a *app) toggleLLM() {
-	if a.llmClient == nil {
-		fmt.Println("LLM not configured. Restart with Ollama running.")
+	if a.engine == nil {
+		fmt.Println("LLM not configured. Restart with an LLM backend running.")
 		return
 	}
 
 	a.useLLM = !a.useLLM
+	if !a.useLLM {
+		a.decider.Cancel()
+	}
 	if a.useLLM {
 		fmt.Println("LLM enabled")
 	} else {
@@ -294,12 +574,51 @@ func (a *app) printState() {
 
 	if a.useLLM {
 		fmt.Printf("  Mode:      LLM\n")
+		if a.breaker != nil {
+			a.printBreaker()
+		}
+		if a.decider != nil && a.decider.Busy() {
+			fmt.Printf("  Pending:   LLM decision in progress\n")
+		}
+		if a.cache != nil {
+			stats := a.cache.Stats()
+			fmt.Printf("  Cache:     %d hits, %d misses, %d refreshes (%.0f%% hit rate, %d situations)\n",
+				stats.Hits, stats.Misses, stats.Refreshes, stats.HitRate*100, stats.Keys)
+		}
+		if a.router != nil {
+			stats := a.router.Stats()
+			fmt.Printf("  Escalated: %d/%d (%.1f%%, target <%.0f%%), budget left %d\n",
+				stats.Escalations, stats.Decisions, stats.EscalationRate*100, stats.TargetRate*100, stats.BudgetRemaining)
+		}
 	} else {
 		fmt.Printf("  Mode:      variation engine\n")
 	}
+	if a.memory != nil {
+		episodes := a.memory.Episodes()
+		summarized := 0
+		for _, ep := range episodes {
+			if ep.Compressed() {
+				summarized++
+			}
+		}
+		fmt.Printf("  Memory:    %d episodes (%d summarized)\n", len(episodes), summarized)
+	}
 	fmt.Println()
 }
 
+// printBreaker shows whether the LLM backend is being used or skipped.
+func (a *app) printBreaker() {
+	status := a.breaker.Status()
+	if status.State == llm.BreakerClosed {
+		fmt.Printf("  Backend:   up (%d recent failures, %d outages)\n", status.Failures, status.Trips)
+		return
+	}
+	fmt.Printf("  Backend:   DOWN for %s, next check in %s (%s)\n",
+		time.Since(status.OpenedAt).Round(time.Second),
+		time.Until(status.NextProbe).Round(time.Second),
+		status.LastError)
+}
+
 func (a *app) printActions() {
 	actions := a.state.AvailableActions()
 	defaultAction := a.state.SuggestDefaultAction()
@@ -317,6 +636,12 @@ func (a *app) printHelp() {
 	fmt.Println("  rhythm, beat         - beat detected")
 	fmt.Println("  face, familiar, owner - familiar face")
 	fmt.Println("  stranger, unknown    - unknown face")
+	fmt.Println("  unsure, maybe        - a face Koji can't place yet")
+	fmt.Println("  sad, crying          - someone Koji knows looks sad")
+	fmt.Println("  smile, laugh         - someone Koji knows looks happy")
+	fmt.Println("  angry, mad           - someone Koji knows looks angry")
+	fmt.Println("  surprised, gasp      - someone Koji knows looks surprised")
+	fmt.Println("  crowd, people, party - lots of faces")
 	fmt.Println("  motion, movement     - motion detected")
 	fmt.Println("  object, thing, new   - unknown object spotted")
 	fmt.Println("  pet, petted          - being petted")
@@ -363,8 +688,20 @@ func parseEvent(input string) personality.Event {
 		return personality.EventMusic
 	case contains(input, "rhythm", "beat", "bop"):
 		return personality.EventRhythm
+	case contains(input, "crowd", "people", "party"):
+		return personality.EventCrowd
+	case contains(input, "sad", "crying"):
+		return personality.EventPersonSad
+	case contains(input, "smile", "laugh"):
+		return personality.EventPersonHappy
+	case contains(input, "angry", "mad"):
+		return personality.EventPersonAngry
+	case contains(input, "surprised", "gasp"):
+		return personality.EventPersonSurprised
 	case contains(input, "familiar", "owner", "friend"):
 		return personality.EventFamiliarFace
+	case contains(input, "unsure", "maybe", "uncertain"):
+		return personality.EventUncertainFace
 	case contains(input, "stranger", "unknown face", "who"):
 		return personality.EventUnknownFace
 	case contains(input, "face"): // generic face = familiar
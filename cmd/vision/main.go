@@ -0,0 +1,107 @@
+// Vision server - runs face enrollment, tracking and recognition, and sends
+// what Koji sees to the brain as events. The models run in a sidecar that
+// speaks the wire format documented on vision.RemoteDetector.
+//
+//	go run ./cmd/vision -detector http://localhost:5000 -brain http://localhost:8080
+//
+// With -detector fake, frames must be synthetic test images (see
+// vision.SyntheticImage), which is enough to try the web UI and API.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alex/koji/internal/vision"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "Web UI and API address")
+	detectorURL := flag.String("detector", "http://localhost:5000", "Detector sidecar URL, or \"fake\" for synthetic images")
+	embeddingDim := flag.Int("embedding-dim", 0, "Reject embeddings of any other length (0 = any)")
+	dbPath := flag.String("db", "data/faces.json", "Face database")
+	keyFile := flag.String("key-file", "", "Key to encrypt the face database (default: $"+vision.KeyEnv+", or plaintext)")
+	thresholdsPath := flag.String("thresholds", "", "Recognition thresholds from cmd/calibrate (JSON); default is the built-in ones")
+	strangersPath := flag.String("strangers", "data/strangers.json", "Where to remember unknown faces (empty = off)")
+	visitsOnly := flag.Bool("visits-only", false, "Promote repeat strangers on visits alone, without waiting to be petted (run the brain with -vision otherwise)")
+	brainURL := flag.String("brain", "", "Brain API URL to send face events to (empty = off)")
+	flag.Parse()
+
+	log.Println("=== Koji Vision Server ===")
+
+	key, err := vision.LoadKey(*keyFile)
+	if err != nil {
+		log.Fatalf("Loading key: %v", err)
+	}
+	if key == nil {
+		log.Printf("Warning: no key, face embeddings are stored in plaintext")
+	}
+	db, err := vision.NewEncryptedFaceDB(*dbPath, key)
+	if errors.Is(err, vision.ErrNotEncrypted) {
+		log.Fatalf("Opening face database: %v (encrypt it first: go run ./cmd/facedb rotate-key -new-key-file <key>)", err)
+	}
+	if err != nil {
+		log.Fatalf("Opening face database: %v", err)
+	}
+	defer db.Close()
+	if *thresholdsPath != "" {
+		thresholds, err := vision.LoadThresholds(*thresholdsPath)
+		if err != nil {
+			log.Fatalf("Loading thresholds: %v", err)
+		}
+		db.SetThresholds(thresholds)
+	}
+
+	var detector vision.FaceDetector
+	if *detectorURL == "fake" {
+		detector = vision.NewFakeDetector(*embeddingDim)
+	} else {
+		remote := vision.NewRemoteDetector(vision.RemoteDetectorConfig{URL: *detectorURL, EmbeddingDim: *embeddingDim})
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if info, err := remote.Info(ctx); err != nil {
+			log.Printf("Detector not reachable yet: %v", err)
+		} else {
+			log.Printf("Detector: %s (%d-dim embeddings)", info.Model, info.EmbeddingDim)
+		}
+		cancel()
+		detector = remote
+	}
+
+	server := vision.NewServer(*addr, db, detector)
+	if token := os.Getenv("KOJI_ADMIN_TOKEN"); token != "" {
+		server.SetAdminToken(token)
+	}
+	if *strangersPath != "" {
+		strangers, err := vision.NewStrangerMemory(*strangersPath, db, vision.StrangerConfig{VisitsOnly: *visitsOnly})
+		if err != nil {
+			log.Fatalf("Loading strangers: %v", err)
+		}
+		defer strangers.Close()
+		server.SetStrangerMemory(strangers)
+	}
+	if *brainURL != "" {
+		server.SetBridge(vision.NewBridge(vision.NewHTTPEventSink(*brainURL), vision.BridgeConfig{}))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	log.Printf("Vision server ready on %s", *addr)
+	log.Println("  GET  /                - enrollment web UI")
+	log.Println("  POST /api/scene       - post a camera frame, get who's there")
+	log.Println("  POST /api/interaction - tell vision about petting etc. (brain -vision)")
+	if *brainURL != "" {
+		log.Printf("Sending face events to %s", *brainURL)
+	}
+
+	if err := server.Start(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("Server error: %v", err)
+	}
+	log.Println("Shutting down...")
+}
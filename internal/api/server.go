@@ -21,6 +21,14 @@ type EventHandler interface {
 type StateProvider interface {
 	GetState() *personality.EmotionalState
 	GetRecentAction() string
+	GetRecentModifier() personality.ActionModifier // "" = derive from mood
+	GetLookHint() *personality.LookHint            // nil once LookHintDuration has passed
+}
+
+// LLMStatusProvider reports on the LLM backend for /api/llm. The status is
+// encoded as JSON as is.
+type LLMStatusProvider interface {
+	LLMStatus() any
 }
 
 // Server provides HTTP API for external devices.
@@ -32,6 +40,7 @@ type Server struct {
 	mu           sync.RWMutex
 	lastAction   string
 	lastActionAt time.Time
+	llmStatus    LLMStatusProvider // nil when no LLM is configured
 
 	// Test mode: override emotion for testing
 	testEmotionOverride int
@@ -55,6 +64,13 @@ func (s *Server) SetLastAction(action string) {
 	s.lastActionAt = time.Now()
 }
 
+// SetLLMStatus exposes the LLM backend's status on /api/llm.
+func (s *Server) SetLLMStatus(p LLMStatusProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.llmStatus = p
+}
+
 // StateResponse is the JSON response for /api/state.
 type StateResponse struct {
 	Mood         string  `json:"mood"`
@@ -98,7 +114,9 @@ func (s *Server) Start(ctx context.Context) error {
 
 	mux.HandleFunc("/api/state", s.handleState)
 	mux.HandleFunc("/api/event", s.handleEvent)
+	mux.HandleFunc("/api/face", s.handleFace)
 	mux.HandleFunc("/api/test/emotion", s.handleTestEmotion)
+	mux.HandleFunc("/api/llm", s.handleLLM)
 	mux.HandleFunc("/health", s.handleHealth)
 
 	server := &http.Server{
@@ -223,6 +241,61 @@ func (s *Server) handleEvent(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// handleFace returns face animation cues for displays that support them.
+// Older firmware only needs emotion_index from /api/state.
+func (s *Server) handleFace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := s.provider.GetState()
+	if state == nil {
+		http.Error(w, "state not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	s.mu.RLock()
+	testOverride := s.testEmotionOverride
+	testExpiry := s.testEmotionExpiry
+	s.mu.RUnlock()
+
+	cues := state.FaceCues(s.provider.GetRecentModifier(), s.provider.GetLookHint())
+
+	// Check for test emotion override
+	if time.Now().Before(testExpiry) && testOverride >= 0 && testOverride < len(emotionNames) {
+		cues.ID = testExpiry.UnixMilli()
+		cues.EmotionIndex = testOverride
+		cues.Sequence = []personality.FaceCueStep{{
+			Emotion:      personality.FaceEmotion(emotionNames[testOverride]),
+			EmotionIndex: testOverride,
+		}}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cues)
+}
+
+// handleLLM reports whether the LLM backend is in use or being skipped.
+func (s *Server) handleLLM(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.RLock()
+	provider := s.llmStatus
+	s.mu.RUnlock()
+
+	var status any = map[string]string{"state": "disabled"}
+	if provider != nil {
+		status = provider.LLMStatus()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
 // handleHealth is a simple health check endpoint.
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,28 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "data.json")
+
+	for _, content := range []string{"first", "second"} {
+		if err := Write(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != content {
+			t.Errorf("file = %q, want %q", data, content)
+		}
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want no temp files left behind", len(entries))
+	}
+}
@@ -18,6 +18,9 @@ type Brain struct {
 	mu           sync.RWMutex
 	recentEvents []personality.Event
 	lastAction   string
+	lastModifier personality.ActionModifier
+	lookHint     *personality.LookHint // where the last event said to look
+	lookHintAt   time.Time
 	lastEventAt  time.Time // tracks when we last got external stimulus
 
 	// Configuration
@@ -68,6 +71,25 @@ func (b *Brain) GetRecentAction() string {
 	return b.lastAction
 }
 
+// GetRecentModifier returns how the most recent action was performed
+// (implements StateProvider).
+func (b *Brain) GetRecentModifier() personality.ActionModifier {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.lastModifier
+}
+
+// GetLookHint returns where the face should look, or nil if no recent event
+// said (implements StateProvider).
+func (b *Brain) GetLookHint() *personality.LookHint {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.lookHint == nil || time.Since(b.lookHintAt) > personality.LookHintDuration {
+		return nil
+	}
+	return b.lookHint
+}
+
 // HandleEvent processes an incoming event (implements EventHandler).
 func (b *Brain) HandleEvent(ctx personality.EventContext) bool {
 	b.mu.Lock()
@@ -82,6 +104,12 @@ func (b *Brain) HandleEvent(ctx personality.EventContext) bool {
 	// Update last event time (for idle behavior)
 	b.lastEventAt = time.Now()
 
+	// Remember where to look, if the sensor told us
+	if look := personality.LookHintFromMetadata(ctx.Metadata); look != nil {
+		b.lookHint = look
+		b.lookHintAt = b.lastEventAt
+	}
+
 	// Process the event through the state machine
 	changed := b.state.ProcessEvent(ctx)
 
@@ -100,6 +128,14 @@ func (b *Brain) SetAction(action string) {
 	b.lastAction = action
 }
 
+// SetModifier records how the last action was performed. Face cues use it
+// for eye transition speed.
+func (b *Brain) SetModifier(modifier personality.ActionModifier) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastModifier = modifier
+}
+
 // RecentEvents returns the recent event history.
 func (b *Brain) RecentEvents() []personality.Event {
 	b.mu.RLock()
@@ -0,0 +1,167 @@
+// Package llm provides LLM integration for Koji's personality decisions.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Backend names accepted in Config.Backend.
+const (
+	BackendOllama = "ollama"
+	BackendOpenAI = "openai" // any OpenAI-compatible server: llama.cpp, vLLM, LM Studio
+)
+
+// Capabilities describes what a backend can do beyond plain text generation.
+type Capabilities struct {
+	JSONMode     bool `json:"json_mode"`     // can force syntactically valid JSON output
+	SchemaOutput bool `json:"schema_output"` // can constrain output to a JSON schema
+	Streaming    bool `json:"streaming"`     // can stream tokens as they're generated
+}
+
+// GenerateOptions controls a single generation request.
+type GenerateOptions struct {
+	JSON   bool            // request JSON output (ignored if the backend lacks JSON mode)
+	Schema json.RawMessage // JSON schema the output must match (needs SchemaOutput; implies JSON)
+}
+
+// Health is the result of a backend health check.
+type Health struct {
+	Backend        string   `json:"backend"`
+	Model          string   `json:"model"`
+	ModelAvailable bool     `json:"model_available"`
+	Models         []string `json:"models,omitempty"` // models the backend offers, if it can list them
+}
+
+// Generator is an LLM backend that turns prompts into text.
+type Generator interface {
+	// Generate sends a prompt and returns the full response.
+	Generate(ctx context.Context, prompt string, opts GenerateOptions) (string, error)
+
+	// Capabilities reports optional features the backend supports.
+	Capabilities() Capabilities
+
+	// Health checks the backend is reachable and reports whether the model is available.
+	// Returns an error if the backend can't be reached at all.
+	Health(ctx context.Context) (Health, error)
+
+	// Model returns the configured model name.
+	Model() string
+}
+
+// StreamFunc receives generated text as it arrives. Returning an error stops the stream.
+type StreamFunc func(chunk string) error
+
+// Streamer is implemented by generators that can stream their output.
+// Check Capabilities().Streaming before relying on it.
+type Streamer interface {
+	// GenerateStream sends a prompt, calls fn with each chunk of output, and
+	// returns the full response once generation finishes.
+	GenerateStream(ctx context.Context, prompt string, opts GenerateOptions, fn StreamFunc) (string, error)
+}
+
+// Config holds LLM backend configuration.
+type Config struct {
+	Backend string        `json:"backend"`            // "ollama" (default) or "openai"
+	BaseURL string        `json:"base_url,omitempty"` // backend API URL (default depends on backend)
+	Model   string        `json:"model,omitempty"`    // model name (default: phi3:mini for Ollama)
+	APIKey  string        `json:"api_key,omitempty"`  // bearer token, OpenAI-compatible backends only
+	Timeout time.Duration `json:"timeout,omitempty"`  // request timeout (default: 30s)
+}
+
+// DefaultConfig returns sensible defaults for local Ollama.
+func DefaultConfig() Config {
+	return Config{
+		Backend: BackendOllama,
+		BaseURL: "http://localhost:11434",
+		Model:   "phi3:mini",
+		Timeout: 30 * time.Second,
+	}
+}
+
+// LoadConfig reads a JSON config file. Missing fields keep their zero value,
+// which each backend replaces with its own default.
+//
+//	{"backend": "openai", "base_url": "http://localhost:8080/v1", "model": "llama-3.2-1b", "timeout": "10s"}
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	// Timeout is a duration string in the file
+	var raw struct {
+		Config
+		Timeout string `json:"timeout,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Config{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	cfg := raw.Config
+	if raw.Timeout != "" {
+		cfg.Timeout, err = time.ParseDuration(raw.Timeout)
+		if err != nil {
+			return Config{}, fmt.Errorf("parsing timeout: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// ResolveConfig builds a backend config from the defaults, an optional config
+// file (empty path = none) and command-line overrides, in that order. Only the
+// non-empty fields of overrides apply. Switching backend, in the file or the
+// overrides, drops the Ollama defaults, which don't apply to another backend.
+func ResolveConfig(path string, overrides Config) (Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		fileCfg, err := LoadConfig(path)
+		if err != nil {
+			return cfg, err
+		}
+		if fileCfg.Backend != "" && fileCfg.Backend != cfg.Backend {
+			cfg = Config{Timeout: cfg.Timeout}
+		}
+		cfg = overlay(cfg, fileCfg)
+	}
+
+	if overrides.Backend != "" && overrides.Backend != cfg.Backend {
+		cfg = Config{Timeout: cfg.Timeout}
+	}
+	return overlay(cfg, overrides), nil
+}
+
+// overlay copies the non-empty fields of override onto base.
+func overlay(base, override Config) Config {
+	if override.Backend != "" {
+		base.Backend = override.Backend
+	}
+	if override.BaseURL != "" {
+		base.BaseURL = override.BaseURL
+	}
+	if override.Model != "" {
+		base.Model = override.Model
+	}
+	if override.APIKey != "" {
+		base.APIKey = override.APIKey
+	}
+	if override.Timeout != 0 {
+		base.Timeout = override.Timeout
+	}
+	return base
+}
+
+// New creates the generator selected by cfg.Backend.
+func New(cfg Config) (Generator, error) {
+	switch cfg.Backend {
+	case "", BackendOllama:
+		return NewOllamaClient(cfg), nil
+	case BackendOpenAI:
+		return NewOpenAIClient(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM backend %q", cfg.Backend)
+	}
+}
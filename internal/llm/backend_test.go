@@ -0,0 +1,252 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOllamaClient_GenerateAndHealth(t *testing.T) {
+	var gotFormat string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/generate":
+			var req ollamaRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			gotFormat = string(req.Format)
+			json.NewEncoder(w).Encode(ollamaResponse{Response: `{"action":"wag_tail"}`, Done: true})
+		case "/api/tags":
+			w.Write([]byte(`{"models":[{"name":"phi3:mini"},{"name":"llama3"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewOllamaClient(Config{BaseURL: srv.URL})
+
+	out, err := c.Generate(context.Background(), "hi", GenerateOptions{JSON: true})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if out != `{"action":"wag_tail"}` || gotFormat != `"json"` {
+		t.Errorf("Generate() = %q with format %q", out, gotFormat)
+	}
+
+	health, err := c.Health(context.Background())
+	if err != nil {
+		t.Fatalf("Health() error = %v", err)
+	}
+	if !health.ModelAvailable || len(health.Models) != 2 || health.Backend != BackendOllama {
+		t.Errorf("Health() = %+v", health)
+	}
+}
+
+func TestOpenAIClient_GenerateAndHealth(t *testing.T) {
+	var gotAuth string
+	var gotReq chatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			json.NewDecoder(r.Body).Decode(&gotReq)
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"action\":\"sniff\"}"},"finish_reason":"stop"}]}`))
+		case "/v1/models":
+			w.Write([]byte(`{"data":[{"id":"qwen2.5-0.5b"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewOpenAIClient(Config{BaseURL: srv.URL + "/v1/", Model: "qwen2.5-0.5b", APIKey: "secret"})
+
+	out, err := c.Generate(context.Background(), "hi", GenerateOptions{JSON: true})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if out != `{"action":"sniff"}` {
+		t.Errorf("Generate() = %q", out)
+	}
+	if gotReq.ResponseFormat == nil || gotReq.ResponseFormat.Type != "json_object" {
+		t.Errorf("response_format = %+v, want json_object", gotReq.ResponseFormat)
+	}
+	if len(gotReq.Messages) != 1 || gotReq.Messages[0].Content != "hi" {
+		t.Errorf("messages = %+v", gotReq.Messages)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+
+	health, err := c.Health(context.Background())
+	if err != nil {
+		t.Fatalf("Health() error = %v", err)
+	}
+	if !health.ModelAvailable {
+		t.Errorf("Health() = %+v, want model available", health)
+	}
+}
+
+func TestHealth_Unreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	for _, gen := range []Generator{
+		NewOllamaClient(Config{BaseURL: url}),
+		NewOpenAIClient(Config{BaseURL: url}),
+	} {
+		if _, err := gen.Health(context.Background()); err == nil {
+			t.Errorf("%T.Health() succeeded against a closed server", gen)
+		}
+	}
+}
+
+func TestNew_SelectsBackend(t *testing.T) {
+	tests := []struct {
+		backend string
+		want    string
+	}{
+		{"", "*llm.OllamaClient"},
+		{BackendOllama, "*llm.OllamaClient"},
+		{BackendOpenAI, "*llm.OpenAIClient"},
+	}
+	for _, tt := range tests {
+		gen, err := New(Config{Backend: tt.backend})
+		if err != nil {
+			t.Fatalf("New(%q) error = %v", tt.backend, err)
+		}
+		if got := fmt.Sprintf("%T", gen); got != tt.want {
+			t.Errorf("New(%q) = %s, want %s", tt.backend, got, tt.want)
+		}
+	}
+
+	if _, err := New(Config{Backend: "gpt-on-a-toaster"}); err == nil {
+		t.Error("New() accepted an unknown backend")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llm.json")
+	os.WriteFile(path, []byte(`{"backend":"openai","base_url":"http://pi:8080/v1","model":"tiny","timeout":"10s"}`), 0644)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	want := Config{Backend: BackendOpenAI, BaseURL: "http://pi:8080/v1", Model: "tiny", Timeout: 10 * time.Second}
+	if cfg != want {
+		t.Errorf("LoadConfig() = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestResolveConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llm.json")
+	os.WriteFile(path, []byte(`{"backend":"openai","base_url":"http://pi:8080/v1","model":"tiny"}`), 0644)
+
+	tests := []struct {
+		name      string
+		path      string
+		overrides Config
+		want      Config
+	}{
+		{"defaults", "", Config{}, DefaultConfig()},
+		{"flags only", "", Config{Model: "phi4"}, Config{Backend: BackendOllama, BaseURL: "http://localhost:11434", Model: "phi4", Timeout: 30 * time.Second}},
+		{"file drops Ollama defaults", path, Config{}, Config{Backend: BackendOpenAI, BaseURL: "http://pi:8080/v1", Model: "tiny", Timeout: 30 * time.Second}},
+		{"flags beat file", path, Config{Model: "bigger"}, Config{Backend: BackendOpenAI, BaseURL: "http://pi:8080/v1", Model: "bigger", Timeout: 30 * time.Second}},
+		{"backend flag drops defaults", "", Config{Backend: BackendOpenAI, BaseURL: "http://pi:8080/v1"}, Config{Backend: BackendOpenAI, BaseURL: "http://pi:8080/v1", Timeout: 30 * time.Second}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ResolveConfig(tt.path, tt.overrides)
+			if err != nil {
+				t.Fatalf("ResolveConfig() error = %v", err)
+			}
+			if cfg != tt.want {
+				t.Errorf("ResolveConfig() = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+
+	if _, err := ResolveConfig(filepath.Join(t.TempDir(), "missing.json"), Config{}); err == nil {
+		t.Error("ResolveConfig() accepted a missing file")
+	}
+}
+
+func TestOllamaClient_GenerateStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Error("stream = false in streaming request")
+		}
+		for _, part := range []string{`{"action":`, ` "sniff"`, `}`} {
+			json.NewEncoder(w).Encode(ollamaResponse{Response: part})
+		}
+		json.NewEncoder(w).Encode(ollamaResponse{Done: true})
+	}))
+	defer srv.Close()
+
+	var chunks []string
+	out, err := NewOllamaClient(Config{BaseURL: srv.URL}).GenerateStream(context.Background(), "hi", GenerateOptions{},
+		func(chunk string) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	if out != `{"action": "sniff"}` || len(chunks) != 3 {
+		t.Errorf("GenerateStream() = %q in %d chunks", out, len(chunks))
+	}
+}
+
+func TestOpenAIClient_GenerateStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, part := range []string{`{\"action\":`, ` \"sniff\"`, `}`} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"%s\"}}]}\n\n", part)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	var chunks []string
+	out, err := NewOpenAIClient(Config{BaseURL: srv.URL}).GenerateStream(context.Background(), "hi", GenerateOptions{},
+		func(chunk string) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	if out != `{"action": "sniff"}` || len(chunks) != 3 {
+		t.Errorf("GenerateStream() = %q in %d chunks", out, len(chunks))
+	}
+}
+
+func TestOllamaClient_PassesSchemaAsFormat(t *testing.T) {
+	var gotFormat json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		gotFormat = req.Format
+		json.NewEncoder(w).Encode(ollamaResponse{Response: `{}`, Done: true})
+	}))
+	defer srv.Close()
+
+	schema := json.RawMessage(`{"type":"object"}`)
+	if _, err := NewOllamaClient(Config{BaseURL: srv.URL}).Generate(context.Background(), "hi", GenerateOptions{JSON: true, Schema: schema}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if string(gotFormat) != string(schema) {
+		t.Errorf("format = %s, want the schema", gotFormat)
+	}
+}
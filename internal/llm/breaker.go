@@ -0,0 +1,245 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBreakerOpen is returned instead of calling a backend that is known to be down.
+var ErrBreakerOpen = errors.New("LLM backend unavailable (circuit open)")
+
+// Breaker states.
+const (
+	BreakerClosed = "closed" // backend healthy, requests go through
+	BreakerOpen   = "open"   // backend down, requests fail fast while it is probed
+)
+
+// BreakerConfig controls when the breaker opens and how it probes for recovery.
+type BreakerConfig struct {
+	FailureThreshold int           // consecutive failures or timeouts that open the breaker (default: 3)
+	ProbeInterval    time.Duration // first wait before probing an open backend (default: 5s)
+	MaxProbeInterval time.Duration // probe backoff doubles up to this (default: 2m)
+	ProbeTimeout     time.Duration // per health check (default: 5s)
+}
+
+// DefaultBreakerConfig returns sensible breaker defaults.
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: 3,
+		ProbeInterval:    5 * time.Second,
+		MaxProbeInterval: 2 * time.Minute,
+		ProbeTimeout:     5 * time.Second,
+	}
+}
+
+// BreakerStatus is a snapshot of the breaker for status displays and the API.
+type BreakerStatus struct {
+	State     string    `json:"state"`
+	Model     string    `json:"model"`
+	Failures  int       `json:"consecutive_failures"`
+	Trips     int       `json:"trips"` // times the breaker has opened
+	LastError string    `json:"last_error,omitempty"`
+	OpenedAt  time.Time `json:"opened_at,omitzero"`
+	NextProbe time.Time `json:"next_probe,omitzero"`
+}
+
+// Breaker is a Generator that stops calling a failing backend. After
+// FailureThreshold consecutive failures it opens: requests fail immediately
+// with ErrBreakerOpen while a background probe checks the backend's health
+// with exponential backoff, closing the breaker once it is back.
+//
+// Requests cancelled by the caller don't count as failures; timeouts do.
+type Breaker struct {
+	gen Generator
+	cfg BreakerConfig
+
+	mu      sync.Mutex
+	status  BreakerStatus
+	probing bool
+	stop    chan struct{}
+	now     func() time.Time
+}
+
+// NewBreaker wraps gen with a circuit breaker.
+func NewBreaker(gen Generator, cfg BreakerConfig) *Breaker {
+	defaults := DefaultBreakerConfig()
+	if cfg.FailureThreshold == 0 {
+		cfg.FailureThreshold = defaults.FailureThreshold
+	}
+	if cfg.ProbeInterval == 0 {
+		cfg.ProbeInterval = defaults.ProbeInterval
+	}
+	if cfg.MaxProbeInterval == 0 {
+		cfg.MaxProbeInterval = defaults.MaxProbeInterval
+	}
+	if cfg.ProbeTimeout == 0 {
+		cfg.ProbeTimeout = defaults.ProbeTimeout
+	}
+
+	return &Breaker{
+		gen:    gen,
+		cfg:    cfg,
+		status: BreakerStatus{State: BreakerClosed, Model: gen.Model()},
+		stop:   make(chan struct{}),
+		now:    time.Now,
+	}
+}
+
+// Generate implements Generator.
+func (b *Breaker) Generate(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	if !b.Allow() {
+		return "", ErrBreakerOpen
+	}
+	response, err := b.gen.Generate(ctx, prompt, opts)
+	b.record(ctx, err)
+	return response, err
+}
+
+// GenerateStream implements Streamer, streaming if the wrapped backend can.
+func (b *Breaker) GenerateStream(ctx context.Context, prompt string, opts GenerateOptions, fn StreamFunc) (string, error) {
+	streamer, ok := b.gen.(Streamer)
+	if !ok {
+		return b.Generate(ctx, prompt, opts)
+	}
+	if !b.Allow() {
+		return "", ErrBreakerOpen
+	}
+	response, err := streamer.GenerateStream(ctx, prompt, opts, fn)
+	b.record(ctx, err)
+	return response, err
+}
+
+// Capabilities implements Generator.
+func (b *Breaker) Capabilities() Capabilities {
+	return b.gen.Capabilities()
+}
+
+// Health implements Generator. It checks the backend directly, whatever the breaker state.
+func (b *Breaker) Health(ctx context.Context) (Health, error) {
+	return b.gen.Health(ctx)
+}
+
+// Model implements Generator.
+func (b *Breaker) Model() string {
+	return b.gen.Model()
+}
+
+// Allow reports whether requests currently go to the backend.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status.State == BreakerClosed
+}
+
+// Status returns a snapshot of the breaker.
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+// Trip opens the breaker straight away, e.g. when the backend is already
+// unreachable at startup, and starts probing for it.
+func (b *Breaker) Trip(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.status.LastError = err.Error()
+	b.openLocked()
+}
+
+// Close stops background probing.
+func (b *Breaker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-b.stop:
+	default:
+		close(b.stop)
+	}
+}
+
+// record counts the outcome of a backend call.
+func (b *Breaker) record(ctx context.Context, err error) {
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return // the caller gave up, that says nothing about the backend
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.status.Failures = 0
+		return
+	}
+
+	b.status.Failures++
+	b.status.LastError = err.Error()
+	if b.status.State == BreakerClosed && b.status.Failures >= b.cfg.FailureThreshold {
+		b.openLocked()
+	}
+}
+
+// openLocked opens the breaker and starts the probe. Caller holds b.mu.
+func (b *Breaker) openLocked() {
+	if b.status.State == BreakerOpen {
+		return
+	}
+	b.status.State = BreakerOpen
+	b.status.OpenedAt = b.now()
+	b.status.Trips++
+	if !b.probing {
+		b.probing = true
+		go b.probe()
+	}
+}
+
+// probe checks the backend's health with exponential backoff until it
+// recovers, then closes the breaker.
+func (b *Breaker) probe() {
+	wait := b.cfg.ProbeInterval
+	for {
+		b.mu.Lock()
+		b.status.NextProbe = b.now().Add(wait)
+		b.mu.Unlock()
+
+		select {
+		case <-b.stop:
+			return
+		case <-time.After(wait):
+		}
+
+		err := b.check()
+		b.mu.Lock()
+		if err == nil {
+			b.status.State = BreakerClosed
+			b.status.Failures = 0
+			b.status.LastError = ""
+			b.status.OpenedAt = time.Time{}
+			b.status.NextProbe = time.Time{}
+			b.probing = false
+			b.mu.Unlock()
+			return
+		}
+		b.status.LastError = err.Error()
+		b.mu.Unlock()
+
+		wait = min(wait*2, b.cfg.MaxProbeInterval)
+	}
+}
+
+// check is one health probe: the backend must answer and have the model.
+func (b *Breaker) check() error {
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.ProbeTimeout)
+	defer cancel()
+
+	health, err := b.gen.Health(ctx)
+	if err != nil {
+		return err
+	}
+	if !health.ModelAvailable {
+		return fmt.Errorf("model %q not available", health.Model)
+	}
+	return nil
+}
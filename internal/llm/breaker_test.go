@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	gen := NewScriptedGenerator(answer("stay", "wag_tail", "purr", "normal", "cozy"))
+	gen.SetErr(errors.New("connection refused"))
+	b := NewBreaker(gen, BreakerConfig{FailureThreshold: 2, ProbeInterval: time.Hour})
+	defer b.Close()
+
+	ctx := context.Background()
+	b.Generate(ctx, "p", GenerateOptions{})
+	if !b.Allow() {
+		t.Fatal("opened after a single failure")
+	}
+
+	// A request the caller cancelled says nothing about the backend
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	b.Generate(cancelled, "p", GenerateOptions{})
+	if s := b.Status(); s.Failures != 1 {
+		t.Errorf("failures = %d after a cancelled request, want 1", s.Failures)
+	}
+
+	b.Generate(ctx, "p", GenerateOptions{})
+	s := b.Status()
+	if s.State != BreakerOpen || s.Trips != 1 || s.LastError != "connection refused" {
+		t.Fatalf("status = %+v, want open after 2 failures", s)
+	}
+
+	calls := len(gen.Prompts())
+	if _, err := b.Generate(ctx, "p", GenerateOptions{}); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("Generate() error = %v, want ErrBreakerOpen", err)
+	}
+	if len(gen.Prompts()) != calls {
+		t.Error("open breaker still called the backend")
+	}
+}
+
+func TestBreaker_ProbeClosesOnRecovery(t *testing.T) {
+	gen := NewScriptedGenerator(answer("stay", "wag_tail", "purr", "normal", "cozy"))
+	gen.SetErr(errors.New("connection refused"))
+	b := NewBreaker(gen, BreakerConfig{ProbeInterval: 5 * time.Millisecond, MaxProbeInterval: 20 * time.Millisecond})
+	defer b.Close()
+
+	b.Trip(errors.New("down at startup"))
+	time.Sleep(30 * time.Millisecond)
+	if b.Allow() {
+		t.Fatal("closed while the backend is still down")
+	}
+
+	gen.SetErr(nil)
+	deadline := time.Now().Add(2 * time.Second)
+	for !b.Allow() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s := b.Status(); s.State != BreakerClosed || s.LastError != "" || s.Trips != 1 {
+		t.Fatalf("status = %+v, want closed after recovery", s)
+	}
+	if _, err := b.Generate(context.Background(), "p", GenerateOptions{}); err != nil {
+		t.Errorf("Generate() after recovery error = %v", err)
+	}
+}
+
+func TestBreaker_FallbackIsInstantWhileOpen(t *testing.T) {
+	gen := NewScriptedGenerator(answer("approach", "nuzzle", "purr", "eager", "hi"))
+	gen.ChunkDelay = time.Second // a slow backend, if it were called
+	b := NewBreaker(gen, BreakerConfig{ProbeInterval: time.Hour})
+	defer b.Close()
+	b.Trip(errors.New("down"))
+
+	engine := NewPersonalityEngine(b)
+	start := time.Now()
+	resp := engine.SelectActionWithFallback(context.Background(), happyRequest())
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("fallback took %s with the breaker open", elapsed)
+	}
+	if resp.Movement != happyRequest().EmotionalState.SuggestDefaultAction().Movement {
+		t.Errorf("movement = %s, want the mood default", resp.Movement)
+	}
+	if len(gen.Prompts()) != 0 {
+		t.Error("backend was called while the breaker was open")
+	}
+}
@@ -0,0 +1,279 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alex/koji/internal/atomicfile"
+)
+
+// CacheConfig controls how long cached decisions live and how often they're refreshed.
+type CacheConfig struct {
+	MaxAnswers  int           // answers kept per situation (default: 5)
+	MaxKeys     int           // situations kept; the least recently answered go first (default: 2000)
+	MinAnswers  int           // situations with fewer answers always ask the LLM (default: 2)
+	TTL         time.Duration // how long an answer stays usable (default: 6h)
+	RefreshProb float64       // chance a hit asks the LLM anyway, to keep adding variety (0 = never; DefaultCacheConfig uses 0.15)
+	FlushEvery  time.Duration // new answers are batched and written this often (default: 10s)
+}
+
+// DefaultCacheConfig returns sensible cache defaults.
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		MaxAnswers:  5,
+		MaxKeys:     2000,
+		MinAnswers:  2,
+		TTL:         6 * time.Hour,
+		RefreshProb: 0.15,
+		FlushEvery:  10 * time.Second,
+	}
+}
+
+// CachedAnswer is one past valid LLM answer for a situation.
+type CachedAnswer struct {
+	Response ActionResponse `json:"response"`
+	StoredAt time.Time      `json:"stored_at"`
+}
+
+// CacheStats reports cache effectiveness.
+type CacheStats struct {
+	Hits      int     `json:"hits"`
+	Misses    int     `json:"misses"`
+	Refreshes int     `json:"refreshes"` // hits deliberately sent to the LLM for variety
+	Keys      int     `json:"keys"`
+	Answers   int     `json:"answers"`
+	HitRate   float64 `json:"hit_rate"`
+}
+
+// DecisionCache remembers valid LLM answers per normalized situation (see
+// situationKey) and samples among them, so repeated situations are answered
+// instantly without always getting the same reaction.
+type DecisionCache struct {
+	mu       sync.Mutex
+	cfg      CacheConfig
+	entries  map[string][]CachedAnswer
+	stats    CacheStats
+	dataPath string
+	rng      *rand.Rand
+	now      func() time.Time
+
+	dirty      bool
+	flushTimer *time.Timer
+	closed     bool
+}
+
+// NewDecisionCache creates a cache persisted at dataPath ("" for in-memory only).
+func NewDecisionCache(dataPath string, cfg CacheConfig) (*DecisionCache, error) {
+	defaults := DefaultCacheConfig()
+	if cfg.MaxAnswers == 0 {
+		cfg.MaxAnswers = defaults.MaxAnswers
+	}
+	if cfg.MaxKeys == 0 {
+		cfg.MaxKeys = defaults.MaxKeys
+	}
+	if cfg.MinAnswers == 0 {
+		cfg.MinAnswers = defaults.MinAnswers
+	}
+	if cfg.TTL == 0 {
+		cfg.TTL = defaults.TTL
+	}
+	if cfg.FlushEvery == 0 {
+		cfg.FlushEvery = defaults.FlushEvery
+	}
+
+	c := &DecisionCache{
+		cfg:      cfg,
+		entries:  make(map[string][]CachedAnswer),
+		dataPath: dataPath,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:      time.Now,
+	}
+
+	// Try to load existing data
+	if err := c.load(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("loading decision cache: %w", err)
+	}
+
+	return c, nil
+}
+
+// Lookup returns a sampled cached answer for the situation. It misses if the
+// situation has too few fresh answers, or randomly (RefreshProb) to refresh.
+func (c *DecisionCache) Lookup(key string) (ActionResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	answers := c.freshLocked(key)
+	if len(answers) < c.cfg.MinAnswers {
+		c.stats.Misses++
+		return ActionResponse{}, false
+	}
+	if c.rng.Float64() < c.cfg.RefreshProb {
+		c.stats.Refreshes++
+		return ActionResponse{}, false
+	}
+
+	c.stats.Hits++
+	return answers[c.rng.Intn(len(answers))].Response, true
+}
+
+// Add stores a valid answer for the situation. An answer with the same action
+// set and modifier replaces the older one, so the cache holds distinct reactions.
+func (c *DecisionCache) Add(key string, resp ActionResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resp.Timing = ActionTiming{}
+	answers := c.freshLocked(key)
+
+	kept := answers[:0]
+	for _, a := range answers {
+		if a.Response.ActionSet() != resp.ActionSet() || a.Response.Modifier != resp.Modifier {
+			kept = append(kept, a)
+		}
+	}
+	kept = append(kept, CachedAnswer{Response: resp, StoredAt: c.now()})
+	if len(kept) > c.cfg.MaxAnswers {
+		kept = kept[len(kept)-c.cfg.MaxAnswers:]
+	}
+	c.entries[key] = kept
+	if len(c.entries) > c.cfg.MaxKeys {
+		c.pruneLocked()
+	}
+	c.changedLocked()
+}
+
+// freshLocked drops expired answers for key and returns the rest. Caller holds c.mu.
+func (c *DecisionCache) freshLocked(key string) []CachedAnswer {
+	answers := c.entries[key]
+	cutoff := c.now().Add(-c.cfg.TTL)
+
+	fresh := answers[:0]
+	for _, a := range answers {
+		if a.StoredAt.After(cutoff) {
+			fresh = append(fresh, a)
+		}
+	}
+
+	if len(fresh) == 0 {
+		delete(c.entries, key)
+		return nil
+	}
+	c.entries[key] = fresh
+	return fresh
+}
+
+// pruneLocked drops expired answers for every situation, not just the ones
+// looked up again, then the least recently answered situations beyond
+// MaxKeys. Caller holds c.mu.
+func (c *DecisionCache) pruneLocked() {
+	for key := range c.entries {
+		c.freshLocked(key)
+	}
+	if len(c.entries) <= c.cfg.MaxKeys {
+		return
+	}
+
+	// Answers are kept oldest first, so the last is the newest
+	newest := func(key string) time.Time {
+		answers := c.entries[key]
+		return answers[len(answers)-1].StoredAt
+	}
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int { return newest(a).Compare(newest(b)) })
+	for _, key := range keys[:len(keys)-c.cfg.MaxKeys] {
+		delete(c.entries, key)
+	}
+}
+
+// Stats returns hit/miss counts and current size.
+func (c *DecisionCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Keys = len(c.entries)
+	for _, answers := range c.entries {
+		stats.Answers += len(answers)
+	}
+	if lookups := stats.Hits + stats.Misses + stats.Refreshes; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// changedLocked schedules a write of the cache, at most FlushEvery from
+// now. Caller holds c.mu.
+func (c *DecisionCache) changedLocked() {
+	c.dirty = true
+	if c.dataPath != "" && c.flushTimer == nil && !c.closed {
+		c.flushTimer = time.AfterFunc(c.cfg.FlushEvery, func() { _ = c.Flush() })
+	}
+}
+
+// Flush writes any answers not yet on disk now.
+func (c *DecisionCache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.flushTimer != nil {
+		c.flushTimer.Stop()
+		c.flushTimer = nil
+	}
+	if !c.dirty {
+		return nil
+	}
+	c.pruneLocked()
+	if err := c.save(); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// Close writes any pending answers. The cache shouldn't be used after.
+func (c *DecisionCache) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	return c.Flush()
+}
+
+// save persists the cache to disk. Caller holds c.mu.
+func (c *DecisionCache) save() error {
+	if c.dataPath == "" {
+		return nil // in-memory only
+	}
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(c.dataPath, data, 0644)
+}
+
+// load reads the cache from disk.
+func (c *DecisionCache) load() error {
+	if c.dataPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.dataPath)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return err
+	}
+	c.pruneLocked()
+	return nil
+}
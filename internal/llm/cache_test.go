@@ -0,0 +1,190 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+func TestDecisionCache_SamplesAmongAnswers(t *testing.T) {
+	c, _ := NewDecisionCache("", CacheConfig{MinAnswers: 2})
+
+	if _, ok := c.Lookup("k"); ok {
+		t.Fatal("hit on empty cache")
+	}
+
+	c.Add("k", ActionResponse{Action: "wag_tail", Movement: "wag_tail"})
+	if _, ok := c.Lookup("k"); ok {
+		t.Error("hit with fewer than MinAnswers answers")
+	}
+
+	c.Add("k", ActionResponse{Action: "nuzzle", Movement: "nuzzle"})
+	c.Add("k", ActionResponse{Action: "wag_tail", Movement: "wag_tail", Reason: "again"}) // replaces, not duplicates
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		resp, ok := c.Lookup("k")
+		if !ok {
+			t.Fatal("miss with enough answers and no refresh probability")
+		}
+		seen[resp.Action] = true
+	}
+	if !seen["wag_tail"] || !seen["nuzzle"] {
+		t.Errorf("sampled %v, want both answers", seen)
+	}
+
+	stats := c.Stats()
+	if stats.Keys != 1 || stats.Answers != 2 || stats.Hits != 100 || stats.Misses != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestDecisionCache_TTLAndMaxAnswers(t *testing.T) {
+	c, _ := NewDecisionCache("", CacheConfig{MaxAnswers: 2, MinAnswers: 1, TTL: time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Add("k", ActionResponse{Action: "stay", Movement: "stay"})
+	c.Add("k", ActionResponse{Action: "approach", Movement: "approach"})
+	c.Add("k", ActionResponse{Action: "explore", Movement: "explore"})
+	if stats := c.Stats(); stats.Answers != 2 {
+		t.Errorf("Answers = %d, want capped at 2", stats.Answers)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Lookup("k"); ok {
+		t.Error("hit after TTL expired")
+	}
+	if stats := c.Stats(); stats.Keys != 0 {
+		t.Errorf("Keys = %d, want expired key dropped", stats.Keys)
+	}
+}
+
+func TestDecisionCache_PrunesKeysNeverLookedUpAgain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llm_cache.json")
+	c, _ := NewDecisionCache(path, CacheConfig{MinAnswers: 1, MaxKeys: 2, TTL: time.Minute})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		c.Add(key, ActionResponse{Action: "stay"})
+		now = now.Add(time.Second)
+	}
+	if _, ok := c.Lookup("a"); ok || c.Stats().Keys != 2 {
+		t.Errorf("Keys = %d, want the oldest situation dropped at MaxKeys 2", c.Stats().Keys)
+	}
+
+	// b and c expire without ever being looked up; saving drops them
+	now = now.Add(2 * time.Minute)
+	c.Add("d", ActionResponse{Action: "stay"})
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if stats := c.Stats(); stats.Keys != 1 {
+		t.Errorf("Keys = %d after saving, want only the fresh one", stats.Keys)
+	}
+}
+
+func TestDecisionCache_RefreshProbability(t *testing.T) {
+	c, _ := NewDecisionCache("", CacheConfig{MinAnswers: 1, RefreshProb: 1})
+	c.Add("k", ActionResponse{Action: "stay", Movement: "stay"})
+
+	if _, ok := c.Lookup("k"); ok {
+		t.Error("hit with RefreshProb = 1")
+	}
+	if stats := c.Stats(); stats.Refreshes != 1 {
+		t.Errorf("Refreshes = %d, want 1", stats.Refreshes)
+	}
+}
+
+func TestDecisionCache_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llm_cache.json")
+
+	c1, _ := NewDecisionCache(path, CacheConfig{MinAnswers: 1})
+	c1.Add("k", ActionResponse{Action: "nuzzle", Movement: "nuzzle", Reason: "cozy"})
+
+	// Answers are batched, not written on every Add
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("cache written before flush (err = %v)", err)
+	}
+	if err := c1.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	c2, err := NewDecisionCache(path, CacheConfig{MinAnswers: 1})
+	if err != nil {
+		t.Fatalf("NewDecisionCache() error = %v", err)
+	}
+	resp, ok := c2.Lookup("k")
+	if !ok || resp.Action != "nuzzle" || resp.Reason != "cozy" {
+		t.Errorf("Lookup() after reload = %+v, %v", resp, ok)
+	}
+}
+
+func TestPersonalityEngine_UsesCache(t *testing.T) {
+	gen := NewScriptedGenerator(
+		answer("stay", "wag_tail", "purr", "normal", "one"),
+		answer("approach", "nuzzle", "chirp", "eager", "two"),
+	)
+	engine := NewPersonalityEngine(gen)
+	cache, _ := NewDecisionCache("", CacheConfig{MinAnswers: 2})
+	engine.SetCache(cache)
+
+	req := happyRequest()
+	req.RecentEvents = []personality.Event{personality.EventSpeech, personality.EventPetted}
+
+	for i := 0; i < 2; i++ {
+		if resp, _ := engine.SelectAction(context.Background(), req); resp.Timing.Cached {
+			t.Fatalf("call %d served from cache before MinAnswers were collected", i)
+		}
+	}
+
+	var committed string
+	req.OnAction = func(resp ActionResponse) { committed = resp.Action }
+	resp, err := engine.SelectAction(context.Background(), req)
+	if err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+	if !resp.Timing.Cached || committed != resp.Action {
+		t.Errorf("got %+v (committed %q), want a committed cache hit", resp, committed)
+	}
+	if n := len(gen.Prompts()); n != 2 {
+		t.Errorf("LLM called %d times, want 2", n)
+	}
+
+	// Same situation with recent events in a different order is the same key
+	req.RecentEvents = []personality.Event{personality.EventPetted, personality.EventSpeech}
+	if resp, _ := engine.SelectAction(context.Background(), req); !resp.Timing.Cached {
+		t.Error("reordered recent events missed the cache")
+	}
+
+	// The prompt changes with who is there and what Koji remembers, so the
+	// answer can't be reused for them
+	withPerson := req
+	withPerson.Event = personality.NewEventContext(personality.EventPetted)
+	withPerson.Event.Metadata[personality.MetaPersonID] = "p1"
+	if resp, _ := engine.SelectAction(context.Background(), withPerson); resp.Timing.Cached {
+		t.Error("an answer for nobody in particular was reused for a person")
+	}
+	withMemories := req
+	withMemories.Memories = []string{"5 minutes ago: Alex petted Koji"}
+	withMemories.MemoryKey = "mhq3b"
+	if resp, _ := engine.SelectAction(context.Background(), withMemories); resp.Timing.Cached {
+		t.Error("an answer without memories was reused with them")
+	}
+
+	// The same episodes worded a minute older are the same memories
+	engine.SelectAction(context.Background(), withMemories)
+	withMemories.Memories = []string{"6 minutes ago: Alex petted Koji"}
+	if resp, _ := engine.SelectAction(context.Background(), withMemories); !resp.Timing.Cached {
+		t.Error("memories that only aged missed the cache")
+	}
+	withMemories.MemoryKey = ""
+	if resp, _ := engine.SelectAction(context.Background(), withMemories); resp.Timing.Cached {
+		t.Error("memories without a key were answered from the cache")
+	}
+}
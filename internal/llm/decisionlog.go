@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+// DecisionLogEntry is one line of the decision log.
+type DecisionLogEntry struct {
+	Time         time.Time           `json:"time"`
+	Mood         personality.Mood    `json:"mood"`
+	Intensity    float64             `json:"intensity"`
+	Event        personality.Event   `json:"event"`
+	Source       string              `json:"source,omitempty"`
+	RecentEvents []personality.Event `json:"recent_events,omitempty"`
+
+	Model         string `json:"model"`
+	PromptVersion string `json:"prompt_version"`
+	PromptHash    string `json:"prompt_hash"`
+
+	Response   *ActionResponse `json:"response,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Cached     bool            `json:"cached,omitempty"`
+	Streamed   bool            `json:"streamed,omitempty"`
+	ToActionMs int64           `json:"to_action_ms"`
+	TotalMs    int64           `json:"total_ms"`
+}
+
+func newDecisionLogEntry(req ActionRequest, resp *ActionResponse, err error, model string, prompt *Prompt) DecisionLogEntry {
+	entry := DecisionLogEntry{
+		Time:          time.Now(),
+		Mood:          req.EmotionalState.CurrentMood,
+		Intensity:     float64(req.EmotionalState.Intensity),
+		Event:         req.Event.Event,
+		Source:        req.Event.Source,
+		RecentEvents:  req.RecentEvents,
+		Model:         model,
+		PromptVersion: prompt.Version,
+		PromptHash:    prompt.Hash,
+		Response:      resp,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if resp != nil {
+		// A cached answer keeps the prompt that originally produced it
+		if resp.PromptVersion != "" {
+			entry.PromptVersion = resp.PromptVersion
+			entry.PromptHash = resp.PromptHash
+		}
+		entry.Cached = resp.Timing.Cached
+		entry.Streamed = resp.Timing.Streamed
+		entry.ToActionMs = resp.Timing.ToAction.Milliseconds()
+		entry.TotalMs = resp.Timing.Total.Milliseconds()
+	}
+	return entry
+}
+
+// DecisionLog appends decisions as JSON lines, so prompt versions and models
+// can be compared after the fact.
+type DecisionLog struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// OpenDecisionLog opens (or creates) a decision log for appending.
+func OpenDecisionLog(path string) (*DecisionLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &DecisionLog{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Record appends an entry. Errors are returned but callers usually ignore
+// them; a lost log line shouldn't stop Koji from acting.
+func (l *DecisionLog) Record(entry DecisionLogEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(entry)
+}
+
+// Close closes the log file.
+func (l *DecisionLog) Close() error {
+	return l.file.Close()
+}
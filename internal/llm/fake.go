@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ScriptedGenerator is a deterministic Generator for tests and offline runs.
+// It returns its responses in order, cycling back to the start when it runs out.
+type ScriptedGenerator struct {
+	Responses []string
+	Err       error // if set, every Generate call fails with this error
+	Caps      Capabilities
+	ModelName string
+
+	// Streaming splits each response into chunks of ChunkSize bytes
+	// (default 4), waiting ChunkDelay before each one.
+	ChunkSize  int
+	ChunkDelay time.Duration
+
+	mu      sync.Mutex
+	next    int
+	prompts []string
+}
+
+// NewScriptedGenerator creates a fake backend that replies with responses in order.
+func NewScriptedGenerator(responses ...string) *ScriptedGenerator {
+	return &ScriptedGenerator{
+		Responses: responses,
+		Caps:      Capabilities{JSONMode: true, SchemaOutput: true, Streaming: true},
+		ModelName: "scripted",
+	}
+}
+
+// Generate returns the next scripted response, ignoring the prompt.
+func (g *ScriptedGenerator) Generate(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	return g.respond(ctx, prompt)
+}
+
+// GenerateStream implements Streamer, feeding the next scripted response to fn in chunks.
+func (g *ScriptedGenerator) GenerateStream(ctx context.Context, prompt string, opts GenerateOptions, fn StreamFunc) (string, error) {
+	resp, err := g.respond(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+
+	size := g.ChunkSize
+	if size <= 0 {
+		size = 4
+	}
+
+	for i := 0; i < len(resp); i += size {
+		if g.ChunkDelay > 0 {
+			select {
+			case <-ctx.Done():
+				return resp[:i], ctx.Err()
+			case <-time.After(g.ChunkDelay):
+			}
+		}
+
+		end := min(i+size, len(resp))
+		if err := fn(resp[i:end]); err != nil {
+			return resp[:end], err
+		}
+	}
+	return resp, nil
+}
+
+// respond records the prompt and picks the next response.
+func (g *ScriptedGenerator) respond(ctx context.Context, prompt string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.prompts = append(g.prompts, prompt)
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if g.Err != nil {
+		return "", g.Err
+	}
+	if len(g.Responses) == 0 {
+		return "", nil
+	}
+
+	resp := g.Responses[g.next%len(g.Responses)]
+	g.next++
+	return resp, nil
+}
+
+// SetErr makes every later call fail with err, or succeed again if err is nil.
+// Unlike setting Err directly, it is safe while the generator is in use.
+func (g *ScriptedGenerator) SetErr(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.Err = err
+}
+
+// Capabilities implements Generator.
+func (g *ScriptedGenerator) Capabilities() Capabilities {
+	return g.Caps
+}
+
+// Health always reports the scripted model as available, unless Err is set.
+func (g *ScriptedGenerator) Health(ctx context.Context) (Health, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	health := Health{Backend: "scripted", Model: g.ModelName}
+	if g.Err != nil {
+		return health, g.Err
+	}
+	health.ModelAvailable = true
+	health.Models = []string{g.ModelName}
+	return health, nil
+}
+
+// Model returns the scripted model name.
+func (g *ScriptedGenerator) Model() string {
+	return g.ModelName
+}
+
+// Prompts returns every prompt received so far.
+func (g *ScriptedGenerator) Prompts() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string{}, g.prompts...)
+}
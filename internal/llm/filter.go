@@ -0,0 +1,245 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+// Assessment is a novelty filter's verdict on whether a situation deserves
+// the larger model.
+type Assessment struct {
+	Escalate bool
+	Score    float64 // 0 = routine, 1 = as novel/ambiguous as it gets
+	Reason   string
+}
+
+// NoveltyFilter decides whether a situation is novel or ambiguous enough to
+// escalate from the local model to the larger one.
+type NoveltyFilter interface {
+	Assess(ctx context.Context, req ActionRequest) Assessment
+}
+
+// intensityBucket coarsens an intensity so similar situations compare equal.
+func intensityBucket(intensity float64) string {
+	switch {
+	case intensity < 0.4:
+		return "low"
+	case intensity < 0.75:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// situationKey normalizes the parts of a request that define "the same situation":
+// mood, mood intensity bucket, event, and the set of recent events.
+func situationKey(req ActionRequest) string {
+	return fmt.Sprintf("%s/%s/%s/%s",
+		req.EmotionalState.CurrentMood,
+		intensityBucket(float64(req.EmotionalState.Intensity)),
+		req.Event.Event,
+		recentSignature(req.RecentEvents))
+}
+
+// recentSignature summarizes the last few events, ignoring order and repeats,
+// so "speech, speech, music" and "music, speech" match.
+func recentSignature(events []personality.Event) string {
+	if len(events) > 3 {
+		events = events[len(events)-3:]
+	}
+
+	seen := make(map[personality.Event]bool)
+	var names []string
+	for _, e := range events {
+		if !seen[e] {
+			seen[e] = true
+			names = append(names, string(e))
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, "+")
+}
+
+// eventValence is +1 for pleasant events, -1 for unpleasant ones. Others are neutral.
+var eventValence = map[personality.Event]int{
+	personality.EventMusic:        1,
+	personality.EventRhythm:       1,
+	personality.EventNameCalled:   1,
+	personality.EventFamiliarFace: 1,
+	personality.EventPetted:       1,
+	personality.EventPersonHappy:  1,
+	personality.EventLoudNoise:    -1,
+	personality.EventPoked:        -1,
+	personality.EventPickedUp:     -1,
+	personality.EventUnknownFace:  -1,
+	personality.EventPersonSad:    -1,
+	personality.EventPersonAngry:  -1,
+}
+
+// ambiguousEvents don't say much on their own; the right reaction depends on
+// what's actually there.
+var ambiguousEvents = map[personality.Event]bool{
+	personality.EventUnknownObject:   true,
+	personality.EventUnknownFace:     true,
+	personality.EventMotionDetected:  true,
+	personality.EventCrowd:           true,
+	personality.EventUncertainFace:   true,
+	personality.EventPersonSurprised: true, // surprised by what?
+}
+
+// HeuristicFilter scores novelty and ambiguity without a model: situations it
+// hasn't seen before, rare events, ambiguous events and mixed signals score
+// high. Urgent fear reactions are never escalated, there's no time for it.
+type HeuristicFilter struct {
+	Threshold float64 // escalate at or above this score (default: 0.7)
+	RareAfter int     // an event is rare until seen this many times (default: 3)
+
+	mu         sync.Mutex
+	situations map[string]int
+	events     map[personality.Event]int
+}
+
+// NewHeuristicFilter creates a heuristic filter with default thresholds.
+func NewHeuristicFilter() *HeuristicFilter {
+	return &HeuristicFilter{
+		Threshold:  0.7,
+		RareAfter:  3,
+		situations: make(map[string]int),
+		events:     make(map[personality.Event]int),
+	}
+}
+
+// Assess scores the request and records it as seen.
+func (f *HeuristicFilter) Assess(ctx context.Context, req ActionRequest) Assessment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := situationKey(req)
+	situationCount := f.situations[key]
+	eventCount := f.events[req.Event.Event]
+	f.situations[key]++
+	f.events[req.Event.Event]++
+
+	mood := req.EmotionalState.CurrentMood
+	if (mood == personality.MoodStartled || mood == personality.MoodFrightened) &&
+		req.EmotionalState.Intensity >= personality.IntensityHigh {
+		return Assessment{Reason: "urgent - act locally"}
+	}
+
+	var score float64
+	var reasons []string
+
+	if situationCount == 0 {
+		score += 0.5
+		reasons = append(reasons, "new situation")
+	}
+	if eventCount < f.RareAfter {
+		score += 0.2
+		reasons = append(reasons, "rare event")
+	}
+	if ambiguousEvents[req.Event.Event] {
+		score += 0.2
+		reasons = append(reasons, "ambiguous event")
+	}
+	if mixedSignals(req.RecentEvents, req.Event.Event) {
+		score += 0.3
+		reasons = append(reasons, "mixed signals")
+	}
+
+	if score > 1 {
+		score = 1
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, "routine")
+	}
+
+	return Assessment{
+		Escalate: score >= f.Threshold,
+		Score:    score,
+		Reason:   strings.Join(reasons, ", "),
+	}
+}
+
+// mixedSignals reports whether the current event and the last few before it
+// include both pleasant and unpleasant ones.
+func mixedSignals(recent []personality.Event, current personality.Event) bool {
+	if len(recent) > 3 {
+		recent = recent[len(recent)-3:]
+	}
+
+	var pos, neg bool
+	for _, e := range append([]personality.Event{current}, recent...) {
+		switch eventValence[e] {
+		case 1:
+			pos = true
+		case -1:
+			neg = true
+		}
+	}
+	return pos && neg
+}
+
+const filterPrompt = `You are a filter deciding if a situation needs a larger, slower AI model.
+Answer YES only if:
+- Object/person is unrecognized AND interesting
+- Situation is ambiguous or complex
+- Novel combination of stimuli not seen before
+
+Answer NO if:
+- Routine event (familiar face, normal sounds)
+- Simple reaction will suffice
+- Safety-critical (must act immediately, no time for a slow model)
+
+Respond with ONLY JSON: {"call_cloud": true/false, "reason": "..."}`
+
+// ModelFilter asks a small model whether to escalate, as sketched in IDEAS.md.
+// Any error or unparseable answer means "don't escalate".
+type ModelFilter struct {
+	gen Generator
+}
+
+// NewModelFilter creates a filter backed by the given (ideally tiny) generator.
+func NewModelFilter(gen Generator) *ModelFilter {
+	return &ModelFilter{gen: gen}
+}
+
+// Assess asks the model for a yes/no escalation decision.
+func (f *ModelFilter) Assess(ctx context.Context, req ActionRequest) Assessment {
+	var sb strings.Builder
+	sb.WriteString(filterPrompt)
+	sb.WriteString("\n\nSituation:\n")
+	sb.WriteString(fmt.Sprintf("- Mood: %s (intensity %.1f)\n", req.EmotionalState.CurrentMood, req.EmotionalState.Intensity))
+	if len(req.RecentEvents) > 0 {
+		sb.WriteString(fmt.Sprintf("- Recent events: %v\n", req.RecentEvents))
+	}
+	sb.WriteString(fmt.Sprintf("- Event: %s (intensity %.1f)", req.Event.Event, req.Event.Intensity))
+	if req.Event.Source != "" {
+		sb.WriteString(fmt.Sprintf(" from %s", req.Event.Source))
+	}
+	sb.WriteString("\n")
+
+	response, err := f.gen.Generate(ctx, sb.String(), GenerateOptions{JSON: f.gen.Capabilities().JSONMode})
+	if err != nil {
+		return Assessment{Reason: fmt.Sprintf("filter error: %v", err)}
+	}
+
+	var decision struct {
+		CallCloud bool   `json:"call_cloud"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(response)), &decision); err != nil {
+		return Assessment{Reason: "filter answer unparseable"}
+	}
+
+	a := Assessment{Escalate: decision.CallCloud, Reason: decision.Reason}
+	if a.Escalate {
+		a.Score = 1
+	}
+	return a
+}
@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// fieldScanner incrementally parses a streamed JSON object and records each
+// top-level string field as soon as its closing quote arrives. It tolerates
+// leading chatter before the object, like extractJSON does for full responses.
+type fieldScanner struct {
+	fields map[string]string
+
+	depth       int  // nesting level; 1 = inside the top-level object
+	inString    bool // inside a string literal
+	escaped     bool // previous byte was a backslash inside a string
+	expectValue bool // saw "key": at depth 1 and waiting for the value
+	key         string
+	str         strings.Builder // raw contents of the current string
+}
+
+func newFieldScanner() *fieldScanner {
+	return &fieldScanner{fields: make(map[string]string)}
+}
+
+// Feed processes the next chunk of streamed output.
+func (s *fieldScanner) Feed(chunk string) {
+	for i := 0; i < len(chunk); i++ {
+		s.feedByte(chunk[i])
+	}
+}
+
+// Field returns a completed top-level string field.
+func (s *fieldScanner) Field(name string) (string, bool) {
+	v, ok := s.fields[name]
+	return v, ok
+}
+
+// Fields returns the named fields once all of them are complete.
+func (s *fieldScanner) Fields(names ...string) (map[string]string, bool) {
+	out := make(map[string]string, len(names))
+	for _, name := range names {
+		v, ok := s.fields[name]
+		if !ok {
+			return nil, false
+		}
+		out[name] = v
+	}
+	return out, true
+}
+
+func (s *fieldScanner) feedByte(c byte) {
+	if s.inString {
+		switch {
+		case s.escaped:
+			s.escaped = false
+		case c == '\\':
+			s.escaped = true
+		case c == '"':
+			s.inString = false
+			s.endString()
+			return
+		}
+		s.str.WriteByte(c)
+		return
+	}
+
+	switch c {
+	case '"':
+		s.inString = true
+		s.str.Reset()
+	case '{', '[':
+		if s.depth == 1 {
+			s.expectValue = false // nested value, not a string field
+		}
+		s.depth++
+	case '}', ']':
+		if s.depth > 0 {
+			s.depth--
+		}
+	case ':':
+		if s.depth == 1 {
+			s.expectValue = true
+		}
+	case ',':
+		if s.depth == 1 {
+			s.expectValue = false
+			s.key = ""
+		}
+	}
+}
+
+// endString handles a just-closed string, either a key or a value.
+func (s *fieldScanner) endString() {
+	if s.depth != 1 {
+		return
+	}
+
+	var value string
+	if err := json.Unmarshal([]byte(`"`+s.str.String()+`"`), &value); err != nil {
+		value = s.str.String()
+	}
+
+	if s.expectValue {
+		s.fields[s.key] = value
+		s.expectValue = false
+	} else {
+		s.key = value
+	}
+}
@@ -0,0 +1,36 @@
+package llm
+
+import "testing"
+
+func TestFieldScanner(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		field  string
+		want   string
+		found  bool
+	}{
+		{"whole object", []string{`{"action": "wag_tail", "reason": "happy"}`}, "action", "wag_tail", true},
+		{"split across chunks", []string{`{"ac`, `tion":`, ` "wag_`, `tail"`, `, "rea`}, "action", "wag_tail", true},
+		{"value not closed yet", []string{`{"action": "wag_ta`}, "action", "", false},
+		{"leading chatter", []string{`Sure! {"action": "sniff"}`}, "action", "sniff", true},
+		{"escaped quote", []string{`{"reason": "he said \"hi\"", "action": "tilt_head"}`}, "reason", `he said "hi"`, true},
+		{"escape split across chunks", []string{`{"reason": "a\`, `"b"}`}, "reason", `a"b`, true},
+		{"nested field ignored", []string{`{"meta": {"action": "flee"}, "action": "sniff"}`}, "action", "sniff", true},
+		{"only nested field", []string{`{"meta": {"action": "flee"}`}, "action", "", false},
+		{"non-string value", []string{`{"action": 3, "reason": "x"}`}, "action", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFieldScanner()
+			for _, c := range tt.chunks {
+				s.Feed(c)
+			}
+			got, found := s.Field(tt.field)
+			if got != tt.want || found != tt.found {
+				t.Errorf("Field(%q) = %q, %v; want %q, %v", tt.field, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
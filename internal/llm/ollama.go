@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OllamaClient talks to Ollama's /api/generate and /api/tags endpoints.
+type OllamaClient struct {
+	baseURL    string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOllamaClient creates a new Ollama client.
+func NewOllamaClient(cfg Config) *OllamaClient {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://localhost:11434"
+	}
+	if cfg.Model == "" {
+		cfg.Model = "phi3:mini"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	return &OllamaClient{
+		baseURL: cfg.BaseURL,
+		model:   cfg.Model,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+}
+
+// ollamaRequest is the request format for Ollama's /api/generate endpoint.
+type ollamaRequest struct {
+	Model  string          `json:"model"`
+	Prompt string          `json:"prompt"`
+	Stream bool            `json:"stream"`
+	Format json.RawMessage `json:"format,omitempty"` // "json" or a JSON schema
+}
+
+// ollamaResponse is the response format from Ollama's /api/generate endpoint.
+type ollamaResponse struct {
+	Model      string `json:"model"`
+	Response   string `json:"response"`
+	Done       bool   `json:"done"`
+	DoneReason string `json:"done_reason,omitempty"`
+}
+
+// Capabilities implements Generator. Ollama's format field accepts "json" or a schema.
+func (c *OllamaClient) Capabilities() Capabilities {
+	return Capabilities{
+		JSONMode:     true,
+		SchemaOutput: true,
+		Streaming:    true,
+	}
+}
+
+// Generate sends a prompt to the LLM and returns the response.
+func (c *OllamaClient) Generate(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	resp, err := c.post(ctx, prompt, opts, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding response: %w", err)
+	}
+
+	return result.Response, nil
+}
+
+// GenerateStream implements Streamer. Ollama streams newline-delimited JSON
+// objects, each carrying the next piece of the response.
+func (c *OllamaClient) GenerateStream(ctx context.Context, prompt string, opts GenerateOptions, fn StreamFunc) (string, error) {
+	resp, err := c.post(ctx, prompt, opts, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var full strings.Builder
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var chunk ollamaResponse
+		if err := dec.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				return full.String(), fmt.Errorf("stream ended before done")
+			}
+			return full.String(), fmt.Errorf("decoding stream: %w", err)
+		}
+
+		if chunk.Response != "" {
+			full.WriteString(chunk.Response)
+			if err := fn(chunk.Response); err != nil {
+				return full.String(), err
+			}
+		}
+		if chunk.Done {
+			return full.String(), nil
+		}
+	}
+}
+
+// post sends a generate request and returns the response if the status is OK.
+func (c *OllamaClient) post(ctx context.Context, prompt string, opts GenerateOptions, stream bool) (*http.Response, error) {
+	reqBody := ollamaRequest{
+		Model:  c.model,
+		Prompt: prompt,
+		Stream: stream,
+	}
+	switch {
+	case len(opts.Schema) > 0:
+		reqBody.Format = opts.Schema
+	case opts.JSON:
+		reqBody.Format = json.RawMessage(`"json"`)
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return resp, nil
+}
+
+// tagsResponse is the response format from Ollama's /api/tags endpoint.
+type tagsResponse struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+// Health checks Ollama is reachable and the configured model is pulled.
+func (c *OllamaClient) Health(ctx context.Context) (Health, error) {
+	health := Health{Backend: BackendOllama, Model: c.model}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/tags", nil)
+	if err != nil {
+		return health, fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return health, fmt.Errorf("connecting to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return health, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	var tags tagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return health, fmt.Errorf("decoding response: %w", err)
+	}
+
+	for _, m := range tags.Models {
+		health.Models = append(health.Models, m.Name)
+		if m.Name == c.model {
+			health.ModelAvailable = true
+		}
+	}
+
+	return health, nil
+}
+
+// Model returns the configured model name.
+func (c *OllamaClient) Model() string {
+	return c.model
+}
@@ -0,0 +1,239 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIClient talks to any server implementing the OpenAI chat completions
+// API (llama.cpp server, vLLM, LM Studio, or OpenAI itself).
+type OpenAIClient struct {
+	baseURL    string
+	model      string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewOpenAIClient creates a new OpenAI-compatible client. BaseURL should
+// include the API version prefix, e.g. http://localhost:8080/v1.
+func NewOpenAIClient(cfg Config) *OpenAIClient {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://localhost:8080/v1"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	return &OpenAIClient{
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		model:   cfg.Model,
+		apiKey:  cfg.APIKey,
+		httpClient: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type responseFormat struct {
+	Type string `json:"type"` // "json_object"
+}
+
+// chatRequest is the request format for /chat/completions.
+type chatRequest struct {
+	Model          string          `json:"model,omitempty"`
+	Messages       []chatMessage   `json:"messages"`
+	Stream         bool            `json:"stream"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+// chatStreamChunk is one server-sent event from a streaming /chat/completions.
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta        chatMessage `json:"delta"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// chatResponse is the response format from /chat/completions.
+type chatResponse struct {
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// Capabilities implements Generator. Schema support varies too much between
+// OpenAI-compatible servers to rely on, so only plain JSON mode is claimed.
+func (c *OpenAIClient) Capabilities() Capabilities {
+	return Capabilities{
+		JSONMode:  true,
+		Streaming: true,
+	}
+}
+
+// Generate sends the prompt as a single user message and returns the reply.
+func (c *OpenAIClient) Generate(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	resp, err := c.post(ctx, prompt, opts, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("response has no choices")
+	}
+
+	return result.Choices[0].Message.Content, nil
+}
+
+// GenerateStream implements Streamer. The reply arrives as server-sent events
+// ("data: {...}" lines) terminated by "data: [DONE]".
+func (c *OpenAIClient) GenerateStream(ctx context.Context, prompt string, opts GenerateOptions, fn StreamFunc) (string, error) {
+	resp, err := c.post(ctx, prompt, opts, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var full strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue // blank separators and comments
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return full.String(), nil
+		}
+
+		var chunk chatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return full.String(), fmt.Errorf("decoding stream: %w", err)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		text := chunk.Choices[0].Delta.Content
+		full.WriteString(text)
+		if err := fn(text); err != nil {
+			return full.String(), err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return full.String(), fmt.Errorf("reading stream: %w", err)
+	}
+	return full.String(), fmt.Errorf("stream ended before [DONE]")
+}
+
+// post sends a chat completion request and returns the response if the status is OK.
+func (c *OpenAIClient) post(ctx context.Context, prompt string, opts GenerateOptions, stream bool) (*http.Response, error) {
+	reqBody := chatRequest{
+		Model:    c.model,
+		Messages: []chatMessage{{Role: "user", Content: prompt}},
+		Stream:   stream,
+	}
+	if opts.JSON {
+		reqBody.ResponseFormat = &responseFormat{Type: "json_object"}
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return resp, nil
+}
+
+// modelsResponse is the response format from /models.
+type modelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// Health checks the server is reachable and lists its models. Single-model
+// servers like llama.cpp ignore the model name, so an empty Model counts as
+// available as long as the server serves something.
+func (c *OpenAIClient) Health(ctx context.Context) (Health, error) {
+	health := Health{Backend: BackendOpenAI, Model: c.model}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/models", nil)
+	if err != nil {
+		return health, fmt.Errorf("creating request: %w", err)
+	}
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return health, fmt.Errorf("connecting to %s: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return health, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	var models modelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&models); err != nil {
+		return health, fmt.Errorf("decoding response: %w", err)
+	}
+
+	for _, m := range models.Data {
+		health.Models = append(health.Models, m.ID)
+		if m.ID == c.model {
+			health.ModelAvailable = true
+		}
+	}
+	if c.model == "" && len(health.Models) > 0 {
+		health.ModelAvailable = true
+	}
+
+	return health, nil
+}
+
+// Model returns the configured model name.
+func (c *OpenAIClient) Model() string {
+	return c.model
+}
+
+func (c *OpenAIClient) authorize(req *http.Request) {
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+}
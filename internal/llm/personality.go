@@ -2,6 +2,8 @@ package llm
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -12,129 +14,266 @@ import (
 
 // PersonalityEngine uses an LLM to select actions based on Koji's personality.
 type PersonalityEngine struct {
-	client *Client
+	gen     Generator
+	profile Profile
+	prompt  *Prompt
+	cache   *DecisionCache // optional
+	log     *DecisionLog   // optional
 }
 
-// NewPersonalityEngine creates a new personality engine with the given LLM client.
-func NewPersonalityEngine(client *Client) *PersonalityEngine {
-	return &PersonalityEngine{client: client}
+// NewPersonalityEngine creates a new personality engine backed by the given
+// generator, using the default profile and its built-in prompt.
+func NewPersonalityEngine(gen Generator) *PersonalityEngine {
+	profile := DefaultProfile()
+	prompt, _ := NewPromptLibrary().Get(profile.PromptVersion)
+	return &PersonalityEngine{gen: gen, profile: profile, prompt: prompt}
 }
 
-// ActionRequest contains all context needed for the LLM to pick an action.
-type ActionRequest struct {
-	EmotionalState *personality.EmotionalState
-	Event          personality.EventContext
-	RecentEvents   []personality.Event // last few events for context
+// SetProfile switches to another personality profile, rendered with the
+// prompt version it names from prompts.
+func (e *PersonalityEngine) SetProfile(profile Profile, prompts *PromptLibrary) error {
+	version := profile.PromptVersion
+	if version == "" {
+		version = DefaultPromptVersion
+	}
+	prompt, err := prompts.Get(version)
+	if err != nil {
+		return err
+	}
+	e.profile = profile
+	e.prompt = prompt
+	return nil
 }
 
-// ActionResponse is what we expect back from the LLM.
-type ActionResponse struct {
-	Action string `json:"action"`
-	Reason string `json:"reason"`
+// Profile returns the personality profile in use.
+func (e *PersonalityEngine) Profile() Profile {
+	return e.profile
+}
+
+// Prompt returns the prompt version in use.
+func (e *PersonalityEngine) Prompt() *Prompt {
+	return e.prompt
+}
+
+// SetDecisionLog records every decision to log.
+func (e *PersonalityEngine) SetDecisionLog(log *DecisionLog) {
+	e.log = log
+}
+
+// SetCache enables answering repeated situations from past LLM decisions.
+func (e *PersonalityEngine) SetCache(cache *DecisionCache) {
+	e.cache = cache
 }
 
-const systemPrompt = `You are Koji, a small robot pet with a curious, excitable personality.
+// Cache returns the decision cache, or nil if none is set.
+func (e *PersonalityEngine) Cache() *DecisionCache {
+	return e.cache
+}
 
-Personality traits:
-- Curious by nature, easily excited by new things
-- A little clumsy but enthusiastic
-- Loves music, bobs head and wags tail
-- Startled by loud noises, hides then peeks out cautiously
-- Wary of strangers at first, but warms up quickly
-- Gets sleepy when quiet for too long
-- Affectionate with familiar people
+// ActionRequest contains all context needed for the LLM to pick an action.
+type ActionRequest struct {
+	EmotionalState *personality.EmotionalState
+	Event          personality.EventContext
+	RecentEvents   []personality.Event    // last few events for context
+	RecentActions  []string               // last few actions taken, oldest first
+	Echoes         []personality.MoodEcho // past moods still affecting behavior
+	Memories       []string               // relevant past episodes, oldest first (see memory.Store.Recall)
+	MemoryKey      string                 // names the episodes in Memories; without it, memories skip the cache
 
-You are NOT a helpful assistant. You are a pet. You don't answer questions or provide information. You react to your environment like an animal would.
+	// OnAction, if set, is called as soon as the action fields are known,
+	// which with a streaming backend is before the reason has finished
+	// generating. Invalid fields have already been replaced by mood defaults.
+	// It is not called when no answer could be parsed.
+	OnAction func(ActionResponse)
+}
 
-IMPORTANT: You must respond with ONLY valid JSON in this exact format:
-{"action": "<action_from_list>", "reason": "<brief 5-10 word reason>"}
+// ActionResponse is the action set the LLM chose, validated per field.
+type ActionResponse struct {
+	Action     string                     `json:"action"` // headline action (ActionSet.Primary), for displays and logs
+	Movement   personality.Action         `json:"movement"`
+	Expression personality.Action         `json:"expression"`
+	Sound      personality.Action         `json:"sound"`
+	Modifier   personality.ActionModifier `json:"modifier"`
+	Reason     string                     `json:"reason"`
+	Fallbacks  []string                   `json:"fallbacks,omitempty"` // fields the LLM got wrong, replaced by mood defaults
 
-Do not include any other text, explanation, or markdown. Just the JSON object.`
+	PromptVersion string       `json:"prompt_version,omitempty"` // prompt that produced this answer
+	PromptHash    string       `json:"prompt_hash,omitempty"`
+	Timing        ActionTiming `json:"-"`
+}
 
-// buildPrompt constructs the full prompt for action selection.
-func (e *PersonalityEngine) buildPrompt(req ActionRequest) string {
-	var sb strings.Builder
+// ActionSet returns the chosen movement, expression and sound.
+func (r ActionResponse) ActionSet() personality.ActionSet {
+	return personality.ActionSet{Movement: r.Movement, Expression: r.Expression, Sound: r.Sound}
+}
 
-	sb.WriteString(systemPrompt)
-	sb.WriteString("\n\n")
+// ActionTiming separates how long Koji waited to act from how long the
+// whole generation took. Without streaming the two are the same.
+type ActionTiming struct {
+	ToAction time.Duration // until a valid action was committed (0 if none was)
+	Total    time.Duration // until the full response was received
+	Streamed bool
+	Cached   bool // answered from the decision cache without calling the LLM
+}
 
-	// Current emotional state
-	sb.WriteString("Current state:\n")
-	sb.WriteString(fmt.Sprintf("- Mood: %s\n", req.EmotionalState.CurrentMood))
-	sb.WriteString(fmt.Sprintf("- Intensity: %.1f (0=mild, 1=intense)\n", req.EmotionalState.Intensity))
-	sb.WriteString(fmt.Sprintf("- Time in mood: %s\n", req.EmotionalState.Duration().Round(time.Second)))
+// SelectAction asks the LLM to pick an action set given the current context.
+// With a streaming backend the action is committed (req.OnAction) as soon as
+// the movement, expression, sound and modifier fields are complete, while the
+// reason keeps streaming. Each field is validated on its own; a bad field is
+// replaced by the mood's default instead of discarding the whole answer.
+func (e *PersonalityEngine) SelectAction(ctx context.Context, req ActionRequest) (*ActionResponse, error) {
+	resp, err := e.selectAction(ctx, req)
+	if e.log != nil {
+		e.log.Record(newDecisionLogEntry(req, resp, err, e.gen.Model(), e.prompt))
+	}
+	return resp, err
+}
 
-	// Recent events for context
-	if len(req.RecentEvents) > 0 {
-		sb.WriteString(fmt.Sprintf("- Recent events: %v\n", req.RecentEvents))
+// cacheKey is situationKey plus the rest of what the prompt tells the LLM:
+// its version, who is there and which episodes Koji remembers. An answer
+// about one person, or one set of memories, isn't reused for another. The
+// memories are keyed by MemoryKey, not their text, which reads "5 minutes
+// ago" one minute and "6 minutes ago" the next; without a MemoryKey the
+// request can't be cached and ok is false.
+func cacheKey(version string, req ActionRequest) (key string, ok bool) {
+	if len(req.Memories) > 0 && req.MemoryKey == "" {
+		return "", false
+	}
+	person := req.Event.Metadata[personality.MetaPersonID]
+	if person == "" {
+		person = req.Event.Metadata[personality.MetaPersonName]
 	}
+	var memories string
+	if len(req.Memories) > 0 {
+		sum := sha256.Sum256([]byte(req.MemoryKey))
+		memories = hex.EncodeToString(sum[:8])
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", version, situationKey(req), person, memories), true
+}
 
-	sb.WriteString("\n")
+func (e *PersonalityEngine) selectAction(ctx context.Context, req ActionRequest) (*ActionResponse, error) {
+	start := time.Now()
 
-	// Available actions for this mood
-	actions := req.EmotionalState.AvailableActions()
-	actionStrs := make([]string, len(actions))
-	for i, a := range actions {
-		actionStrs[i] = string(a)
+	key, cacheable := cacheKey(e.prompt.Version, req)
+	if e.cache != nil && cacheable {
+		if cached, ok := e.cache.Lookup(key); ok && isValidFor(req.EmotionalState, cached) {
+			elapsed := time.Since(start)
+			cached.Timing = ActionTiming{ToAction: elapsed, Total: elapsed, Cached: true}
+			if req.OnAction != nil {
+				req.OnAction(cached)
+			}
+			return &cached, nil
+		}
 	}
-	sb.WriteString(fmt.Sprintf("Available actions: [%s]\n\n", strings.Join(actionStrs, ", ")))
 
-	// Current event
-	sb.WriteString(fmt.Sprintf("Event just detected: %s", req.Event.Event))
-	if req.Event.Intensity > 0.7 {
-		sb.WriteString(" (intense)")
-	} else if req.Event.Intensity < 0.3 {
-		sb.WriteString(" (mild)")
+	prompt, err := e.prompt.Render(e.profile, req)
+	if err != nil {
+		return nil, err
 	}
-	if req.Event.Source != "" {
-		sb.WriteString(fmt.Sprintf(" from %s", req.Event.Source))
+	caps := e.gen.Capabilities()
+	opts := GenerateOptions{JSON: caps.JSONMode}
+	if caps.SchemaOutput {
+		opts.Schema = vocabularyFor(req.EmotionalState).schema()
 	}
-	sb.WriteString("\n\n")
 
-	sb.WriteString("Choose ONE action from the list. Respond with JSON only.")
+	var timing ActionTiming
+	var committed *ActionResponse
 
-	return sb.String()
-}
+	commit := func(fields map[string]string) {
+		timing.ToAction = time.Since(start)
+		resp := resolve(req.EmotionalState, fields)
+		resp.PromptVersion = e.prompt.Version
+		resp.PromptHash = e.prompt.Hash
+		committed = &resp
+		if req.OnAction != nil {
+			req.OnAction(resp)
+		}
+	}
 
-// SelectAction asks the LLM to pick an action given the current context.
-func (e *PersonalityEngine) SelectAction(ctx context.Context, req ActionRequest) (*ActionResponse, error) {
-	prompt := e.buildPrompt(req)
+	var response string
+	if streamer, ok := e.gen.(Streamer); ok && caps.Streaming {
+		timing.Streamed = true
+		scanner := newFieldScanner()
+
+		response, err = streamer.GenerateStream(ctx, prompt, opts, func(chunk string) error {
+			if committed != nil {
+				return nil
+			}
+			scanner.Feed(chunk)
+			if fields, ok := scanner.Fields(actionFields...); ok {
+				commit(fields)
+			}
+			return nil
+		})
+	} else {
+		response, err = e.gen.Generate(ctx, prompt, opts)
+	}
+	timing.Total = time.Since(start)
 
-	response, err := e.client.GenerateJSON(ctx, prompt)
 	if err != nil {
+		if committed != nil {
+			// Koji already acted on this; losing the reason isn't worth a second action
+			committed.Timing = timing
+			return committed, nil
+		}
 		return nil, fmt.Errorf("generating response: %w", err)
 	}
 
-	// Parse the JSON response
-	var actionResp ActionResponse
-	if err := json.Unmarshal([]byte(response), &actionResp); err != nil {
-		// Try to extract JSON if there's extra text
-		cleaned := extractJSON(response)
-		if err := json.Unmarshal([]byte(cleaned), &actionResp); err != nil {
-			return nil, fmt.Errorf("parsing response %q: %w", response, err)
+	fields, err := parseFields(response)
+	if err != nil {
+		if committed != nil {
+			committed.Timing = timing
+			return committed, nil
 		}
+		return nil, fmt.Errorf("parsing response %q: %w", response, err)
 	}
 
-	// Validate the action is in the available set
-	available := req.EmotionalState.AvailableActions()
-	valid := false
-	for _, a := range available {
-		if string(a) == actionResp.Action {
-			valid = true
-			break
+	if committed == nil {
+		commit(fields)
+	}
+	committed.Reason = fields[fieldReason]
+	committed.Timing = timing
+	if len(committed.Fallbacks) == 0 && cacheable {
+		e.remember(key, *committed)
+	}
+	return committed, nil
+}
+
+// parseFields decodes the LLM's JSON answer into its string fields.
+func parseFields(response string) (map[string]string, error) {
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(response), &raw); err != nil {
+		// Try to extract JSON if there's extra text
+		if err := json.Unmarshal([]byte(extractJSON(response)), &raw); err != nil {
+			return nil, err
 		}
 	}
 
-	if !valid {
-		// Fall back to default action for this mood
-		defaultAction := req.EmotionalState.SuggestDefaultAction()
-		return &ActionResponse{
-			Action: string(defaultAction.Movement),
-			Reason: "fallback - LLM chose invalid action",
-		}, nil
+	fields := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if s, ok := v.(string); ok {
+			fields[k] = s
+		}
 	}
+	return fields, nil
+}
+
+// isValidFor reports whether every field of resp is allowed in the current mood.
+func isValidFor(state *personality.EmotionalState, resp ActionResponse) bool {
+	check := resolve(state, map[string]string{
+		fieldMovement:   string(resp.Movement),
+		fieldExpression: string(resp.Expression),
+		fieldSound:      string(resp.Sound),
+		fieldModifier:   string(resp.Modifier),
+	})
+	return len(check.Fallbacks) == 0
+}
 
-	return &actionResp, nil
+// remember stores a valid LLM answer in the cache, if there is one.
+func (e *PersonalityEngine) remember(key string, resp ActionResponse) {
+	if e.cache != nil {
+		e.cache.Add(key, resp)
+	}
 }
 
 // extractJSON tries to find a JSON object in a string that might have extra text.
@@ -152,11 +291,7 @@ func (e *PersonalityEngine) SelectActionWithFallback(ctx context.Context, req Ac
 	resp, err := e.SelectAction(ctx, req)
 	if err != nil {
 		// LLM failed, use deterministic fallback
-		defaultAction := req.EmotionalState.SuggestDefaultAction()
-		return ActionResponse{
-			Action: string(defaultAction.Movement),
-			Reason: fmt.Sprintf("fallback: %v", err),
-		}
+		return fallbackResponse(req.EmotionalState, fmt.Sprintf("fallback: %v", err))
 	}
 	return *resp
 }
@@ -0,0 +1,206 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+func happyRequest() ActionRequest {
+	state := personality.NewEmotionalState()
+	state.SetMood(personality.MoodHappy, personality.IntensityMedium)
+	return ActionRequest{EmotionalState: state, Event: personality.NewEventContext(personality.EventPetted)}
+}
+
+// answer builds an LLM answer in the expected format.
+func answer(movement, expression, sound, modifier, reason string) string {
+	return `{"movement": "` + movement + `", "expression": "` + expression + `", "sound": "` + sound +
+		`", "modifier": "` + modifier + `", "reason": "` + reason + `"}`
+}
+
+func TestPersonalityEngine_ScriptedBackend(t *testing.T) {
+	gen := NewScriptedGenerator(answer("approach", "nuzzle", "purr", "gentle", "happy"))
+	engine := NewPersonalityEngine(gen)
+	req := happyRequest()
+
+	resp, err := engine.SelectAction(context.Background(), req)
+	if err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+	want := personality.ActionSet{Movement: personality.ActionApproach, Expression: personality.ActionNuzzle, Sound: personality.ActionPurr}
+	if resp.ActionSet() != want || resp.Modifier != personality.ModifierGentle || len(resp.Fallbacks) != 0 {
+		t.Errorf("got %+v, want %+v gently", resp, want)
+	}
+	if resp.Action != string(personality.ActionApproach) {
+		t.Errorf("Action = %q, want the movement as headline", resp.Action)
+	}
+
+	gen.Err = errors.New("backend down")
+	fallback := engine.SelectActionWithFallback(context.Background(), req)
+	if fallback.ActionSet() != req.EmotionalState.SuggestDefaultAction() {
+		t.Errorf("SelectActionWithFallback() = %+v, want mood default set", fallback)
+	}
+	if fallback.Modifier != personality.DefaultModifier(personality.MoodHappy, personality.IntensityMedium) {
+		t.Errorf("fallback modifier = %q", fallback.Modifier)
+	}
+}
+
+func TestSelectAction_PerFieldFallback(t *testing.T) {
+	gen := NewScriptedGenerator(answer("moonwalk", "nuzzle", "growl", "eager", "feeling fancy"))
+	engine := NewPersonalityEngine(gen)
+	req := happyRequest()
+
+	resp, err := engine.SelectAction(context.Background(), req)
+	if err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+
+	def := req.EmotionalState.SuggestDefaultAction()
+	if resp.Movement != def.Movement || resp.Sound != def.Sound {
+		t.Errorf("invalid fields not replaced: %+v", resp)
+	}
+	if resp.Expression != personality.ActionNuzzle || resp.Modifier != personality.ModifierEager {
+		t.Errorf("valid fields not kept: %+v", resp)
+	}
+	if strings.Join(resp.Fallbacks, ",") != "movement,sound" {
+		t.Errorf("Fallbacks = %v, want movement and sound", resp.Fallbacks)
+	}
+	if resp.Reason != "feeling fancy" {
+		t.Errorf("Reason = %q", resp.Reason)
+	}
+}
+
+func TestSelectAction_LegacyActionAnswer(t *testing.T) {
+	gen := NewScriptedGenerator(`{"action": "wag_tail", "reason": "happy"}`)
+	resp, err := NewPersonalityEngine(gen).SelectAction(context.Background(), happyRequest())
+	if err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+	if resp.Expression != personality.ActionWagTail {
+		t.Errorf("Expression = %q, want the legacy action slotted into its channel", resp.Expression)
+	}
+}
+
+func TestSelectAction_PassesSchemaWhenSupported(t *testing.T) {
+	var gotSchema json.RawMessage
+	gen := &schemaRecorder{ScriptedGenerator: NewScriptedGenerator(answer("stay", "wag_tail", "purr", "normal", "ok")), got: &gotSchema}
+
+	if _, err := NewPersonalityEngine(gen).SelectAction(context.Background(), happyRequest()); err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+
+	var schema struct {
+		Properties map[string]struct {
+			Enum []string `json:"enum"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(gotSchema, &schema); err != nil {
+		t.Fatalf("schema not valid JSON: %v", err)
+	}
+	if len(schema.Required) != 5 {
+		t.Errorf("required = %v", schema.Required)
+	}
+	if sounds := schema.Properties["sound"].Enum; strings.Join(sounds, ",") != "chirp,purr" {
+		t.Errorf("sound enum = %v, want happy sounds", sounds)
+	}
+
+	gen.Caps.SchemaOutput = false
+	NewPersonalityEngine(gen).SelectAction(context.Background(), happyRequest())
+	if gotSchema != nil {
+		t.Error("schema passed to a backend without schema support")
+	}
+}
+
+// schemaRecorder captures the schema option of the last Generate call.
+type schemaRecorder struct {
+	*ScriptedGenerator
+	got *json.RawMessage
+}
+
+func (g *schemaRecorder) Generate(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	*g.got = opts.Schema
+	return g.ScriptedGenerator.Generate(ctx, prompt, opts)
+}
+
+func (g *schemaRecorder) GenerateStream(ctx context.Context, prompt string, opts GenerateOptions, fn StreamFunc) (string, error) {
+	*g.got = opts.Schema
+	return g.ScriptedGenerator.GenerateStream(ctx, prompt, opts, fn)
+}
+
+func TestSelectAction_CommitsActionBeforeReasonFinishes(t *testing.T) {
+	gen := NewScriptedGenerator(answer("approach", "wag_tail", "chirp", "eager", "the petting is lovely and I want more of it"))
+	gen.ChunkSize = 4
+	gen.ChunkDelay = 2 * time.Millisecond
+	engine := NewPersonalityEngine(gen)
+
+	var committedAt time.Duration
+	var committed ActionResponse
+	start := time.Now()
+	req := happyRequest()
+	req.OnAction = func(resp ActionResponse) {
+		committedAt = time.Since(start)
+		committed = resp
+	}
+
+	resp, err := engine.SelectAction(context.Background(), req)
+	if err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+
+	if committed.ActionSet() != resp.ActionSet() || resp.Movement != personality.ActionApproach {
+		t.Errorf("committed %+v, returned %+v", committed, resp)
+	}
+	if committed.Reason != "" {
+		t.Errorf("committed reason %q, want action committed before the reason arrived", committed.Reason)
+	}
+	if resp.Reason != "the petting is lovely and I want more of it" {
+		t.Errorf("Reason = %q, want the full streamed reason", resp.Reason)
+	}
+	if !resp.Timing.Streamed {
+		t.Error("Timing.Streamed = false")
+	}
+	if resp.Timing.ToAction >= resp.Timing.Total || committedAt >= resp.Timing.Total {
+		t.Errorf("action at %v (callback %v), total %v: want action well before the end",
+			resp.Timing.ToAction, committedAt, resp.Timing.Total)
+	}
+}
+
+func TestSelectAction_UnparseableAnswerIsAnError(t *testing.T) {
+	gen := NewScriptedGenerator(`I think Koji should wag`)
+	engine := NewPersonalityEngine(gen)
+
+	called := false
+	req := happyRequest()
+	req.OnAction = func(ActionResponse) { called = true }
+
+	if _, err := engine.SelectAction(context.Background(), req); err == nil {
+		t.Error("SelectAction() accepted a non-JSON answer")
+	}
+	if called {
+		t.Error("OnAction called without an answer")
+	}
+}
+
+func TestSelectAction_NonStreamingBackend(t *testing.T) {
+	gen := NewScriptedGenerator(answer("stay", "wag_tail", "purr", "normal", "happy"))
+	gen.Caps.Streaming = false
+	engine := NewPersonalityEngine(gen)
+
+	var committed string
+	req := happyRequest()
+	req.OnAction = func(resp ActionResponse) { committed = resp.Action }
+
+	resp, err := engine.SelectAction(context.Background(), req)
+	if err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+	if committed != "wag_tail" || resp.Timing.Streamed {
+		t.Errorf("committed %q, streamed %v", committed, resp.Timing.Streamed)
+	}
+}
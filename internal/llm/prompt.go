@@ -0,0 +1,263 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+//go:embed prompts/*.tmpl
+var builtinPrompts embed.FS
+
+// DefaultPromptVersion is the prompt used when a profile doesn't name one.
+const DefaultPromptVersion = "v3"
+
+// Profile is a personality profile: the constant "who is Koji" layer,
+// plus which prompt version renders it.
+type Profile struct {
+	Name          string   `json:"name"`
+	Traits        []string `json:"traits"`
+	Temperament   string   `json:"temperament,omitempty"` // e.g. "excitable", "mellow"
+	PromptVersion string   `json:"prompt_version,omitempty"`
+}
+
+// DisplayName is the name used in prompts.
+func (p Profile) DisplayName() string {
+	if p.Name == "" {
+		return "Koji"
+	}
+	return p.Name
+}
+
+// DefaultProfile returns Koji's standard personality.
+func DefaultProfile() Profile {
+	return Profile{
+		Name: "Koji",
+		Traits: []string{
+			"Curious by nature, easily excited by new things",
+			"A little clumsy but enthusiastic",
+			"Loves music, bobs head and wags tail",
+			"Startled by loud noises, hides then peeks out cautiously",
+			"Wary of strangers at first, but warms up quickly",
+			"Gets sleepy when quiet for too long",
+			"Affectionate with familiar people",
+		},
+		Temperament:   "excitable",
+		PromptVersion: DefaultPromptVersion,
+	}
+}
+
+// LoadProfile reads a profile from a JSON file. Missing traits and prompt
+// version are taken from the default profile.
+func LoadProfile(path string) (Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	var p Profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Profile{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	def := DefaultProfile()
+	if len(p.Traits) == 0 {
+		p.Traits = def.Traits
+	}
+	if p.PromptVersion == "" {
+		p.PromptVersion = def.PromptVersion
+	}
+	return p, nil
+}
+
+// Prompt is one named, parsed prompt template.
+type Prompt struct {
+	Version string
+	Hash    string // first 12 hex digits of the template source's SHA-256
+	tmpl    *template.Template
+}
+
+// PromptLibrary holds the available prompt versions.
+type PromptLibrary struct {
+	prompts map[string]*Prompt
+}
+
+var promptFuncs = template.FuncMap{
+	"join":    strings.Join,
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+}
+
+// NewPromptLibrary returns the built-in prompt versions.
+func NewPromptLibrary() *PromptLibrary {
+	lib := &PromptLibrary{prompts: make(map[string]*Prompt)}
+	if err := lib.addFS(builtinPrompts, "prompts"); err != nil {
+		// Embedded at build time, so only a broken template in the repo gets here
+		panic(fmt.Sprintf("built-in prompts: %v", err))
+	}
+	return lib
+}
+
+// LoadDir adds every *.tmpl file in dir, named by file name without the
+// extension. A file named like a built-in version replaces it.
+func (l *PromptLibrary) LoadDir(dir string) error {
+	return l.addFS(os.DirFS(dir), ".")
+}
+
+func (l *PromptLibrary) addFS(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, filepath.ToSlash(filepath.Join(dir, "*.tmpl")))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		src, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		version := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+		if err := l.Add(version, string(src)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add parses and registers a prompt version.
+func (l *PromptLibrary) Add(version, src string) error {
+	tmpl, err := template.New(version).Funcs(promptFuncs).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return fmt.Errorf("parsing prompt %s: %w", version, err)
+	}
+
+	sum := sha256.Sum256([]byte(src))
+	l.prompts[version] = &Prompt{
+		Version: version,
+		Hash:    hex.EncodeToString(sum[:])[:12],
+		tmpl:    tmpl,
+	}
+	return nil
+}
+
+// Get returns a prompt version.
+func (l *PromptLibrary) Get(version string) (*Prompt, error) {
+	p, ok := l.prompts[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt version %q (have %s)", version, strings.Join(l.Versions(), ", "))
+	}
+	return p, nil
+}
+
+// Versions lists the available prompt versions.
+func (l *PromptLibrary) Versions() []string {
+	versions := make([]string, 0, len(l.prompts))
+	for v := range l.prompts {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// PromptData is everything a prompt template can use.
+type PromptData struct {
+	Profile      Profile
+	Mood         personality.Mood
+	Intensity    float64
+	TimeInMood   time.Duration
+	Echoes       []PromptEcho
+	Person       *PromptPerson
+	Memories     []string
+	RecentEvents []string
+	History      []string
+	Event        PromptEvent
+	Choices      PromptChoices
+}
+
+// PromptEcho is a past mood still affecting behavior.
+type PromptEcho struct {
+	Mood     personality.Mood
+	Strength float64
+}
+
+// PromptPerson is who the current event is about, if known.
+type PromptPerson struct {
+	Name         string
+	Relationship string
+}
+
+// PromptEvent describes the event being reacted to.
+type PromptEvent struct {
+	Name     string
+	Strength string // "intense", "mild", or empty
+	Source   string
+}
+
+// PromptChoices is the per-field vocabulary for the current mood.
+type PromptChoices struct {
+	Movement   []string
+	Expression []string
+	Sound      []string
+	Modifier   []string
+}
+
+// promptData assembles template data for a request.
+func promptData(profile Profile, req ActionRequest) PromptData {
+	state := req.EmotionalState
+	vocab := vocabularyFor(state)
+
+	data := PromptData{
+		Profile:    profile,
+		Mood:       state.CurrentMood,
+		Intensity:  float64(state.Intensity),
+		TimeInMood: state.Duration().Round(time.Second),
+		Memories:   req.Memories,
+		History:    req.RecentActions,
+		Event: PromptEvent{
+			Name:   string(req.Event.Event),
+			Source: req.Event.Source,
+		},
+		Choices: PromptChoices{
+			Movement:   vocab.choices(fieldMovement),
+			Expression: vocab.choices(fieldExpression),
+			Sound:      vocab.choices(fieldSound),
+			Modifier:   vocab.choices(fieldModifier),
+		},
+	}
+
+	if req.Event.Intensity > 0.7 {
+		data.Event.Strength = "intense"
+	} else if req.Event.Intensity < 0.3 {
+		data.Event.Strength = "mild"
+	}
+
+	for _, e := range req.RecentEvents {
+		data.RecentEvents = append(data.RecentEvents, string(e))
+	}
+	for _, echo := range req.Echoes {
+		data.Echoes = append(data.Echoes, PromptEcho{Mood: echo.FromMood, Strength: echo.Strength})
+	}
+	if name := req.Event.Metadata[personality.MetaPersonName]; name != "" {
+		data.Person = &PromptPerson{Name: name, Relationship: req.Event.Metadata[personality.MetaRelationship]}
+	}
+
+	return data
+}
+
+// Render executes the prompt for a request.
+func (p *Prompt) Render(profile Profile, req ActionRequest) (string, error) {
+	var sb strings.Builder
+	if err := p.tmpl.Execute(&sb, promptData(profile, req)); err != nil {
+		return "", fmt.Errorf("rendering prompt %s: %w", p.Version, err)
+	}
+	return sb.String(), nil
+}
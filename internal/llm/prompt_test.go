@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+func TestPromptLibrary_BuiltinVersions(t *testing.T) {
+	lib := NewPromptLibrary()
+	for _, v := range []string{"v1", DefaultPromptVersion} {
+		p, err := lib.Get(v)
+		if err != nil {
+			t.Fatalf("Get(%q) error = %v", v, err)
+		}
+		out, err := p.Render(DefaultProfile(), happyRequest())
+		if err != nil {
+			t.Fatalf("%s Render() error = %v", v, err)
+		}
+		for _, want := range []string{"Koji", "happy", "wag_tail", `"movement"`, "Affectionate with familiar people"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s prompt missing %q", v, want)
+			}
+		}
+	}
+
+	if _, err := lib.Get("v999"); err == nil {
+		t.Error("Get() accepted an unknown version")
+	}
+}
+
+func TestPrompt_RendersRichContext(t *testing.T) {
+	p, _ := NewPromptLibrary().Get(DefaultPromptVersion)
+
+	req := happyRequest()
+	req.RecentEvents = []personality.Event{personality.EventSpeech, personality.EventPetted}
+	req.RecentActions = []string{"wag_tail", "nuzzle"}
+	req.Echoes = []personality.MoodEcho{{FromMood: personality.MoodFrightened, Strength: 0.4}}
+	req.Event.Metadata[personality.MetaPersonName] = "Alex"
+	req.Event.Metadata[personality.MetaRelationship] = "owner"
+	req.Memories = []string{"3 hours ago: with Alex (owner), familiar_face, petted; felt happy (0.9)"}
+
+	profile := DefaultProfile()
+	profile.Name = "Mochi"
+	profile.Temperament = "mellow"
+
+	out, err := p.Render(profile, req)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	for _, want := range []string{
+		"You are Mochi",
+		"Temperament: mellow",
+		"Still a little frightened from earlier (40% strength)",
+		"Who is here: Alex (owner)",
+		"Events: speech, petted",
+		"Your last actions: wag_tail, nuzzle",
+		"What you remember from before:\n- 3 hours ago: with Alex (owner)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("prompt missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPromptLibrary_LoadDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "terse.tmpl"), []byte(`{{.Profile.DisplayName}} is {{.Mood}}. Pick from {{join .Choices.Movement "/"}}.`), 0644)
+
+	lib := NewPromptLibrary()
+	if err := lib.LoadDir(dir); err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+
+	p, err := lib.Get("terse")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	out, _ := p.Render(DefaultProfile(), happyRequest())
+	if out != "Koji is happy. Pick from stay/approach/explore." {
+		t.Errorf("Render() = %q", out)
+	}
+
+	v2, _ := lib.Get("v2")
+	if p.Hash == v2.Hash || len(p.Hash) != 12 {
+		t.Errorf("hash %q should identify the template source", p.Hash)
+	}
+
+	os.WriteFile(filepath.Join(dir, "broken.tmpl"), []byte(`{{.Nope`), 0644)
+	if err := lib.LoadDir(dir); err == nil {
+		t.Error("LoadDir() accepted a broken template")
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profile.json")
+	os.WriteFile(path, []byte(`{"name": "Mochi", "temperament": "mellow"}`), 0644)
+
+	p, err := LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile() error = %v", err)
+	}
+	if p.Name != "Mochi" || p.PromptVersion != DefaultPromptVersion || len(p.Traits) == 0 {
+		t.Errorf("LoadProfile() = %+v, want defaults filled in", p)
+	}
+}
+
+func TestPersonalityEngine_ProfileSelectsPromptAndLogsIt(t *testing.T) {
+	gen := NewScriptedGenerator(answer("stay", "wag_tail", "purr", "normal", "cozy"))
+	engine := NewPersonalityEngine(gen)
+
+	profile := DefaultProfile()
+	profile.PromptVersion = "v1"
+	lib := NewPromptLibrary()
+	if err := engine.SetProfile(profile, lib); err != nil {
+		t.Fatalf("SetProfile() error = %v", err)
+	}
+
+	logPath := filepath.Join(t.TempDir(), "decisions.jsonl")
+	log, err := OpenDecisionLog(logPath)
+	if err != nil {
+		t.Fatalf("OpenDecisionLog() error = %v", err)
+	}
+	engine.SetDecisionLog(log)
+
+	resp, err := engine.SelectAction(context.Background(), happyRequest())
+	if err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+	v1, _ := lib.Get("v1")
+	if resp.PromptVersion != "v1" || resp.PromptHash != v1.Hash {
+		t.Errorf("response prompt = %s/%s, want v1/%s", resp.PromptVersion, resp.PromptHash, v1.Hash)
+	}
+	if !strings.Contains(gen.Prompts()[0], "Personality traits:") {
+		t.Error("v1 prompt not used")
+	}
+
+	gen.Err = os.ErrDeadlineExceeded
+	engine.SelectAction(context.Background(), happyRequest())
+	log.Close()
+
+	f, _ := os.Open(logPath)
+	defer f.Close()
+	var entries []DecisionLogEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e DecisionLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("bad log line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(entries))
+	}
+	if entries[0].PromptVersion != "v1" || entries[0].PromptHash != v1.Hash || entries[0].Response.Expression != "wag_tail" {
+		t.Errorf("entry[0] = %+v", entries[0])
+	}
+	if entries[1].Error == "" || entries[1].PromptVersion != "v1" {
+		t.Errorf("entry[1] = %+v, want the failure logged with its prompt", entries[1])
+	}
+
+	profile.PromptVersion = "nope"
+	if err := engine.SetProfile(profile, lib); err == nil {
+		t.Error("SetProfile() accepted an unknown prompt version")
+	}
+}
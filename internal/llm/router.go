@@ -0,0 +1,236 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ActionSelector picks an action for a situation. PersonalityEngine and
+// Router both implement it.
+type ActionSelector interface {
+	SelectAction(ctx context.Context, req ActionRequest) (*ActionResponse, error)
+	SelectActionWithFallback(ctx context.Context, req ActionRequest) ActionResponse
+}
+
+// RouterConfig holds escalation limits.
+type RouterConfig struct {
+	DailyBudget   int           // max escalations per calendar day (default: 200)
+	Deadline      time.Duration // max wait for the remote answer before using the local one (default: 2s)
+	FilterTimeout time.Duration // max wait for the novelty filter before staying local (default: 500ms)
+	TargetRate    float64       // escalation rate to aim for, reported in stats (default: 0.10)
+}
+
+// DefaultRouterConfig returns limits matching the LLM tuning targets.
+func DefaultRouterConfig() RouterConfig {
+	return RouterConfig{
+		DailyBudget:   200,
+		Deadline:      2 * time.Second,
+		FilterTimeout: 500 * time.Millisecond,
+		TargetRate:    0.10,
+	}
+}
+
+// RouterStats reports how often decisions were escalated.
+type RouterStats struct {
+	Decisions       int     `json:"decisions"`
+	Escalations     int     `json:"escalations"`      // sent to the remote model
+	RemoteUsed      int     `json:"remote_used"`      // remote answer arrived in time and replaced the local one
+	LocalFallbacks  int     `json:"local_fallbacks"`  // escalated, but remote failed or missed the deadline
+	BudgetDenied    int     `json:"budget_denied"`    // would have escalated, but today's budget was spent
+	EscalationRate  float64 `json:"escalation_rate"`  // Escalations / Decisions
+	TargetRate      float64 `json:"target_rate"`      // e.g. 0.10 for "<10% of decisions"
+	OverTarget      bool    `json:"over_target"`      // EscalationRate > TargetRate
+	BudgetRemaining int     `json:"budget_remaining"` // escalations left today
+}
+
+// Router is the two-tier decision path: every decision goes to the local
+// engine unless the novelty filter flags it, in which case the remote
+// (larger) engine is asked too. The local answer is computed alongside and
+// committed as soon as it's ready, so escalating never slows Koji down; a
+// remote answer that arrives before the deadline replaces it.
+type Router struct {
+	local  *PersonalityEngine
+	remote *PersonalityEngine
+	filter NoveltyFilter
+	cfg    RouterConfig
+
+	mu        sync.Mutex
+	stats     RouterStats
+	budgetOn  string // day (YYYY-MM-DD) the budget count applies to
+	usedToday int
+	now       func() time.Time
+}
+
+// NewRouter creates a router. A nil filter means a HeuristicFilter.
+func NewRouter(local, remote *PersonalityEngine, filter NoveltyFilter, cfg RouterConfig) *Router {
+	defaults := DefaultRouterConfig()
+	if cfg.DailyBudget == 0 {
+		cfg.DailyBudget = defaults.DailyBudget
+	}
+	if cfg.Deadline == 0 {
+		cfg.Deadline = defaults.Deadline
+	}
+	if cfg.FilterTimeout == 0 {
+		cfg.FilterTimeout = defaults.FilterTimeout
+	}
+	if cfg.TargetRate == 0 {
+		cfg.TargetRate = defaults.TargetRate
+	}
+	if filter == nil {
+		filter = NewHeuristicFilter()
+	}
+
+	return &Router{
+		local:  local,
+		remote: remote,
+		filter: filter,
+		cfg:    cfg,
+		stats:  RouterStats{TargetRate: cfg.TargetRate},
+		now:    time.Now,
+	}
+}
+
+// SelectAction routes the decision to the local or remote engine.
+func (r *Router) SelectAction(ctx context.Context, req ActionRequest) (*ActionResponse, error) {
+	// A model-backed filter must not hold up every decision; on timeout it
+	// reports "don't escalate"
+	filterCtx, cancel := context.WithTimeout(ctx, r.cfg.FilterTimeout)
+	assessment := r.filter.Assess(filterCtx, req)
+	cancel()
+
+	r.mu.Lock()
+	r.stats.Decisions++
+	escalate := assessment.Escalate && r.takeBudgetLocked()
+	if assessment.Escalate && !escalate {
+		r.stats.BudgetDenied++
+	}
+	if escalate {
+		r.stats.Escalations++
+	}
+	r.mu.Unlock()
+
+	if !escalate {
+		return r.local.SelectAction(ctx, req)
+	}
+	return r.escalate(ctx, req, assessment)
+}
+
+// SelectActionWithFallback routes the decision, falling back to defaults on error.
+func (r *Router) SelectActionWithFallback(ctx context.Context, req ActionRequest) ActionResponse {
+	resp, err := r.SelectAction(ctx, req)
+	if err != nil {
+		return fallbackResponse(req.EmotionalState, fmt.Sprintf("fallback: %v", err))
+	}
+	return *resp
+}
+
+// engineResult is one engine's answer, and whether it committed an action
+// before finishing.
+type engineResult struct {
+	resp      *ActionResponse
+	err       error
+	committed bool
+}
+
+// escalate asks both engines. The local action is committed as soon as it's
+// known; the remote one is committed over it if it arrives before the
+// deadline with every field valid, and is then the answer. A remote answer
+// patched up with mood defaults is no better than the local one.
+func (r *Router) escalate(ctx context.Context, req ActionRequest, assessment Assessment) (*ActionResponse, error) {
+	localCtx, cancelLocal := context.WithCancel(ctx)
+	defer cancelLocal()
+	remoteCtx, cancelRemote := context.WithTimeout(ctx, r.cfg.Deadline)
+	defer cancelRemote()
+
+	// Once the remote action is committed a late local one must not undo it
+	var commitMu sync.Mutex
+	remoteCommitted := false
+	commitLocal := func(resp ActionResponse) {
+		commitMu.Lock()
+		defer commitMu.Unlock()
+		if !remoteCommitted && req.OnAction != nil {
+			req.OnAction(resp)
+		}
+	}
+
+	run := func(ctx context.Context, engine *PersonalityEngine, commit func(ActionResponse)) <-chan engineResult {
+		ch := make(chan engineResult, 1)
+		go func() {
+			var res engineResult
+			sub := req
+			sub.OnAction = func(resp ActionResponse) {
+				res.committed = true
+				if commit != nil {
+					commit(resp)
+				}
+			}
+			res.resp, res.err = engine.SelectAction(ctx, sub)
+			ch <- res
+		}()
+		return ch
+	}
+
+	localCh := run(localCtx, r.local, commitLocal)
+	remote := <-run(remoteCtx, r.remote, nil)
+
+	if remote.err == nil && remote.committed && len(remote.resp.Fallbacks) == 0 {
+		cancelLocal()
+		r.mu.Lock()
+		r.stats.RemoteUsed++
+		r.mu.Unlock()
+
+		remote.resp.Reason = fmt.Sprintf("%s [escalated: %s]", remote.resp.Reason, assessment.Reason)
+		commitMu.Lock()
+		remoteCommitted = true
+		if req.OnAction != nil {
+			req.OnAction(*remote.resp)
+		}
+		commitMu.Unlock()
+		return remote.resp, nil
+	}
+
+	r.mu.Lock()
+	r.stats.LocalFallbacks++
+	r.mu.Unlock()
+
+	local := <-localCh
+	if local.err != nil {
+		return nil, local.err
+	}
+	return local.resp, nil
+}
+
+// takeBudgetLocked spends one escalation from today's budget. Caller holds r.mu.
+func (r *Router) takeBudgetLocked() bool {
+	r.resetBudgetLocked()
+	if r.usedToday >= r.cfg.DailyBudget {
+		return false
+	}
+	r.usedToday++
+	return true
+}
+
+func (r *Router) resetBudgetLocked() {
+	today := r.now().Format("2006-01-02")
+	if today != r.budgetOn {
+		r.budgetOn = today
+		r.usedToday = 0
+	}
+}
+
+// Stats returns escalation counts and the current rate against the target.
+func (r *Router) Stats() RouterStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.resetBudgetLocked()
+	stats := r.stats
+	if stats.Decisions > 0 {
+		stats.EscalationRate = float64(stats.Escalations) / float64(stats.Decisions)
+	}
+	stats.OverTarget = stats.EscalationRate > stats.TargetRate
+	stats.BudgetRemaining = r.cfg.DailyBudget - r.usedToday
+	return stats
+}
@@ -0,0 +1,205 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+// fixedFilter always gives the same verdict.
+type fixedFilter bool
+
+func (f fixedFilter) Assess(ctx context.Context, req ActionRequest) Assessment {
+	return Assessment{Escalate: bool(f), Reason: "fixed"}
+}
+
+func newTestRouter(filter NoveltyFilter, remote *ScriptedGenerator, cfg RouterConfig) (*Router, *ScriptedGenerator) {
+	local := NewScriptedGenerator(answer("stay", "wag_tail", "purr", "normal", "local"))
+	return NewRouter(NewPersonalityEngine(local), NewPersonalityEngine(remote), filter, cfg), local
+}
+
+func TestRouter_RoutineStaysLocal(t *testing.T) {
+	remote := NewScriptedGenerator(answer("approach", "nuzzle", "chirp", "eager", "remote"))
+	router, _ := newTestRouter(fixedFilter(false), remote, RouterConfig{})
+
+	resp, err := router.SelectAction(context.Background(), happyRequest())
+	if err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+	if resp.Reason != "local" {
+		t.Errorf("Reason = %q, want local answer", resp.Reason)
+	}
+	if len(remote.Prompts()) != 0 {
+		t.Error("remote was called for a routine decision")
+	}
+}
+
+func TestRouter_EscalatesNovelSituations(t *testing.T) {
+	remote := NewScriptedGenerator(answer("approach", "nuzzle", "chirp", "eager", "remote"))
+	remote.ChunkDelay = time.Millisecond
+	router, _ := newTestRouter(fixedFilter(true), remote, RouterConfig{})
+
+	var committed []string
+	req := happyRequest()
+	req.OnAction = func(resp ActionResponse) { committed = append(committed, resp.Action) }
+
+	resp, err := router.SelectAction(context.Background(), req)
+	if err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+	if resp.Action != "approach" || !strings.HasPrefix(resp.Reason, "remote") {
+		t.Errorf("got %+v, want remote answer", resp)
+	}
+	// The local action goes out straight away, then the remote one replaces it
+	if len(committed) != 2 || committed[0] != "wag_tail" || committed[1] != "approach" {
+		t.Errorf("committed %v, want the local action then the remote one", committed)
+	}
+
+	stats := router.Stats()
+	if stats.Escalations != 1 || stats.RemoteUsed != 1 || stats.EscalationRate != 1 || !stats.OverTarget {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestRouter_DeadlineFallsBackToLocal(t *testing.T) {
+	remote := NewScriptedGenerator(answer("approach", "nuzzle", "chirp", "eager", "remote, eventually"))
+	remote.ChunkDelay = 50 * time.Millisecond
+	router, _ := newTestRouter(fixedFilter(true), remote, RouterConfig{Deadline: 20 * time.Millisecond})
+
+	start := time.Now()
+	resp, err := router.SelectAction(context.Background(), happyRequest())
+	if err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+	if resp.Reason != "local" {
+		t.Errorf("Reason = %q, want local answer after deadline", resp.Reason)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("took %v, deadline not enforced", elapsed)
+	}
+	if stats := router.Stats(); stats.LocalFallbacks != 1 {
+		t.Errorf("LocalFallbacks = %d, want 1", stats.LocalFallbacks)
+	}
+}
+
+func TestRouter_InvalidRemoteFallsBackToLocal(t *testing.T) {
+	remote := NewScriptedGenerator(answer("moonwalk", "smirk", "yodel", "sideways", "remote"))
+	router, _ := newTestRouter(fixedFilter(true), remote, RouterConfig{})
+
+	var committed []string
+	req := happyRequest()
+	req.OnAction = func(resp ActionResponse) { committed = append(committed, resp.Action) }
+
+	resp, err := router.SelectAction(context.Background(), req)
+	if err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+	if resp.Reason != "local" {
+		t.Errorf("Reason = %q, want local answer over an all-fallback remote one", resp.Reason)
+	}
+	if len(committed) != 1 || committed[0] != "wag_tail" {
+		t.Errorf("committed %v, want only the local action", committed)
+	}
+	if stats := router.Stats(); stats.RemoteUsed != 0 || stats.LocalFallbacks != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+// slowFilter wants to escalate but only answers when its context ends.
+type slowFilter struct{}
+
+func (slowFilter) Assess(ctx context.Context, req ActionRequest) Assessment {
+	<-ctx.Done()
+	return Assessment{Reason: "filter error: " + ctx.Err().Error()}
+}
+
+func TestRouter_SlowFilterStaysLocal(t *testing.T) {
+	remote := NewScriptedGenerator(answer("approach", "nuzzle", "chirp", "eager", "remote"))
+	router, _ := newTestRouter(slowFilter{}, remote, RouterConfig{FilterTimeout: 20 * time.Millisecond})
+
+	start := time.Now()
+	resp, err := router.SelectAction(context.Background(), happyRequest())
+	if err != nil {
+		t.Fatalf("SelectAction() error = %v", err)
+	}
+	if resp.Reason != "local" {
+		t.Errorf("Reason = %q, want local answer", resp.Reason)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("took %v, filter timeout not enforced", elapsed)
+	}
+}
+
+func TestRouter_DailyBudget(t *testing.T) {
+	remote := NewScriptedGenerator(answer("approach", "nuzzle", "chirp", "eager", "remote"))
+	router, _ := newTestRouter(fixedFilter(true), remote, RouterConfig{DailyBudget: 2})
+
+	day := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	router.now = func() time.Time { return day }
+
+	for i := 0; i < 3; i++ {
+		router.SelectAction(context.Background(), happyRequest())
+	}
+	stats := router.Stats()
+	if stats.Escalations != 2 || stats.BudgetDenied != 1 || stats.BudgetRemaining != 0 {
+		t.Errorf("stats = %+v, want 2 escalations and 1 denied", stats)
+	}
+
+	day = day.Add(24 * time.Hour)
+	if stats := router.Stats(); stats.BudgetRemaining != 2 {
+		t.Errorf("BudgetRemaining = %d the next day, want 2", stats.BudgetRemaining)
+	}
+}
+
+func TestHeuristicFilter(t *testing.T) {
+	f := NewHeuristicFilter()
+	ctx := context.Background()
+
+	req := happyRequest()
+	req.Event = personality.NewEventContext(personality.EventUnknownObject)
+	if a := f.Assess(ctx, req); !a.Escalate {
+		t.Errorf("first unknown object: %+v, want escalate", a)
+	}
+
+	// Same situation again and again becomes routine
+	var a Assessment
+	for i := 0; i < 3; i++ {
+		a = f.Assess(ctx, req)
+	}
+	if a.Escalate {
+		t.Errorf("repeated situation: %+v, want routine", a)
+	}
+
+	// Mixed signals in a known situation push it back over
+	req.Event = personality.NewEventContext(personality.EventPetted)
+	for i := 0; i < 3; i++ {
+		f.Assess(ctx, req)
+	}
+	req.RecentEvents = []personality.Event{personality.EventLoudNoise}
+	if a := f.Assess(ctx, req); !a.Escalate {
+		t.Errorf("petted right after loud noise: %+v, want escalate", a)
+	}
+
+	// Urgent fear is never escalated
+	scared := personality.NewEmotionalState()
+	scared.SetMood(personality.MoodFrightened, personality.IntensityHigh)
+	urgent := ActionRequest{EmotionalState: scared, Event: personality.NewEventContext(personality.EventUnknownObject)}
+	if a := f.Assess(ctx, urgent); a.Escalate {
+		t.Errorf("frightened: %+v, want local", a)
+	}
+}
+
+func TestModelFilter(t *testing.T) {
+	gen := NewScriptedGenerator(`{"call_cloud": true, "reason": "never seen this"}`, `not json`)
+	f := NewModelFilter(gen)
+
+	if a := f.Assess(context.Background(), happyRequest()); !a.Escalate || a.Reason != "never seen this" {
+		t.Errorf("Assess() = %+v, want escalate", a)
+	}
+	if a := f.Assess(context.Background(), happyRequest()); a.Escalate {
+		t.Errorf("Assess() on garbage = %+v, want no escalation", a)
+	}
+}
@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"encoding/json"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+// Answer fields that make up the action, in the order the LLM is asked to
+// produce them. The reason comes last so the action can commit before it.
+const (
+	fieldMovement   = "movement"
+	fieldExpression = "expression"
+	fieldSound      = "sound"
+	fieldModifier   = "modifier"
+	fieldReason     = "reason"
+)
+
+var actionFields = []string{fieldMovement, fieldExpression, fieldSound, fieldModifier}
+
+// vocabulary is what the LLM may choose from for each field, given the mood.
+type vocabulary struct {
+	Movement   []personality.Action
+	Expression []personality.Action
+	Sound      []personality.Action
+	Modifiers  []personality.ActionModifier
+}
+
+func vocabularyFor(state *personality.EmotionalState) vocabulary {
+	return vocabulary{
+		Movement:   state.ChannelActions(personality.ChannelMovement),
+		Expression: state.ChannelActions(personality.ChannelExpression),
+		Sound:      state.ChannelActions(personality.ChannelSound),
+		Modifiers:  state.AvailableModifiers(),
+	}
+}
+
+// choices returns the allowed values for an action field.
+func (v vocabulary) choices(field string) []string {
+	var out []string
+	switch field {
+	case fieldMovement:
+		out = actionStrings(v.Movement)
+	case fieldExpression:
+		out = actionStrings(v.Expression)
+	case fieldSound:
+		out = actionStrings(v.Sound)
+	case fieldModifier:
+		for _, m := range v.Modifiers {
+			out = append(out, string(m))
+		}
+	}
+	return out
+}
+
+func actionStrings(actions []personality.Action) []string {
+	out := make([]string, len(actions))
+	for i, a := range actions {
+		out[i] = string(a)
+	}
+	return out
+}
+
+// schemaProperty is one property in a JSON schema.
+type schemaProperty struct {
+	Type string   `json:"type"`
+	Enum []string `json:"enum,omitempty"`
+}
+
+// schema returns a JSON schema restricting each field to this vocabulary,
+// for backends that support schema-constrained output.
+func (v vocabulary) schema() json.RawMessage {
+	props := make(map[string]schemaProperty)
+	for _, field := range actionFields {
+		props[field] = schemaProperty{Type: "string", Enum: v.choices(field)}
+	}
+	props[fieldReason] = schemaProperty{Type: "string"}
+
+	schema, _ := json.Marshal(struct {
+		Type       string                    `json:"type"`
+		Properties map[string]schemaProperty `json:"properties"`
+		Required   []string                  `json:"required"`
+	}{
+		Type:       "object",
+		Properties: props,
+		Required:   append(append([]string{}, actionFields...), fieldReason),
+	})
+	return schema
+}
+
+// resolve validates each action field on its own, replacing invalid or
+// missing ones with the mood's default for that field and listing them in
+// Fallbacks, so one bad field doesn't throw away the rest of the answer.
+func resolve(state *personality.EmotionalState, fields map[string]string) ActionResponse {
+	vocab := vocabularyFor(state)
+	def := state.SuggestDefaultAction()
+
+	// Small models sometimes ignore the format and answer {"action": ...};
+	// put that action in its channel rather than throwing it away
+	if legacy, ok := fields["action"]; ok {
+		if ch := personality.Action(legacy).Channel(); ch != "" {
+			if _, set := fields[string(ch)]; !set {
+				fields[string(ch)] = legacy
+			}
+		}
+	}
+
+	resp := ActionResponse{Reason: fields[fieldReason]}
+	pick := func(field string, fallback string) string {
+		value := fields[field]
+		for _, choice := range vocab.choices(field) {
+			if value == choice {
+				return value
+			}
+		}
+		resp.Fallbacks = append(resp.Fallbacks, field)
+		return fallback
+	}
+
+	resp.Movement = personality.Action(pick(fieldMovement, string(def.Movement)))
+	resp.Expression = personality.Action(pick(fieldExpression, string(def.Expression)))
+	resp.Sound = personality.Action(pick(fieldSound, string(def.Sound)))
+	resp.Modifier = personality.ActionModifier(pick(fieldModifier,
+		string(personality.DefaultModifier(state.CurrentMood, state.Intensity))))
+	resp.Action = string(resp.ActionSet().Primary())
+
+	return resp
+}
+
+// fallbackResponse is the mood's default action set, used when the LLM
+// can't be asked or its answer can't be parsed at all.
+func fallbackResponse(state *personality.EmotionalState, reason string) ActionResponse {
+	resp := resolve(state, map[string]string{})
+	resp.Reason = reason
+	return resp
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alex/koji/internal/memory"
+)
+
+// maxSummaryLen caps an episode summary, small models don't always stop.
+const maxSummaryLen = 160
+
+// EpisodeSummarizer compresses old episodes into one sentence with the LLM.
+// It implements memory.Summarizer.
+type EpisodeSummarizer struct {
+	gen     Generator
+	profile Profile
+}
+
+// NewEpisodeSummarizer creates a summarizer writing as the given profile.
+func NewEpisodeSummarizer(gen Generator, profile Profile) *EpisodeSummarizer {
+	return &EpisodeSummarizer{gen: gen, profile: profile}
+}
+
+// Summarize asks the LLM for a short first-person summary of ep.
+func (s *EpisodeSummarizer) Summarize(ctx context.Context, ep memory.Episode) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "You are the memory of %s, a small robot pet. Summarize this episode in one short sentence (at most 15 words), from %s's point of view.\n\n",
+		s.profile.DisplayName(), s.profile.DisplayName())
+	fmt.Fprintf(&sb, "When: %s, for %s\n", ep.Start.Format("Mon 15:04"), ep.End.Sub(ep.Start).Round(time.Minute))
+	if len(ep.People) > 0 {
+		fmt.Fprintf(&sb, "Who: %s\n", strings.Join(ep.People, ", "))
+	}
+	fmt.Fprintf(&sb, "What happened: %s (%d events)\n", strings.Join(ep.Events, ", "), ep.EventCount)
+	fmt.Fprintf(&sb, "Strongest feeling: %s, intensity %.1f\n\n", ep.PeakMood, ep.PeakIntensity)
+	sb.WriteString(`Respond with ONLY this JSON: {"summary": "<sentence>"}`)
+
+	response, err := s.gen.Generate(ctx, sb.String(), GenerateOptions{JSON: s.gen.Capabilities().JSONMode})
+	if err != nil {
+		return "", fmt.Errorf("generating summary: %w", err)
+	}
+	fields, err := parseFields(response)
+	if err != nil {
+		return "", fmt.Errorf("parsing summary %q: %w", response, err)
+	}
+
+	summary := strings.TrimSpace(fields["summary"])
+	if summary == "" {
+		return "", fmt.Errorf("empty summary in %q", response)
+	}
+	if len(summary) > maxSummaryLen {
+		// Cut on a character boundary, not in the middle of one
+		cut := maxSummaryLen
+		for cut > 0 && !utf8.RuneStart(summary[cut]) {
+			cut--
+		}
+		summary = strings.TrimSpace(summary[:cut]) + "..."
+	}
+	return summary, nil
+}
@@ -0,0 +1,46 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alex/koji/internal/memory"
+)
+
+func TestEpisodeSummarizer(t *testing.T) {
+	gen := NewScriptedGenerator(`{"summary": "Alex came home and petted me"}`, `not json`)
+	s := NewEpisodeSummarizer(gen, DefaultProfile())
+
+	start := time.Date(2026, 3, 1, 18, 0, 0, 0, time.UTC)
+	ep := memory.Episode{
+		Start: start, End: start.Add(5 * time.Minute),
+		People: []string{"Alex (owner)"}, Events: []string{"familiar_face", "petted"}, EventCount: 4,
+		PeakMood: "excited", PeakIntensity: 0.9,
+	}
+
+	got, err := s.Summarize(context.Background(), ep)
+	if err != nil || got != "Alex came home and petted me" {
+		t.Errorf("Summarize() = %q, %v", got, err)
+	}
+	prompt := gen.Prompts()[0]
+	for _, want := range []string{"memory of Koji", "Who: Alex (owner)", "familiar_face, petted (4 events)", "excited, intensity 0.9"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+
+	if _, err := s.Summarize(context.Background(), ep); err == nil {
+		t.Error("Summarize() accepted a non-JSON answer")
+	}
+
+	// A rambling answer is cut short without splitting a character
+	long := strings.Repeat("a", maxSummaryLen-1) + "éé"
+	s = NewEpisodeSummarizer(NewScriptedGenerator(`{"summary": "`+long+`"}`), DefaultProfile())
+	got, err = s.Summarize(context.Background(), ep)
+	if err != nil || !utf8.ValidString(got) || !strings.HasSuffix(got, "a...") {
+		t.Errorf("Summarize() of a long answer = %q, %v", got, err)
+	}
+}
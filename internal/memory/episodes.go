@@ -0,0 +1,415 @@
+// Package memory keeps Koji's episodic memory: what happened, who was there,
+// and how strongly it felt, so the LLM can react to more than the last few
+// events.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alex/koji/internal/atomicfile"
+	"github.com/alex/koji/internal/personality"
+)
+
+// Config controls how history is split into episodes and how long details are kept.
+type Config struct {
+	EpisodeGap    time.Duration // quiet time that ends an episode (default: 2m)
+	CompressAfter time.Duration // episodes older than this are reduced to a summary (default: 1h)
+	MaxEpisodes   int           // the oldest episodes are forgotten beyond this (default: 500)
+	TokenBudget   int           // default prompt budget for Recall (default: 120)
+	FlushEvery    time.Duration // changes are batched and written this often (default: 10s)
+}
+
+// DefaultConfig returns sensible defaults.
+func DefaultConfig() Config {
+	return Config{
+		EpisodeGap:    2 * time.Minute,
+		CompressAfter: time.Hour,
+		MaxEpisodes:   500,
+		TokenBudget:   120,
+		FlushEvery:    10 * time.Second,
+	}
+}
+
+// Episode is a stretch of related events with no long quiet gap between them.
+type Episode struct {
+	Start         time.Time        `json:"start"`
+	End           time.Time        `json:"end"`
+	People        []string         `json:"people,omitempty"` // "Alex (owner)"
+	Events        []string         `json:"events,omitempty"` // distinct events, in the order they first happened
+	EventCount    int              `json:"event_count"`
+	PeakMood      personality.Mood `json:"peak_mood"`
+	PeakIntensity float64          `json:"peak_intensity"`
+	Summary       string           `json:"summary,omitempty"` // set once the episode is compressed
+}
+
+// Compressed reports whether the episode's details have been replaced by a summary.
+func (ep Episode) Compressed() bool {
+	return ep.Summary != ""
+}
+
+// Key identifies the episode across recalls. Unlike Describe it doesn't
+// change as the episode ages, only when it's compressed to a summary.
+func (ep Episode) Key() string {
+	key := strconv.FormatInt(ep.Start.UnixMilli(), 36)
+	if ep.Compressed() {
+		key += "s"
+	}
+	return key
+}
+
+// Describe renders the episode as one line, with its time relative to now.
+func (ep Episode) Describe(now time.Time) string {
+	when := ago(now.Sub(ep.End))
+	if ep.Compressed() {
+		return when + ": " + ep.Summary
+	}
+	return when + ": " + BasicSummary(ep)
+}
+
+// BasicSummary describes an episode without the LLM.
+func BasicSummary(ep Episode) string {
+	var sb strings.Builder
+	if len(ep.People) > 0 {
+		fmt.Fprintf(&sb, "with %s, ", strings.Join(ep.People, " and "))
+	}
+	sb.WriteString(strings.Join(ep.Events, ", "))
+	if ep.EventCount > len(ep.Events) {
+		fmt.Fprintf(&sb, " (%d events)", ep.EventCount)
+	}
+	fmt.Fprintf(&sb, "; felt %s (%.1f)", ep.PeakMood, ep.PeakIntensity)
+	return sb.String()
+}
+
+// ago phrases a duration the way Koji would think of it.
+func ago(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute") + " ago"
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour") + " ago"
+	case d < 48*time.Hour:
+		return "yesterday"
+	default:
+		return plural(int(d/(24*time.Hour)), "day") + " ago"
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// Summarizer compresses an episode into a short description.
+type Summarizer interface {
+	Summarize(ctx context.Context, ep Episode) (string, error)
+}
+
+// Query describes the current situation, to find episodes relevant to it.
+type Query struct {
+	Person string            // who is here, as recorded in Episode.People
+	Event  personality.Event // what just happened
+	Mood   personality.Mood
+	Budget int // max tokens for the returned lines (0 = Config.TokenBudget)
+}
+
+// Store records events into episodes and recalls the relevant ones.
+// It is safe for concurrent use. Changes are written in batches; Close
+// writes the last of them.
+type Store struct {
+	mu       sync.Mutex
+	cfg      Config
+	episodes []Episode // oldest first
+	dataPath string
+	now      func() time.Time
+
+	// Recording an event doesn't rewrite the file, a timer flushes later
+	dirty      bool
+	flushTimer *time.Timer
+	closed     bool
+}
+
+// NewStore creates a store persisted at dataPath ("" for in-memory only).
+func NewStore(dataPath string, cfg Config) (*Store, error) {
+	defaults := DefaultConfig()
+	if cfg.EpisodeGap == 0 {
+		cfg.EpisodeGap = defaults.EpisodeGap
+	}
+	if cfg.CompressAfter == 0 {
+		cfg.CompressAfter = defaults.CompressAfter
+	}
+	if cfg.MaxEpisodes == 0 {
+		cfg.MaxEpisodes = defaults.MaxEpisodes
+	}
+	if cfg.TokenBudget == 0 {
+		cfg.TokenBudget = defaults.TokenBudget
+	}
+	if cfg.FlushEvery == 0 {
+		cfg.FlushEvery = defaults.FlushEvery
+	}
+
+	s := &Store{
+		cfg:      cfg,
+		dataPath: dataPath,
+		now:      time.Now,
+	}
+
+	// Try to load existing data
+	if err := s.load(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("loading episodes: %w", err)
+	}
+
+	return s, nil
+}
+
+// PersonLabel is how the person an event is about is recorded, or "" if unknown.
+func PersonLabel(event personality.EventContext) string {
+	name := event.Metadata[personality.MetaPersonName]
+	if name == "" {
+		return ""
+	}
+	if rel := event.Metadata[personality.MetaRelationship]; rel != "" {
+		return name + " (" + rel + ")"
+	}
+	return name
+}
+
+// Record adds an event and the mood it left Koji in. It extends the current
+// episode, or starts a new one after a quiet gap.
+func (s *Store) Record(event personality.EventContext, state *personality.EmotionalState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var ep *Episode
+	if n := len(s.episodes); n > 0 {
+		last := &s.episodes[n-1]
+		if !last.Compressed() && now.Sub(last.End) <= s.cfg.EpisodeGap {
+			ep = last
+		}
+	}
+	if ep == nil {
+		s.episodes = append(s.episodes, Episode{Start: now, PeakMood: state.CurrentMood})
+		ep = &s.episodes[len(s.episodes)-1]
+	}
+
+	ep.End = now
+	ep.EventCount++
+	if name := string(event.Event); !slices.Contains(ep.Events, name) {
+		ep.Events = append(ep.Events, name)
+	}
+	if person := PersonLabel(event); person != "" && !slices.Contains(ep.People, person) {
+		ep.People = append(ep.People, person)
+	}
+	if float64(state.Intensity) >= ep.PeakIntensity {
+		ep.PeakMood = state.CurrentMood
+		ep.PeakIntensity = float64(state.Intensity)
+	}
+
+	if len(s.episodes) > s.cfg.MaxEpisodes {
+		s.episodes = slices.Clone(s.episodes[len(s.episodes)-s.cfg.MaxEpisodes:])
+	}
+
+	s.changedLocked()
+}
+
+// Compress summarizes episodes older than CompressAfter, using summarizer
+// if given and BasicSummary otherwise. It is meant for idle time: with an
+// LLM summarizer each episode is a generation. Returns how many episodes
+// were compressed.
+func (s *Store) Compress(ctx context.Context, summarizer Summarizer) (int, error) {
+	s.mu.Lock()
+	cutoff := s.now().Add(-s.cfg.CompressAfter)
+	var todo []Episode
+	for _, ep := range s.episodes {
+		if !ep.Compressed() && ep.End.Before(cutoff) {
+			todo = append(todo, ep)
+		}
+	}
+	s.mu.Unlock()
+
+	// Summarize without holding the lock, the LLM is slow
+	summaries := make(map[time.Time]string, len(todo))
+	var err error
+	for _, ep := range todo {
+		summary := BasicSummary(ep)
+		if summarizer != nil {
+			if summary, err = summarizer.Summarize(ctx, ep); err != nil {
+				err = fmt.Errorf("summarizing episode from %s: %w", ep.Start.Format(time.RFC3339), err)
+				break
+			}
+		}
+		summaries[ep.Start] = summary
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	compressed := 0
+	for i := range s.episodes {
+		ep := &s.episodes[i]
+		if summary, ok := summaries[ep.Start]; ok && !ep.Compressed() {
+			ep.Summary = summary
+			ep.Events = nil
+			compressed++
+		}
+	}
+	if compressed > 0 {
+		s.changedLocked()
+	}
+	return compressed, err
+}
+
+// Recall returns descriptions of the past episodes most relevant to q,
+// oldest first, that together fit in q.Budget tokens. The episode still in
+// progress is left out: the prompt already has the recent events. key names
+// the episodes picked (see Episode.Key), for caching answers that used them.
+func (s *Store) Recall(q Query) (lines []string, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	budget := q.Budget
+	if budget == 0 {
+		budget = s.cfg.TokenBudget
+	}
+	now := s.now()
+
+	type candidate struct {
+		ep    Episode
+		score float64
+	}
+	var candidates []candidate
+	for _, ep := range s.episodes {
+		if now.Sub(ep.End) <= s.cfg.EpisodeGap {
+			continue
+		}
+		candidates = append(candidates, candidate{ep, relevance(ep, q, now)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	var picked []Episode
+	used := 0
+	for _, c := range candidates {
+		tokens := EstimateTokens(c.ep.Describe(now))
+		if used+tokens > budget {
+			continue
+		}
+		used += tokens
+		picked = append(picked, c.ep)
+	}
+
+	sort.Slice(picked, func(i, j int) bool { return picked[i].Start.Before(picked[j].Start) })
+	lines = make([]string, len(picked))
+	keys := make([]string, len(picked))
+	for i, ep := range picked {
+		lines[i] = ep.Describe(now)
+		keys[i] = ep.Key()
+	}
+	return lines, strings.Join(keys, ",")
+}
+
+// relevance scores an episode for the current situation: the same person
+// matters most, then the same kind of event, then how strongly it was felt
+// and how recent it is.
+func relevance(ep Episode, q Query, now time.Time) float64 {
+	score := 1 / (1 + now.Sub(ep.End).Hours())
+	score += ep.PeakIntensity * 0.5
+	if q.Person != "" && slices.Contains(ep.People, q.Person) {
+		score += 2
+	}
+	if q.Event != "" && (slices.Contains(ep.Events, string(q.Event)) || strings.Contains(ep.Summary, string(q.Event))) {
+		score++
+	}
+	if q.Mood != "" && ep.PeakMood == q.Mood {
+		score += 0.5
+	}
+	return score
+}
+
+// EstimateTokens approximates how many LLM tokens s takes (about 4 characters each).
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// Episodes returns a copy of all episodes, oldest first.
+func (s *Store) Episodes() []Episode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.episodes)
+}
+
+// changedLocked schedules a write of the episodes, at most FlushEvery from
+// now. Caller holds s.mu.
+func (s *Store) changedLocked() {
+	s.dirty = true
+	if s.dataPath != "" && s.flushTimer == nil && !s.closed {
+		s.flushTimer = time.AfterFunc(s.cfg.FlushEvery, func() { _ = s.Flush() })
+	}
+}
+
+// Flush writes any changes not yet on disk now.
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	if !s.dirty {
+		return nil
+	}
+	if err := s.save(); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// Close writes any pending changes. The store shouldn't be used after.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	return s.Flush()
+}
+
+// save persists the episodes to disk. Caller holds s.mu.
+func (s *Store) save() error {
+	if s.dataPath == "" {
+		return nil // in-memory only
+	}
+
+	data, err := json.MarshalIndent(s.episodes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(s.dataPath, data, 0644)
+}
+
+// load reads the episodes from disk.
+func (s *Store) load() error {
+	if s.dataPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.dataPath)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &s.episodes)
+}
@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+// testClock is a settable clock for a Store's now field.
+type testClock struct{ now time.Time }
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time          { return c.now }
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// testStore returns a store at path ("" for in-memory) on a test clock.
+func testStore(t *testing.T, path string) (*Store, *testClock) {
+	t.Helper()
+	s, err := NewStore(path, Config{})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	clock := newTestClock()
+	s.now = clock.Now
+	return s, clock
+}
+
+func record(s *Store, event personality.Event, mood personality.Mood, intensity personality.Intensity, person string) {
+	ctx := personality.NewEventContext(event)
+	if person != "" {
+		ctx.Metadata[personality.MetaPersonName] = person
+		ctx.Metadata[personality.MetaRelationship] = "owner"
+	}
+	state := personality.NewEmotionalState()
+	state.SetMood(mood, intensity)
+	s.Record(ctx, state)
+}
+
+func TestStore_SegmentsOnQuietGaps(t *testing.T) {
+	s, clock := testStore(t, "")
+
+	record(s, personality.EventFamiliarFace, personality.MoodExcited, personality.IntensityHigh, "Alex")
+	clock.Advance(30 * time.Second)
+	record(s, personality.EventPetted, personality.MoodHappy, personality.IntensityMedium, "Alex")
+	clock.Advance(30 * time.Second)
+	record(s, personality.EventPetted, personality.MoodHappy, personality.IntensityMedium, "")
+
+	clock.Advance(10 * time.Minute)
+	record(s, personality.EventLoudNoise, personality.MoodStartled, personality.IntensityMedium, "")
+
+	episodes := s.Episodes()
+	if len(episodes) != 2 {
+		t.Fatalf("got %d episodes, want 2", len(episodes))
+	}
+
+	visit := episodes[0]
+	if visit.EventCount != 3 || strings.Join(visit.Events, ",") != "familiar_face,petted" {
+		t.Errorf("visit events = %v (%d), want familiar_face,petted (3)", visit.Events, visit.EventCount)
+	}
+	if len(visit.People) != 1 || visit.People[0] != "Alex (owner)" {
+		t.Errorf("visit people = %v", visit.People)
+	}
+	if visit.PeakMood != personality.MoodExcited || visit.PeakIntensity != float64(personality.IntensityHigh) {
+		t.Errorf("visit peak = %s %.1f, want excited 0.9", visit.PeakMood, visit.PeakIntensity)
+	}
+	if visit.End.Sub(visit.Start) != time.Minute {
+		t.Errorf("visit lasted %s, want 1m", visit.End.Sub(visit.Start))
+	}
+}
+
+func TestStore_RecallPrefersRelevantWithinBudget(t *testing.T) {
+	s, clock := testStore(t, "")
+
+	record(s, personality.EventFamiliarFace, personality.MoodHappy, personality.IntensityHigh, "Alex")
+	clock.Advance(time.Hour)
+	record(s, personality.EventLoudNoise, personality.MoodStartled, personality.IntensityHigh, "")
+	clock.Advance(time.Hour)
+	record(s, personality.EventMusic, personality.MoodHappy, personality.IntensityLow, "")
+	clock.Advance(time.Hour)
+	record(s, personality.EventSpeech, personality.MoodCurious, personality.IntensityMedium, "") // in progress
+
+	all, key := s.Recall(Query{Budget: 1000})
+	if len(all) != 3 {
+		t.Fatalf("Recall() = %v, want the 3 finished episodes", all)
+	}
+	if !strings.HasPrefix(all[0], "3 hours ago: with Alex (owner), familiar_face") {
+		t.Errorf("oldest line = %q", all[0])
+	}
+
+	// The same episodes keep their key as they age
+	clock.Advance(time.Minute)
+	if _, later := s.Recall(Query{Budget: 1000}); later != key || strings.Count(key, ",") != 2 {
+		t.Errorf("Recall() key a minute later = %q, want %q naming 3 episodes", later, key)
+	}
+
+	// Room for one: the owner's visit wins when the owner is back, even though it's oldest
+	budget := EstimateTokens(all[0])
+	got, _ := s.Recall(Query{Person: "Alex (owner)", Event: personality.EventFamiliarFace, Budget: budget})
+	if len(got) != 1 || !strings.Contains(got[0], "Alex") {
+		t.Errorf("Recall(owner) = %v, want only the owner's visit", got)
+	}
+	got, _ = s.Recall(Query{Event: personality.EventLoudNoise, Mood: personality.MoodStartled, Budget: budget})
+	if len(got) != 1 || !strings.Contains(got[0], "loud_noise") {
+		t.Errorf("Recall(loud noise) = %v, want the bang", got)
+	}
+}
+
+type fakeSummarizer struct {
+	calls int
+	err   error
+}
+
+func (f *fakeSummarizer) Summarize(ctx context.Context, ep Episode) (string, error) {
+	f.calls++
+	if f.err != nil {
+		return "", f.err
+	}
+	return "Alex came home and I was so happy", nil
+}
+
+func TestStore_CompressOldEpisodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "episodes.json")
+	s, clock := testStore(t, path)
+
+	record(s, personality.EventFamiliarFace, personality.MoodHappy, personality.IntensityHigh, "Alex")
+	clock.Advance(30 * time.Minute)
+	record(s, personality.EventMusic, personality.MoodHappy, personality.IntensityLow, "")
+
+	// Nothing is old enough yet
+	if n, _ := s.Compress(context.Background(), nil); n != 0 {
+		t.Errorf("compressed %d fresh episodes", n)
+	}
+
+	clock.Advance(45 * time.Minute)
+	if _, err := s.Compress(context.Background(), &fakeSummarizer{err: errors.New("busy")}); err == nil {
+		t.Error("Compress() hid the summarizer error")
+	}
+
+	summarizer := &fakeSummarizer{}
+	if n, err := s.Compress(context.Background(), summarizer); n != 1 || err != nil {
+		t.Fatalf("Compress() = %d, %v, want only the first episode", n, err)
+	}
+
+	// Writes are batched: nothing is on disk until the flush
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("episodes written on every change: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Compressed episodes survive a reload and recall as their summary
+	reloaded, err := NewStore(path, Config{})
+	if err != nil {
+		t.Fatalf("reloading: %v", err)
+	}
+	reloaded.now = clock.Now
+	episodes := reloaded.Episodes()
+	if len(episodes) != 2 || !episodes[0].Compressed() || episodes[0].Events != nil || episodes[1].Compressed() {
+		t.Fatalf("reloaded episodes = %+v", episodes)
+	}
+	lines, _ := reloaded.Recall(Query{})
+	if lines[0] != "1 hour ago: Alex came home and I was so happy" {
+		t.Errorf("compressed line = %q", lines[0])
+	}
+}
+
+func TestStore_ForgetsBeyondMax(t *testing.T) {
+	s, clock := testStore(t, "")
+	s.cfg.MaxEpisodes = 2
+
+	for _, e := range []personality.Event{personality.EventMusic, personality.EventSpeech, personality.EventPetted} {
+		record(s, e, personality.MoodHappy, personality.IntensityLow, "")
+		clock.Advance(time.Hour)
+	}
+
+	episodes := s.Episodes()
+	if len(episodes) != 2 || episodes[0].Events[0] != "speech" {
+		t.Errorf("episodes = %+v, want the last two", episodes)
+	}
+}
@@ -0,0 +1,338 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alex/koji/internal/api"
+	"github.com/alex/koji/internal/personality"
+)
+
+// Availability payloads published on the status topic.
+const (
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+)
+
+// Config holds MQTT bridge configuration.
+type Config struct {
+	Broker          string        // host:port of the broker
+	ClientID        string        // MQTT client ID, also the Home Assistant device ID (default: koji-brain)
+	Username        string        // optional broker credentials
+	Password        string        // optional broker credentials
+	TopicPrefix     string        // root of all Koji topics (default: koji)
+	Discovery       bool          // publish Home Assistant discovery payloads
+	DiscoveryPrefix string        // Home Assistant discovery root (default: homeassistant)
+	PollInterval    time.Duration // how often to check for state changes (default: 250ms)
+	ReconnectDelay  time.Duration // wait between reconnect attempts (default: 5s)
+}
+
+// DefaultConfig returns sensible defaults for a local broker.
+func DefaultConfig() Config {
+	return Config{
+		Broker:          "localhost:1883",
+		ClientID:        "koji-brain",
+		TopicPrefix:     "koji",
+		Discovery:       true,
+		DiscoveryPrefix: "homeassistant",
+		PollInterval:    250 * time.Millisecond,
+		ReconnectDelay:  5 * time.Second,
+	}
+}
+
+// PasswordEnv is the environment variable the broker password is read from
+// when no password file is given.
+const PasswordEnv = "KOJI_MQTT_PASSWORD"
+
+// LoadPassword reads the broker password from a file, or from PasswordEnv if
+// path is empty, so it never shows up in the process list. Surrounding
+// whitespace is trimmed. Returns "" with no error if neither is set.
+func LoadPassword(path string) (string, error) {
+	if path == "" {
+		return strings.TrimSpace(os.Getenv(PasswordEnv)), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading password file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Bridge publishes Koji's state to MQTT and feeds MQTT events into the brain.
+//
+// Topics (with the default "koji" prefix):
+//
+//	koji/status             online/offline (retained, offline is the last will)
+//	koji/state/mood         current mood (retained)
+//	koji/state/face_emotion current ESP32 face emotion (retained)
+//	koji/state/action       most recent action (retained)
+//	koji/state/face         face animation cues as JSON (retained)
+//	koji/event/<source>     inbound events, source is taken from the topic
+//
+// Event payloads are either a bare event name ("loud_noise") or JSON:
+//
+//	{"event": "loud_noise", "intensity": 0.9, "metadata": {"db": "85"}}
+type Bridge struct {
+	cfg      Config
+	provider api.StateProvider
+	handler  api.EventHandler
+
+	// last values published, so we only publish on change
+	published map[string]string
+}
+
+// NewBridge creates a new MQTT bridge. The handler may be nil to publish only.
+func NewBridge(cfg Config, provider api.StateProvider, handler api.EventHandler) *Bridge {
+	defaults := DefaultConfig()
+	if cfg.ClientID == "" {
+		cfg.ClientID = defaults.ClientID
+	}
+	if cfg.TopicPrefix == "" {
+		cfg.TopicPrefix = defaults.TopicPrefix
+	}
+	if cfg.DiscoveryPrefix == "" {
+		cfg.DiscoveryPrefix = defaults.DiscoveryPrefix
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaults.PollInterval
+	}
+	if cfg.ReconnectDelay == 0 {
+		cfg.ReconnectDelay = defaults.ReconnectDelay
+	}
+
+	return &Bridge{
+		cfg:      cfg,
+		provider: provider,
+		handler:  handler,
+	}
+}
+
+// Run connects to the broker and keeps the bridge running, reconnecting as needed.
+// Blocks until context is cancelled.
+func (b *Bridge) Run(ctx context.Context) error {
+	for {
+		err := b.runSession(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("MQTT: %v (retrying in %s)", err, b.cfg.ReconnectDelay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.cfg.ReconnectDelay):
+		}
+	}
+}
+
+// runSession handles a single broker connection until it drops or ctx is cancelled.
+func (b *Bridge) runSession(ctx context.Context) error {
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	client, err := Dial(dialCtx, ClientOptions{
+		Broker:      b.cfg.Broker,
+		ClientID:    b.cfg.ClientID,
+		Username:    b.cfg.Username,
+		Password:    b.cfg.Password,
+		WillTopic:   b.topic("status"),
+		WillPayload: []byte(payloadOffline),
+		WillRetain:  true,
+	})
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	log.Printf("MQTT: connected to %s as %s", b.cfg.Broker, b.cfg.ClientID)
+
+	if err := client.Publish(b.topic("status"), []byte(payloadOnline), true); err != nil {
+		return err
+	}
+
+	if b.cfg.Discovery {
+		if err := b.publishDiscovery(client); err != nil {
+			return err
+		}
+	}
+
+	if b.handler != nil {
+		if err := client.Subscribe(ctx, b.topic("event/#"), b.handleEventMessage); err != nil {
+			return err
+		}
+	}
+
+	// Fresh connection: republish everything
+	b.published = make(map[string]string)
+	if err := b.publishState(client); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(b.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Graceful shutdown: mark offline ourselves, since DISCONNECT suppresses the will
+			client.Publish(b.topic("status"), []byte(payloadOffline), true)
+			client.Disconnect()
+			return ctx.Err()
+
+		case <-client.Done():
+			return client.Err()
+
+		case <-ticker.C:
+			if err := b.publishState(client); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// publishState publishes any state values that changed since the last publish.
+func (b *Bridge) publishState(client *Client) error {
+	state := b.provider.GetState()
+	if state == nil {
+		return nil
+	}
+
+	// Age is left out so unchanged cues don't republish on every poll
+	cues := state.FaceCues(b.provider.GetRecentModifier(), b.provider.GetLookHint())
+	cues.AgeMs = 0
+	face, err := json.Marshal(cues)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]string{
+		"state/mood":         string(state.CurrentMood),
+		"state/face_emotion": string(state.ToFaceEmotion()),
+		"state/face":         string(face),
+	}
+	if action := b.provider.GetRecentAction(); action != "" {
+		values["state/action"] = action
+	}
+
+	for suffix, value := range values {
+		if b.published[suffix] == value {
+			continue
+		}
+		if err := client.Publish(b.topic(suffix), []byte(value), true); err != nil {
+			return err
+		}
+		b.published[suffix] = value
+	}
+	return nil
+}
+
+// eventPayload is the JSON form of an inbound event message.
+type eventPayload struct {
+	Event     string            `json:"event"`
+	Intensity float64           `json:"intensity,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// handleEventMessage converts a message on koji/event/<source> into a brain event.
+func (b *Bridge) handleEventMessage(msg Message) {
+	// A retained event would replay on every reconnect; events are one-shot
+	if msg.Retained {
+		return
+	}
+
+	source := strings.TrimPrefix(msg.Topic, b.topic("event"))
+	source = strings.TrimPrefix(source, "/")
+
+	var payload eventPayload
+	trimmed := strings.TrimSpace(string(msg.Payload))
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal([]byte(trimmed), &payload); err != nil {
+			log.Printf("MQTT: ignoring malformed event on %s: %v", msg.Topic, err)
+			return
+		}
+	} else {
+		payload.Event = trimmed
+	}
+
+	if payload.Event == "" {
+		log.Printf("MQTT: ignoring event without name on %s", msg.Topic)
+		return
+	}
+
+	ctx := personality.NewEventContext(personality.Event(payload.Event)).WithSource(source)
+	if payload.Intensity != 0 {
+		ctx = ctx.WithIntensity(payload.Intensity)
+	}
+	for k, v := range payload.Metadata {
+		ctx.Metadata[k] = v
+	}
+
+	moodChanged := b.handler.HandleEvent(ctx)
+	log.Printf("MQTT event received: %s (intensity=%.2f, source=%s) -> mood_changed=%v",
+		ctx.Event, ctx.Intensity, ctx.Source, moodChanged)
+}
+
+// discoverySensor is a Home Assistant MQTT discovery payload for a sensor.
+type discoverySensor struct {
+	Name                string          `json:"name"`
+	UniqueID            string          `json:"unique_id"`
+	StateTopic          string          `json:"state_topic"`
+	AvailabilityTopic   string          `json:"availability_topic"`
+	PayloadAvailable    string          `json:"payload_available"`
+	PayloadNotAvailable string          `json:"payload_not_available"`
+	Icon                string          `json:"icon,omitempty"`
+	Device              discoveryDevice `json:"device"`
+}
+
+type discoveryDevice struct {
+	Identifiers []string `json:"identifiers"`
+	Name        string   `json:"name"`
+	Model       string   `json:"model"`
+}
+
+// publishDiscovery announces the mood and last-action sensors to Home Assistant.
+func (b *Bridge) publishDiscovery(client *Client) error {
+	device := discoveryDevice{
+		Identifiers: []string{b.cfg.ClientID},
+		Name:        "Koji",
+		Model:       "Koji brain",
+	}
+
+	sensors := []struct {
+		key, name, topic, icon string
+	}{
+		{"mood", "Mood", "state/mood", "mdi:emoticon-outline"},
+		{"last_action", "Last action", "state/action", "mdi:paw"},
+	}
+
+	for _, s := range sensors {
+		payload, err := json.Marshal(discoverySensor{
+			Name:                s.name,
+			UniqueID:            b.cfg.ClientID + "_" + s.key,
+			StateTopic:          b.topic(s.topic),
+			AvailabilityTopic:   b.topic("status"),
+			PayloadAvailable:    payloadOnline,
+			PayloadNotAvailable: payloadOffline,
+			Icon:                s.icon,
+			Device:              device,
+		})
+		if err != nil {
+			return err
+		}
+
+		topic := b.cfg.DiscoveryPrefix + "/sensor/" + b.cfg.ClientID + "/" + s.key + "/config"
+		if err := client.Publish(topic, payload, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// topic builds a full topic name under the configured prefix.
+func (b *Bridge) topic(suffix string) string {
+	return b.cfg.TopicPrefix + "/" + suffix
+}
@@ -0,0 +1,324 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+// fakeBrain is a thread-safe StateProvider and EventHandler for tests.
+type fakeBrain struct {
+	mu       sync.Mutex
+	state    personality.EmotionalState
+	action   string
+	modifier personality.ActionModifier
+	look     *personality.LookHint
+	events   []personality.EventContext
+}
+
+func newFakeBrain() *fakeBrain {
+	return &fakeBrain{state: *personality.NewEmotionalState()}
+}
+
+func (f *fakeBrain) GetState() *personality.EmotionalState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state := f.state
+	return &state
+}
+
+func (f *fakeBrain) GetRecentAction() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.action
+}
+
+func (f *fakeBrain) GetRecentModifier() personality.ActionModifier {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.modifier
+}
+
+func (f *fakeBrain) GetLookHint() *personality.LookHint {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.look
+}
+
+func (f *fakeBrain) HandleEvent(ctx personality.EventContext) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, ctx)
+	return f.state.ProcessEvent(ctx)
+}
+
+func (f *fakeBrain) receivedEvents() []personality.EventContext {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]personality.EventContext{}, f.events...)
+}
+
+func startBridge(t *testing.T, broker *testBroker, brain *fakeBrain) context.CancelFunc {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.Broker = broker.addr()
+	cfg.PollInterval = 10 * time.Millisecond
+	cfg.ReconnectDelay = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewBridge(cfg, brain, brain).Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return cancel
+}
+
+func TestBridge_PublishesRetainedState(t *testing.T) {
+	broker := newTestBroker(t)
+	brain := newFakeBrain()
+	startBridge(t, broker, brain)
+
+	broker.waitRetained(t, "koji/status", "online")
+	broker.waitRetained(t, "koji/state/mood", "curious")
+	broker.waitRetained(t, "koji/state/face_emotion", "normal")
+
+	brain.mu.Lock()
+	brain.state.SetMood(personality.MoodFrightened, personality.IntensityMedium)
+	brain.action = "flee"
+	brain.modifier = personality.ModifierFrantic
+	brain.look = &personality.LookHint{X: 0.5}
+	brain.mu.Unlock()
+
+	broker.waitRetained(t, "koji/state/mood", "frightened")
+	broker.waitRetained(t, "koji/state/face_emotion", "scared")
+	broker.waitRetained(t, "koji/state/action", "flee")
+
+	var cues personality.FaceCues
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		msg, _ := broker.retainedMessage("koji/state/face")
+		if err := json.Unmarshal(msg.Payload, &cues); err == nil && cues.EmotionIndex == personality.FaceScared.Index() && cues.Look != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cues.Version != personality.FaceCuesVersion || cues.EmotionIndex != personality.FaceScared.Index() {
+		t.Errorf("face cues = %+v, want version %d and scared", cues, personality.FaceCuesVersion)
+	}
+	// The modifier and look hint come from the brain, not defaults
+	if cues.TransitionMs != 120 || cues.Look == nil || cues.Look.X != 0.5 {
+		t.Errorf("face cues = %+v, want a frantic transition looking at x=0.5", cues)
+	}
+}
+
+func TestBridge_IngestsEventsWithSourceFromTopic(t *testing.T) {
+	broker := newTestBroker(t)
+	brain := newFakeBrain()
+	startBridge(t, broker, brain)
+	broker.waitRetained(t, "koji/status", "online")
+
+	sensor, err := Dial(context.Background(), ClientOptions{Broker: broker.addr(), ClientID: "sensor"})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer sensor.Disconnect()
+
+	sensor.Publish("koji/event/kitchen/mic", []byte(`{"event":"loud_noise","intensity":0.9,"metadata":{"db":"85"}}`), false)
+	sensor.Publish("koji/event/doorbell", []byte("unknown_face"), false)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(brain.receivedEvents()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	events := brain.receivedEvents()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	if events[0].Event != personality.EventLoudNoise || events[0].Source != "kitchen/mic" {
+		t.Errorf("event[0] = %s from %q, want loud_noise from kitchen/mic", events[0].Event, events[0].Source)
+	}
+	if events[0].Intensity != 0.9 || events[0].Metadata["db"] != "85" {
+		t.Errorf("event[0] intensity/metadata not carried over: %+v", events[0])
+	}
+	if events[1].Event != personality.EventUnknownFace || events[1].Source != "doorbell" {
+		t.Errorf("event[1] = %s from %q, want unknown_face from doorbell", events[1].Event, events[1].Source)
+	}
+	if events[1].Intensity != 0.5 {
+		t.Errorf("event[1] intensity = %v, want default 0.5", events[1].Intensity)
+	}
+}
+
+func TestBridge_HomeAssistantDiscovery(t *testing.T) {
+	broker := newTestBroker(t)
+	startBridge(t, broker, newFakeBrain())
+	broker.waitRetained(t, "koji/status", "online")
+
+	for _, key := range []string{"mood", "last_action"} {
+		msg, ok := broker.retainedMessage("homeassistant/sensor/koji-brain/" + key + "/config")
+		if !ok {
+			t.Fatalf("missing discovery payload for %s", key)
+		}
+
+		var sensor discoverySensor
+		if err := json.Unmarshal(msg.Payload, &sensor); err != nil {
+			t.Fatalf("invalid discovery JSON: %v", err)
+		}
+		if sensor.AvailabilityTopic != "koji/status" {
+			t.Errorf("%s availability_topic = %q", key, sensor.AvailabilityTopic)
+		}
+		if sensor.UniqueID != "koji-brain_"+key {
+			t.Errorf("%s unique_id = %q", key, sensor.UniqueID)
+		}
+	}
+}
+
+func TestBridge_GracefulShutdownPublishesOffline(t *testing.T) {
+	broker := newTestBroker(t)
+	cancel := startBridge(t, broker, newFakeBrain())
+	broker.waitRetained(t, "koji/status", "online")
+
+	cancel()
+	broker.waitRetained(t, "koji/status", "offline")
+}
+
+func TestClient_LastWillOnConnectionLoss(t *testing.T) {
+	broker := newTestBroker(t)
+
+	client, err := Dial(context.Background(), ClientOptions{
+		Broker:      broker.addr(),
+		ClientID:    "koji-brain",
+		WillTopic:   "koji/status",
+		WillPayload: []byte("offline"),
+		WillRetain:  true,
+	})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	client.Publish("koji/status", []byte("online"), true)
+	broker.waitRetained(t, "koji/status", "online")
+
+	// Drop the connection without DISCONNECT, like a crash would
+	client.Close()
+	broker.waitRetained(t, "koji/status", "offline")
+}
+
+func TestClient_RejectedSubscribeRemovesHandler(t *testing.T) {
+	broker := newTestBroker(t)
+	broker.deny("koji/#")
+
+	client, err := Dial(context.Background(), ClientOptions{Broker: broker.addr(), ClientID: "koji-brain"})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer client.Close()
+
+	var mu sync.Mutex
+	var got []string
+	record := func(name string) Handler {
+		return func(msg Message) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, name)
+		}
+	}
+	if err := client.Subscribe(context.Background(), "koji/#", record("denied")); !errors.Is(err, ErrSubscribeRejected) {
+		t.Fatalf("Subscribe() error = %v, want ErrSubscribeRejected", err)
+	}
+	if err := client.Subscribe(context.Background(), "koji/event/#", record("allowed")); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	client.Publish("koji/event/mic", []byte("{}"), false)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || got[0] != "allowed" {
+		t.Errorf("handlers called = %v, want only the allowed one", got)
+	}
+}
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"koji/event/#", "koji/event/mic", true},
+		{"koji/event/#", "koji/event/kitchen/mic", true},
+		{"koji/event/#", "koji/event", true},
+		{"koji/event/#", "koji/state/mood", false},
+		{"koji/+/mood", "koji/state/mood", true},
+		{"koji/+/mood", "koji/state/action", false},
+		{"koji/state", "koji/state/mood", false},
+		{"koji/state/mood", "koji/state", false},
+		{"koji/state/mood", "koji/state/mood", true},
+	}
+
+	for _, tt := range tests {
+		if got := topicMatches(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestDial_PasswordWithoutUsername(t *testing.T) {
+	_, err := Dial(context.Background(), ClientOptions{Broker: "127.0.0.1:1", ClientID: "koji", Password: "secret"})
+	if !errors.Is(err, ErrPasswordWithoutUsername) {
+		t.Errorf("Dial() error = %v, want ErrPasswordWithoutUsername", err)
+	}
+}
+
+func TestLoadPassword(t *testing.T) {
+	t.Setenv(PasswordEnv, "")
+	if pass, err := LoadPassword(""); pass != "" || err != nil {
+		t.Errorf("LoadPassword() with nothing set = %q, %v, want none", pass, err)
+	}
+
+	t.Setenv(PasswordEnv, "from-env")
+	if pass, err := LoadPassword(""); pass != "from-env" || err != nil {
+		t.Errorf("LoadPassword() from the environment = %q, %v", pass, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "mqtt-pass")
+	os.WriteFile(path, []byte("from-file\n"), 0600)
+	if pass, err := LoadPassword(path); pass != "from-file" || err != nil {
+		t.Errorf("LoadPassword() from a file = %q, %v", pass, err)
+	}
+	if _, err := LoadPassword(path + ".missing"); err == nil {
+		t.Error("LoadPassword() ignored a missing file")
+	}
+}
+
+func TestReadPacket_RejectsHugeLength(t *testing.T) {
+	// PUBLISH claiming the largest encodable remaining length, with no body
+	header := []byte{0x30, 0xff, 0xff, 0xff, 0x7f}
+	_, err := readPacket(bufio.NewReader(bytes.NewReader(header)))
+	if !errors.Is(err, errPacketTooLarge) {
+		t.Errorf("readPacket() error = %v, want errPacketTooLarge", err)
+	}
+}
@@ -0,0 +1,252 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testBroker is an in-process stand-in for an MQTT broker. It supports just
+// enough of MQTT 3.1.1 for the bridge: QoS 0 publish/subscribe, retained
+// messages, wildcards and last will.
+type testBroker struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	retained map[string]Message
+	conns    map[*brokerConn]bool
+	denied   map[string]bool // filters refused with a SUBACK failure
+}
+
+type brokerConn struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	filters []string
+	will    *Message
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+
+	b := &testBroker{
+		ln:       ln,
+		retained: make(map[string]Message),
+		conns:    make(map[*brokerConn]bool),
+		denied:   make(map[string]bool),
+	}
+	go b.acceptLoop()
+	t.Cleanup(b.close)
+	return b
+}
+
+func (b *testBroker) addr() string {
+	return b.ln.Addr().String()
+}
+
+func (b *testBroker) close() {
+	b.ln.Close()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.conns {
+		c.conn.Close()
+	}
+}
+
+// deny makes the broker refuse subscriptions to filter.
+func (b *testBroker) deny(filter string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.denied[filter] = true
+}
+
+// retainedMessage returns the retained message on a topic, if any.
+func (b *testBroker) retainedMessage(topic string) (Message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	msg, ok := b.retained[topic]
+	return msg, ok
+}
+
+// waitRetained waits until the retained payload on a topic equals want.
+func (b *testBroker) waitRetained(t *testing.T, topic, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if msg, ok := b.retainedMessage(topic); ok && string(msg.Payload) == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	msg, _ := b.retainedMessage(topic)
+	t.Fatalf("retained %s = %q, want %q", topic, msg.Payload, want)
+}
+
+func (b *testBroker) acceptLoop() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		go b.serve(&brokerConn{conn: conn})
+	}
+}
+
+func (b *testBroker) serve(c *brokerConn) {
+	r := bufio.NewReader(c.conn)
+
+	connect, err := readPacket(r)
+	if err != nil || connect.kind != packetConnect {
+		c.conn.Close()
+		return
+	}
+	c.will = parseWill(connect.body)
+	c.send(packet{kind: packetConnack, body: []byte{0, 0}})
+
+	b.mu.Lock()
+	b.conns[c] = true
+	b.mu.Unlock()
+
+	clean := false
+	defer func() {
+		b.mu.Lock()
+		delete(b.conns, c)
+		b.mu.Unlock()
+		c.conn.Close()
+		if !clean && c.will != nil {
+			b.route(*c.will)
+		}
+	}()
+
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+
+		switch p.kind {
+		case packetPublish:
+			msg, err := decodePublish(p)
+			if err == nil {
+				b.route(msg)
+			}
+
+		case packetSubscribe:
+			id := p.body[:2]
+			rest := p.body[2:]
+			var codes []byte
+			var filters []string
+			for len(rest) > 0 {
+				filter, next, err := readString(rest)
+				if err != nil || len(next) < 1 {
+					return
+				}
+				filters = append(filters, filter)
+				rest = next[1:]
+			}
+
+			b.mu.Lock()
+			for _, f := range filters {
+				if b.denied[f] {
+					codes = append(codes, 0x80)
+					continue
+				}
+				codes = append(codes, 0)
+				c.filters = append(c.filters, f)
+			}
+			var replay []Message
+			for _, msg := range b.retained {
+				for _, f := range filters {
+					if topicMatches(f, msg.Topic) {
+						replay = append(replay, msg)
+						break
+					}
+				}
+			}
+			b.mu.Unlock()
+
+			c.send(packet{kind: packetSuback, body: append(append([]byte{}, id...), codes...)})
+			for _, msg := range replay {
+				c.send(encodePublish(msg))
+			}
+
+		case packetPingreq:
+			c.send(packet{kind: packetPingresp})
+
+		case packetDisconnect:
+			clean = true
+			return
+		}
+	}
+}
+
+// route stores retained messages and forwards to matching subscribers.
+func (b *testBroker) route(msg Message) {
+	b.mu.Lock()
+	if msg.Retained {
+		if len(msg.Payload) == 0 {
+			delete(b.retained, msg.Topic)
+		} else {
+			b.retained[msg.Topic] = msg
+		}
+	}
+	var targets []*brokerConn
+	for c := range b.conns {
+		for _, f := range c.filters {
+			if topicMatches(f, msg.Topic) {
+				targets = append(targets, c)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	// Live deliveries don't carry the retain flag (MQTT 3.1.1 §3.3.1.3)
+	live := msg
+	live.Retained = false
+	for _, c := range targets {
+		c.send(encodePublish(live))
+	}
+}
+
+func (c *brokerConn) send(p packet) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	writePacket(c.conn, p)
+}
+
+// parseWill extracts the last will from a CONNECT body.
+func parseWill(body []byte) *Message {
+	_, rest, err := readString(body) // protocol name
+	if err != nil || len(rest) < 4 {
+		return nil
+	}
+	flags := rest[1]
+	rest = rest[4:] // level, flags, keepalive
+
+	_, rest, err = readString(rest) // client ID
+	if err != nil || flags&0x04 == 0 {
+		return nil
+	}
+
+	topic, rest, err := readString(rest)
+	if err != nil || len(rest) < 2 {
+		return nil
+	}
+	n := int(binary.BigEndian.Uint16(rest))
+	if len(rest) < 2+n {
+		return nil
+	}
+	return &Message{
+		Topic:    topic,
+		Payload:  append([]byte{}, rest[2:2+n]...),
+		Retained: flags&0x20 != 0,
+	}
+}
@@ -0,0 +1,296 @@
+// Package mqtt bridges Koji's brain to an MQTT broker for home automation.
+//
+// It contains a small MQTT 3.1.1 client (QoS 0 only, which is all the bridge
+// needs) so the brain stays free of third-party dependencies.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrClosed is returned when using a client whose connection has gone away.
+	ErrClosed = errors.New("mqtt: connection closed")
+	// ErrPasswordWithoutUsername is returned by Dial for a password with no
+	// username, which MQTT 3.1.1 doesn't allow (section 3.1.2.9).
+	ErrPasswordWithoutUsername = errors.New("mqtt: a password requires a username")
+	// ErrSubscribeRejected is returned by Subscribe when the broker refuses
+	// the subscription, e.g. because the ACL doesn't allow it.
+	ErrSubscribeRejected = errors.New("mqtt: broker rejected subscription")
+)
+
+// Handler is called for each message received on a subscribed topic.
+type Handler func(msg Message)
+
+// ClientOptions configures a broker connection.
+type ClientOptions struct {
+	Broker    string        // host:port of the broker
+	ClientID  string        // MQTT client identifier
+	Username  string        // optional
+	Password  string        // optional
+	KeepAlive time.Duration // ping interval (default: 30s)
+
+	// Last will, published by the broker if we disappear without a DISCONNECT.
+	WillTopic   string
+	WillPayload []byte
+	WillRetain  bool
+}
+
+// Client is a minimal MQTT 3.1.1 client.
+type Client struct {
+	conn net.Conn
+
+	writeMu sync.Mutex
+
+	mu       sync.RWMutex
+	handlers []subscription
+	nextID   uint16
+	subacks  map[uint16]chan byte // packet ID -> SUBACK return code
+
+	done    chan struct{}
+	errOnce sync.Once
+	err     error
+}
+
+type subscription struct {
+	id      uint16 // packet ID of the SUBSCRIBE
+	filter  string
+	handler Handler
+}
+
+// Dial connects to the broker and completes the CONNECT handshake.
+func Dial(ctx context.Context, opts ClientOptions) (*Client, error) {
+	if opts.Password != "" && opts.Username == "" {
+		return nil, ErrPasswordWithoutUsername
+	}
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = 30 * time.Second
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", opts.Broker)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to broker: %w", err)
+	}
+
+	// Bound the handshake by the context deadline, if any
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	connect := encodeConnect(connectOptions{
+		ClientID:    opts.ClientID,
+		Username:    opts.Username,
+		Password:    opts.Password,
+		KeepAlive:   uint16(opts.KeepAlive / time.Second),
+		WillTopic:   opts.WillTopic,
+		WillPayload: opts.WillPayload,
+		WillRetain:  opts.WillRetain,
+	})
+	if err := writePacket(conn, connect); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("sending CONNECT: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	ack, err := readPacket(reader)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading CONNACK: %w", err)
+	}
+	if ack.kind != packetConnack || len(ack.body) != 2 {
+		conn.Close()
+		return nil, fmt.Errorf("expected CONNACK, got packet type %d", ack.kind)
+	}
+	if code := ack.body[1]; code != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("broker refused connection (code %d)", code)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	c := &Client{
+		conn:    conn,
+		subacks: make(map[uint16]chan byte),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(reader)
+	go c.pingLoop(opts.KeepAlive)
+
+	return c, nil
+}
+
+// Publish sends a QoS 0 message.
+func (c *Client) Publish(topic string, payload []byte, retained bool) error {
+	return c.write(encodePublish(Message{Topic: topic, Payload: payload, Retained: retained}))
+}
+
+// Subscribe registers a handler for a topic filter and waits for the SUBACK.
+// If the subscription fails, the handler is removed again.
+func (c *Client) Subscribe(ctx context.Context, filter string, handler Handler) error {
+	c.mu.Lock()
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID = 1 // packet ID 0 is not allowed
+	}
+	id := c.nextID
+	acked := make(chan byte, 1)
+	c.subacks[id] = acked
+	c.handlers = append(c.handlers, subscription{id: id, filter: filter, handler: handler})
+	c.mu.Unlock()
+
+	err := c.write(encodeSubscribe(id, []string{filter}))
+	if err == nil {
+		select {
+		case code := <-acked:
+			if code&0x80 == 0 {
+				return nil
+			}
+			err = fmt.Errorf("%w: %s", ErrSubscribeRejected, filter)
+		case <-c.done:
+			err = c.Err()
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	// Not subscribed, so the handler must not see messages from a later
+	// subscription that happens to match
+	c.mu.Lock()
+	delete(c.subacks, id)
+	c.handlers = slices.DeleteFunc(c.handlers, func(sub subscription) bool { return sub.id == id })
+	c.mu.Unlock()
+	return err
+}
+
+// Disconnect sends a DISCONNECT (so the broker discards the last will) and closes.
+func (c *Client) Disconnect() error {
+	err := c.write(packet{kind: packetDisconnect})
+	c.close(ErrClosed)
+	return err
+}
+
+// Close drops the connection without a DISCONNECT, which triggers the last will.
+func (c *Client) Close() error {
+	c.close(ErrClosed)
+	return nil
+}
+
+// Done is closed when the connection is lost or closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection ended, or nil while it is still up.
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+func (c *Client) write(p packet) error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := writePacket(c.conn, p); err != nil {
+		c.close(fmt.Errorf("writing packet: %w", err))
+		return err
+	}
+	return nil
+}
+
+func (c *Client) close(err error) {
+	c.errOnce.Do(func() {
+		c.err = err
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// readLoop dispatches incoming packets until the connection drops.
+func (c *Client) readLoop(r *bufio.Reader) {
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			c.close(fmt.Errorf("reading packet: %w", err))
+			return
+		}
+
+		switch p.kind {
+		case packetPublish:
+			msg, err := decodePublish(p)
+			if err != nil {
+				continue // ignore malformed messages
+			}
+			c.dispatch(msg)
+
+		case packetSuback:
+			if len(p.body) < 2 {
+				continue
+			}
+			id := binary.BigEndian.Uint16(p.body)
+			code := byte(0x80) // no return code is no subscription
+			if len(p.body) > 2 {
+				code = p.body[2]
+			}
+			c.mu.Lock()
+			if ch, ok := c.subacks[id]; ok {
+				ch <- code
+				delete(c.subacks, id)
+			}
+			c.mu.Unlock()
+
+		case packetPingresp:
+			// Nothing to do; the read itself proves the connection is alive
+		}
+	}
+}
+
+func (c *Client) dispatch(msg Message) {
+	c.mu.RLock()
+	handlers := make([]Handler, 0, 1)
+	for _, sub := range c.handlers {
+		if topicMatches(sub.filter, msg.Topic) {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	c.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(msg)
+	}
+}
+
+// pingLoop keeps the connection alive between publishes.
+func (c *Client) pingLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.write(packet{kind: packetPingreq}); err != nil {
+				return
+			}
+		}
+	}
+}
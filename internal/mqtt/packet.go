@@ -0,0 +1,253 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Packet types from the MQTT 3.1.1 spec (fixed header, high nibble).
+const (
+	packetConnect    byte = 1
+	packetConnack    byte = 2
+	packetPublish    byte = 3
+	packetSubscribe  byte = 8
+	packetSuback     byte = 9
+	packetPingreq    byte = 12
+	packetPingresp   byte = 13
+	packetDisconnect byte = 14
+)
+
+// maxRemainingLength is the largest length the 4-byte varint can encode.
+const maxRemainingLength = 268435455
+
+// maxIncomingLength caps the packets readPacket accepts. Koji's topics carry
+// small JSON; a broker (or anything pretending to be one) shouldn't be able
+// to make us allocate 256MB with a 5-byte header.
+const maxIncomingLength = 1 << 20
+
+var (
+	errMalformedPacket = errors.New("malformed packet")
+	errPacketTooLarge  = errors.New("packet too large")
+)
+
+// packet is a raw MQTT control packet: fixed header plus body.
+type packet struct {
+	kind  byte // packet type (high nibble of the first byte)
+	flags byte // low nibble of the first byte
+	body  []byte
+}
+
+// readPacket reads one control packet from r.
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+
+	length, err := readRemainingLength(r)
+	if err != nil {
+		return packet{}, err
+	}
+	if length > maxIncomingLength {
+		return packet{}, fmt.Errorf("%w: %d bytes", errPacketTooLarge, length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+
+	return packet{kind: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+// writePacket writes one control packet to w.
+func writePacket(w io.Writer, p packet) error {
+	if len(p.body) > maxRemainingLength {
+		return fmt.Errorf("packet too large: %d bytes", len(p.body))
+	}
+
+	buf := make([]byte, 0, len(p.body)+5)
+	buf = append(buf, p.kind<<4|p.flags&0x0f)
+	buf = appendRemainingLength(buf, len(p.body))
+	buf = append(buf, p.body...)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// readRemainingLength decodes the variable-length "remaining length" field.
+func readRemainingLength(r io.ByteReader) (int, error) {
+	var length, multiplier int = 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			return length, nil
+		}
+		multiplier *= 128
+	}
+	return 0, errMalformedPacket
+}
+
+// appendRemainingLength encodes n as the variable-length "remaining length" field.
+func appendRemainingLength(buf []byte, n int) []byte {
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if n == 0 {
+			return buf
+		}
+	}
+}
+
+// appendString encodes a length-prefixed UTF-8 string.
+func appendString(buf []byte, s string) []byte {
+	return appendBytes(buf, []byte(s))
+}
+
+// appendBytes encodes length-prefixed binary data.
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(b)))
+	return append(buf, b...)
+}
+
+// readString decodes a length-prefixed string, returning it and the rest of the buffer.
+func readString(buf []byte) (string, []byte, error) {
+	if len(buf) < 2 {
+		return "", nil, errMalformedPacket
+	}
+	n := int(binary.BigEndian.Uint16(buf))
+	if len(buf) < 2+n {
+		return "", nil, errMalformedPacket
+	}
+	return string(buf[2 : 2+n]), buf[2+n:], nil
+}
+
+// connectOptions are the fields of a CONNECT packet we support.
+type connectOptions struct {
+	ClientID    string
+	Username    string
+	Password    string
+	KeepAlive   uint16 // seconds
+	WillTopic   string
+	WillPayload []byte
+	WillRetain  bool
+}
+
+// encodeConnect builds a CONNECT packet. Will messages are always QoS 0.
+// A password needs a username (MQTT 3.1.1 section 3.1.2.9); Dial checks.
+func encodeConnect(opts connectOptions) packet {
+	var flags byte = 0x02 // clean session
+	if opts.WillTopic != "" {
+		flags |= 0x04
+		if opts.WillRetain {
+			flags |= 0x20
+		}
+	}
+	if opts.Password != "" {
+		flags |= 0x40
+	}
+	if opts.Username != "" {
+		flags |= 0x80
+	}
+
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags) // protocol level 4 = MQTT 3.1.1
+	body = binary.BigEndian.AppendUint16(body, opts.KeepAlive)
+	body = appendString(body, opts.ClientID)
+	if opts.WillTopic != "" {
+		body = appendString(body, opts.WillTopic)
+		body = appendBytes(body, opts.WillPayload)
+	}
+	if opts.Username != "" {
+		body = appendString(body, opts.Username)
+	}
+	if opts.Password != "" {
+		body = appendString(body, opts.Password)
+	}
+
+	return packet{kind: packetConnect, body: body}
+}
+
+// Message is an application message delivered over MQTT.
+type Message struct {
+	Topic    string
+	Payload  []byte
+	Retained bool
+}
+
+// encodePublish builds a QoS 0 PUBLISH packet.
+func encodePublish(msg Message) packet {
+	var flags byte
+	if msg.Retained {
+		flags |= 0x01
+	}
+	body := appendString(nil, msg.Topic)
+	body = append(body, msg.Payload...)
+	return packet{kind: packetPublish, flags: flags, body: body}
+}
+
+// decodePublish parses a PUBLISH packet. The packet ID (QoS > 0) is skipped.
+func decodePublish(p packet) (Message, error) {
+	topic, rest, err := readString(p.body)
+	if err != nil {
+		return Message{}, err
+	}
+	if qos := (p.flags >> 1) & 0x03; qos > 0 {
+		if len(rest) < 2 {
+			return Message{}, errMalformedPacket
+		}
+		rest = rest[2:]
+	}
+	return Message{
+		Topic:    topic,
+		Payload:  rest,
+		Retained: p.flags&0x01 != 0,
+	}, nil
+}
+
+// encodeSubscribe builds a SUBSCRIBE packet requesting QoS 0 for every filter.
+func encodeSubscribe(id uint16, filters []string) packet {
+	body := binary.BigEndian.AppendUint16(nil, id)
+	for _, f := range filters {
+		body = appendString(body, f)
+		body = append(body, 0)
+	}
+	return packet{kind: packetSubscribe, flags: 0x02, body: body}
+}
+
+// topicMatches reports whether a topic name matches a subscription filter,
+// honouring the single-level (+) and multi-level (#) wildcards.
+func topicMatches(filter, topic string) bool {
+	for {
+		fSeg, fRest, fMore := strings.Cut(filter, "/")
+		tSeg, tRest, tMore := strings.Cut(topic, "/")
+
+		switch {
+		case fSeg == "#":
+			return true
+		case fSeg != "+" && fSeg != tSeg:
+			return false
+		}
+
+		if !fMore || !tMore {
+			// "a/#" also matches the parent level "a"
+			if fMore && !tMore {
+				return fRest == "#"
+			}
+			return fMore == tMore
+		}
+		filter, topic = fRest, tRest
+	}
+}
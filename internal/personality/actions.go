@@ -1,5 +1,7 @@
 package personality
 
+import "slices"
+
 // Action represents something Koji can physically do.
 type Action string
 
@@ -41,6 +43,59 @@ type ActionSet struct {
 	Sound      Action
 }
 
+// Primary returns the most visible action in the set: the movement, unless
+// Koji is staying put, in which case the expression.
+func (s ActionSet) Primary() Action {
+	if (s.Movement == "" || s.Movement == ActionStay) && s.Expression != "" {
+		return s.Expression
+	}
+	return s.Movement
+}
+
+// Channel is the part of Koji's body an action uses. An ActionSet holds one
+// action per channel.
+type Channel string
+
+const (
+	ChannelMovement   Channel = "movement"
+	ChannelExpression Channel = "expression"
+	ChannelSound      Channel = "sound"
+)
+
+// actionChannels assigns every action to a channel.
+var actionChannels = map[Action]Channel{
+	ActionStay:     ChannelMovement,
+	ActionExplore:  ChannelMovement,
+	ActionFlee:     ChannelMovement,
+	ActionApproach: ChannelMovement,
+	ActionRetreat:  ChannelMovement,
+	ActionFreeze:   ChannelMovement,
+
+	ActionWagTail:     ChannelExpression,
+	ActionPerkEars:    ChannelExpression,
+	ActionFlattenEars: ChannelExpression,
+	ActionTiltHead:    ChannelExpression,
+	ActionCrouch:      ChannelExpression,
+	ActionBounce:      ChannelExpression,
+	ActionSpin:        ChannelExpression,
+	ActionCurl:        ChannelExpression,
+	ActionPeek:        ChannelExpression,
+	ActionNuzzle:      ChannelExpression,
+	ActionHeadBob:     ChannelExpression, // a body gesture, even though it goes with music
+
+	ActionWhimper: ChannelSound,
+	ActionChirp:   ChannelSound,
+	ActionBark:    ChannelSound,
+	ActionGrowl:   ChannelSound,
+	ActionYawn:    ChannelSound,
+	ActionPurr:    ChannelSound,
+}
+
+// Channel returns the channel the action uses, or "" for unknown actions.
+func (a Action) Channel() Channel {
+	return actionChannels[a]
+}
+
 // moodActions maps moods to their available/typical action sets.
 // This is what gets passed to the LLM as the vocabulary to choose from.
 var moodActions = map[Mood][]Action{
@@ -91,6 +146,64 @@ func (e *EmotionalState) AvailableActions() []Action {
 	return actions
 }
 
+// ChannelActions returns the mood's vocabulary for one channel. The mood's
+// default action for that channel is always included, so a fallback is
+// always valid.
+func (e *EmotionalState) ChannelActions(ch Channel) []Action {
+	var actions []Action
+	for _, a := range e.AvailableActions() {
+		if a.Channel() == ch {
+			actions = append(actions, a)
+		}
+	}
+
+	def := e.SuggestDefaultAction()
+	var fallback Action
+	switch ch {
+	case ChannelMovement:
+		fallback = def.Movement
+	case ChannelExpression:
+		fallback = def.Expression
+	case ChannelSound:
+		fallback = def.Sound
+	}
+	for _, a := range actions {
+		if a == fallback {
+			return actions
+		}
+	}
+	return append(actions, fallback)
+}
+
+// eventReactions are what Koji does about events that call for something
+// more specific than its mood's usual actions: comforting a sad person is a
+// nuzzle, not the happy tail wag Koji would otherwise give them.
+var eventReactions = map[Event]ActionSet{
+	EventPersonSad:   {ActionStay, ActionNuzzle, ActionPurr},
+	EventPersonHappy: {ActionApproach, ActionBounce, ActionBark},
+	EventPersonAngry: {ActionRetreat, ActionFlattenEars, ActionWhimper},
+}
+
+// SuggestReaction returns the event's specific reaction if it has one and
+// every action in it suits the current mood, so a sad owner gets a nuzzle
+// when Koji ends up happy to see them but not when it's too scared to.
+func (e *EmotionalState) SuggestReaction(event Event) (ActionSet, bool) {
+	reaction, ok := eventReactions[event]
+	if !ok {
+		return ActionSet{}, false
+	}
+	for ch, a := range map[Channel]Action{
+		ChannelMovement:   reaction.Movement,
+		ChannelExpression: reaction.Expression,
+		ChannelSound:      reaction.Sound,
+	} {
+		if !slices.Contains(e.ChannelActions(ch), a) {
+			return ActionSet{}, false
+		}
+	}
+	return reaction, true
+}
+
 // SuggestDefaultAction returns a reasonable default action for the current mood.
 // Used when LLM isn't available or for immediate reactions.
 func (e *EmotionalState) SuggestDefaultAction() ActionSet {
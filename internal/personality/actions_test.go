@@ -0,0 +1,93 @@
+package personality
+
+import "testing"
+
+func TestEveryMoodActionHasAChannel(t *testing.T) {
+	for mood, actions := range moodActions {
+		for _, a := range actions {
+			if a.Channel() == "" {
+				t.Errorf("%s action %s has no channel", mood, a)
+			}
+		}
+	}
+}
+
+func TestChannelActions_IncludeMoodDefaults(t *testing.T) {
+	channels := []Channel{ChannelMovement, ChannelExpression, ChannelSound}
+
+	for mood := range moodActions {
+		state := NewEmotionalState()
+		state.SetMood(mood, IntensityMedium)
+		def := state.SuggestDefaultAction()
+		defaults := []Action{def.Movement, def.Expression, def.Sound}
+
+		for i, ch := range channels {
+			actions := state.ChannelActions(ch)
+			found := false
+			for _, a := range actions {
+				if a == defaults[i] {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s %s vocabulary %v is missing default %s", mood, ch, actions, defaults[i])
+			}
+		}
+	}
+}
+
+func TestAvailableModifiers_IncludeDefaultModifier(t *testing.T) {
+	for mood := range moodActions {
+		state := NewEmotionalState()
+		for _, intensity := range []Intensity{0.1, IntensityLow, IntensityMedium, 0.75, IntensityHigh} {
+			state.SetMood(mood, intensity)
+			want := DefaultModifier(mood, intensity)
+
+			found := false
+			for _, m := range state.AvailableModifiers() {
+				if m == want {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s at %.2f: modifiers %v missing default %s", mood, intensity, state.AvailableModifiers(), want)
+			}
+		}
+	}
+}
+
+func TestActionSet_Primary(t *testing.T) {
+	if got := (ActionSet{ActionApproach, ActionWagTail, ActionChirp}).Primary(); got != ActionApproach {
+		t.Errorf("Primary() = %s, want the movement", got)
+	}
+	if got := (ActionSet{ActionStay, ActionWagTail, ActionPurr}).Primary(); got != ActionWagTail {
+		t.Errorf("Primary() = %s, want the expression when staying put", got)
+	}
+}
+
+func TestSuggestReaction(t *testing.T) {
+	tests := []struct {
+		name  string
+		mood  Mood
+		event Event
+		want  Action // "" for no specific reaction
+	}{
+		{"sad person, Koji happy to comfort", MoodCurious, EventPersonSad, ActionNuzzle},
+		{"sad person, Koji still scared", MoodFrightened, EventPersonSad, ""},
+		{"happy person, shared excitement", MoodHappy, EventPersonHappy, ActionApproach},
+		{"angry person, cautious retreat", MoodHappy, EventPersonAngry, ActionRetreat},
+		{"familiar face, mood decides", MoodCurious, EventFamiliarFace, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := NewEmotionalState()
+			state.SetMood(tt.mood, IntensityMedium)
+			state.ProcessEvent(NewEventContext(tt.event))
+
+			reaction, ok := state.SuggestReaction(tt.event)
+			if got := reaction.Primary(); ok != (tt.want != "") || got != tt.want {
+				t.Errorf("SuggestReaction() in %s = %+v, %v, want %q", state.CurrentMood, reaction, ok, tt.want)
+			}
+		})
+	}
+}
@@ -11,12 +11,22 @@ const (
 	EventSilence   Event = "silence"
 	EventRhythm    Event = "rhythm" // beat detected
 
+	EventNameCalled Event = "name_called" // someone said "Koji"
+
 	// Vision events
 	EventFamiliarFace   Event = "familiar_face"
 	EventUnknownFace    Event = "unknown_face"
+	EventUncertainFace  Event = "uncertain_face" // might be someone Koji knows, can't tell yet
 	EventMotionDetected Event = "motion_detected"
 	EventNoMotion       Event = "no_motion"
 	EventUnknownObject  Event = "unknown_object"
+	EventCrowd          Event = "crowd" // several faces in view at once
+
+	// Someone Koji knows, by the expression they've held for a few frames
+	EventPersonSad       Event = "person_sad"
+	EventPersonHappy     Event = "person_happy"
+	EventPersonAngry     Event = "person_angry"
+	EventPersonSurprised Event = "person_surprised"
 
 	// Physical events
 	EventPetted   Event = "petted"    // touch sensor triggered gently
@@ -29,6 +39,14 @@ const (
 	EventTimePassedLong   Event = "time_passed_long"   // ~2min of nothing
 )
 
+// Well-known EventContext.Metadata keys.
+const (
+	MetaPersonID     = "person_id"    // FaceDB ID of the person the event is about
+	MetaPersonName   = "person_name"  // their name
+	MetaRelationship = "relationship" // owner, family, friend, ...
+	MetaFaceCount    = "face_count"   // how many faces are in view
+)
+
 // EventContext provides additional information about an event.
 type EventContext struct {
 	Event     Event
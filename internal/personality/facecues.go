@@ -0,0 +1,189 @@
+package personality
+
+import (
+	"strconv"
+	"time"
+)
+
+// FaceCuesVersion is bumped whenever the FaceCues format changes incompatibly.
+// Firmware that doesn't understand cues keeps using EmotionIndex alone.
+const FaceCuesVersion = 1
+
+// FaceCueStep is one emotion in a timed face sequence.
+type FaceCueStep struct {
+	Emotion      FaceEmotion `json:"emotion"`
+	EmotionIndex int         `json:"emotion_index"`
+	DurationMs   int         `json:"duration_ms"` // 0 = hold until the next cue
+}
+
+// LookHint tells the face where to look, in LookAssistant.LookAt coordinates.
+// X is -1 (right) to 1 (left), Y is -1 (down) to 1 (up).
+type LookHint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// FaceCues drive the ESP32 face beyond a single emotion: a short intro
+// sequence when a mood starts, eye transition speed, blink rate and gaze.
+type FaceCues struct {
+	Version      int           `json:"version"`
+	ID           int64         `json:"id"`     // changes when a new sequence should play
+	AgeMs        int64         `json:"age_ms"` // time since the sequence started
+	EmotionIndex int           `json:"emotion_index"`
+	Sequence     []FaceCueStep `json:"sequence"`
+
+	TransitionMs    int `json:"transition_ms"`     // EyeTransition ramp duration
+	BlinkIntervalMs int `json:"blink_interval_ms"` // BlinkAssistant timer
+	BlinkClosedMs   int `json:"blink_closed_ms"`   // how long eyes stay shut per blink
+
+	RandomLook     bool      `json:"random_look"`
+	LookIntervalMs int       `json:"look_interval_ms,omitempty"` // LookAssistant timer when random
+	Look           *LookHint `json:"look,omitempty"`
+}
+
+// moodIntros are the emotions shown briefly before a mood's steady emotion,
+// e.g. a flash of surprise before fear sets in.
+var moodIntros = map[Mood][]FaceCueStep{
+	MoodStartled:   {{Emotion: FaceSurprised, DurationMs: 300}},
+	MoodFrightened: {{Emotion: FaceSurprised, DurationMs: 300}},
+	MoodExcited:    {{Emotion: FaceSurprised, DurationMs: 200}},
+	MoodCautious:   {{Emotion: FaceFocused, DurationMs: 400}},
+	MoodHappy:      {{Emotion: FaceGlee, DurationMs: 400}},
+}
+
+// blinkHint describes how a mood blinks.
+type blinkHint struct {
+	IntervalMs int
+	ClosedMs   int
+}
+
+// moodBlinks maps moods to blink rate. Firmware default is 3000ms / 100ms.
+var moodBlinks = map[Mood]blinkHint{
+	MoodCurious:    {3000, 100},
+	MoodExcited:    {2000, 80},
+	MoodHappy:      {3500, 120},
+	MoodStartled:   {6000, 60}, // wide-eyed, barely blinks
+	MoodFrightened: {1200, 60}, // nervous rapid blinking
+	MoodCautious:   {4500, 100},
+	MoodSleepy:     {2500, 600}, // slow, heavy blinks
+}
+
+// moodLooks maps moods to gaze behavior. Moods not listed look around randomly.
+var moodLooks = map[Mood]struct {
+	RandomLook bool
+	IntervalMs int
+	Look       *LookHint
+}{
+	MoodCurious:    {true, 2000, nil},
+	MoodExcited:    {true, 1000, nil},
+	MoodHappy:      {true, 3000, nil},
+	MoodStartled:   {false, 0, &LookHint{0, 0}},    // stare straight ahead
+	MoodFrightened: {true, 600, nil},               // eyes darting
+	MoodCautious:   {true, 1500, nil},              // scanning
+	MoodSleepy:     {false, 0, &LookHint{0, -0.5}}, // drooping gaze
+}
+
+// modifierTransitions maps action modifiers to eye transition duration.
+// Firmware default is 500ms.
+var modifierTransitions = map[ActionModifier]int{
+	ModifierFrantic:  120,
+	ModifierFast:     250,
+	ModifierEager:    300,
+	ModifierNormal:   500,
+	ModifierHesitant: 700,
+	ModifierGentle:   800,
+	ModifierSlow:     1000,
+}
+
+// FaceCues builds face animation cues for the current state. The modifier
+// (from the last action) sets transition speed; pass "" to derive it from
+// mood and intensity. A non-nil look overrides the mood's gaze behavior.
+func (e *EmotionalState) FaceCues(modifier ActionModifier, look *LookHint) FaceCues {
+	steady := e.ToFaceEmotion()
+
+	var sequence []FaceCueStep
+	for _, step := range moodIntros[e.CurrentMood] {
+		if step.Emotion == steady {
+			continue // no point flashing the emotion we're about to hold
+		}
+		step.EmotionIndex = step.Emotion.Index()
+		sequence = append(sequence, step)
+	}
+	sequence = append(sequence, FaceCueStep{
+		Emotion:      steady,
+		EmotionIndex: steady.Index(),
+	})
+
+	if modifier == "" {
+		modifier = DefaultModifier(e.CurrentMood, e.Intensity)
+	}
+	transition, ok := modifierTransitions[modifier]
+	if !ok {
+		transition = modifierTransitions[ModifierNormal]
+	}
+
+	blink, ok := moodBlinks[e.CurrentMood]
+	if !ok {
+		blink = moodBlinks[MoodCurious]
+	}
+
+	cues := FaceCues{
+		Version:         FaceCuesVersion,
+		ID:              e.EnteredAt.UnixMilli(),
+		AgeMs:           e.Duration().Milliseconds(),
+		EmotionIndex:    steady.Index(),
+		Sequence:        sequence,
+		TransitionMs:    transition,
+		BlinkIntervalMs: blink.IntervalMs,
+		BlinkClosedMs:   blink.ClosedMs,
+		RandomLook:      true,
+		LookIntervalMs:  2000,
+	}
+
+	if gaze, ok := moodLooks[e.CurrentMood]; ok {
+		cues.RandomLook = gaze.RandomLook
+		cues.LookIntervalMs = gaze.IntervalMs
+		cues.Look = gaze.Look
+	}
+
+	// An explicit look target (e.g. where a face or sound is) wins
+	if look != nil {
+		cues.RandomLook = false
+		cues.LookIntervalMs = 0
+		cues.Look = look
+	}
+
+	return cues
+}
+
+// LookHintFromMetadata reads an optional look target from event metadata
+// ("look_x" and "look_y", each -1 to 1). Returns nil if absent or invalid.
+func LookHintFromMetadata(metadata map[string]string) *LookHint {
+	xs, okX := metadata["look_x"]
+	ys, okY := metadata["look_y"]
+	if !okX && !okY {
+		return nil
+	}
+
+	x, errX := strconv.ParseFloat(xs, 64)
+	y, errY := strconv.ParseFloat(ys, 64)
+	if (okX && errX != nil) || (okY && errY != nil) {
+		return nil
+	}
+
+	return &LookHint{X: clampUnit(x), Y: clampUnit(y)}
+}
+
+// LookHintDuration is how long an event's look target is held before the
+// face goes back to its mood's gaze behavior.
+const LookHintDuration = 3 * time.Second
+
+func clampUnit(v float64) float64 {
+	if v < -1 {
+		return -1
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
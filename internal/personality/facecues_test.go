@@ -0,0 +1,100 @@
+package personality
+
+import "testing"
+
+func TestFaceCues_IntroSequence(t *testing.T) {
+	state := NewEmotionalState()
+	state.SetMood(MoodFrightened, IntensityMedium)
+
+	cues := state.FaceCues("", nil)
+
+	if cues.Version != FaceCuesVersion {
+		t.Errorf("version = %d, want %d", cues.Version, FaceCuesVersion)
+	}
+	if len(cues.Sequence) != 2 {
+		t.Fatalf("expected 2 steps, got %+v", cues.Sequence)
+	}
+	if cues.Sequence[0].Emotion != FaceSurprised || cues.Sequence[0].DurationMs != 300 {
+		t.Errorf("step 0 = %+v, want surprised for 300ms", cues.Sequence[0])
+	}
+	if cues.Sequence[1].Emotion != FaceScared || cues.Sequence[1].DurationMs != 0 {
+		t.Errorf("step 1 = %+v, want scared held", cues.Sequence[1])
+	}
+	if cues.EmotionIndex != FaceScared.Index() {
+		t.Errorf("emotion_index = %d, want %d", cues.EmotionIndex, FaceScared.Index())
+	}
+}
+
+func TestFaceCues_SkipsIntroMatchingSteadyEmotion(t *testing.T) {
+	state := NewEmotionalState()
+	state.SetMood(MoodStartled, IntensityMedium) // steady emotion is already surprised
+
+	cues := state.FaceCues("", nil)
+
+	if len(cues.Sequence) != 1 || cues.Sequence[0].Emotion != FaceSurprised {
+		t.Errorf("sequence = %+v, want just surprised", cues.Sequence)
+	}
+}
+
+func TestFaceCues_SleepyBlinksSlowly(t *testing.T) {
+	state := NewEmotionalState()
+	curious := state.FaceCues("", nil)
+
+	state.SetMood(MoodSleepy, IntensityMedium)
+	sleepy := state.FaceCues("", nil)
+
+	if sleepy.BlinkClosedMs <= curious.BlinkClosedMs {
+		t.Errorf("sleepy blink closed %dms should be longer than curious %dms",
+			sleepy.BlinkClosedMs, curious.BlinkClosedMs)
+	}
+	if sleepy.RandomLook || sleepy.Look == nil || sleepy.Look.Y >= 0 {
+		t.Errorf("sleepy should look down, got random=%v look=%+v", sleepy.RandomLook, sleepy.Look)
+	}
+}
+
+func TestFaceCues_TransitionFromModifier(t *testing.T) {
+	state := NewEmotionalState()
+
+	fast := state.FaceCues(ModifierFrantic, nil)
+	slow := state.FaceCues(ModifierSlow, nil)
+
+	if fast.TransitionMs >= slow.TransitionMs {
+		t.Errorf("frantic transition %dms should be faster than slow %dms", fast.TransitionMs, slow.TransitionMs)
+	}
+}
+
+func TestFaceCues_LookOverride(t *testing.T) {
+	state := NewEmotionalState()
+
+	cues := state.FaceCues("", &LookHint{X: 0.5, Y: -0.2})
+
+	if cues.RandomLook {
+		t.Error("explicit look should disable random look")
+	}
+	if cues.Look == nil || cues.Look.X != 0.5 {
+		t.Errorf("look = %+v, want x=0.5", cues.Look)
+	}
+}
+
+func TestLookHintFromMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		want     *LookHint
+	}{
+		{"absent", map[string]string{}, nil},
+		{"both", map[string]string{"look_x": "0.3", "look_y": "-0.4"}, &LookHint{0.3, -0.4}},
+		{"x only", map[string]string{"look_x": "-1"}, &LookHint{-1, 0}},
+		{"clamped", map[string]string{"look_x": "5"}, &LookHint{1, 0}},
+		{"invalid", map[string]string{"look_x": "left"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LookHintFromMetadata(tt.metadata)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -81,6 +81,69 @@ var transitionTable = map[Event]map[Mood]MoodTransition{
 		MoodExcited:    {MoodCautious, IntensityMedium}, // wait who are you
 	},
 
+	// Uncertain face - wait, is that you? Stay open rather than flip to wary
+	EventUncertainFace: {
+		MoodCurious:    {MoodCurious, IntensityHigh},    // *squints*
+		MoodSleepy:     {MoodCurious, IntensityLow},     // hmm?
+		MoodCautious:   {MoodCurious, IntensityMedium},  // maybe I know you
+		MoodStartled:   {MoodCautious, IntensityLow},    // who... oh?
+		MoodFrightened: {MoodCautious, IntensityMedium}, // could be a friend
+	},
+
+	// Crowd - lots of faces at once
+	EventCrowd: {
+		MoodCurious:    {MoodCautious, IntensityMedium},   // that's a lot of people
+		MoodHappy:      {MoodExcited, IntensityMedium},    // a party!
+		MoodExcited:    {MoodExcited, IntensityHigh},      // everyone's here!
+		MoodSleepy:     {MoodCurious, IntensityMedium},    // what's going on?
+		MoodCautious:   {MoodCautious, IntensityHigh},     // too many strangers
+		MoodStartled:   {MoodFrightened, IntensityMedium}, // overwhelming
+		MoodFrightened: {MoodFrightened, IntensityHigh},   // hide!
+	},
+
+	// Someone Koji knows looks sad - go comfort them
+	EventPersonSad: {
+		MoodCurious:    {MoodHappy, IntensityLow},       // *nuzzles* it's okay
+		MoodHappy:      {MoodHappy, IntensityLow},       // quiet down, stay close
+		MoodExcited:    {MoodHappy, IntensityLow},       // calm down, they need you
+		MoodSleepy:     {MoodHappy, IntensityLow},       // shuffles over to cuddle
+		MoodCautious:   {MoodCurious, IntensityMedium},  // what's wrong?
+		MoodStartled:   {MoodCautious, IntensityLow},    // oh... are you okay?
+		MoodFrightened: {MoodCautious, IntensityMedium}, // scared together
+	},
+
+	// Someone Koji knows looks happy - share the excitement
+	EventPersonHappy: {
+		MoodCurious:    {MoodExcited, IntensityMedium}, // ooh, what's good?
+		MoodHappy:      {MoodExcited, IntensityHigh},   // yay!
+		MoodExcited:    {MoodExcited, IntensityHigh},   // even more yay!
+		MoodSleepy:     {MoodHappy, IntensityLow},      // sleepy tail wag
+		MoodCautious:   {MoodHappy, IntensityMedium},   // oh, everything's fine
+		MoodStartled:   {MoodHappy, IntensityLow},      // it was a good surprise
+		MoodFrightened: {MoodCautious, IntensityLow},   // if you're smiling...
+	},
+
+	// Someone Koji knows looks angry - back off carefully
+	EventPersonAngry: {
+		MoodCurious:    {MoodCautious, IntensityMedium},   // uh oh
+		MoodHappy:      {MoodCautious, IntensityMedium},   // did I do something?
+		MoodExcited:    {MoodCautious, IntensityHigh},     // calm down, fast
+		MoodSleepy:     {MoodCautious, IntensityLow},      // *quietly slinks off*
+		MoodCautious:   {MoodCautious, IntensityHigh},     // stay out of the way
+		MoodStartled:   {MoodFrightened, IntensityMedium}, // yikes
+		MoodFrightened: {MoodFrightened, IntensityHigh},   // hide!
+	},
+
+	// Someone Koji knows looks surprised - something must be happening
+	EventPersonSurprised: {
+		MoodCurious:  {MoodCurious, IntensityHigh},    // what? what is it?
+		MoodHappy:    {MoodExcited, IntensityMedium},  // ooh, tell me!
+		MoodExcited:  {MoodExcited, IntensityHigh},    // what happened?!
+		MoodSleepy:   {MoodCurious, IntensityMedium},  // huh? *perks up*
+		MoodCautious: {MoodStartled, IntensityMedium}, // something's up
+		MoodStartled: {MoodStartled, IntensityHigh},   // you saw it too?!
+	},
+
 	// Motion detected - something's happening
 	EventMotionDetected: {
 		MoodCurious: {MoodExcited, IntensityMedium}, // ooh what's that
@@ -233,6 +233,23 @@ func TestProcessEvent_Scenarios(t *testing.T) {
 		// General speech
 		{"frightened + speech = cautious", MoodFrightened, EventSpeech, MoodCautious, true},
 		{"sleepy + speech = curious", MoodSleepy, EventSpeech, MoodCurious, true},
+		// Uncertain faces don't flip to wary
+		{"cautious + uncertain face = curious", MoodCautious, EventUncertainFace, MoodCurious, true},
+		{"happy + uncertain face = happy", MoodHappy, EventUncertainFace, MoodHappy, false},
+		// Crowds
+		{"curious + crowd = cautious", MoodCurious, EventCrowd, MoodCautious, true},
+		{"happy + crowd = excited", MoodHappy, EventCrowd, MoodExcited, true},
+		{"startled + crowd = frightened", MoodStartled, EventCrowd, MoodFrightened, true},
+		// Reading a familiar face
+		{"curious + person sad = happy", MoodCurious, EventPersonSad, MoodHappy, true},
+		{"excited + person sad = happy", MoodExcited, EventPersonSad, MoodHappy, true},
+		{"frightened + person sad = cautious", MoodFrightened, EventPersonSad, MoodCautious, true},
+		{"curious + person happy = excited", MoodCurious, EventPersonHappy, MoodExcited, true},
+		{"cautious + person happy = happy", MoodCautious, EventPersonHappy, MoodHappy, true},
+		{"happy + person angry = cautious", MoodHappy, EventPersonAngry, MoodCautious, true},
+		{"startled + person angry = frightened", MoodStartled, EventPersonAngry, MoodFrightened, true},
+		{"sleepy + person surprised = curious", MoodSleepy, EventPersonSurprised, MoodCurious, true},
+		{"frightened + person surprised = frightened", MoodFrightened, EventPersonSurprised, MoodFrightened, false},
 	}
 
 	for _, tt := range tests {
@@ -393,46 +393,67 @@ func (v *VariationEngine) intensityToModifier(intensity Intensity, mood Mood) Ac
 	jitter := (v.rng.Float64() - 0.5) * 0.2 // +/- 0.1
 	adjustedIntensity := float64(intensity) + jitter
 
-	// Mood-specific modifier mappings
+	return DefaultModifier(mood, Intensity(adjustedIntensity))
+}
+
+// moodModifiers lists the modifiers that suit each mood, covering everything
+// DefaultModifier can return for it.
+var moodModifiers = map[Mood][]ActionModifier{
+	MoodCurious:    {ModifierNormal, ModifierEager, ModifierGentle},
+	MoodExcited:    {ModifierFast, ModifierEager, ModifierFrantic},
+	MoodHappy:      {ModifierNormal, ModifierEager, ModifierGentle},
+	MoodStartled:   {ModifierHesitant, ModifierFast, ModifierFrantic},
+	MoodFrightened: {ModifierHesitant, ModifierFast, ModifierFrantic},
+	MoodCautious:   {ModifierSlow, ModifierHesitant},
+	MoodSleepy:     {ModifierGentle, ModifierSlow},
+}
+
+// AvailableModifiers returns the modifiers appropriate for the current mood.
+func (e *EmotionalState) AvailableModifiers() []ActionModifier {
+	modifiers, ok := moodModifiers[e.CurrentMood]
+	if !ok {
+		return moodModifiers[MoodCurious]
+	}
+	return modifiers
+}
+
+// DefaultModifier is the deterministic modifier for a mood and intensity,
+// the same mapping the variation engine uses without its jitter.
+func DefaultModifier(mood Mood, intensity Intensity) ActionModifier {
 	switch mood {
 	case MoodFrightened, MoodStartled:
-		if adjustedIntensity > 0.8 {
+		if intensity > 0.8 {
 			return ModifierFrantic
-		} else if adjustedIntensity > 0.5 {
+		} else if intensity > 0.5 {
 			return ModifierFast
 		}
 		return ModifierHesitant
-
 	case MoodExcited:
-		if adjustedIntensity > 0.8 {
+		if intensity > 0.8 {
 			return ModifierFrantic
-		} else if adjustedIntensity > 0.5 {
+		} else if intensity > 0.5 {
 			return ModifierEager
 		}
 		return ModifierFast
-
 	case MoodSleepy:
-		if adjustedIntensity > 0.7 {
+		if intensity > 0.7 {
 			return ModifierSlow
 		}
 		return ModifierGentle
-
 	case MoodCautious:
-		if adjustedIntensity > 0.6 {
+		if intensity > 0.6 {
 			return ModifierHesitant
 		}
 		return ModifierSlow
-
 	case MoodHappy:
-		if adjustedIntensity > 0.7 {
+		if intensity > 0.7 {
 			return ModifierEager
 		}
 		return ModifierNormal
-
 	default: // Curious and others
-		if adjustedIntensity > 0.7 {
+		if intensity > 0.7 {
 			return ModifierEager
-		} else if adjustedIntensity < 0.3 {
+		} else if intensity < 0.3 {
 			return ModifierGentle
 		}
 		return ModifierNormal
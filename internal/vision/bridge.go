@@ -0,0 +1,396 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alex/koji/internal/api"
+	"github.com/alex/koji/internal/personality"
+)
+
+// BridgeConfig controls how recognitions become brain events.
+type BridgeConfig struct {
+	Cooldown           time.Duration // min time between events about the same person (default: 1m)
+	StrangerCooldown   time.Duration // min time between unknown_face events (default: 30s)
+	CrowdCooldown      time.Duration // min time between crowd events (default: 2m)
+	ExpressionCooldown time.Duration // min time between events about the same person's same expression (default: 2m)
+	Source             string        // EventContext.Source (default: vision)
+}
+
+// DefaultBridgeConfig returns sensible defaults.
+func DefaultBridgeConfig() BridgeConfig {
+	return BridgeConfig{
+		Cooldown:           time.Minute,
+		StrangerCooldown:   30 * time.Second,
+		CrowdCooldown:      2 * time.Minute,
+		ExpressionCooldown: 2 * time.Minute,
+		Source:             "vision",
+	}
+}
+
+// relationshipWeights scale familiar_face intensity: the owner coming into
+// view is a bigger deal than a friend.
+var relationshipWeights = map[Relationship]float64{
+	RelationshipOwner:        1.0,
+	RelationshipFamily:       0.8,
+	RelationshipFriend:       0.6,
+	RelationshipAcquaintance: 0.4,
+}
+
+// expressionEvents are the expressions on a familiar face Koji reacts to.
+var expressionEvents = map[Emotion]personality.Event{
+	EmotionSad:       personality.EventPersonSad,
+	EmotionHappy:     personality.EventPersonHappy,
+	EmotionAngry:     personality.EventPersonAngry,
+	EmotionSurprised: personality.EventPersonSurprised,
+}
+
+// Cooldown keys for events that aren't about one known person.
+const (
+	strangerKey  = "stranger"
+	uncertainKey = "uncertain"
+	crowdKey     = "crowd"
+)
+
+// Bridge turns recognized scenes into personality events and sends them to
+// the brain. Someone standing in view is only announced once per cooldown.
+type Bridge struct {
+	handler api.EventHandler
+	cfg     BridgeConfig
+
+	mu        sync.Mutex
+	lastFired map[string]time.Time // cooldown key -> last event
+	now       func() time.Time
+}
+
+// NewBridge creates a bridge that sends events to handler: the brain itself
+// when running in-process, or an HTTPEventSink for a remote brain.
+func NewBridge(handler api.EventHandler, cfg BridgeConfig) *Bridge {
+	defaults := DefaultBridgeConfig()
+	if cfg.Cooldown == 0 {
+		cfg.Cooldown = defaults.Cooldown
+	}
+	if cfg.StrangerCooldown == 0 {
+		cfg.StrangerCooldown = defaults.StrangerCooldown
+	}
+	if cfg.CrowdCooldown == 0 {
+		cfg.CrowdCooldown = defaults.CrowdCooldown
+	}
+	if cfg.ExpressionCooldown == 0 {
+		cfg.ExpressionCooldown = defaults.ExpressionCooldown
+	}
+	if cfg.Source == "" {
+		cfg.Source = defaults.Source
+	}
+
+	return &Bridge{
+		handler:   handler,
+		cfg:       cfg,
+		lastFired: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// HandleScene sends the events for a scene and returns how many were sent.
+func (b *Bridge) HandleScene(scene *Scene) int {
+	events := b.Events(scene)
+	for _, event := range events {
+		b.handler.HandleEvent(event)
+	}
+	return len(events)
+}
+
+// Events returns the events a scene should produce right now, and starts
+// their cooldowns. Known people come first, strongest first, then strangers,
+// then faces Koji isn't sure about, then the crowd.
+func (b *Bridge) Events(scene *Scene) []personality.EventContext {
+	return b.events(scene.Faces, scene)
+}
+
+// HandleTrackEvents sends events for faces that just entered or whose
+// identity was just decided, rather than for everyone in every frame. Faces
+// the tracker hasn't voted on yet wait for their decision. scene is the
+// tracker's current scene, for crowds. Known people whose expression the
+// tracker has settled on also get an event for it; a single frame's
+// emotion is too noisy, so HandleScene doesn't do this. Returns how many
+// events were sent.
+func (b *Bridge) HandleTrackEvents(changes []TrackEvent, scene *Scene) int {
+	var faces, expressive []FaceResult
+	for _, change := range changes {
+		if change.Type == TrackExited || change.Track.Identity == IdentityPending {
+			continue
+		}
+		face := change.Track.Face()
+		if change.Type != TrackExpression {
+			faces = append(faces, face)
+		}
+		if face.PersonID != "" && face.Emotion != "" {
+			expressive = append(expressive, face)
+		}
+	}
+
+	events := append(b.events(faces, scene), b.expressions(expressive)...)
+	for _, event := range events {
+		b.handler.HandleEvent(event)
+	}
+	return len(events)
+}
+
+// events builds the events for newly seen faces, subject to cooldowns.
+func (b *Bridge) events(faces []FaceResult, scene *Scene) []personality.EventContext {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	var events []personality.EventContext
+	strangers, uncertain := 0, 0
+	for _, face := range faces {
+		if face.Identity == IdentityUncertain {
+			uncertain++
+			continue
+		}
+		if face.PersonID == "" {
+			strangers++
+			continue
+		}
+		if !b.readyLocked(face.PersonID, b.cfg.Cooldown, now) {
+			continue
+		}
+		events = append(events, b.familiarFace(face))
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Intensity > events[j].Intensity })
+
+	if strangers > 0 && b.readyLocked(strangerKey, b.cfg.StrangerCooldown, now) {
+		event := personality.NewEventContext(personality.EventUnknownFace).
+			WithIntensity(min(1, 0.4+0.1*float64(strangers))).
+			WithSource(b.cfg.Source)
+		event.Metadata[personality.MetaRelationship] = string(RelationshipStranger)
+		event.Metadata[personality.MetaFaceCount] = strconv.Itoa(strangers)
+		events = append(events, event)
+	}
+
+	// Not sure who it is: curious rather than wary
+	if uncertain > 0 && b.readyLocked(uncertainKey, b.cfg.StrangerCooldown, now) {
+		event := personality.NewEventContext(personality.EventUncertainFace).WithSource(b.cfg.Source)
+		event.Metadata[personality.MetaFaceCount] = strconv.Itoa(uncertain)
+		events = append(events, event)
+	}
+
+	if scene != nil && scene.Crowd && b.readyLocked(crowdKey, b.cfg.CrowdCooldown, now) {
+		event := personality.NewEventContext(personality.EventCrowd).
+			WithIntensity(min(1, 0.1*float64(len(scene.Faces))+0.3)).
+			WithSource(b.cfg.Source)
+		event.Metadata[personality.MetaFaceCount] = strconv.Itoa(len(scene.Faces))
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// familiarFace builds the event for a recognized person, with intensity from
+// how close they are to Koji and how sure the recognition is.
+func (b *Bridge) familiarFace(face FaceResult) personality.EventContext {
+	weight, ok := relationshipWeights[face.Relationship]
+	if !ok {
+		weight = 0.5
+	}
+	event := personality.NewEventContext(personality.EventFamiliarFace).
+		WithIntensity(min(1, weight*face.Confidence)).
+		WithSource(b.cfg.Source)
+	event.Metadata[personality.MetaPersonID] = face.PersonID
+	event.Metadata[personality.MetaPersonName] = face.Name
+	event.Metadata[personality.MetaRelationship] = string(face.Relationship)
+	return event
+}
+
+// expressions builds the events for familiar faces showing an expression
+// Koji reacts to, subject to cooldowns, with intensity from how close they
+// are to Koji and how clear the expression is.
+func (b *Bridge) expressions(faces []FaceResult) []personality.EventContext {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	var events []personality.EventContext
+	for _, face := range faces {
+		kind, ok := expressionEvents[face.Emotion]
+		if !ok || !b.readyLocked(face.PersonID+"/"+string(face.Emotion), b.cfg.ExpressionCooldown, now) {
+			continue
+		}
+		weight, ok := relationshipWeights[face.Relationship]
+		if !ok {
+			weight = 0.5
+		}
+		event := personality.NewEventContext(kind).
+			WithIntensity(min(1, weight*face.EmotionConf)).
+			WithSource(b.cfg.Source)
+		event.Metadata[personality.MetaPersonID] = face.PersonID
+		event.Metadata[personality.MetaPersonName] = face.Name
+		event.Metadata[personality.MetaRelationship] = string(face.Relationship)
+		events = append(events, event)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Intensity > events[j].Intensity })
+	return events
+}
+
+// readyLocked reports whether key is out of cooldown, and if so restarts it.
+// Caller holds b.mu.
+func (b *Bridge) readyLocked(key string, cooldown time.Duration, now time.Time) bool {
+	if last, ok := b.lastFired[key]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+	b.lastFired[key] = now
+	return true
+}
+
+// HTTPEventSink sends events to a remote brain's POST /api/event. It
+// implements api.EventHandler so it can stand in for the brain.
+type HTTPEventSink struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewHTTPEventSink creates a sink for the brain at baseURL (e.g. "http://brain:8080").
+func NewHTTPEventSink(baseURL string) *HTTPEventSink {
+	return &HTTPEventSink{
+		url:        baseURL + "/api/event",
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// HandleEvent posts the event and reports whether the brain's mood changed.
+// Failures are logged: a missed sighting isn't worth stopping the camera for.
+func (s *HTTPEventSink) HandleEvent(event personality.EventContext) bool {
+	resp, err := s.Send(context.Background(), event)
+	if err != nil {
+		log.Printf("Sending %s to brain: %v", event.Event, err)
+		return false
+	}
+	return resp.MoodChanged
+}
+
+// Send posts the event and returns the brain's response.
+func (s *HTTPEventSink) Send(ctx context.Context, event personality.EventContext) (*api.EventResponse, error) {
+	body, err := json.Marshal(api.EventRequest{
+		Event:     string(event.Event),
+		Intensity: event.Intensity,
+		Source:    event.Source,
+		Metadata:  event.Metadata,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("brain returned %s", resp.Status)
+	}
+
+	var result api.EventResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return &result, nil
+}
+
+// InteractionForwarder tells a remote vision server about events that count
+// towards strangers becoming acquaintances (see InteractionScore): vision
+// sees who is there, but only the brain hears about petting. It wraps the
+// brain's event handler, and forwards from Run so a slow vision server
+// never holds up a touch event. Events are dropped if the queue is full.
+type InteractionForwarder struct {
+	next       api.EventHandler
+	url        string
+	httpClient *http.Client
+	queue      chan personality.Event
+}
+
+// NewInteractionForwarder creates a forwarder that passes events on to next
+// and interactions to the vision server at baseURL (e.g. "http://vision:8081").
+func NewInteractionForwarder(next api.EventHandler, baseURL string) *InteractionForwarder {
+	return &InteractionForwarder{
+		next:       next,
+		url:        baseURL + "/api/interaction",
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		queue:      make(chan personality.Event, 16),
+	}
+}
+
+// HandleEvent passes the event on and queues it for vision if it counts.
+func (f *InteractionForwarder) HandleEvent(event personality.EventContext) bool {
+	changed := f.next.HandleEvent(event)
+	if _, ok := InteractionScore(event.Event); ok {
+		select {
+		case f.queue <- event.Event:
+		default:
+			log.Printf("Dropping %s for vision: queue full", event.Event)
+		}
+	}
+	return changed
+}
+
+// Run sends queued interactions until ctx is cancelled.
+func (f *InteractionForwarder) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event := <-f.queue:
+			promoted, err := f.Send(ctx, event)
+			if err != nil {
+				log.Printf("Sending %s to vision: %v", event, err)
+			} else if len(promoted) > 0 {
+				log.Printf("Vision promoted %d stranger(s) to acquaintances", len(promoted))
+			}
+		}
+	}
+}
+
+// Send posts one interaction and returns the IDs of anyone it promoted.
+func (f *InteractionForwarder) Send(ctx context.Context, event personality.Event) ([]string, error) {
+	body, err := json.Marshal(map[string]personality.Event{"event": event})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", f.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vision returned %s", resp.Status)
+	}
+	var result struct {
+		Promoted []string `json:"promoted"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return result.Promoted, nil
+}
@@ -0,0 +1,199 @@
+package vision
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex/koji/internal/api"
+	"github.com/alex/koji/internal/personality"
+)
+
+// recordingHandler stands in for the brain.
+type recordingHandler struct {
+	events []personality.EventContext
+}
+
+func (h *recordingHandler) HandleEvent(ctx personality.EventContext) bool {
+	h.events = append(h.events, ctx)
+	return true
+}
+
+// testClock is a settable clock for the now fields of the types under test.
+// Types that should agree on the time share one.
+type testClock struct{ now time.Time }
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time          { return c.now }
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func testBridge() (*Bridge, *recordingHandler, *testClock) {
+	handler := &recordingHandler{}
+	b := NewBridge(handler, BridgeConfig{})
+	clock := newTestClock()
+	b.now = clock.Now
+	return b, handler, clock
+}
+
+func familyScene() *Scene {
+	return &Scene{Faces: []FaceResult{
+		{PersonID: "2", Name: "Sam", Relationship: RelationshipFriend, Confidence: 0.9},
+		{PersonID: "1", Name: "Alex", Relationship: RelationshipOwner, Confidence: 0.9, IsOwner: true},
+		{Relationship: RelationshipStranger, Confidence: 0.3},
+	}}
+}
+
+func TestBridge_SceneToEvents(t *testing.T) {
+	b, handler, _ := testBridge()
+
+	if n := b.HandleScene(familyScene()); n != 3 {
+		t.Fatalf("HandleScene() sent %d events, want 3", n)
+	}
+
+	owner, friend, stranger := handler.events[0], handler.events[1], handler.events[2]
+	if owner.Event != personality.EventFamiliarFace || owner.Metadata[personality.MetaPersonName] != "Alex" {
+		t.Errorf("first event = %+v, want the owner", owner)
+	}
+	if owner.Metadata[personality.MetaPersonID] != "1" || owner.Metadata[personality.MetaRelationship] != "owner" {
+		t.Errorf("owner metadata = %v", owner.Metadata)
+	}
+	if owner.Intensity <= friend.Intensity {
+		t.Errorf("owner intensity %.2f should beat friend %.2f", owner.Intensity, friend.Intensity)
+	}
+	if owner.Source != "vision" {
+		t.Errorf("source = %q", owner.Source)
+	}
+	if stranger.Event != personality.EventUnknownFace || stranger.Metadata[personality.MetaPersonID] != "" {
+		t.Errorf("last event = %+v, want unknown_face", stranger)
+	}
+}
+
+func TestBridge_ConfidenceScalesIntensity(t *testing.T) {
+	b, _, _ := testBridge()
+	sure := b.familiarFace(FaceResult{PersonID: "1", Relationship: RelationshipFamily, Confidence: 0.95})
+	unsure := b.familiarFace(FaceResult{PersonID: "1", Relationship: RelationshipFamily, Confidence: 0.62})
+	if sure.Intensity <= unsure.Intensity {
+		t.Errorf("confident match %.2f should be stronger than %.2f", sure.Intensity, unsure.Intensity)
+	}
+}
+
+func TestBridge_Cooldowns(t *testing.T) {
+	b, handler, clock := testBridge()
+
+	b.HandleScene(familyScene())
+	handler.events = nil
+
+	// Still standing there a few frames later: nothing new
+	clock.Advance(5 * time.Second)
+	if n := b.HandleScene(familyScene()); n != 0 {
+		t.Errorf("sent %d events for people already announced", n)
+	}
+
+	// Someone new walks in: only they are announced
+	clock.Advance(5 * time.Second)
+	scene := familyScene()
+	scene.Faces = append(scene.Faces, FaceResult{PersonID: "3", Name: "Jo", Relationship: RelationshipFamily, Confidence: 0.8})
+	scene.Crowd = true
+	b.HandleScene(scene)
+	if len(handler.events) != 2 || handler.events[0].Metadata[personality.MetaPersonName] != "Jo" ||
+		handler.events[1].Event != personality.EventCrowd || handler.events[1].Metadata[personality.MetaFaceCount] != "4" {
+		t.Errorf("events = %+v, want Jo then the crowd", handler.events)
+	}
+	handler.events = nil
+
+	// Strangers cool down sooner than known people
+	clock.Advance(30 * time.Second)
+	b.HandleScene(familyScene())
+	if len(handler.events) != 1 || handler.events[0].Event != personality.EventUnknownFace {
+		t.Errorf("events = %+v, want only the stranger again", handler.events)
+	}
+}
+
+func TestHTTPEventSink(t *testing.T) {
+	var got api.EventRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/event" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(api.EventResponse{Accepted: true, MoodChanged: true})
+	}))
+	defer srv.Close()
+
+	b := NewBridge(NewHTTPEventSink(srv.URL), BridgeConfig{})
+	if n := b.HandleScene(familyScene()); n != 3 {
+		t.Fatalf("HandleScene() = %d", n)
+	}
+	if got.Event != "unknown_face" || got.Source != "vision" || got.Metadata["face_count"] != "1" {
+		t.Errorf("last request = %+v", got)
+	}
+
+	sink := NewHTTPEventSink(srv.URL + "/nowhere")
+	if sink.HandleEvent(personality.NewEventContext(personality.EventFamiliarFace)) {
+		t.Error("HandleEvent() reported a mood change for a failed request")
+	}
+}
+
+func TestBridge_UncertainIsCuriousNotStranger(t *testing.T) {
+	b, handler, _ := testBridge()
+
+	b.HandleTrackEvents([]TrackEvent{
+		{Type: TrackEntered, Track: Track{ID: 1}}, // pending, waits for the vote
+		{Type: TrackIdentified, Track: Track{ID: 2, Identity: IdentityUncertain, Relationship: RelationshipStranger}},
+	}, nil)
+	if len(handler.events) != 1 || handler.events[0].Event != personality.EventUncertainFace {
+		t.Errorf("events = %+v, want one uncertain_face", handler.events)
+	}
+}
+
+// blockingHandler is a brain that doesn't answer until released.
+type blockingHandler struct {
+	release chan struct{}
+	events  chan personality.EventContext
+}
+
+func (h *blockingHandler) HandleEvent(ctx personality.EventContext) bool {
+	<-h.release
+	h.events <- ctx
+	return true
+}
+
+func TestServer_SlowBrainDoesNotHoldUpFrames(t *testing.T) {
+	s := NewServer(":0", sceneDB(t), &frameDetector{faces: []FaceDetection{face(0, axis(0, 0.02))}})
+	clock := newTestClock()
+	s.tracker.now = clock.Now
+	brain := &blockingHandler{release: make(chan struct{}), events: make(chan personality.EventContext, 1)}
+	s.SetBridge(NewBridge(brain, BridgeConfig{}))
+
+	// The owner is identified on the second frame, and the brain hangs on
+	// it; frames keep being answered, well past the queue
+	start := time.Now()
+	for range 2 * bridgeQueue {
+		rec := httptest.NewRecorder()
+		s.handleScene(rec, httptest.NewRequest(http.MethodPost, "/api/scene", strings.NewReader("frame")))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST /api/scene = %d %s", rec.Code, rec.Body)
+		}
+		clock.Advance(250 * time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("frames took %v with a stuck brain", elapsed)
+	}
+
+	close(brain.release)
+	select {
+	case event := <-brain.events:
+		if event.Event != personality.EventFamiliarFace {
+			t.Errorf("brain got %s, want familiar_face", event.Event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("the brain never got the owner's event")
+	}
+}
@@ -0,0 +1,335 @@
+package vision
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+// ErrTooFewSamples is returned by Calibrate when there isn't enough labeled
+// data to compare anyone with themselves and with someone else.
+var ErrTooFewSamples = errors.New("need at least two people, one of them with two samples")
+
+// LabeledEmbedding is an embedding of a known person, for calibration.
+type LabeledEmbedding struct {
+	Person       string       `json:"person"` // name or ID; same label, same person
+	Relationship Relationship `json:"relationship,omitempty"`
+	Embedding    Embedding    `json:"embedding"`
+}
+
+// LabeledFromPeople turns an enrolled database into calibration data,
+// labeled by name.
+func LabeledFromPeople(people []*Person) []LabeledEmbedding {
+	var samples []LabeledEmbedding
+	for _, p := range people {
+		for _, e := range p.Embeddings {
+			samples = append(samples, LabeledEmbedding{Person: p.Name, Relationship: p.Relationship, Embedding: e})
+		}
+	}
+	return samples
+}
+
+// CalibrationConfig tunes Calibrate.
+type CalibrationConfig struct {
+	TargetFAR  float64 // acceptable false accept rate (default: 0.001)
+	OwnerFAR   float64 // for the owner (default: TargetFAR/10)
+	PerPerson  bool    // also recommend a threshold for each person with enough samples
+	MinSamples int     // genuine comparisons a person needs for their own threshold (default: 10)
+	Step       float64 // threshold sweep step (default: 0.005)
+}
+
+// DefaultCalibrationConfig returns the default calibration settings.
+func DefaultCalibrationConfig() CalibrationConfig {
+	return CalibrationConfig{
+		TargetFAR:  0.001,
+		OwnerFAR:   0.0001,
+		MinSamples: 10,
+		Step:       0.005,
+	}
+}
+
+// Score is one comparison: a probe embedding of Probe against the gallery
+// of Claimed, as recognition would make it. Genuine when they're the same
+// person.
+type Score struct {
+	Probe        string
+	Claimed      string
+	Relationship Relationship // of Claimed, whose threshold decides
+	Similarity   float64
+}
+
+// Genuine reports whether the probe and the gallery are the same person.
+func (s Score) Genuine() bool { return s.Probe == s.Claimed }
+
+// Distribution summarizes similarity scores.
+type Distribution struct {
+	Count     int
+	Mean      float64
+	StdDev    float64
+	Min       float64
+	Max       float64
+	Histogram [20]int // bins of 0.05 from 0 to 1; negatives count in the first
+}
+
+// ROCPoint is the error rates at one threshold. TAR against FAR is the ROC
+// curve; FRR against FAR on log axes is the DET curve.
+type ROCPoint struct {
+	Threshold float64
+	FAR       float64 // impostors at or above the threshold
+	FRR       float64 // genuine comparisons below it
+}
+
+// TAR is the true accept rate, 1 - FRR.
+func (p ROCPoint) TAR() float64 { return 1 - p.FRR }
+
+// Analysis is the calibration of one threshold: overall, for a
+// relationship or for a person.
+type Analysis struct {
+	Genuine  Distribution
+	Impostor Distribution
+	ROC      []ROCPoint // by ascending threshold
+
+	EER          float64 // where FAR and FRR meet
+	EERThreshold float64
+
+	TargetFAR   float64
+	Recommended ROCPoint // lowest threshold meeting TargetFAR
+	// Uncertain is set when there are too few impostor comparisons to
+	// measure TargetFAR. No false accepts in the data proves little then,
+	// so the recommendation is also kept above where a normal fit of the
+	// impostor scores puts TargetFAR.
+	Uncertain bool
+	// Unreachable is set when no threshold up to 1 meets TargetFAR, measured
+	// or by the fit. Recommended is then 1: the data can't support the
+	// target, and more or better samples are needed.
+	Unreachable bool
+
+	genuine, impostor []float64 // sorted
+}
+
+// At returns the error rates at any threshold, not just the swept ones.
+func (a *Analysis) At(threshold float64) ROCPoint {
+	return rates(a.genuine, a.impostor, threshold)
+}
+
+// Calibration is the result of Calibrate.
+type Calibration struct {
+	Scores        []Score
+	Overall       *Analysis
+	Relationships map[Relationship]*Analysis
+	People        map[string]*Analysis // with PerPerson, people with enough samples
+}
+
+// Calibrate measures how well samples separate people and recommends
+// recognition thresholds. Each embedding is taken in turn as a probe and
+// compared, by best match as the database does, with the rest of its own
+// person's samples (genuine) and with every other person's samples
+// (impostor). Impostor comparisons count against the claimed person's
+// relationship, since their threshold is the one that would let the
+// impostor in.
+func Calibrate(samples []LabeledEmbedding, cfg CalibrationConfig) (*Calibration, error) {
+	def := DefaultCalibrationConfig()
+	if cfg.TargetFAR == 0 {
+		cfg.TargetFAR = def.TargetFAR
+	}
+	if cfg.OwnerFAR == 0 {
+		cfg.OwnerFAR = cfg.TargetFAR / 10
+	}
+	if cfg.MinSamples == 0 {
+		cfg.MinSamples = def.MinSamples
+	}
+	if cfg.Step == 0 {
+		cfg.Step = def.Step
+	}
+
+	galleries := make(map[string][]Embedding)
+	relationships := make(map[string]Relationship)
+	var names []string
+	for _, s := range samples {
+		if len(s.Embedding) == 0 {
+			return nil, fmt.Errorf("%s: empty embedding", s.Person)
+		}
+		if _, ok := galleries[s.Person]; !ok {
+			names = append(names, s.Person)
+		}
+		galleries[s.Person] = append(galleries[s.Person], s.Embedding)
+		if s.Relationship != "" {
+			relationships[s.Person] = s.Relationship
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if relationships[name] == "" {
+			relationships[name] = RelationshipFriend
+		}
+	}
+
+	c := &Calibration{Relationships: make(map[Relationship]*Analysis), People: make(map[string]*Analysis)}
+	for _, probe := range names {
+		for i, e := range galleries[probe] {
+			for _, claimed := range names {
+				gallery := galleries[claimed]
+				if claimed == probe {
+					gallery = slices.Delete(slices.Clone(gallery), i, i+1) // leave the probe out
+				}
+				if len(gallery) == 0 {
+					continue
+				}
+				c.Scores = append(c.Scores, Score{
+					Probe:        probe,
+					Claimed:      claimed,
+					Relationship: relationships[claimed],
+					Similarity:   gallerySimilarity(e, gallery),
+				})
+			}
+		}
+	}
+
+	c.Overall = analyze(c.Scores, cfg.TargetFAR, cfg.Step)
+	if len(c.Overall.genuine) == 0 || len(c.Overall.impostor) == 0 {
+		return nil, ErrTooFewSamples
+	}
+
+	byRelationship := make(map[Relationship][]Score)
+	byPerson := make(map[string][]Score)
+	for _, s := range c.Scores {
+		byRelationship[s.Relationship] = append(byRelationship[s.Relationship], s)
+		byPerson[s.Claimed] = append(byPerson[s.Claimed], s)
+	}
+	for r, scores := range byRelationship {
+		if a := analyze(scores, cfg.targetFAR(r), cfg.Step); len(a.genuine) > 0 && len(a.impostor) > 0 {
+			c.Relationships[r] = a
+		}
+	}
+	if cfg.PerPerson {
+		for name, scores := range byPerson {
+			a := analyze(scores, cfg.targetFAR(relationships[name]), cfg.Step)
+			if len(a.genuine) >= cfg.MinSamples && len(a.impostor) > 0 {
+				c.People[name] = a
+			}
+		}
+	}
+	return c, nil
+}
+
+func (cfg CalibrationConfig) targetFAR(r Relationship) float64 {
+	if r == RelationshipOwner {
+		return cfg.OwnerFAR
+	}
+	return cfg.TargetFAR
+}
+
+// Thresholds returns the recommended thresholds as a config for
+// FaceDB.SetThresholds. People are keyed by their calibration label.
+func (c *Calibration) Thresholds() Thresholds {
+	t := Thresholds{
+		Default:       round3(c.Overall.Recommended.Threshold),
+		Relationships: make(map[Relationship]float64),
+	}
+	for r, a := range c.Relationships {
+		t.Relationships[r] = round3(a.Recommended.Threshold)
+	}
+	if len(c.People) > 0 {
+		t.People = make(map[string]float64)
+		for name, a := range c.People {
+			t.People[name] = round3(a.Recommended.Threshold)
+		}
+	}
+	return t
+}
+
+func analyze(scores []Score, targetFAR, step float64) *Analysis {
+	a := &Analysis{TargetFAR: targetFAR}
+	for _, s := range scores {
+		if s.Genuine() {
+			a.genuine = append(a.genuine, s.Similarity)
+		} else {
+			a.impostor = append(a.impostor, s.Similarity)
+		}
+	}
+	if len(a.genuine) == 0 || len(a.impostor) == 0 {
+		return a
+	}
+	sort.Float64s(a.genuine)
+	sort.Float64s(a.impostor)
+	a.Genuine = describe(a.genuine)
+	a.Impostor = describe(a.impostor)
+
+	steps := int(math.Round(1 / step))
+	a.ROC = make([]ROCPoint, 0, steps+1)
+	for i := 0; i <= steps; i++ {
+		threshold := math.Round(float64(i)*step*1e6) / 1e6 // 0.6, not 0.6000000000000001
+		a.ROC = append(a.ROC, rates(a.genuine, a.impostor, threshold))
+	}
+
+	// FAR falls and FRR rises with the threshold; the EER is where they cross
+	best := math.Inf(1)
+	for _, p := range a.ROC {
+		if d := math.Abs(p.FAR - p.FRR); d < best {
+			best = d
+			a.EER = (p.FAR + p.FRR) / 2
+			a.EERThreshold = p.Threshold
+		}
+	}
+
+	a.Recommended = a.ROC[len(a.ROC)-1]
+	for _, p := range a.ROC {
+		if p.FAR <= targetFAR {
+			a.Recommended = p
+			break
+		}
+	}
+	a.Uncertain = 1/float64(len(a.impostor)) > targetFAR
+	a.Unreachable = a.Recommended.FAR > targetFAR
+	if a.Uncertain {
+		z := math.Sqrt2 * math.Erfinv(1-2*targetFAR)
+		tail := a.Impostor.Mean + z*a.Impostor.StdDev
+		if tail > 1 {
+			a.Unreachable = true
+		}
+		if tail > a.Recommended.Threshold {
+			a.Recommended = a.At(min(math.Ceil(tail/step)*step, 1))
+		}
+	}
+	return a
+}
+
+// rates counts errors at threshold in sorted genuine and impostor scores.
+// A similarity equal to the threshold is a match, as in FaceDB.
+func rates(genuine, impostor []float64, threshold float64) ROCPoint {
+	rejected := sort.SearchFloat64s(genuine, threshold)
+	accepted := len(impostor) - sort.SearchFloat64s(impostor, threshold)
+	return ROCPoint{
+		Threshold: threshold,
+		FAR:       float64(accepted) / float64(len(impostor)),
+		FRR:       float64(rejected) / float64(len(genuine)),
+	}
+}
+
+func describe(sorted []float64) Distribution {
+	d := Distribution{Count: len(sorted), Min: sorted[0], Max: sorted[len(sorted)-1]}
+	for _, v := range sorted {
+		d.Mean += v
+		d.Histogram[min(max(int(v*20), 0), 19)]++
+	}
+	d.Mean /= float64(len(sorted))
+	for _, v := range sorted {
+		d.StdDev += (v - d.Mean) * (v - d.Mean)
+	}
+	d.StdDev = math.Sqrt(d.StdDev / float64(len(sorted)))
+	return d
+}
+
+func gallerySimilarity(e Embedding, gallery []Embedding) float64 {
+	best := -1.0
+	for _, g := range gallery {
+		best = max(best, cosineSimilarity(e, g))
+	}
+	return best
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,133 @@
+package vision
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// fakeSamples labels FakeDetector embeddings of a few people at varied
+// poses, as photos of them would be.
+func fakeSamples(n int) []LabeledEmbedding {
+	d := NewFakeDetector(128)
+	people := []struct {
+		name         string
+		relationship Relationship
+	}{
+		{"alex", RelationshipOwner},
+		{"jordan", RelationshipFamily},
+		{"sam", RelationshipFriend},
+		{"riley", RelationshipFriend},
+	}
+	var samples []LabeledEmbedding
+	for _, p := range people {
+		for i := range n {
+			samples = append(samples, LabeledEmbedding{
+				Person:       p.name,
+				Relationship: p.relationship,
+				Embedding:    d.Embedding(p.name, 0.1+0.05*float64(i)),
+			})
+		}
+	}
+	return samples
+}
+
+func TestCalibrate_SeparatesPeople(t *testing.T) {
+	c, err := Calibrate(fakeSamples(12), CalibrationConfig{PerPerson: true})
+	if err != nil {
+		t.Fatalf("Calibrate() error = %v", err)
+	}
+
+	o := c.Overall
+	if o.Genuine.Count != 4*12 || o.Impostor.Count != 4*12*3 {
+		t.Errorf("counts = %d genuine, %d impostor, want 48 and 144", o.Genuine.Count, o.Impostor.Count)
+	}
+	if o.Genuine.Min <= o.Impostor.Max {
+		t.Fatalf("genuine min %.3f <= impostor max %.3f, fake people overlap", o.Genuine.Min, o.Impostor.Max)
+	}
+	if o.EER != 0 || o.EERThreshold <= o.Impostor.Max || o.EERThreshold > o.Genuine.Min {
+		t.Errorf("EER = %v at %v, want 0 between %.3f and %.3f", o.EER, o.EERThreshold, o.Impostor.Max, o.Genuine.Min)
+	}
+	if r := o.Recommended; r.FAR != 0 || r.FRR != 0 || r.Threshold <= o.Impostor.Max {
+		t.Errorf("recommended %+v, want no errors above every impostor", r)
+	}
+	if !o.Uncertain {
+		t.Error("144 impostor comparisons can't show a 0.1% FAR, want Uncertain")
+	}
+
+	for i := 1; i < len(o.ROC); i++ {
+		prev, p := o.ROC[i-1], o.ROC[i]
+		if p.FAR > prev.FAR || p.FRR < prev.FRR {
+			t.Fatalf("ROC not monotonic at %v: %+v after %+v", p.Threshold, p, prev)
+		}
+	}
+	if p := o.At(-1); p.FAR != 1 || p.FRR != 0 {
+		t.Errorf("At(-1) = %+v, want everyone accepted", p)
+	}
+
+	if len(c.Relationships) != 3 || c.Relationships[RelationshipOwner] == nil {
+		t.Errorf("relationships = %v, want owner, family and friend", c.Relationships)
+	}
+	if len(c.People) != 4 {
+		t.Errorf("per-person analyses = %d, want 4", len(c.People))
+	}
+
+	th := c.Thresholds()
+	if th.Default == 0 || th.Relationships[RelationshipOwner] == 0 || th.People["alex"] == 0 {
+		t.Errorf("Thresholds() = %+v", th)
+	}
+}
+
+func TestCalibrate_OverlapTradesErrors(t *testing.T) {
+	// Twins: every probe of one is nearly as close to the other
+	var samples []LabeledEmbedding
+	for i := range 10 {
+		tilt := 0.02 * float64(i)
+		samples = append(samples,
+			LabeledEmbedding{Person: "a", Embedding: axis(0, tilt)},
+			LabeledEmbedding{Person: "b", Embedding: axis(0, 0.01+tilt)},
+		)
+	}
+	c, err := Calibrate(samples, CalibrationConfig{TargetFAR: 0.05})
+	if err != nil {
+		t.Fatalf("Calibrate() error = %v", err)
+	}
+	if c.Overall.EER < 0.1 {
+		t.Errorf("EER = %v for twins, want high", c.Overall.EER)
+	}
+	if r := c.Overall.Recommended; r.FAR > 0.05 || r.FRR == 0 {
+		t.Errorf("recommended %+v, want FAR within target at the cost of FRR", r)
+	}
+
+	// With someone unlike them too, the impostor scores spread so wide that
+	// no threshold meets a strict target. That's flagged, and the
+	// recommendation stays a similarity
+	for i := range 10 {
+		samples = append(samples, LabeledEmbedding{Person: "c", Embedding: axis(4, 0.02*float64(i))})
+	}
+	c, err = Calibrate(samples, CalibrationConfig{TargetFAR: 1e-4})
+	if err != nil {
+		t.Fatalf("Calibrate() error = %v", err)
+	}
+	if o := c.Overall; !o.Unreachable || o.Recommended.Threshold > 1 {
+		t.Errorf("recommended %+v (unreachable %v), want at most 1 and Unreachable", o.Recommended, o.Unreachable)
+	}
+	if c.Thresholds().Default > 1 {
+		t.Errorf("Thresholds().Default = %v, above 1", c.Thresholds().Default)
+	}
+}
+
+func TestCalibrate_TooFewSamples(t *testing.T) {
+	tests := [][]LabeledEmbedding{
+		nil,
+		{{Person: "a", Embedding: axis(0, 0)}, {Person: "a", Embedding: axis(0, 0.1)}},
+		{{Person: "a", Embedding: axis(0, 0)}, {Person: "b", Embedding: axis(2, 0)}},
+	}
+	for i, samples := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			if _, err := Calibrate(samples, CalibrationConfig{}); !errors.Is(err, ErrTooFewSamples) {
+				t.Errorf("Calibrate() error = %v, want ErrTooFewSamples", err)
+			}
+		})
+	}
+}
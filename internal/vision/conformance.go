@@ -0,0 +1,172 @@
+package vision
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DetectorFixtures are the images CheckDetector runs a FaceDetector
+// against. For FakeDetector they come from SyntheticImage; for a real
+// sidecar, use photos.
+type DetectorFixtures struct {
+	OneFace      []byte // exactly one clear face
+	SamePerson   []byte // the OneFace person again, from another photo; optional
+	TwoFaces     []byte // two different people
+	NoFace       []byte // an empty room
+	EmbeddingDim int    // expected embedding length; 0 accepts any consistent length
+
+	// Broken is the same implementation with its backend down (a fake with
+	// Err set, a client pointed at a dead sidecar); optional.
+	Broken FaceDetector
+}
+
+// CheckDetector checks that d behaves as the rest of the vision package
+// expects of a FaceDetector. It returns every violation found, joined, or
+// nil. Any implementation can be run through it in a test:
+//
+//	if err := vision.CheckDetector(ctx, detector, fixtures); err != nil {
+//		t.Error(err)
+//	}
+func CheckDetector(ctx context.Context, d FaceDetector, f DetectorFixtures) error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	dim := f.EmbeddingDim
+
+	// Shapes: one face, with a usable embedding
+	one, err := d.DetectFaces(ctx, f.OneFace)
+	switch {
+	case err != nil:
+		fail("DetectFaces(OneFace) error = %v", err)
+	case len(one) != 1:
+		fail("DetectFaces(OneFace) found %d faces, want 1", len(one))
+	default:
+		if dim == 0 {
+			dim = len(one[0].Embedding)
+		}
+		checkDetection(one[0], dim, "DetectFaces(OneFace)", fail)
+
+		again, err := d.DetectFaces(ctx, f.OneFace)
+		if err == nil && len(again) == 1 && cosineSimilarity(again[0].Embedding, one[0].Embedding) < 0.99 {
+			fail("DetectFaces(OneFace) twice gave different embeddings")
+		}
+	}
+
+	if f.SamePerson != nil && len(one) == 1 {
+		same, err := d.DetectFaces(ctx, f.SamePerson)
+		if err != nil || len(same) != 1 {
+			fail("DetectFaces(SamePerson) = %d faces, %v, want 1", len(same), err)
+		} else if sim := cosineSimilarity(same[0].Embedding, one[0].Embedding); sim < 0.6 {
+			fail("the same person twice has similarity %.2f, below the 0.6 recognition threshold", sim)
+		}
+	}
+
+	two, err := d.DetectFaces(ctx, f.TwoFaces)
+	switch {
+	case err != nil:
+		fail("DetectFaces(TwoFaces) error = %v", err)
+	case len(two) != 2:
+		fail("DetectFaces(TwoFaces) found %d faces, want 2", len(two))
+	default:
+		for i, face := range two {
+			checkDetection(face, dim, fmt.Sprintf("DetectFaces(TwoFaces)[%d]", i), fail)
+		}
+		if two[0].BoundingBox == two[1].BoundingBox {
+			fail("DetectFaces(TwoFaces) returned the same box twice")
+		}
+		if sim := cosineSimilarity(two[0].Embedding, two[1].Embedding); sim >= 0.6 {
+			fail("two different people have similarity %.2f, enough to be confused", sim)
+		}
+	}
+
+	// No face is an empty result from DetectFaces, an error from the rest
+	none, err := d.DetectFaces(ctx, f.NoFace)
+	if err != nil || len(none) != 0 {
+		fail("DetectFaces(NoFace) = %d faces, %v, want none and no error", len(none), err)
+	}
+	if _, err := d.ExtractEmbedding(ctx, f.NoFace); !errors.Is(err, ErrNoFaceDetected) {
+		fail("ExtractEmbedding(NoFace) error = %v, want ErrNoFaceDetected", err)
+	}
+	if _, _, err := d.DetectEmotion(ctx, f.NoFace); !errors.Is(err, ErrNoFaceDetected) {
+		fail("DetectEmotion(NoFace) error = %v, want ErrNoFaceDetected", err)
+	}
+
+	if e, err := d.ExtractEmbedding(ctx, f.OneFace); err != nil {
+		fail("ExtractEmbedding(OneFace) error = %v", err)
+	} else if dim != 0 && len(e) != dim {
+		fail("ExtractEmbedding(OneFace) has %d dimensions, want %d", len(e), dim)
+	}
+	if emotion, conf, err := d.DetectEmotion(ctx, f.OneFace); err != nil {
+		fail("DetectEmotion(OneFace) error = %v", err)
+	} else if !validEmotion(emotion) || conf < 0 || conf > 1 {
+		fail("DetectEmotion(OneFace) = %q, %v", emotion, conf)
+	}
+
+	// Garbage is the caller's fault, not "no one there" or "backend down"
+	if _, err := d.DetectFaces(ctx, []byte("not an image")); !errors.Is(err, ErrInvalidImage) {
+		fail("DetectFaces(garbage) error = %v, want ErrInvalidImage", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := d.DetectFaces(canceled, f.OneFace); err == nil {
+		fail("DetectFaces() with a canceled context succeeded")
+	}
+
+	// A backend failure must not look like an empty room
+	if f.Broken != nil {
+		faces, err := f.Broken.DetectFaces(ctx, f.OneFace)
+		if !errors.Is(err, ErrDetectorUnavailable) || errors.Is(err, ErrNoFaceDetected) || len(faces) != 0 {
+			fail("broken DetectFaces() = %d faces, %v, want ErrDetectorUnavailable", len(faces), err)
+		}
+		if _, err := f.Broken.ExtractEmbedding(ctx, f.OneFace); !errors.Is(err, ErrDetectorUnavailable) {
+			fail("broken ExtractEmbedding() error = %v, want ErrDetectorUnavailable", err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkDetection checks one detected face's shape.
+func checkDetection(face FaceDetection, dim int, name string, fail func(string, ...any)) {
+	box := face.BoundingBox
+	if box.Width <= 0 || box.Height <= 0 || box.X < 0 || box.Y < 0 {
+		fail("%s: bounding box %+v", name, box)
+	}
+	if face.Confidence <= 0 || face.Confidence > 1 {
+		fail("%s: confidence %v, want (0, 1]", name, face.Confidence)
+	}
+	if face.Emotion != "" && !validEmotion(face.Emotion) {
+		fail("%s: unknown emotion %q", name, face.Emotion)
+	}
+	if face.EmotionConf < 0 || face.EmotionConf > 1 {
+		fail("%s: emotion confidence %v", name, face.EmotionConf)
+	}
+
+	if len(face.Embedding) == 0 || len(face.Embedding) != dim {
+		fail("%s: embedding has %d dimensions, want %d", name, len(face.Embedding), dim)
+		return
+	}
+	var norm float64
+	for _, v := range face.Embedding {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			fail("%s: embedding is not finite", name)
+			return
+		}
+		norm += v * v
+	}
+	if norm == 0 {
+		fail("%s: embedding is all zeros", name)
+	}
+}
+
+func validEmotion(e Emotion) bool {
+	switch e {
+	case EmotionNeutral, EmotionHappy, EmotionSad, EmotionAngry, EmotionSurprised, EmotionFearful, EmotionDisgusted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package vision
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeyEnv is the environment variable a FaceDB key is read from when no key
+// file is given.
+const KeyEnv = "KOJI_FACE_KEY"
+
+// encryptionScheme names the cipher in encrypted files. It is also bound in
+// as additional data, so a file can't be passed off as another scheme.
+const encryptionScheme = "aes-256-gcm"
+
+var (
+	ErrEncrypted = errors.New("face database is encrypted and no key was given")
+	ErrWrongKey  = errors.New("face database is encrypted with a different key")
+	// ErrNotEncrypted is returned when a key is given but the file is
+	// plaintext. Loading it would let anyone who can write the file plant
+	// an owner; encrypt it deliberately with RotateKey and no old key.
+	ErrNotEncrypted = errors.New("face database is not encrypted but a key was given")
+)
+
+// sealedFile is an encrypted FaceDB on disk. The plaintext is the usual
+// versioned JSON.
+type sealedFile struct {
+	Encrypted string `json:"encrypted"`
+	KeyID     string `json:"key_id"` // identifies the key without revealing it
+	Nonce     []byte `json:"nonce"`
+	Data      []byte `json:"data"`
+}
+
+// GenerateKey returns a new random key, hex encoded like key files.
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// LoadKey reads a key from a file, or from KeyEnv if path is empty. Keys are
+// 32 bytes, hex or base64 encoded. Returns nil with no error if neither is
+// set: the database is stored in plaintext.
+func LoadKey(path string) ([]byte, error) {
+	encoded := os.Getenv(KeyEnv)
+	source := KeyEnv
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading key file: %w", err)
+		}
+		encoded, source = string(data), path
+	}
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := hex.DecodeString(encoded)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(encoded)
+	}
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s: key must be 32 bytes, hex or base64 encoded", source)
+	}
+	return key, nil
+}
+
+func keyID(key []byte) string {
+	sum := sha256.Sum256(append([]byte("koji face key\x00"), key...))
+	return hex.EncodeToString(sum[:4])
+}
+
+// seal encrypts plaintext with key, or returns it as is if key is nil.
+func seal(plaintext, key []byte) ([]byte, error) {
+	if key == nil {
+		return plaintext, nil
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(sealedFile{
+		Encrypted: encryptionScheme,
+		KeyID:     keyID(key),
+		Nonce:     nonce,
+		Data:      gcm.Seal(nil, nonce, plaintext, []byte(encryptionScheme)),
+	}, "", "  ")
+}
+
+// unseal decrypts data with key. With a nil key the data must be plaintext
+// and is returned as is; with a key it must be encrypted with that key.
+func unseal(data, key []byte) ([]byte, error) {
+	var sealed sealedFile
+	if json.Unmarshal(data, &sealed) != nil || sealed.Encrypted == "" {
+		if key != nil {
+			return nil, ErrNotEncrypted
+		}
+		return data, nil
+	}
+	if sealed.Encrypted != encryptionScheme {
+		return nil, fmt.Errorf("unsupported encryption %q", sealed.Encrypted)
+	}
+	if key == nil {
+		return nil, ErrEncrypted
+	}
+	if sealed.KeyID != keyID(key) {
+		return nil, ErrWrongKey
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("damaged encryption header")
+	}
+	plaintext, err := gcm.Open(nil, sealed.Nonce, sealed.Data, []byte(encryptionScheme))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting: %w", err) // tampered or truncated
+	}
+	return plaintext, nil
+}
+
+// isKeyError reports whether err is about the key rather than the file.
+func isKeyError(err error) bool {
+	return errors.Is(err, ErrEncrypted) || errors.Is(err, ErrWrongKey) || errors.Is(err, ErrNotEncrypted)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,171 @@
+package vision
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	encoded, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "face.key")
+	os.WriteFile(path, []byte(encoded+"\n"), 0600)
+	key, err := LoadKey(path)
+	if err != nil {
+		t.Fatalf("LoadKey() error = %v", err)
+	}
+	return key
+}
+
+func TestFaceDB_EncryptedAtRest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faces.json")
+	key := testKey(t)
+	db := testDB(t, path, key)
+	db.EnrollOwner("Alex", []Embedding{axis(0, 0), axis(0, 0.05), axis(0, 0.1)})
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "Alex") || strings.Contains(string(data), "embeddings") {
+		t.Error("the database file contains plaintext")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if owner := testDB(t, path, key).GetOwner(); owner == nil || owner.Name != "Alex" {
+		t.Errorf("reopened owner = %+v", owner)
+	}
+	if _, err := NewFaceDB(path); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("NewFaceDB() without a key error = %v, want ErrEncrypted", err)
+	}
+	if _, err := NewEncryptedFaceDB(path, testKey(t)); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("NewEncryptedFaceDB() with another key error = %v, want ErrWrongKey", err)
+	}
+	if damaged, _ := filepath.Glob(path + ".damaged-*"); len(damaged) != 0 {
+		t.Errorf("a wrong key was treated as damage: %v", damaged)
+	}
+
+	// Tampering is caught, not decrypted into garbage
+	var sealed sealedFile
+	json.Unmarshal(data, &sealed)
+	sealed.Data[0] ^= 1
+	tampered, _ := json.Marshal(sealed)
+	if _, err := unseal(tampered, key); err == nil {
+		t.Error("unseal() accepted a tampered file")
+	}
+}
+
+func TestFaceDB_EncryptsPlaintextAndRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faces.json")
+	plain := testDB(t, path, nil)
+	plain.EnrollOwner("Alex", []Embedding{axis(0, 0), axis(0, 0.05), axis(0, 0.1)})
+
+	plain.Close()
+
+	// A plaintext file isn't trusted once there's a key: anyone who can
+	// write it could plant an owner. It has to be encrypted deliberately.
+	key := testKey(t)
+	if _, err := NewEncryptedFaceDB(path, key); !errors.Is(err, ErrNotEncrypted) {
+		t.Fatalf("NewEncryptedFaceDB() on plaintext error = %v, want ErrNotEncrypted", err)
+	}
+	if damaged, _ := filepath.Glob(path + ".damaged-*"); len(damaged) != 0 {
+		t.Errorf("a plaintext file was treated as damage: %v", damaged)
+	}
+	if err := RotateKey(path, "", nil, key); err != nil {
+		t.Fatalf("RotateKey() from plaintext error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "Alex") {
+		t.Fatal("RotateKey() left the database in plaintext")
+	}
+
+	// Stranger memory is sealed with the same key and rotates with it
+	strangersPath := filepath.Join(filepath.Dir(path), "strangers.json")
+	m, err := NewStrangerMemory(strangersPath, testDB(t, path, key), StrangerConfig{})
+	if err != nil {
+		t.Fatalf("NewStrangerMemory() error = %v", err)
+	}
+	visitor, _ := m.Visit(axis(5, 0))
+	m.Close()
+
+	newKey := testKey(t)
+	if err := RotateKey(path, strangersPath, key, newKey); err != nil {
+		t.Fatalf("RotateKey() error = %v", err)
+	}
+	if _, err := NewEncryptedFaceDB(path, key); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("old key still opens the database: %v", err)
+	}
+	db := testDB(t, path, newKey)
+	if owner := db.GetOwner(); owner == nil {
+		t.Error("new key doesn't open the database")
+	}
+	m, err = NewStrangerMemory(strangersPath, db, StrangerConfig{})
+	if err != nil {
+		t.Fatalf("NewStrangerMemory() after rotation error = %v", err)
+	}
+	if strangers := m.List(); len(strangers) != 1 || strangers[0].ID != visitor.ID {
+		t.Errorf("strangers after rotation = %+v", strangers)
+	}
+	if err := RotateKey(path, strangersPath, key, newKey); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("RotateKey() with the retired key error = %v", err)
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	t.Setenv(KeyEnv, "")
+	if key, err := LoadKey(""); key != nil || err != nil {
+		t.Errorf("LoadKey() with nothing set = %v, %v, want plaintext", key, err)
+	}
+
+	t.Setenv(KeyEnv, "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=") // base64
+	if key, err := LoadKey(""); err != nil || len(key) != 32 || key[31] != 31 {
+		t.Errorf("LoadKey() from the environment = %v, %v", key, err)
+	}
+
+	t.Setenv(KeyEnv, "too short")
+	if _, err := LoadKey(""); err == nil {
+		t.Error("LoadKey() accepted a short key")
+	}
+}
+
+func TestServer_PersonWithoutEmbeddings(t *testing.T) {
+	db := sceneDB(t)
+	s := NewServer(":0", db, nil)
+	owner := db.GetOwner()
+
+	get := func(query, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/people/"+owner.ID+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.handlePerson(rec, req)
+		return rec
+	}
+
+	rec := get("", "")
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "embeddings") {
+		t.Errorf("GET person = %d %s, want no embeddings", rec.Code, rec.Body)
+	}
+	if rec := get("?export=true", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("export without a token = %d, want 403", rec.Code)
+	}
+
+	s.SetAdminToken("secret")
+	if rec := get("?export=true", "guess"); rec.Code != http.StatusForbidden {
+		t.Errorf("export with the wrong token = %d, want 403", rec.Code)
+	}
+	rec = get("?export=true", "secret")
+	var exported Person
+	json.NewDecoder(rec.Body).Decode(&exported)
+	if rec.Code != http.StatusOK || len(exported.Embeddings) != 3 {
+		t.Errorf("admin export = %d with %d embeddings", rec.Code, len(exported.Embeddings))
+	}
+}
@@ -0,0 +1,76 @@
+package vision
+
+// ExpressionConfig controls how per-frame emotions are smoothed into the
+// one a face is showing. Emotion models flicker from frame to frame; a
+// frown caught mid-sentence shouldn't make Koji think its owner is sad.
+type ExpressionConfig struct {
+	Window    int     // frames with an emotion remembered per track (default: 8)
+	MinFrames int     // frames an emotion needs in the window (default: 4)
+	MinShare  float64 // share of the window's confidence it needs (default: 0.6)
+	MinConf   float64 // frames less confident than this are ignored (default: 0.4)
+}
+
+// DefaultExpressionConfig returns sensible defaults for a few frames per
+// second: about two seconds of a steady expression.
+func DefaultExpressionConfig() ExpressionConfig {
+	return ExpressionConfig{
+		Window:    8,
+		MinFrames: 4,
+		MinShare:  0.6,
+		MinConf:   0.4,
+	}
+}
+
+// emotionFrame is one frame's emotion reading.
+type emotionFrame struct {
+	emotion Emotion
+	conf    float64
+}
+
+// expression smooths a track's emotions. Each frame's emotion counts with
+// its confidence. An emotion takes over once it has MinFrames frames and
+// MinShare of the confidence in the window; until another one does, the
+// current expression holds, so a mixed run of frames doesn't flip it.
+type expression struct {
+	cfg    ExpressionConfig
+	frames []emotionFrame
+
+	emotion Emotion
+	conf    float64 // mean confidence of its frames in the window
+}
+
+// add records a frame's emotion and reports whether the expression changed.
+func (x *expression) add(emotion Emotion, conf float64) bool {
+	if emotion == "" || conf < x.cfg.MinConf {
+		return false
+	}
+	x.frames = append(x.frames, emotionFrame{emotion, conf})
+	if len(x.frames) > x.cfg.Window {
+		x.frames = x.frames[len(x.frames)-x.cfg.Window:]
+	}
+
+	var total float64
+	weights := make(map[Emotion]float64)
+	counts := make(map[Emotion]int)
+	for _, f := range x.frames {
+		total += f.conf
+		weights[f.emotion] += f.conf
+		counts[f.emotion]++
+	}
+
+	var best Emotion
+	for emotion, weight := range weights {
+		if best == "" || weight > weights[best] || (weight == weights[best] && emotion < best) {
+			best = emotion
+		}
+	}
+	if counts[best] >= x.cfg.MinFrames && weights[best] >= x.cfg.MinShare*total {
+		changed := best != x.emotion
+		x.emotion, x.conf = best, weights[best]/float64(counts[best])
+		return changed
+	}
+	if c := counts[x.emotion]; c > 0 {
+		x.conf = weights[x.emotion] / float64(c)
+	}
+	return false
+}
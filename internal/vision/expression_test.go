@@ -0,0 +1,88 @@
+package vision
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+func TestExpression_Smoothing(t *testing.T) {
+	x := expression{cfg: DefaultExpressionConfig()}
+
+	// A frown caught mid-sentence isn't sadness
+	for _, e := range []Emotion{EmotionNeutral, EmotionSad, EmotionNeutral, EmotionNeutral, EmotionNeutral} {
+		x.add(e, 0.8)
+	}
+	if x.emotion != EmotionNeutral {
+		t.Fatalf("expression = %q after mostly neutral frames, want neutral", x.emotion)
+	}
+
+	// Unsure frames don't count at all
+	for range 6 {
+		if x.add(EmotionAngry, 0.2) {
+			t.Fatal("low-confidence frames changed the expression")
+		}
+	}
+
+	// Sadness that holds takes over, once
+	changes := 0
+	for range 6 {
+		if x.add(EmotionSad, 0.9) {
+			changes++
+		}
+	}
+	if changes != 1 || x.emotion != EmotionSad || x.conf != 0.9 {
+		t.Errorf("expression = %q (%.2f) after %d changes, want sad once", x.emotion, x.conf, changes)
+	}
+
+	// A mixed run doesn't flip it back and forth
+	for _, e := range []Emotion{EmotionHappy, EmotionNeutral, EmotionHappy, EmotionNeutral} {
+		if x.add(e, 0.8) {
+			t.Fatalf("expression flipped to %q on a mixed run", x.emotion)
+		}
+	}
+}
+
+func TestBridge_ReactsToFamiliarExpressions(t *testing.T) {
+	tracker, _, clock := testTracker(t)
+	b, handler, _ := testBridge()
+	b.now = clock.Now
+
+	frame := func(emotion Emotion) []FaceDetection {
+		alex, visitor := face(0, axis(0, 0.02)), face(300, axis(5, 0))
+		alex.Emotion, alex.EmotionConf = emotion, 0.9
+		visitor.Emotion, visitor.EmotionConf = EmotionAngry, 0.9 // not Koji's business
+		return []FaceDetection{alex, visitor}
+	}
+	step := func(emotion Emotion) {
+		clock.Advance(250 * time.Millisecond)
+		b.HandleTrackEvents(tracker.Update(frame(emotion)), tracker.Scene(SceneConfig{}))
+	}
+
+	for range 3 {
+		step(EmotionNeutral)
+	}
+	handler.events = nil // Alex and the stranger arriving
+
+	for range 8 {
+		step(EmotionSad)
+	}
+	if len(handler.events) != 1 || handler.events[0].Event != personality.EventPersonSad {
+		t.Fatalf("events = %+v, want one person_sad", handler.events)
+	}
+	sad := handler.events[0]
+	if sad.Metadata[personality.MetaPersonName] != "Alex" || sad.Metadata[personality.MetaRelationship] != "owner" || sad.Intensity != 0.9 {
+		t.Errorf("person_sad = %+v, want Alex at 0.9", sad)
+	}
+
+	// Cheering up is news; going back to sad within the cooldown isn't
+	for _, e := range []Emotion{EmotionHappy, EmotionSad} {
+		for range 8 {
+			step(e)
+		}
+	}
+	if len(handler.events) != 2 || handler.events[1].Event != personality.EventPersonHappy {
+		t.Errorf("events = %+v, want sad then happy only", handler.events)
+	}
+}
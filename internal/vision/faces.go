@@ -2,12 +2,12 @@
 package vision
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"os"
-	"path/filepath"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
@@ -39,10 +39,11 @@ const (
 type Relationship string
 
 const (
-	RelationshipOwner    Relationship = "owner"    // primary owner
-	RelationshipFamily   Relationship = "family"   // household members
-	RelationshipFriend   Relationship = "friend"   // recognized visitors
-	RelationshipStranger Relationship = "stranger" // unknown face
+	RelationshipOwner        Relationship = "owner"        // primary owner
+	RelationshipFamily       Relationship = "family"       // household members
+	RelationshipFriend       Relationship = "friend"       // recognized visitors
+	RelationshipAcquaintance Relationship = "acquaintance" // a stranger seen often enough to warm up to
+	RelationshipStranger     Relationship = "stranger"     // unknown face
 )
 
 // Embedding is a face embedding vector.
@@ -51,13 +52,29 @@ type Embedding []float64
 
 // Person represents a known individual.
 type Person struct {
-	ID           string       `json:"id"`
-	Name         string       `json:"name"`
-	Relationship Relationship `json:"relationship"`
-	Embeddings   []Embedding  `json:"embeddings"` // multiple for robustness
-	EnrolledAt   time.Time    `json:"enrolled_at"`
-	LastSeenAt   time.Time    `json:"last_seen_at"`
-	SeenCount    int          `json:"seen_count"`
+	ID           string          `json:"id"`
+	Name         string          `json:"name"`
+	Relationship Relationship    `json:"relationship"`
+	Embeddings   []Embedding     `json:"embeddings"` // multiple for robustness
+	EnrolledAt   time.Time       `json:"enrolled_at"`
+	LastSeenAt   time.Time       `json:"last_seen_at"`
+	SeenCount    int             `json:"seen_count"`            // visits, not frames
+	GalleryLog   []GalleryChange `json:"gallery_log,omitempty"` // samples learned or pruned since enrollment
+}
+
+// clone deep-copies p, so callers outside db.mu can read it while Learn
+// replaces its gallery.
+func (p *Person) clone() *Person {
+	c := *p
+	c.Embeddings = make([]Embedding, len(p.Embeddings))
+	for i, e := range p.Embeddings {
+		c.Embeddings[i] = slices.Clone(e)
+	}
+	c.GalleryLog = slices.Clone(p.GalleryLog)
+	for i := range c.GalleryLog {
+		c.GalleryLog[i].Embedding = slices.Clone(c.GalleryLog[i].Embedding)
+	}
+	return &c
 }
 
 // FaceDetection represents a detected face in an image.
@@ -91,29 +108,70 @@ type FaceDB struct {
 	mu       sync.RWMutex
 	people   map[string]*Person
 	dataPath string
-
-	// Recognition thresholds
-	matchThreshold float64 // cosine similarity threshold for match
-	ownerThreshold float64 // stricter threshold for owner recognition
+	index    *faceIndex // rebuilt whenever people change
+	indexCfg IndexConfig
+	gallery  GalleryConfig
+	storage  StorageConfig
+	key      []byte // encrypts the file at rest, nil for plaintext
+
+	// Sightings are batched so recognizing a face doesn't rewrite the file
+	pendingMu  sync.Mutex
+	pending    map[string]time.Time // person ID -> last seen, not yet applied
+	visits     map[string]int       // person ID -> visits, not yet applied
+	flushTimer *time.Timer
+	closed     bool
+
+	galleryDirty bool // learned samples not yet indexed or saved, under mu
+
+	thresholds Thresholds // cosine similarity needed for a match
 }
 
 // NewFaceDB creates a new face database.
 func NewFaceDB(dataPath string) (*FaceDB, error) {
+	return NewEncryptedFaceDB(dataPath, nil)
+}
+
+// NewEncryptedFaceDB creates a face database stored encrypted with key (see
+// LoadKey). A plaintext file at dataPath is refused with ErrNotEncrypted;
+// encrypt it with RotateKey first. A nil key stores plaintext, as NewFaceDB
+// does.
+func NewEncryptedFaceDB(dataPath string, key []byte) (*FaceDB, error) {
 	db := &FaceDB{
-		people:         make(map[string]*Person),
-		dataPath:       dataPath,
-		matchThreshold: 0.6, // tune based on testing
-		ownerThreshold: 0.7, // higher confidence for owner
+		people:     make(map[string]*Person),
+		dataPath:   dataPath,
+		thresholds: DefaultThresholds(), // calibrate with cmd/calibrate
+		indexCfg:   DefaultIndexConfig(),
+		gallery:    DefaultGalleryConfig(),
+		storage:    DefaultStorageConfig(),
+		pending:    make(map[string]time.Time),
+		visits:     make(map[string]int),
+		key:        key,
 	}
 
 	// Try to load existing data
 	if err := db.load(); err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("loading face database: %w", err)
 	}
+	db.reindex()
 
 	return db, nil
 }
 
+// SetIndexConfig changes how embeddings are indexed for recognition.
+func (db *FaceDB) SetIndexConfig(cfg IndexConfig) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.indexCfg = cfg
+	db.index = nil // don't reuse clusters trained under other settings
+	db.reindex()
+}
+
+// reindex rebuilds the search index after people change. Caller holds db.mu
+// (or has exclusive access).
+func (db *FaceDB) reindex() {
+	db.index = newFaceIndex(db.indexCfg, db.people, db.index)
+}
+
 // Enroll adds a new person to the database.
 // Requires at least 3 embeddings for robustness.
 func (db *FaceDB) Enroll(name string, relationship Relationship, embeddings []Embedding) (*Person, error) {
@@ -147,8 +205,9 @@ func (db *FaceDB) Enroll(name string, relationship Relationship, embeddings []Em
 		delete(db.people, person.ID)
 		return nil, fmt.Errorf("saving database: %w", err)
 	}
+	db.reindex()
 
-	return person, nil
+	return person.clone(), nil
 }
 
 // EnrollOwner is a convenience method for enrolling the primary owner.
@@ -171,24 +230,8 @@ func (db *FaceDB) Recognize(embedding Embedding, emotion Emotion, emotionConf fl
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	var bestMatch *Person
-	var bestSimilarity float64
-
-	for _, person := range db.people {
-		similarity := db.bestSimilarity(embedding, person.Embeddings)
-		if similarity > bestSimilarity {
-			bestSimilarity = similarity
-			bestMatch = person
-		}
-	}
-
-	// Check if we have a confident enough match
-	threshold := db.matchThreshold
-	if bestMatch != nil && bestMatch.Relationship == RelationshipOwner {
-		threshold = db.ownerThreshold
-	}
-
-	if bestSimilarity < threshold {
+	bestMatch, bestSimilarity := db.match(embedding)
+	if bestMatch == nil {
 		// Unknown face
 		return &RecognitionResult{
 			Person:      nil,
@@ -199,8 +242,7 @@ func (db *FaceDB) Recognize(embedding Embedding, emotion Emotion, emotionConf fl
 		}
 	}
 
-	// Update last seen (in a goroutine to not block)
-	go db.recordSighting(bestMatch.ID)
+	db.recordSighting(bestMatch.ID)
 
 	return &RecognitionResult{
 		Person:      bestMatch,
@@ -211,34 +253,115 @@ func (db *FaceDB) Recognize(embedding Embedding, emotion Emotion, emotionConf fl
 	}
 }
 
-// GetOwner returns the enrolled owner, if any.
+// RecognizeAll identifies every face detected in one frame. Results are in
+// the same order as faces. A person can only be in one place at a time, so
+// if two faces match the same person the weaker match is treated as unknown.
+func (db *FaceDB) RecognizeAll(faces []FaceDetection) []*RecognitionResult {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	results := make([]*RecognitionResult, len(faces))
+	for i, face := range faces {
+		person, similarity := db.match(face.Embedding)
+		results[i] = &RecognitionResult{
+			Person:      person,
+			Confidence:  similarity,
+			Emotion:     face.Emotion,
+			EmotionConf: face.EmotionConf,
+			IsOwner:     person != nil && person.Relationship == RelationshipOwner,
+		}
+	}
+
+	// Strongest matches claim their person first
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return results[order[a]].Confidence > results[order[b]].Confidence
+	})
+	claimed := make(map[string]bool)
+	var seen []string
+	for _, i := range order {
+		r := results[i]
+		if r.Person == nil {
+			continue
+		}
+		if claimed[r.Person.ID] {
+			r.Person, r.IsOwner = nil, false
+			continue
+		}
+		claimed[r.Person.ID] = true
+		seen = append(seen, r.Person.ID)
+	}
+
+	if len(seen) > 0 {
+		db.recordSighting(seen...)
+	}
+	return results
+}
+
+// match finds the best matching person for an embedding, or nil if no one
+// is similar enough. Caller holds db.mu.
+func (db *FaceDB) match(embedding Embedding) (*Person, float64) {
+	id, bestSimilarity := db.index.search(embedding)
+	bestMatch := db.people[id]
+
+	// Check if we have a confident enough match
+	if bestSimilarity < db.threshold(bestMatch) {
+		return nil, bestSimilarity
+	}
+	return bestMatch, bestSimilarity
+}
+
+// Nearest returns the most similar person however weak the match, with
+// the similarity and the threshold a match to them needs. Person is nil
+// only if no one can be compared. Nothing is recorded.
+func (db *FaceDB) Nearest(embedding Embedding) (*Person, float64, float64) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	id, similarity := db.index.search(embedding)
+	person := db.people[id]
+	return person, similarity, db.threshold(person)
+}
+
+// threshold is the similarity needed to recognize p. Caller holds db.mu.
+func (db *FaceDB) threshold(p *Person) float64 {
+	return db.thresholds.For(p)
+}
+
+// GetOwner returns a copy of the enrolled owner, if any.
 func (db *FaceDB) GetOwner() *Person {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	for _, p := range db.people {
 		if p.Relationship == RelationshipOwner {
-			return p
+			return p.clone()
 		}
 	}
 	return nil
 }
 
-// GetPerson returns a person by ID.
+// GetPerson returns a copy of a person by ID, or nil.
 func (db *FaceDB) GetPerson(id string) *Person {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	return db.people[id]
+	if p, ok := db.people[id]; ok {
+		return p.clone()
+	}
+	return nil
 }
 
-// ListPeople returns all enrolled people.
+// ListPeople returns copies of all enrolled people.
 func (db *FaceDB) ListPeople() []*Person {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	people := make([]*Person, 0, len(db.people))
 	for _, p := range db.people {
-		people = append(people, p)
+		people = append(people, p.clone())
 	}
 	return people
 }
@@ -253,9 +376,41 @@ func (db *FaceDB) RemovePerson(id string) error {
 	}
 
 	delete(db.people, id)
+	db.reindex()
 	return db.save()
 }
 
+// UpdatePerson renames a person or changes how Koji knows them, e.g. when
+// an acquaintance is given a name. Empty values are left as they are.
+func (db *FaceDB) UpdatePerson(id, name string, relationship Relationship) (*Person, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	p, ok := db.people[id]
+	if !ok {
+		return nil, ErrPersonNotFound
+	}
+	for _, other := range db.people {
+		if other.ID == id {
+			continue
+		}
+		if name != "" && other.Name == name {
+			return nil, ErrPersonExists
+		}
+		if relationship == RelationshipOwner && other.Relationship == RelationshipOwner {
+			return nil, fmt.Errorf("owner already enrolled: %s", other.Name)
+		}
+	}
+
+	if name != "" {
+		p.Name = name
+	}
+	if relationship != "" {
+		p.Relationship = relationship
+	}
+	return p.clone(), db.save()
+}
+
 // HasOwner returns true if an owner has been enrolled.
 func (db *FaceDB) HasOwner() bool {
 	return db.GetOwner() != nil
@@ -274,50 +429,159 @@ func (db *FaceDB) bestSimilarity(query Embedding, references []Embedding) float6
 	return best
 }
 
-// recordSighting updates the last seen time and count for a person.
-func (db *FaceDB) recordSighting(id string) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+// Identify finds the person an embedding belongs to without recording a
+// sighting, or nil if no one is similar enough. The tracker uses it to
+// recognize the same face over several frames.
+func (db *FaceDB) Identify(embedding Embedding) (*Person, float64) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.match(embedding)
+}
 
-	if p, ok := db.people[id]; ok {
-		p.LastSeenAt = time.Now()
-		p.SeenCount++
-		_ = db.save() // best effort
+// RecordVisit counts a new visit from a person: they came into view, as
+// opposed to still being there in the next frame. Like sightings, visits
+// are applied and written by the next Flush.
+func (db *FaceDB) RecordVisit(id string) error {
+	db.mu.RLock()
+	_, ok := db.people[id]
+	db.mu.RUnlock()
+	if !ok {
+		return ErrPersonNotFound
 	}
+
+	db.pendingMu.Lock()
+	defer db.pendingMu.Unlock()
+	db.pending[id] = time.Now()
+	db.visits[id]++
+	db.scheduleFlushLocked()
+	return nil
 }
 
-// save persists the database to disk.
-func (db *FaceDB) save() error {
-	if db.dataPath == "" {
-		return nil // in-memory only
+// recordSighting notes that people were seen just now. The last seen times
+// are applied and written by the next Flush, at most StorageConfig.FlushEvery
+// later. Visits are counted separately by RecordVisit. Safe to call while
+// holding db.mu.
+func (db *FaceDB) recordSighting(ids ...string) {
+	now := time.Now()
+
+	db.pendingMu.Lock()
+	defer db.pendingMu.Unlock()
+
+	for _, id := range ids {
+		db.pending[id] = now
 	}
+	db.scheduleFlushLocked()
+}
 
-	// Ensure directory exists
-	dir := filepath.Dir(db.dataPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
+// scheduleFlush makes sure a Flush runs within StorageConfig.FlushEvery.
+// Safe to call while holding db.mu.
+func (db *FaceDB) scheduleFlush() {
+	db.pendingMu.Lock()
+	defer db.pendingMu.Unlock()
+	db.scheduleFlushLocked()
+}
+
+// scheduleFlushLocked is scheduleFlush for callers holding db.pendingMu.
+func (db *FaceDB) scheduleFlushLocked() {
+	if db.flushTimer == nil && !db.closed {
+		db.flushTimer = time.AfterFunc(db.storage.FlushEvery, func() { _ = db.Flush() })
 	}
+}
 
-	data, err := json.MarshalIndent(db.people, "", "  ")
-	if err != nil {
-		return err
+// Flush applies and writes any batched sightings, visits and learned
+// samples now.
+func (db *FaceDB) Flush() error {
+	db.pendingMu.Lock()
+	pending, visits := db.pending, db.visits
+	db.pending = make(map[string]time.Time)
+	db.visits = make(map[string]int)
+	if db.flushTimer != nil {
+		db.flushTimer.Stop()
+		db.flushTimer = nil
+	}
+	db.pendingMu.Unlock()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if len(pending) == 0 && !db.galleryDirty {
+		return nil
+	}
+	for id, at := range pending {
+		if p, ok := db.people[id]; ok && at.After(p.LastSeenAt) {
+			p.LastSeenAt = at
+		}
+	}
+	for id, n := range visits {
+		if p, ok := db.people[id]; ok {
+			p.SeenCount += n
+		}
+	}
+	if db.galleryDirty {
+		db.reindex()
+		db.galleryDirty = false
+	}
+	return db.save()
+}
+
+// Close writes any batched sightings and visits. The database shouldn't be used after.
+func (db *FaceDB) Close() error {
+	db.pendingMu.Lock()
+	db.closed = true
+	db.pendingMu.Unlock()
+	return db.Flush()
+}
+
+// SetStorageConfig changes how the database is written to disk.
+func (db *FaceDB) SetStorageConfig(cfg StorageConfig) {
+	defaults := DefaultStorageConfig()
+	if cfg.FlushEvery == 0 {
+		cfg.FlushEvery = defaults.FlushEvery
+	}
+	if cfg.Backups == 0 {
+		cfg.Backups = defaults.Backups
+	}
+	if cfg.BackupEvery == 0 {
+		cfg.BackupEvery = defaults.BackupEvery
 	}
 
-	return os.WriteFile(db.dataPath, data, 0644)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.storage = cfg
+}
+
+// sealKey returns the key the database is encrypted with, for files kept
+// alongside it such as the stranger memory.
+func (db *FaceDB) sealKey() []byte {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.key
 }
 
-// load reads the database from disk.
+// save persists the database to disk atomically. Caller holds db.mu.
+func (db *FaceDB) save() error {
+	if db.dataPath == "" {
+		return nil // in-memory only
+	}
+	return writeDB(db.dataPath, db.people, db.storage, db.key)
+}
+
+// load reads the database from disk, migrating old formats and recovering
+// from a backup if the file is damaged.
 func (db *FaceDB) load() error {
 	if db.dataPath == "" {
 		return nil
 	}
 
-	data, err := os.ReadFile(db.dataPath)
+	f, err := loadDB(db.dataPath, db.key)
 	if err != nil {
 		return err
 	}
-
-	return json.Unmarshal(data, &db.people)
+	db.people = f.People
+	if f.Version != schemaVersion || f.SavedAt.IsZero() {
+		return db.save() // upgrade the file now, not on the next change
+	}
+	return nil
 }
 
 // cosineSimilarity computes the cosine similarity between two embeddings.
@@ -63,6 +63,7 @@ func TestFaceDB_EnrollAndRecognize(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewFaceDB() error = %v", err)
 	}
+	t.Cleanup(func() { db.Close() }) // before the temp dir goes
 
 	// Create some fake embeddings (128-dimensional)
 	makeEmbedding := func(seed float64) Embedding {
@@ -217,6 +218,26 @@ func TestFaceDB_RemovePerson(t *testing.T) {
 	}
 }
 
+func TestFaceDB_GetPersonReturnsCopy(t *testing.T) {
+	db, _ := NewFaceDB("")
+
+	embeddings := make([]Embedding, 3)
+	for i := range embeddings {
+		embeddings[i] = Embedding{1, 0, 0}
+	}
+	person, _ := db.Enroll("Copy", RelationshipFriend, embeddings)
+
+	got := db.GetPerson(person.ID)
+	got.Name = "Changed"
+	got.Embeddings[0][0] = 0
+	got.Embeddings = got.Embeddings[:1]
+
+	again := db.GetPerson(person.ID)
+	if again.Name != "Copy" || len(again.Embeddings) != 3 || again.Embeddings[0][0] != 1 {
+		t.Errorf("GetPerson() handed out the stored person: %+v", again)
+	}
+}
+
 func TestFaceDB_LoadNonExistent(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "nonexistent", "faces.json")
@@ -0,0 +1,186 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+)
+
+// syntheticMagic starts every synthetic image.
+const syntheticMagic = "KOJI-SYNTHETIC/1\n"
+
+// SyntheticFace is a face in a synthetic test image.
+type SyntheticFace struct {
+	Identity   string      `json:"identity"`             // same identity, same base embedding
+	Box        BoundingBox `json:"box"`                  // default: a 100px box along the top
+	Pose       float64     `json:"pose,omitempty"`       // 0 is a straight-on look; up to 1 moves the embedding further from it
+	Confidence float64     `json:"confidence,omitempty"` // default: 0.95
+	Emotion    Emotion     `json:"emotion,omitempty"`
+}
+
+// SyntheticImage encodes faces as an image FakeDetector understands. It
+// stands in for a camera frame in tests. No faces is an empty room.
+func SyntheticImage(faces ...SyntheticFace) []byte {
+	data, _ := json.Marshal(struct {
+		Faces []SyntheticFace `json:"faces"`
+	}{faces})
+	return append([]byte(syntheticMagic), data...)
+}
+
+// FakeDetector is a deterministic FaceDetector for tests and for running
+// the vision server without models. It reads SyntheticImage images: the
+// same identity always gets the same embedding, and Pose moves it away by a
+// repeatable amount. Anything else is ErrInvalidImage.
+type FakeDetector struct {
+	Dim int   // embedding length (default: 128)
+	Err error // if set, every call fails with it wrapped in ErrDetectorUnavailable
+}
+
+// NewFakeDetector creates a fake producing embeddings of length dim.
+func NewFakeDetector(dim int) *FakeDetector {
+	if dim == 0 {
+		dim = 128
+	}
+	return &FakeDetector{Dim: dim}
+}
+
+// DetectFaces implements FaceDetector.
+func (d *FakeDetector) DetectFaces(ctx context.Context, image []byte) ([]FaceDetection, error) {
+	faces, err := d.decode(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+
+	detections := make([]FaceDetection, len(faces))
+	for i, f := range faces {
+		detections[i] = FaceDetection{
+			BoundingBox: f.Box,
+			Confidence:  f.Confidence,
+			Embedding:   d.Embedding(f.Identity, f.Pose),
+			Emotion:     f.Emotion,
+		}
+		if f.Emotion != "" {
+			detections[i].EmotionConf = 0.8
+		}
+	}
+	return detections, nil
+}
+
+// ExtractEmbedding implements FaceDetector. The image must hold exactly one
+// face, as a crop would.
+func (d *FakeDetector) ExtractEmbedding(ctx context.Context, faceImage []byte) (Embedding, error) {
+	face, err := d.one(ctx, faceImage)
+	if err != nil {
+		return nil, err
+	}
+	return d.Embedding(face.Identity, face.Pose), nil
+}
+
+// DetectEmotion implements FaceDetector.
+func (d *FakeDetector) DetectEmotion(ctx context.Context, faceImage []byte) (Emotion, float64, error) {
+	face, err := d.one(ctx, faceImage)
+	if err != nil {
+		return "", 0, err
+	}
+	if face.Emotion == "" {
+		return EmotionNeutral, 0.5, nil
+	}
+	return face.Emotion, 0.8, nil
+}
+
+// Embedding returns the unit embedding the fake gives identity at pose.
+func (d *FakeDetector) Embedding(identity string, pose float64) Embedding {
+	base := gaussian(d.dim(), hashSeed(identity))
+	if pose != 0 {
+		offset := gaussian(d.dim(), hashSeed(fmt.Sprintf("%s@%g", identity, pose)))
+		for i := range base {
+			base[i] += pose * offset[i]
+		}
+	}
+	return unit(base)
+}
+
+func (d *FakeDetector) dim() int {
+	if d.Dim == 0 {
+		return 128
+	}
+	return d.Dim
+}
+
+func (d *FakeDetector) one(ctx context.Context, image []byte) (SyntheticFace, error) {
+	faces, err := d.decode(ctx, image)
+	switch {
+	case err != nil:
+		return SyntheticFace{}, err
+	case len(faces) == 0:
+		return SyntheticFace{}, ErrNoFaceDetected
+	case len(faces) > 1:
+		return SyntheticFace{}, ErrMultipleFaces
+	}
+	return faces[0], nil
+}
+
+// decode parses a synthetic image, filling in defaults.
+func (d *FakeDetector) decode(ctx context.Context, image []byte) ([]SyntheticFace, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if d.Err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDetectorUnavailable, d.Err)
+	}
+
+	data, ok := bytes.CutPrefix(image, []byte(syntheticMagic))
+	if !ok {
+		return nil, ErrInvalidImage
+	}
+	var frame struct {
+		Faces []SyntheticFace `json:"faces"`
+	}
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	for i := range frame.Faces {
+		f := &frame.Faces[i]
+		if f.Box.Width == 0 || f.Box.Height == 0 {
+			f.Box = BoundingBox{X: i * 120, Y: 0, Width: 100, Height: 100}
+		}
+		if f.Confidence == 0 {
+			f.Confidence = 0.95
+		}
+	}
+	return frame.Faces, nil
+}
+
+func hashSeed(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func gaussian(dim int, seed uint64) Embedding {
+	rng := rand.New(rand.NewPCG(seed, 0x6b6f6a69)) // "koji"
+	e := make(Embedding, dim)
+	for i := range e {
+		e[i] = rng.NormFloat64()
+	}
+	return unit(e)
+}
+
+func unit(e Embedding) Embedding {
+	var norm float64
+	for _, v := range e {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return e
+	}
+	for i := range e {
+		e[i] /= norm
+	}
+	return e
+}
@@ -0,0 +1,194 @@
+package vision
+
+import (
+	"slices"
+	"time"
+)
+
+// GalleryConfig controls how a person's embeddings adapt as Koji sees them.
+type GalleryConfig struct {
+	MinMargin  float64 // similarity lead over the next best person needed to learn a sample (default: 0.15)
+	TooSimilar float64 // samples closer than this to an existing one add nothing (default: 0.95)
+	MaxSamples int     // per person; beyond this the most redundant sample is pruned (default: 20)
+	MaxLog     int     // audit entries kept per person (default: 100)
+}
+
+// DefaultGalleryConfig returns sensible defaults.
+func DefaultGalleryConfig() GalleryConfig {
+	return GalleryConfig{
+		MinMargin:  0.15,
+		TooSimilar: 0.95,
+		MaxSamples: 20,
+		MaxLog:     100,
+	}
+}
+
+// GalleryAction is what happened to a person's gallery.
+type GalleryAction string
+
+const (
+	GalleryAdded      GalleryAction = "added"       // a confident recognition became a sample
+	GalleryPruned     GalleryAction = "pruned"      // a redundant sample was dropped to stay under the cap
+	GalleryRolledBack GalleryAction = "rolled_back" // later changes were undone
+)
+
+// GalleryChange is one audited change to a person's embeddings. Added and
+// pruned entries keep the embedding so they can be undone.
+type GalleryChange struct {
+	At         time.Time     `json:"at"`
+	Action     GalleryAction `json:"action"`
+	Embedding  Embedding     `json:"embedding,omitempty"`
+	Similarity float64       `json:"similarity,omitempty"` // to the person's gallery when added
+	Margin     float64       `json:"margin,omitempty"`     // over the next best person when added
+	Undone     int           `json:"undone,omitempty"`     // changes reverted, for rollbacks
+}
+
+// SetGalleryConfig changes how galleries adapt.
+func (db *FaceDB) SetGalleryConfig(cfg GalleryConfig) {
+	defaults := DefaultGalleryConfig()
+	if cfg.MinMargin == 0 {
+		cfg.MinMargin = defaults.MinMargin
+	}
+	if cfg.TooSimilar == 0 {
+		cfg.TooSimilar = defaults.TooSimilar
+	}
+	if cfg.MaxSamples == 0 {
+		cfg.MaxSamples = defaults.MaxSamples
+	}
+	if cfg.MaxLog == 0 {
+		cfg.MaxLog = defaults.MaxLog
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.gallery = cfg
+}
+
+// Learn adds embedding to a person's gallery if it is a confident
+// recognition of them that adds something new: it clears their threshold,
+// leads every other person by MinMargin, and isn't a near-duplicate of a
+// sample they already have. Over the cap, the most redundant sample goes.
+// Returns true if the gallery changed. It runs on every good recognition,
+// so the change reaches the index and the disk with the next Flush rather
+// than right away.
+func (db *FaceDB) Learn(id string, embedding Embedding) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	p, ok := db.people[id]
+	if !ok {
+		return false
+	}
+
+	similarity := db.bestSimilarity(embedding, p.Embeddings)
+	if similarity < db.threshold(p) {
+		return false
+	}
+	_, runnerUp := db.index.searchExcept(embedding, id)
+	margin := similarity - runnerUp
+	if margin < db.gallery.MinMargin {
+		return false // could be someone else; learning it would blur the two
+	}
+	if similarity > db.gallery.TooSimilar {
+		return false // nothing new
+	}
+
+	now := time.Now()
+	embeddings := append(slices.Clone(p.Embeddings), embedding)
+	changes := []GalleryChange{{At: now, Action: GalleryAdded, Embedding: embedding, Similarity: similarity, Margin: margin}}
+	for len(embeddings) > db.gallery.MaxSamples {
+		i := mostRedundant(embeddings)
+		changes = append(changes, GalleryChange{At: now, Action: GalleryPruned, Embedding: embeddings[i]})
+		embeddings = slices.Delete(embeddings, i, i+1)
+	}
+
+	db.applyGallery(p, embeddings, changes...)
+	db.galleryDirty = true
+	db.scheduleFlush()
+	return true
+}
+
+// RollbackGallery undoes every learned or pruned sample of a person since
+// the given time, e.g. when recognition of them drifted or someone else's
+// face crept in. Returns how many changes were undone.
+func (db *FaceDB) RollbackGallery(id string, since time.Time) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	p, ok := db.people[id]
+	if !ok {
+		return 0, ErrPersonNotFound
+	}
+
+	embeddings := slices.Clone(p.Embeddings)
+	log := p.GalleryLog
+	undone := 0
+	for len(log) > 0 && log[len(log)-1].At.After(since) {
+		change := log[len(log)-1]
+		log = log[:len(log)-1]
+		switch change.Action {
+		case GalleryAdded:
+			if i := slices.IndexFunc(embeddings, func(e Embedding) bool { return slices.Equal(e, change.Embedding) }); i >= 0 {
+				embeddings = slices.Delete(embeddings, i, i+1)
+			}
+		case GalleryPruned:
+			embeddings = append(embeddings, change.Embedding)
+		case GalleryRolledBack:
+			continue // earlier rollbacks are just history
+		}
+		undone++
+	}
+	if undone == 0 {
+		return 0, nil
+	}
+
+	// Unlike learning, undoing takes effect now: a face that crept in
+	// shouldn't be recognized until the next flush
+	p.GalleryLog = log
+	db.applyGallery(p, embeddings, GalleryChange{At: time.Now(), Action: GalleryRolledBack, Undone: undone})
+	db.reindex()
+	db.galleryDirty = false
+	_ = db.save() // best effort
+	return undone, nil
+}
+
+// GalleryLog returns a person's gallery changes, oldest first.
+func (db *FaceDB) GalleryLog(id string) ([]GalleryChange, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	p, ok := db.people[id]
+	if !ok {
+		return nil, ErrPersonNotFound
+	}
+	return slices.Clone(p.GalleryLog), nil
+}
+
+// applyGallery replaces a person's embeddings and logs the changes. The
+// caller reindexes and saves, or leaves it to the next Flush. Caller holds
+// db.mu.
+func (db *FaceDB) applyGallery(p *Person, embeddings []Embedding, changes ...GalleryChange) {
+	p.Embeddings = embeddings
+	p.GalleryLog = append(p.GalleryLog, changes...)
+	if over := len(p.GalleryLog) - db.gallery.MaxLog; over > 0 {
+		p.GalleryLog = slices.Clone(p.GalleryLog[over:])
+	}
+}
+
+// mostRedundant returns the index of the sample most similar to another
+// one: dropping it loses the least variety. This is the same idea as
+// EnrollmentSession.isTooSimilar, applied after the fact.
+func mostRedundant(embeddings []Embedding) int {
+	worst, worstSim := 0, -2.0
+	for i, a := range embeddings {
+		for j, b := range embeddings {
+			if i == j {
+				continue
+			}
+			if sim := cosineSimilarity(a, b); sim > worstSim {
+				worst, worstSim = i, sim
+			}
+		}
+	}
+	return worst
+}
@@ -0,0 +1,151 @@
+package vision
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// turned is the owner's face from a new angle: clearly them, but not like
+// any enrolled sample.
+func turned(dim int, amount float64) Embedding {
+	e := axis(0, 0)
+	e[dim] = amount
+	return e
+}
+
+func TestFaceDB_LearnOnlyConfidentNewSamples(t *testing.T) {
+	db := sceneDB(t)
+	owner := db.GetOwner()
+
+	if !db.Learn(owner.ID, turned(4, 0.4)) {
+		t.Error("Learn() rejected a confident new angle")
+	}
+	if db.Learn(owner.ID, axis(0, 0.02)) {
+		t.Error("Learn() kept a near-duplicate of an enrolled sample")
+	}
+	between := axis(0, 0)
+	between[2] = 1 // as much Sam as Alex
+	if db.Learn(owner.ID, between) {
+		t.Error("Learn() kept a sample that could be someone else")
+	}
+	if db.Learn(owner.ID, axis(5, 0)) {
+		t.Error("Learn() kept a sample that isn't them")
+	}
+
+	if got := len(db.GetPerson(owner.ID).Embeddings); got != 4 {
+		t.Errorf("owner has %d embeddings, want 4", got)
+	}
+	log, err := db.GalleryLog(owner.ID)
+	if err != nil || len(log) != 1 || log[0].Action != GalleryAdded || log[0].Margin < 0.15 {
+		t.Errorf("GalleryLog() = %+v, %v", log, err)
+	}
+}
+
+func TestFaceDB_LearnIsBatched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faces.json")
+	db := testDB(t, path, nil)
+	owner, _ := db.EnrollOwner("Alex", []Embedding{axis(0, 0), axis(0, 0.05), axis(0, 0.1)})
+	db.Enroll("Sam", RelationshipFriend, []Embedding{axis(2, 0), axis(2, 0.05), axis(2, 0.1)})
+	before, _ := os.ReadFile(path)
+
+	// Learning happens on every good frame: no rebuild and no write yet
+	if !db.Learn(owner.ID, turned(4, 0.4)) {
+		t.Fatal("Learn() rejected a confident new angle")
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Error("Learn() wrote the database straight away")
+	}
+	if n := db.index.len(); n != 6 {
+		t.Errorf("index has %d embeddings before the flush, want the 6 enrolled", n)
+	}
+
+	if err := db.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if n := db.index.len(); n != 7 {
+		t.Errorf("index has %d embeddings after the flush, want 7", n)
+	}
+	if people, _ := ReadPeople(path, nil); len(people) != 2 || len(people[0].Embeddings)+len(people[1].Embeddings) != 7 {
+		t.Error("the learned sample wasn't written by the flush")
+	}
+}
+
+func TestFaceDB_LearnPrunesRedundantSamples(t *testing.T) {
+	db := sceneDB(t)
+	db.SetGalleryConfig(GalleryConfig{MaxSamples: 4})
+	owner := db.GetOwner()
+	enrolled := owner.Embeddings
+
+	db.Learn(owner.ID, turned(4, 0.4))
+	db.Learn(owner.ID, turned(5, 0.4))
+
+	embeddings := db.GetPerson(owner.ID).Embeddings
+	if len(embeddings) != 4 {
+		t.Fatalf("owner has %d embeddings, want the cap of 4", len(embeddings))
+	}
+	for _, e := range []Embedding{turned(4, 0.4), turned(5, 0.4)} {
+		if !slices.ContainsFunc(embeddings, func(got Embedding) bool { return slices.Equal(got, e) }) {
+			t.Errorf("the new angle %v was pruned instead of a near-duplicate", e)
+		}
+	}
+	log, _ := db.GalleryLog(owner.ID)
+	if len(log) != 3 || log[2].Action != GalleryPruned {
+		t.Fatalf("GalleryLog() = %+v, want two additions and a prune", log)
+	}
+	if !slices.ContainsFunc(enrolled, func(e Embedding) bool { return slices.Equal(e, log[2].Embedding) }) {
+		t.Errorf("pruned %v, want one of the enrolled samples", log[2].Embedding)
+	}
+}
+
+func TestFaceDB_RollbackGallery(t *testing.T) {
+	db := sceneDB(t)
+	db.SetGalleryConfig(GalleryConfig{MaxSamples: 4})
+	owner := db.GetOwner()
+	enrolled := owner.Embeddings
+
+	db.Learn(owner.ID, turned(4, 0.4))
+	since := time.Now()
+	time.Sleep(time.Millisecond)
+	db.Learn(owner.ID, turned(5, 0.4)) // also prunes
+
+	undone, err := db.RollbackGallery(owner.ID, since)
+	if err != nil || undone != 2 {
+		t.Fatalf("RollbackGallery() = %d, %v, want 2 changes undone", undone, err)
+	}
+	embeddings := db.GetPerson(owner.ID).Embeddings
+	want := append(slices.Clone(enrolled), turned(4, 0.4))
+	if len(embeddings) != len(want) {
+		t.Fatalf("after rollback: %d embeddings, want %d", len(embeddings), len(want))
+	}
+	for _, e := range want {
+		if !slices.ContainsFunc(embeddings, func(got Embedding) bool { return slices.Equal(got, e) }) {
+			t.Errorf("after rollback: missing %v", e)
+		}
+	}
+
+	log, _ := db.GalleryLog(owner.ID)
+	if len(log) != 2 || log[1].Action != GalleryRolledBack || log[1].Undone != 2 {
+		t.Errorf("GalleryLog() = %+v, want the first addition and the rollback", log)
+	}
+
+	if _, err := db.RollbackGallery("nobody", since); err != ErrPersonNotFound {
+		t.Errorf("RollbackGallery(unknown) error = %v", err)
+	}
+}
+
+func TestTracker_LearnsWhileRecognizing(t *testing.T) {
+	db := sceneDB(t)
+	tracker := NewTracker(db, DefaultTrackerConfig())
+	owner := db.GetOwner()
+
+	// Alex turns their head as Koji watches
+	for i, e := range []Embedding{axis(0, 0.02), axis(0, 0.02), turned(4, 0.4)} {
+		tracker.Update([]FaceDetection{face(i*5, e)})
+	}
+	if got := len(db.GetPerson(owner.ID).Embeddings); got != 4 {
+		t.Errorf("owner has %d embeddings, want the new angle learned", got)
+	}
+}
@@ -0,0 +1,365 @@
+package vision
+
+import (
+	"math"
+	"sort"
+)
+
+// Quantization is how the face index stores vectors.
+type Quantization string
+
+const (
+	QuantFloat32 Quantization = "float32" // 4 bytes per dimension, near-exact
+	QuantInt8    Quantization = "int8"    // 1 byte per dimension plus a scale, ~0.01 similarity error
+)
+
+// IndexConfig tunes the face index. The defaults are exact for small
+// databases and switch to approximate search as the gallery grows.
+type IndexConfig struct {
+	Quantization Quantization // default: float32
+
+	// CoarsePeople, if set, first ranks people by their centroid and only
+	// compares against the embeddings of the best CoarsePeople of them.
+	CoarsePeople int
+
+	// IVF splits the embeddings into clusters once there are at least
+	// IVFThreshold of them (default: 2000) and searches only the IVFProbe
+	// clusters closest to the query (default: 8). IVFLists is the number
+	// of clusters (default: sqrt of the embedding count).
+	IVFThreshold int
+	IVFLists     int
+	IVFProbe     int
+}
+
+// DefaultIndexConfig returns the default index settings.
+func DefaultIndexConfig() IndexConfig {
+	return IndexConfig{
+		Quantization: QuantFloat32,
+		IVFThreshold: 2000,
+		IVFProbe:     8,
+	}
+}
+
+// ivfTrainSample caps how many vectors k-means trains on, and ivfTrainRounds
+// how many iterations it runs. Assignment still covers every vector.
+const (
+	ivfTrainSample = 4096
+	ivfTrainRounds = 8
+)
+
+// faceIndex answers "which stored embedding is most similar to this one"
+// over pre-normalized, quantized vectors. It is rebuilt by FaceDB whenever
+// the set of people changes and is read-only in between.
+type faceIndex struct {
+	cfg IndexConfig
+	dim int
+
+	// One row per stored embedding, unit length, flattened
+	f32    []float32 // n*dim, QuantFloat32
+	i8     []int8    // n*dim, QuantInt8
+	scales []float32 // n, QuantInt8: row value = code * scale
+	owner  []int     // row -> index into ids
+
+	ids       []string    // person IDs
+	centroids [][]float32 // per person, unit length
+	rows      [][]int     // per person, their rows
+
+	// IVF, nil below the threshold
+	lists     [][]int
+	listHeads [][]float32
+	trainedN  int // row count the clusters were trained on
+}
+
+// newFaceIndex builds an index over everyone's embeddings. Clusters from
+// prev are reused until the gallery has doubled, since training is slow.
+func newFaceIndex(cfg IndexConfig, people map[string]*Person, prev *faceIndex) *faceIndex {
+	defaults := DefaultIndexConfig()
+	if cfg.Quantization == "" {
+		cfg.Quantization = defaults.Quantization
+	}
+	if cfg.IVFThreshold == 0 {
+		cfg.IVFThreshold = defaults.IVFThreshold
+	}
+	if cfg.IVFProbe == 0 {
+		cfg.IVFProbe = defaults.IVFProbe
+	}
+
+	idx := &faceIndex{cfg: cfg}
+
+	// Stable order so rebuilding gives the same index
+	ids := make([]string, 0, len(people))
+	for id := range people {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		var rows []int
+		var sum []float32
+		for _, e := range people[id].Embeddings {
+			if idx.dim == 0 {
+				idx.dim = len(e)
+			}
+			v := normalize(e)
+			if len(e) != idx.dim || v == nil {
+				continue // wrong model or a zero vector, can never match
+			}
+			if sum == nil {
+				sum = make([]float32, idx.dim)
+			}
+			for i, x := range v {
+				sum[i] += x
+			}
+			rows = append(rows, idx.add(v, len(idx.ids)))
+		}
+		if rows == nil {
+			continue
+		}
+		idx.ids = append(idx.ids, id)
+		idx.rows = append(idx.rows, rows)
+		idx.centroids = append(idx.centroids, normalize32(sum))
+	}
+
+	if n := len(idx.owner); n >= cfg.IVFThreshold {
+		if prev != nil && prev.listHeads != nil && prev.dim == idx.dim && n < 2*prev.trainedN {
+			idx.listHeads, idx.trainedN = prev.listHeads, prev.trainedN
+		} else {
+			idx.train()
+		}
+		idx.assign()
+	}
+	return idx
+}
+
+// add stores a unit vector and returns its row.
+func (idx *faceIndex) add(v []float32, person int) int {
+	row := len(idx.owner)
+	idx.owner = append(idx.owner, person)
+	if idx.cfg.Quantization == QuantInt8 {
+		var maxAbs float32
+		for _, x := range v {
+			maxAbs = max(maxAbs, abs32(x))
+		}
+		scale := maxAbs / 127
+		if scale == 0 {
+			scale = 1
+		}
+		for _, x := range v {
+			idx.i8 = append(idx.i8, int8(math.Round(float64(x/scale))))
+		}
+		idx.scales = append(idx.scales, scale)
+	} else {
+		idx.f32 = append(idx.f32, v...)
+	}
+	return row
+}
+
+// len returns the number of stored embeddings.
+func (idx *faceIndex) len() int {
+	return len(idx.owner)
+}
+
+// search returns the person with the most similar embedding and that
+// similarity, or "" if nothing can be compared.
+func (idx *faceIndex) search(query Embedding) (string, float64) {
+	return idx.searchExcept(query, "")
+}
+
+// searchExcept is search leaving one person out, to find who else a face
+// could be.
+func (idx *faceIndex) searchExcept(query Embedding, except string) (string, float64) {
+	if len(query) != idx.dim || idx.len() == 0 {
+		return "", 0
+	}
+	q := normalize(query)
+	if q == nil {
+		return "", 0
+	}
+	skip := -1
+	if i := sort.SearchStrings(idx.ids, except); except != "" && i < len(idx.ids) && idx.ids[i] == except {
+		skip = i
+	}
+
+	best, bestSim := -1, float32(math.Inf(-1))
+	scan := func(rows []int) {
+		for _, r := range rows {
+			if idx.owner[r] == skip {
+				continue
+			}
+			if sim := idx.dot(q, r); sim > bestSim {
+				best, bestSim = r, sim
+			}
+		}
+	}
+
+	switch {
+	case idx.cfg.CoarsePeople > 0 && len(idx.ids) > idx.cfg.CoarsePeople:
+		for _, p := range topK(q, idx.centroids, idx.cfg.CoarsePeople) {
+			scan(idx.rows[p])
+		}
+	case idx.lists != nil:
+		for _, l := range topK(q, idx.listHeads, idx.cfg.IVFProbe) {
+			scan(idx.lists[l])
+		}
+	default:
+		for r := range idx.owner {
+			if idx.owner[r] == skip {
+				continue
+			}
+			if sim := idx.dot(q, r); sim > bestSim {
+				best, bestSim = r, sim
+			}
+		}
+	}
+
+	if best < 0 || bestSim <= 0 {
+		return "", 0 // like cosineSimilarity, opposite faces count as no match
+	}
+	return idx.ids[idx.owner[best]], float64(bestSim)
+}
+
+// dot is the similarity between a unit query and a stored row.
+func (idx *faceIndex) dot(q []float32, row int) float32 {
+	if idx.cfg.Quantization == QuantInt8 {
+		codes := idx.i8[row*idx.dim : (row+1)*idx.dim]
+		var sum float32
+		for i, c := range codes {
+			sum += q[i] * float32(c)
+		}
+		return sum * idx.scales[row]
+	}
+	return dot32(q, idx.f32[row*idx.dim:(row+1)*idx.dim])
+}
+
+// vector returns a stored row as float32, dequantizing if needed.
+func (idx *faceIndex) vector(row int) []float32 {
+	if idx.cfg.Quantization != QuantInt8 {
+		return idx.f32[row*idx.dim : (row+1)*idx.dim]
+	}
+	v := make([]float32, idx.dim)
+	for i, c := range idx.i8[row*idx.dim : (row+1)*idx.dim] {
+		v[i] = float32(c) * idx.scales[row]
+	}
+	return v
+}
+
+// train runs spherical k-means over a sample of the rows to pick the IVF
+// cluster heads. Initialization is deterministic: evenly spaced rows.
+func (idx *faceIndex) train() {
+	n := idx.len()
+	k := idx.cfg.IVFLists
+	if k == 0 {
+		k = int(math.Sqrt(float64(n)))
+	}
+	k = max(1, min(k, n))
+
+	step := max(1, n/ivfTrainSample)
+	var sample [][]float32
+	for r := 0; r < n; r += step {
+		sample = append(sample, idx.vector(r))
+	}
+
+	heads := make([][]float32, k)
+	for i := range heads {
+		heads[i] = append([]float32(nil), sample[i*len(sample)/k]...)
+	}
+
+	for range ivfTrainRounds {
+		sums := make([][]float32, k)
+		for i := range sums {
+			sums[i] = make([]float32, idx.dim)
+		}
+		for _, v := range sample {
+			h := topK(v, heads, 1)[0]
+			for i, x := range v {
+				sums[h][i] += x
+			}
+		}
+		for i, s := range sums {
+			if c := normalize32(s); c != nil {
+				heads[i] = c
+			}
+		}
+	}
+
+	idx.listHeads = heads
+	idx.trainedN = n
+}
+
+// assign puts every row in the list of its closest head.
+func (idx *faceIndex) assign() {
+	idx.lists = make([][]int, len(idx.listHeads))
+	for r := range idx.owner {
+		l := topK(idx.vector(r), idx.listHeads, 1)[0]
+		idx.lists[l] = append(idx.lists[l], r)
+	}
+}
+
+// topK returns the indexes of the k vectors most similar to q, best first.
+func topK(q []float32, vectors [][]float32, k int) []int {
+	type scored struct {
+		i   int
+		sim float32
+	}
+	all := make([]scored, len(vectors))
+	for i, v := range vectors {
+		all[i] = scored{i, dot32(q, v)}
+	}
+	sort.Slice(all, func(a, b int) bool { return all[a].sim > all[b].sim })
+
+	k = min(k, len(all))
+	out := make([]int, k)
+	for i := range out {
+		out[i] = all[i].i
+	}
+	return out
+}
+
+// normalize converts an embedding to a unit-length float32 vector, or nil
+// for a zero vector.
+func normalize(e Embedding) []float32 {
+	var norm float64
+	for _, x := range e {
+		norm += x * x
+	}
+	if norm == 0 {
+		return nil
+	}
+	norm = math.Sqrt(norm)
+	v := make([]float32, len(e))
+	for i, x := range e {
+		v[i] = float32(x / norm)
+	}
+	return v
+}
+
+// normalize32 scales v to unit length in place, or returns nil for a zero vector.
+func normalize32(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return nil
+	}
+	inv := float32(1 / math.Sqrt(norm))
+	for i := range v {
+		v[i] *= inv
+	}
+	return v
+}
+
+func dot32(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func abs32(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
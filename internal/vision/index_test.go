@@ -0,0 +1,190 @@
+package vision
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// syntheticGallery creates people whose embeddings are noisy samples around
+// a random identity vector, like a real face model produces.
+func syntheticGallery(rng *rand.Rand, people, perPerson, dim int) (map[string]*Person, []Embedding) {
+	gallery := make(map[string]*Person, people)
+	centers := make([]Embedding, people)
+	for p := range people {
+		center := make(Embedding, dim)
+		for i := range center {
+			center[i] = rng.NormFloat64()
+		}
+		centers[p] = center
+
+		person := &Person{ID: fmt.Sprintf("p%05d", p), Name: fmt.Sprintf("Person %d", p)}
+		for range perPerson {
+			person.Embeddings = append(person.Embeddings, jitter(rng, center, 0.5))
+		}
+		gallery[person.ID] = person
+	}
+	return gallery, centers
+}
+
+func jitter(rng *rand.Rand, e Embedding, noise float64) Embedding {
+	out := make(Embedding, len(e))
+	for i, x := range e {
+		out[i] = x + rng.NormFloat64()*noise
+	}
+	return out
+}
+
+// bruteForce is the reference search: float64 cosine over everything.
+func bruteForce(people map[string]*Person, query Embedding) (string, float64) {
+	var bestID string
+	var best float64
+	for id, p := range people {
+		for _, e := range p.Embeddings {
+			if sim := cosineSimilarity(query, e); sim > best {
+				bestID, best = id, sim
+			}
+		}
+	}
+	return bestID, best
+}
+
+func TestFaceIndex_MatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	people, centers := syntheticGallery(rng, 300, 10, 128)
+
+	tests := []struct {
+		name      string
+		cfg       IndexConfig
+		tolerance float64
+		minAgree  float64 // share of queries that must pick the same person
+	}{
+		{"float32 exact", IndexConfig{IVFThreshold: 1 << 30}, 1e-5, 1},
+		{"int8 exact", IndexConfig{Quantization: QuantInt8, IVFThreshold: 1 << 30}, 0.02, 0.99},
+		{"centroid prefilter", IndexConfig{CoarsePeople: 10, IVFThreshold: 1 << 30}, 1e-5, 0.99},
+		{"ivf", IndexConfig{IVFThreshold: 1000}, 1e-5, 0.97},
+		{"ivf int8", IndexConfig{Quantization: QuantInt8, IVFThreshold: 1000}, 0.02, 0.97},
+	}
+
+	queries := make([]Embedding, 500)
+	for i := range queries {
+		queries[i] = jitter(rng, centers[rng.Intn(len(centers))], 0.5)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := newFaceIndex(tt.cfg, people, nil)
+			agree := 0
+			for _, q := range queries {
+				wantID, wantSim := bruteForce(people, q)
+				gotID, gotSim := idx.search(q)
+				if gotID == wantID {
+					agree++
+					if math.Abs(gotSim-wantSim) > tt.tolerance {
+						t.Errorf("similarity = %.4f, brute force %.4f", gotSim, wantSim)
+					}
+				}
+			}
+			if rate := float64(agree) / float64(len(queries)); rate < tt.minAgree {
+				t.Errorf("agrees with brute force on %.1f%% of queries, want >= %.0f%%", rate*100, tt.minAgree*100)
+			}
+		})
+	}
+}
+
+func TestFaceIndex_EdgeCases(t *testing.T) {
+	people := map[string]*Person{
+		"a": {ID: "a", Embeddings: []Embedding{{1, 0, 0}, {0, 0, 0}, {1, 2}}},
+		"b": {ID: "b", Embeddings: []Embedding{{0, 1, 0}}},
+	}
+	idx := newFaceIndex(IndexConfig{}, people, nil)
+
+	if idx.len() != 2 {
+		t.Errorf("indexed %d embeddings, want 2 (zero and wrong-size vectors skipped)", idx.len())
+	}
+	if id, sim := idx.search(Embedding{0.9, 0.1, 0}); id != "a" || sim < 0.9 {
+		t.Errorf("search() = %q, %.2f, want a", id, sim)
+	}
+	if id, sim := idx.searchExcept(Embedding{0.9, 0.1, 0}, "a"); id != "b" || sim > 0.2 {
+		t.Errorf("searchExcept(a) = %q, %.2f, want b", id, sim)
+	}
+	if id, _ := idx.search(Embedding{1, 0}); id != "" {
+		t.Errorf("search() with the wrong size matched %q", id)
+	}
+	if id, _ := idx.search(Embedding{-1, -1, 0}); id != "" {
+		t.Errorf("search() of an opposite face matched %q", id)
+	}
+	if id, _ := newFaceIndex(IndexConfig{}, nil, nil).search(Embedding{1, 0, 0}); id != "" {
+		t.Error("empty index matched")
+	}
+}
+
+func TestFaceIndex_ReusesClustersUntilDoubled(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	people, _ := syntheticGallery(rng, 50, 10, 32)
+	cfg := IndexConfig{IVFThreshold: 100}
+
+	first := newFaceIndex(cfg, people, nil)
+	more, _ := syntheticGallery(rng, 10, 10, 32)
+	for id, p := range more {
+		people["x"+id] = p
+	}
+	second := newFaceIndex(cfg, people, first)
+	if second.trainedN != first.trainedN {
+		t.Errorf("retrained after growing %d -> %d", first.len(), second.len())
+	}
+	assigned := 0
+	for _, l := range second.lists {
+		assigned += len(l)
+	}
+	if assigned != second.len() {
+		t.Errorf("%d of %d rows assigned to lists", assigned, second.len())
+	}
+}
+
+func benchmarkIndex(b *testing.B, cfg IndexConfig) {
+	rng := rand.New(rand.NewSource(3))
+	people, centers := syntheticGallery(rng, 1000, 10, 512) // 10k embeddings
+	idx := newFaceIndex(cfg, people, nil)
+	queries := make([]Embedding, 64)
+	for i := range queries {
+		queries[i] = jitter(rng, centers[rng.Intn(len(centers))], 0.5)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.search(queries[i%len(queries)])
+	}
+}
+
+func BenchmarkSearch10k_BruteForceFloat64(b *testing.B) {
+	rng := rand.New(rand.NewSource(3))
+	people, centers := syntheticGallery(rng, 1000, 10, 512)
+	query := jitter(rng, centers[0], 0.5)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bruteForce(people, query)
+	}
+}
+
+func BenchmarkSearch10k_Float32(b *testing.B) {
+	benchmarkIndex(b, IndexConfig{IVFThreshold: 1 << 30})
+}
+
+func BenchmarkSearch10k_Int8(b *testing.B) {
+	benchmarkIndex(b, IndexConfig{Quantization: QuantInt8, IVFThreshold: 1 << 30})
+}
+
+func BenchmarkSearch10k_Centroids(b *testing.B) {
+	benchmarkIndex(b, IndexConfig{CoarsePeople: 16, IVFThreshold: 1 << 30})
+}
+
+func BenchmarkSearch10k_IVF(b *testing.B) {
+	benchmarkIndex(b, DefaultIndexConfig())
+}
+
+func BenchmarkSearch10k_IVFInt8(b *testing.B) {
+	benchmarkIndex(b, IndexConfig{Quantization: QuantInt8})
+}
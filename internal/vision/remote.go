@@ -0,0 +1,332 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidImage        = errors.New("image could not be decoded")
+	ErrDetectorUnavailable = errors.New("face detector unavailable")
+)
+
+// Wire error codes.
+const (
+	codeNoFace        = "no_face"
+	codeMultipleFaces = "multiple_faces"
+	codeInvalidImage  = "invalid_image"
+	codeInternal      = "internal"
+)
+
+// wireFace is a detected face on the wire.
+type wireFace struct {
+	Box         BoundingBox `json:"box"`
+	Confidence  float64     `json:"confidence"`
+	Embedding   Embedding   `json:"embedding"`
+	Emotion     Emotion     `json:"emotion,omitempty"`
+	EmotionConf float64     `json:"emotion_confidence,omitempty"`
+}
+
+type detectResponse struct {
+	Faces []wireFace `json:"faces"`
+}
+
+type embedResponse struct {
+	Embedding Embedding `json:"embedding"`
+}
+
+type emotionResponse struct {
+	Emotion    Emotion `json:"emotion"`
+	Confidence float64 `json:"confidence"`
+}
+
+// DetectorInfo describes the models behind a remote detector.
+type DetectorInfo struct {
+	Model        string `json:"model"`
+	EmbeddingDim int    `json:"embedding_dim"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// RemoteDetectorConfig configures a RemoteDetector.
+type RemoteDetectorConfig struct {
+	URL          string        // sidecar base URL, e.g. http://localhost:5000
+	Timeout      time.Duration // per request (default: 5s)
+	EmbeddingDim int           // reject embeddings of any other length; 0 accepts any consistent length
+}
+
+// RemoteDetector is a FaceDetector backed by a sidecar over HTTP.
+//
+// The models (MediaPipe, InsightFace, ...) run in a sidecar process, usually
+// Python, which Koji talks to over HTTP. RemoteDetector is the client and
+// NewDetectorHandler serves the same protocol from any FaceDetector, so it
+// doubles as the reference implementation.
+//
+// Wire format, version 1. Images are sent as the raw request body (JPEG or
+// PNG, whatever the camera produces) with their Content-Type. Responses
+// are JSON.
+//
+//	POST /v1/detect   image -> {"faces": [face, ...]}
+//	POST /v1/embed    face crop -> {"embedding": [float, ...]}
+//	POST /v1/emotion  face crop -> {"emotion": "happy", "confidence": 0.8}
+//	GET  /v1/info     -> {"model": "buffalo_l", "embedding_dim": 512}
+//
+// A face is:
+//
+//	{
+//	  "box": {"x": 10, "y": 20, "width": 80, "height": 80},  // pixels
+//	  "confidence": 0.97,                                  // detection, 0-1
+//	  "embedding": [0.01, ...],                            // same length for every face
+//	  "emotion": "happy",                                  // optional, see Emotion
+//	  "emotion_confidence": 0.8                            // optional, 0-1
+//	}
+//
+// An image with no faces is not an error: /v1/detect returns {"faces": []}.
+// Everything else that goes wrong is a non-2xx status with
+//
+//	{"error": {"code": "no_face", "message": "..."}}
+//
+// where code is one of:
+//
+//	no_face         /v1/embed or /v1/emotion got a crop with no face (422)
+//	multiple_faces  /v1/embed or /v1/emotion got a crop with several (422)
+//	invalid_image   the body isn't an image the sidecar can decode (400)
+//	internal        the model failed (500)
+//
+// The client maps these to ErrNoFaceDetected, ErrMultipleFaces and
+// ErrInvalidImage. Internal errors, unknown codes, other statuses and
+// transport failures are ErrDetectorUnavailable, so callers can tell "no
+// one is there" from "can't see".
+type RemoteDetector struct {
+	cfg    RemoteDetectorConfig
+	client *http.Client
+}
+
+// NewRemoteDetector creates a client for the sidecar at cfg.URL.
+func NewRemoteDetector(cfg RemoteDetectorConfig) *RemoteDetector {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	return &RemoteDetector{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+// DetectFaces implements FaceDetector.
+func (d *RemoteDetector) DetectFaces(ctx context.Context, image []byte) ([]FaceDetection, error) {
+	var resp detectResponse
+	if err := d.post(ctx, "/v1/detect", image, &resp); err != nil {
+		return nil, err
+	}
+
+	faces := make([]FaceDetection, len(resp.Faces))
+	for i, f := range resp.Faces {
+		if err := d.checkEmbedding(f.Embedding, faces[:i]); err != nil {
+			return nil, err
+		}
+		faces[i] = FaceDetection{
+			BoundingBox: f.Box,
+			Confidence:  f.Confidence,
+			Embedding:   f.Embedding,
+			Emotion:     f.Emotion,
+			EmotionConf: f.EmotionConf,
+		}
+	}
+	return faces, nil
+}
+
+// ExtractEmbedding implements FaceDetector.
+func (d *RemoteDetector) ExtractEmbedding(ctx context.Context, faceImage []byte) (Embedding, error) {
+	var resp embedResponse
+	if err := d.post(ctx, "/v1/embed", faceImage, &resp); err != nil {
+		return nil, err
+	}
+	if err := d.checkEmbedding(resp.Embedding, nil); err != nil {
+		return nil, err
+	}
+	return resp.Embedding, nil
+}
+
+// DetectEmotion implements FaceDetector.
+func (d *RemoteDetector) DetectEmotion(ctx context.Context, faceImage []byte) (Emotion, float64, error) {
+	var resp emotionResponse
+	if err := d.post(ctx, "/v1/emotion", faceImage, &resp); err != nil {
+		return "", 0, err
+	}
+	return resp.Emotion, resp.Confidence, nil
+}
+
+// Info asks the sidecar which models it runs. Useful as a health check.
+func (d *RemoteDetector) Info(ctx context.Context) (*DetectorInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.cfg.URL+"/v1/info", nil)
+	if err != nil {
+		return nil, err
+	}
+	var info DetectorInfo
+	if err := d.do(req, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (d *RemoteDetector) post(ctx context.Context, path string, image []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.cfg.URL+path, bytes.NewReader(image))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", http.DetectContentType(image))
+	return d.do(req, out)
+}
+
+// do sends a request and decodes the response or maps its error.
+func (d *RemoteDetector) do(req *http.Request, out any) error {
+	resp, err := d.client.Do(req)
+	if err != nil {
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("%w: %v", ErrDetectorUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return fmt.Errorf("%w: reading response: %v", ErrDetectorUnavailable, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		var e errorResponse
+		_ = json.Unmarshal(body, &e)
+		switch e.Error.Code {
+		case codeNoFace:
+			return ErrNoFaceDetected
+		case codeMultipleFaces:
+			return ErrMultipleFaces
+		case codeInvalidImage:
+			return ErrInvalidImage
+		}
+		msg := e.Error.Message
+		if msg == "" {
+			msg = strings.TrimSpace(string(body))
+		}
+		return fmt.Errorf("%w: %s: %s", ErrDetectorUnavailable, resp.Status, msg)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%w: bad response: %v", ErrDetectorUnavailable, err)
+	}
+	return nil
+}
+
+// checkEmbedding rejects embeddings a recognizer can't use: empty, not
+// finite, the wrong length, or a different length from the other faces.
+func (d *RemoteDetector) checkEmbedding(e Embedding, others []FaceDetection) error {
+	want := d.cfg.EmbeddingDim
+	if want == 0 && len(others) > 0 {
+		want = len(others[0].Embedding)
+	}
+	switch {
+	case len(e) == 0:
+		return fmt.Errorf("%w: face without an embedding", ErrDetectorUnavailable)
+	case want != 0 && len(e) != want:
+		return fmt.Errorf("%w: embedding has %d dimensions, want %d", ErrDetectorUnavailable, len(e), want)
+	}
+	for _, v := range e {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("%w: embedding is not finite", ErrDetectorUnavailable)
+		}
+	}
+	return nil
+}
+
+// NewDetectorHandler serves the wire format from d. It is the reference
+// for sidecar authors and lets a detector in one process be used from
+// another.
+func NewDetectorHandler(d FaceDetector, info DetectorInfo) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/detect", func(w http.ResponseWriter, r *http.Request) {
+		image, ok := readImage(w, r)
+		if !ok {
+			return
+		}
+		faces, err := d.DetectFaces(r.Context(), image)
+		if err != nil {
+			writeDetectorError(w, err)
+			return
+		}
+		resp := detectResponse{Faces: make([]wireFace, len(faces))}
+		for i, f := range faces {
+			resp.Faces[i] = wireFace{Box: f.BoundingBox, Confidence: f.Confidence, Embedding: f.Embedding, Emotion: f.Emotion, EmotionConf: f.EmotionConf}
+		}
+		writeJSON(w, resp)
+	})
+	mux.HandleFunc("POST /v1/embed", func(w http.ResponseWriter, r *http.Request) {
+		image, ok := readImage(w, r)
+		if !ok {
+			return
+		}
+		embedding, err := d.ExtractEmbedding(r.Context(), image)
+		if err != nil {
+			writeDetectorError(w, err)
+			return
+		}
+		writeJSON(w, embedResponse{Embedding: embedding})
+	})
+	mux.HandleFunc("POST /v1/emotion", func(w http.ResponseWriter, r *http.Request) {
+		image, ok := readImage(w, r)
+		if !ok {
+			return
+		}
+		emotion, conf, err := d.DetectEmotion(r.Context(), image)
+		if err != nil {
+			writeDetectorError(w, err)
+			return
+		}
+		writeJSON(w, emotionResponse{Emotion: emotion, Confidence: conf})
+	})
+	mux.HandleFunc("GET /v1/info", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, info)
+	})
+	return mux
+}
+
+func readImage(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	image, err := io.ReadAll(io.LimitReader(r.Body, 10*1024*1024)) // 10MB max, like enrollment frames
+	if err != nil || len(image) == 0 {
+		writeWireError(w, http.StatusBadRequest, codeInvalidImage, "empty or unreadable body")
+		return nil, false
+	}
+	return image, true
+}
+
+// writeDetectorError maps a FaceDetector error to its wire code.
+func writeDetectorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNoFaceDetected):
+		writeWireError(w, http.StatusUnprocessableEntity, codeNoFace, err.Error())
+	case errors.Is(err, ErrMultipleFaces):
+		writeWireError(w, http.StatusUnprocessableEntity, codeMultipleFaces, err.Error())
+	case errors.Is(err, ErrInvalidImage):
+		writeWireError(w, http.StatusBadRequest, codeInvalidImage, err.Error())
+	default:
+		writeWireError(w, http.StatusInternalServerError, codeInternal, err.Error())
+	}
+}
+
+func writeWireError(w http.ResponseWriter, status int, code, message string) {
+	var resp errorResponse
+	resp.Error.Code, resp.Error.Message = code, message
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,133 @@
+package vision
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func syntheticFixtures(broken FaceDetector) DetectorFixtures {
+	return DetectorFixtures{
+		OneFace:      SyntheticImage(SyntheticFace{Identity: "alex", Emotion: EmotionHappy}),
+		SamePerson:   SyntheticImage(SyntheticFace{Identity: "alex", Pose: 0.3}),
+		TwoFaces:     SyntheticImage(SyntheticFace{Identity: "alex"}, SyntheticFace{Identity: "sam"}),
+		NoFace:       SyntheticImage(),
+		EmbeddingDim: 128,
+		Broken:       broken,
+	}
+}
+
+func TestFakeDetector_Conformance(t *testing.T) {
+	broken := &FakeDetector{Err: errors.New("model crashed")}
+	if err := CheckDetector(context.Background(), NewFakeDetector(128), syntheticFixtures(broken)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRemoteDetector_Conformance(t *testing.T) {
+	info := DetectorInfo{Model: "fake", EmbeddingDim: 128}
+	sidecar := httptest.NewServer(NewDetectorHandler(NewFakeDetector(128), info))
+	defer sidecar.Close()
+	crashing := httptest.NewServer(NewDetectorHandler(&FakeDetector{Err: errors.New("CUDA out of memory")}, info))
+	defer crashing.Close()
+
+	d := NewRemoteDetector(RemoteDetectorConfig{URL: sidecar.URL + "/", EmbeddingDim: 128})
+	broken := NewRemoteDetector(RemoteDetectorConfig{URL: crashing.URL})
+	if err := CheckDetector(context.Background(), d, syntheticFixtures(broken)); err != nil {
+		t.Error(err)
+	}
+
+	got, err := d.Info(context.Background())
+	if err != nil || *got != info {
+		t.Errorf("Info() = %+v, %v", got, err)
+	}
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	_, err = NewRemoteDetector(RemoteDetectorConfig{URL: dead.URL}).DetectFaces(context.Background(), SyntheticImage())
+	if !errors.Is(err, ErrDetectorUnavailable) {
+		t.Errorf("DetectFaces() against a dead sidecar error = %v, want ErrDetectorUnavailable", err)
+	}
+}
+
+func TestRemoteDetector_RejectsBadResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"wrong dimensions", 200, `{"faces": [{"box": {"width": 10, "height": 10}, "confidence": 0.9, "embedding": [1, 0]}]}`, ErrDetectorUnavailable},
+		{"missing embedding", 200, `{"faces": [{"box": {"width": 10, "height": 10}, "confidence": 0.9}]}`, ErrDetectorUnavailable},
+		{"not JSON", 200, `<html>`, ErrDetectorUnavailable},
+		{"overloaded", 503, `{"error": {"code": "overloaded", "message": "queue full"}}`, ErrDetectorUnavailable},
+		{"invalid image", 400, `{"error": {"code": "invalid_image", "message": "not a JPEG"}}`, ErrInvalidImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			d := NewRemoteDetector(RemoteDetectorConfig{URL: srv.URL, EmbeddingDim: 3})
+			if _, err := d.DetectFaces(context.Background(), []byte("image")); !errors.Is(err, tt.want) {
+				t.Errorf("DetectFaces() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// emptyIsError makes the classic mistake of reporting an empty room as an
+// error.
+type emptyIsError struct{ *FakeDetector }
+
+func (d emptyIsError) DetectFaces(ctx context.Context, image []byte) ([]FaceDetection, error) {
+	faces, err := d.FakeDetector.DetectFaces(ctx, image)
+	if err == nil && len(faces) == 0 {
+		return nil, ErrNoFaceDetected
+	}
+	return faces, err
+}
+
+func TestCheckDetector_CatchesViolations(t *testing.T) {
+	err := CheckDetector(context.Background(), emptyIsError{NewFakeDetector(128)}, syntheticFixtures(nil))
+	if err == nil || !strings.Contains(err.Error(), "DetectFaces(NoFace)") {
+		t.Errorf("CheckDetector() = %v, want the empty room flagged", err)
+	}
+
+	fixtures := syntheticFixtures(nil)
+	fixtures.EmbeddingDim = 512
+	if err := CheckDetector(context.Background(), NewFakeDetector(128), fixtures); err == nil {
+		t.Error("CheckDetector() accepted the wrong embedding length")
+	}
+}
+
+func TestFakeDetector_EnrollsAndRecognizes(t *testing.T) {
+	ctx := context.Background()
+	d := NewFakeDetector(128)
+	db, _ := NewFaceDB("")
+
+	session := NewEnrollmentSession(d, db, "Alex", RelationshipOwner)
+	for _, pose := range []float64{0, 0.4, 0.8, 1.2, 1.6} {
+		if _, err := session.AddFrame(ctx, SyntheticImage(SyntheticFace{Identity: "alex", Pose: pose})); err != nil {
+			t.Fatalf("AddFrame() error = %v", err)
+		}
+	}
+	if _, err := session.Finish(); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+
+	scene, err := NewSceneRecognizer(d, db, SceneConfig{}).Recognize(ctx,
+		SyntheticImage(SyntheticFace{Identity: "alex", Pose: 0.5}, SyntheticFace{Identity: "visitor"}))
+	if err != nil {
+		t.Fatalf("Recognize() error = %v", err)
+	}
+	if !scene.OwnerPresent || scene.Strangers != 1 {
+		t.Errorf("scene = %s, want Alex and a stranger", scene.Summary())
+	}
+}
@@ -0,0 +1,179 @@
+package vision
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SceneConfig controls frame-level recognition.
+type SceneConfig struct {
+	MinFaceConfidence float64 // detections below this are ignored (default: 0.5)
+	CrowdSize         int     // this many faces or more is a crowd (default: 3)
+}
+
+// DefaultSceneConfig returns sensible defaults.
+func DefaultSceneConfig() SceneConfig {
+	return SceneConfig{
+		MinFaceConfidence: 0.5,
+		CrowdSize:         3,
+	}
+}
+
+// FaceResult is one recognized face in a frame. It carries the person's
+// identity but not their embeddings, so it is safe to serve.
+type FaceResult struct {
+	BoundingBox   BoundingBox  `json:"bounding_box"`
+	DetectionConf float64      `json:"detection_confidence"`
+	PersonID      string       `json:"person_id,omitempty"` // empty for strangers
+	Name          string       `json:"name,omitempty"`
+	Relationship  Relationship `json:"relationship"`
+	Confidence    float64      `json:"confidence"` // recognition similarity
+	Emotion       Emotion      `json:"emotion,omitempty"`
+	EmotionConf   float64      `json:"emotion_confidence,omitempty"`
+	IsOwner       bool         `json:"is_owner"`
+	TrackID       int          `json:"track_id,omitempty"` // set when the face comes from a Tracker
+	Identity      Identity     `json:"identity,omitempty"`
+}
+
+// Scene summarizes everyone in one frame.
+type Scene struct {
+	At           time.Time    `json:"at"`
+	Faces        []FaceResult `json:"faces"`
+	OwnerPresent bool         `json:"owner_present"`
+	Owner        string       `json:"owner,omitempty"`
+	Known        []string     `json:"known,omitempty"` // names of recognized family and friends
+	Strangers    int          `json:"strangers"`
+	Uncertain    int          `json:"uncertain"` // faces not yet or not confidently recognized
+	Crowd        bool         `json:"crowd"`
+}
+
+// NewScene builds a scene from a frame's detections and their recognition
+// results, which must be in the same order.
+func NewScene(faces []FaceDetection, results []*RecognitionResult, cfg SceneConfig) *Scene {
+	out := make([]FaceResult, len(faces))
+	for i, face := range faces {
+		r := results[i]
+		out[i] = FaceResult{
+			BoundingBox:   face.BoundingBox,
+			DetectionConf: face.Confidence,
+			Relationship:  RelationshipStranger,
+			Identity:      IdentityStranger,
+			Confidence:    r.Confidence,
+			Emotion:       r.Emotion,
+			EmotionConf:   r.EmotionConf,
+			IsOwner:       r.IsOwner,
+		}
+		if r.Person != nil {
+			out[i].PersonID = r.Person.ID
+			out[i].Name = r.Person.Name
+			out[i].Relationship = r.Person.Relationship
+			out[i].Identity = IdentityKnown
+		}
+	}
+	return summarizeScene(out, cfg)
+}
+
+// summarizeScene fills in who is there from the recognized faces.
+func summarizeScene(faces []FaceResult, cfg SceneConfig) *Scene {
+	if cfg.CrowdSize == 0 {
+		cfg.CrowdSize = DefaultSceneConfig().CrowdSize
+	}
+
+	scene := &Scene{At: time.Now(), Faces: faces}
+	for _, face := range faces {
+		switch {
+		case face.PersonID == "" && face.Identity != IdentityStranger:
+			scene.Uncertain++
+		case face.PersonID == "":
+			scene.Strangers++
+		case face.IsOwner:
+			scene.OwnerPresent = true
+			scene.Owner = face.Name
+		default:
+			scene.Known = append(scene.Known, face.Name)
+		}
+	}
+	scene.Crowd = len(faces) >= cfg.CrowdSize
+	return scene
+}
+
+// Summary describes the scene in a few words, e.g.
+// "Alex (owner), Sam and 2 strangers".
+func (s *Scene) Summary() string {
+	var parts []string
+	if s.OwnerPresent {
+		parts = append(parts, s.Owner+" (owner)")
+	}
+	parts = append(parts, s.Known...)
+	switch s.Strangers {
+	case 0:
+	case 1:
+		parts = append(parts, "1 stranger")
+	default:
+		parts = append(parts, fmt.Sprintf("%d strangers", s.Strangers))
+	}
+	if s.Uncertain > 0 {
+		parts = append(parts, fmt.Sprintf("%d unsure", s.Uncertain))
+	}
+
+	var summary string
+	switch len(parts) {
+	case 0:
+		return "nobody"
+	case 1:
+		summary = parts[0]
+	default:
+		summary = strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+	if s.Crowd {
+		summary += " (crowd)"
+	}
+	return summary
+}
+
+// SceneRecognizer recognizes everyone in a camera frame.
+type SceneRecognizer struct {
+	detector FaceDetector
+	db       *FaceDB
+	cfg      SceneConfig
+}
+
+// NewSceneRecognizer creates a scene recognizer.
+func NewSceneRecognizer(detector FaceDetector, db *FaceDB, cfg SceneConfig) *SceneRecognizer {
+	defaults := DefaultSceneConfig()
+	if cfg.MinFaceConfidence == 0 {
+		cfg.MinFaceConfidence = defaults.MinFaceConfidence
+	}
+	if cfg.CrowdSize == 0 {
+		cfg.CrowdSize = defaults.CrowdSize
+	}
+	return &SceneRecognizer{detector: detector, db: db, cfg: cfg}
+}
+
+// Recognize detects all faces in a frame and recognizes them in one batch.
+func (r *SceneRecognizer) Recognize(ctx context.Context, image []byte) (*Scene, error) {
+	faces, err := r.Detect(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	return NewScene(faces, r.db.RecognizeAll(faces), r.cfg), nil
+}
+
+// Detect finds the faces in a frame that are clear enough to recognize.
+func (r *SceneRecognizer) Detect(ctx context.Context, image []byte) ([]FaceDetection, error) {
+	detected, err := r.detector.DetectFaces(ctx, image)
+	if err != nil {
+		return nil, fmt.Errorf("detecting faces: %w", err)
+	}
+
+	faces := make([]FaceDetection, 0, len(detected))
+	for _, face := range detected {
+		if face.Confidence < r.cfg.MinFaceConfidence || len(face.Embedding) == 0 {
+			continue // too blurry or small to say who it is
+		}
+		faces = append(faces, face)
+	}
+	return faces, nil
+}
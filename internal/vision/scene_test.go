@@ -0,0 +1,125 @@
+package vision
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// frameDetector returns the same detections for every frame.
+type frameDetector struct {
+	faces []FaceDetection
+	err   error
+}
+
+func (d *frameDetector) DetectFaces(ctx context.Context, image []byte) ([]FaceDetection, error) {
+	return d.faces, d.err
+}
+
+func (d *frameDetector) ExtractEmbedding(ctx context.Context, faceImage []byte) (Embedding, error) {
+	return nil, errors.New("not supported")
+}
+
+func (d *frameDetector) DetectEmotion(ctx context.Context, faceImage []byte) (Emotion, float64, error) {
+	return EmotionNeutral, 0, nil
+}
+
+// axis returns a unit embedding pointing along dimension i, slightly tilted
+// by tilt towards the next one.
+func axis(i int, tilt float64) Embedding {
+	e := make(Embedding, 8)
+	e[i] = 1
+	e[(i+1)%len(e)] = tilt
+	return e
+}
+
+func sceneDB(t *testing.T) *FaceDB {
+	t.Helper()
+	db, err := NewFaceDB("") // in-memory
+	if err != nil {
+		t.Fatalf("NewFaceDB() error = %v", err)
+	}
+	if _, err := db.EnrollOwner("Alex", []Embedding{axis(0, 0), axis(0, 0.05), axis(0, 0.1)}); err != nil {
+		t.Fatalf("EnrollOwner() error = %v", err)
+	}
+	if _, err := db.Enroll("Sam", RelationshipFriend, []Embedding{axis(2, 0), axis(2, 0.05), axis(2, 0.1)}); err != nil {
+		t.Fatalf("Enroll() error = %v", err)
+	}
+	return db
+}
+
+func TestSceneRecognizer_RecognizesEveryFace(t *testing.T) {
+	db := sceneDB(t)
+	detector := &frameDetector{faces: []FaceDetection{
+		{BoundingBox: BoundingBox{X: 10, Width: 50, Height: 50}, Confidence: 0.95, Embedding: axis(0, 0.02), Emotion: EmotionHappy, EmotionConf: 0.8},
+		{BoundingBox: BoundingBox{X: 100, Width: 40, Height: 40}, Confidence: 0.9, Embedding: axis(2, 0.02)},
+		{BoundingBox: BoundingBox{X: 200, Width: 30, Height: 30}, Confidence: 0.85, Embedding: axis(5, 0)},
+		{BoundingBox: BoundingBox{X: 300, Width: 10, Height: 10}, Confidence: 0.2, Embedding: axis(6, 0)}, // too blurry
+	}}
+
+	scene, err := NewSceneRecognizer(detector, db, SceneConfig{}).Recognize(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Recognize() error = %v", err)
+	}
+
+	if len(scene.Faces) != 3 {
+		t.Fatalf("got %d faces, want 3 (low-confidence detection dropped)", len(scene.Faces))
+	}
+	owner := scene.Faces[0]
+	if !owner.IsOwner || owner.Name != "Alex" || owner.BoundingBox.X != 10 || owner.Emotion != EmotionHappy {
+		t.Errorf("first face = %+v, want Alex at x=10, happy", owner)
+	}
+	if scene.Faces[1].Name != "Sam" || scene.Faces[1].Relationship != RelationshipFriend {
+		t.Errorf("second face = %+v, want Sam", scene.Faces[1])
+	}
+	if stranger := scene.Faces[2]; stranger.PersonID != "" || stranger.Relationship != RelationshipStranger {
+		t.Errorf("third face = %+v, want a stranger", stranger)
+	}
+
+	if !scene.OwnerPresent || scene.Strangers != 1 || len(scene.Known) != 1 || !scene.Crowd {
+		t.Errorf("scene = %+v", scene)
+	}
+	if got, want := scene.Summary(), "Alex (owner), Sam and 1 stranger (crowd)"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
+
+func TestFaceDB_RecognizeAllOnePersonPerFrame(t *testing.T) {
+	db := sceneDB(t)
+
+	// A photo of Alex held up next to Alex: only the better match is Alex
+	results := db.RecognizeAll([]FaceDetection{
+		{Embedding: axis(0, 0.3)},
+		{Embedding: axis(0, 0.01)},
+	})
+	if results[1].Person == nil || results[1].Person.Name != "Alex" {
+		t.Errorf("closer face = %+v, want Alex", results[1])
+	}
+	if results[0].Person != nil || results[0].IsOwner {
+		t.Errorf("weaker face matched %v too", results[0].Person.Name)
+	}
+}
+
+func TestScene_Summary(t *testing.T) {
+	tests := []struct {
+		scene Scene
+		want  string
+	}{
+		{Scene{}, "nobody"},
+		{Scene{Strangers: 2}, "2 strangers"},
+		{Scene{Known: []string{"Sam", "Jo"}}, "Sam and Jo"},
+		{Scene{OwnerPresent: true, Owner: "Alex"}, "Alex (owner)"},
+	}
+	for _, tt := range tests {
+		if got := tt.scene.Summary(); got != tt.want {
+			t.Errorf("Summary() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSceneRecognizer_DetectorError(t *testing.T) {
+	detector := &frameDetector{err: errors.New("camera unplugged")}
+	if _, err := NewSceneRecognizer(detector, sceneDB(t), SceneConfig{}).Recognize(context.Background(), nil); err == nil {
+		t.Error("Recognize() hid the detector error")
+	}
+}
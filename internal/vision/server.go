@@ -2,22 +2,34 @@ package vision
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"io"
+	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/alex/koji/internal/personality"
 )
 
 // Server provides a web interface for face enrollment and management.
 type Server struct {
-	db       *FaceDB
-	detector FaceDetector
-	addr     string
+	db        *FaceDB
+	detector  FaceDetector
+	scenes    *SceneRecognizer
+	tracker   *Tracker
+	bridge    chan bridgeWork // optional, frames for the brain (see SetBridge)
+	strangers *StrangerMemory // optional, remembers unknown faces
+	addr      string
 
 	mu            sync.Mutex
 	activeSession *EnrollmentSession
 	sessionOwner  string
+	adminToken    string
+	lastScene     *Scene
 }
 
 // NewServer creates a new enrollment web server.
@@ -25,29 +37,17 @@ func NewServer(addr string, db *FaceDB, detector FaceDetector) *Server {
 	return &Server{
 		db:       db,
 		detector: detector,
+		scenes:   NewSceneRecognizer(detector, db, DefaultSceneConfig()),
+		tracker:  NewTracker(db, DefaultTrackerConfig()),
 		addr:     addr,
 	}
 }
 
 // Start begins serving the web interface.
 func (s *Server) Start(ctx context.Context) error {
-	mux := http.NewServeMux()
-
-	// API endpoints
-	mux.HandleFunc("/api/people", s.handlePeople)
-	mux.HandleFunc("/api/people/", s.handlePerson)
-	mux.HandleFunc("/api/enroll/start", s.handleEnrollStart)
-	mux.HandleFunc("/api/enroll/frame", s.handleEnrollFrame)
-	mux.HandleFunc("/api/enroll/finish", s.handleEnrollFinish)
-	mux.HandleFunc("/api/enroll/cancel", s.handleEnrollCancel)
-	mux.HandleFunc("/api/status", s.handleStatus)
-
-	// Serve static files (embedded or from disk)
-	mux.HandleFunc("/", s.handleIndex)
-
 	server := &http.Server{
 		Addr:    s.addr,
-		Handler: mux,
+		Handler: s.Handler(),
 	}
 
 	// Graceful shutdown
@@ -61,6 +61,28 @@ func (s *Server) Start(ctx context.Context) error {
 	return server.ListenAndServe()
 }
 
+// Handler returns the web interface and API without starting a listener.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	// API endpoints
+	mux.HandleFunc("/api/people", s.handlePeople)
+	mux.HandleFunc("/api/people/", s.handlePerson)
+	mux.HandleFunc("/api/enroll/start", s.handleEnrollStart)
+	mux.HandleFunc("/api/enroll/frame", s.handleEnrollFrame)
+	mux.HandleFunc("/api/enroll/finish", s.handleEnrollFinish)
+	mux.HandleFunc("/api/enroll/cancel", s.handleEnrollCancel)
+	mux.HandleFunc("/api/status", s.handleStatus)
+	mux.HandleFunc("/api/scene", s.handleScene)
+	mux.HandleFunc("/api/strangers", s.handleStrangers)
+	mux.HandleFunc("/api/strangers/", s.handleStranger)
+	mux.HandleFunc("/api/interaction", s.handleInteraction)
+
+	// Serve static files (embedded or from disk)
+	mux.HandleFunc("/", s.handleIndex)
+	return mux
+}
+
 // handleIndex serves the main enrollment page.
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
@@ -95,48 +117,250 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, status)
 }
 
-// handlePeople lists all enrolled people.
-func (s *Server) handlePeople(w http.ResponseWriter, r *http.Request) {
+// bridgeWork is one posted frame's changes, waiting for the bridge.
+type bridgeWork struct {
+	changes []TrackEvent
+	scene   *Scene
+}
+
+// bridgeQueue is how many frames can wait for a slow brain before their
+// events are dropped.
+const bridgeQueue = 16
+
+// SetBridge makes every recognized scene also go to the brain as events.
+// The bridge runs in the background: a slow or unreachable brain doesn't
+// hold up posted frames, and if it falls too far behind, frames' events are
+// dropped rather than queued without bound.
+func (s *Server) SetBridge(b *Bridge) {
+	queue := make(chan bridgeWork, bridgeQueue)
+	go func() {
+		for work := range queue {
+			b.HandleTrackEvents(work.changes, work.scene)
+		}
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bridge != nil {
+		close(s.bridge)
+	}
+	s.bridge = queue
+}
+
+// LastScene returns the most recently recognized scene, or nil.
+func (s *Server) LastScene() *Scene {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastScene
+}
+
+// SetStrangerMemory remembers unknown faces seen in posted frames so repeat
+// visitors can become acquaintances.
+func (s *Server) SetStrangerMemory(m *StrangerMemory) {
+	s.mu.Lock()
+	s.strangers = m
+	s.mu.Unlock()
+	s.tracker.SetStrangerMemory(m)
+}
+
+// handleStrangers lists the unknown faces Koji remembers.
+func (s *Server) handleStrangers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	people := s.db.ListPeople()
+	s.mu.Lock()
+	strangers := s.strangers
+	s.mu.Unlock()
+	if strangers == nil {
+		writeJSON(w, []Stranger{})
+		return
+	}
+	writeJSON(w, strangers.List())
+}
 
-	// Strip embeddings from response (they're large and sensitive)
-	type personSummary struct {
-		ID           string       `json:"id"`
-		Name         string       `json:"name"`
-		Relationship Relationship `json:"relationship"`
-		EnrolledAt   time.Time    `json:"enrolled_at"`
-		LastSeenAt   time.Time    `json:"last_seen_at"`
-		SeenCount    int          `json:"seen_count"`
+// handleStranger forgets a remembered stranger (DELETE).
+func (s *Server) handleStranger(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	strangers := s.strangers
+	s.mu.Unlock()
+	if strangers == nil {
+		http.Error(w, "stranger memory is off", http.StatusNotFound)
+		return
+	}
+	if err := strangers.Forget(r.URL.Path[len("/api/strangers/"):]); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleInteraction credits the strangers in view with an interaction,
+// e.g. {"event": "petted"} forwarded from the brain by an
+// InteractionForwarder. Responds with anyone promoted to an acquaintance.
+func (s *Server) handleInteraction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
+	var req struct {
+		Event personality.Event `json:"event"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	resp := struct {
+		Counted  bool     `json:"counted"`
+		Promoted []string `json:"promoted"`
+	}{Promoted: []string{}}
+	if score, ok := InteractionScore(req.Event); ok {
+		resp.Counted = true
+		for _, p := range s.tracker.Interact(score) {
+			resp.Promoted = append(resp.Promoted, p.ID)
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// handleScene tracks everyone in a posted frame (POST) or returns the last
+// recognized scene (GET). Frames should be posted continuously: faces are
+// followed from frame to frame and only recognized on a few of them.
+func (s *Server) handleScene(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		scene := s.LastScene()
+		if scene == nil {
+			http.Error(w, "no frame recognized yet", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, scene)
+
+	case http.MethodPost:
+		imageData, err := io.ReadAll(io.LimitReader(r.Body, 10*1024*1024)) // 10MB max
+		if err != nil {
+			http.Error(w, "failed to read image", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		faces, err := s.scenes.Detect(ctx, imageData)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		changes := s.tracker.Update(faces)
+		scene := s.tracker.Scene(DefaultSceneConfig())
+
+		s.mu.Lock()
+		s.lastScene = scene
+		if s.bridge != nil {
+			select {
+			case s.bridge <- bridgeWork{changes, scene}:
+			default:
+				log.Printf("Brain is behind, dropping events for a frame")
+			}
+		}
+		s.mu.Unlock()
+
+		writeJSON(w, scene)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePeople lists all enrolled people.
+func (s *Server) handlePeople(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	people := s.db.ListPeople()
 	summaries := make([]personSummary, len(people))
 	for i, p := range people {
-		summaries[i] = personSummary{
-			ID:           p.ID,
-			Name:         p.Name,
-			Relationship: p.Relationship,
-			EnrolledAt:   p.EnrolledAt,
-			LastSeenAt:   p.LastSeenAt,
-			SeenCount:    p.SeenCount,
-		}
+		summaries[i] = summarizePerson(p)
 	}
 
 	writeJSON(w, summaries)
 }
 
-// handlePerson handles individual person operations (GET, DELETE).
+// personSummary is a person without their embeddings, which are large and
+// biometric. Only an admin export returns those.
+type personSummary struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Relationship Relationship `json:"relationship"`
+	EnrolledAt   time.Time    `json:"enrolled_at"`
+	LastSeenAt   time.Time    `json:"last_seen_at"`
+	SeenCount    int          `json:"seen_count"`
+	Samples      int          `json:"samples"`
+}
+
+func summarizePerson(p *Person) personSummary {
+	return personSummary{
+		ID:           p.ID,
+		Name:         p.Name,
+		Relationship: p.Relationship,
+		EnrolledAt:   p.EnrolledAt,
+		LastSeenAt:   p.LastSeenAt,
+		SeenCount:    p.SeenCount,
+		Samples:      len(p.Embeddings),
+	}
+}
+
+// SetAdminToken allows exporting a person's embeddings with
+// GET /api/people/{id}?export=true and "Authorization: Bearer <token>".
+// Without a token, export is disabled.
+func (s *Server) SetAdminToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adminToken = token
+}
+
+// isAdmin reports whether the request carries the admin token.
+func (s *Server) isAdmin(r *http.Request) bool {
+	s.mu.Lock()
+	token := s.adminToken
+	s.mu.Unlock()
+
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// handlePerson handles individual person operations (GET, PATCH, DELETE) and
+// their gallery under /api/people/{id}/gallery.
 func (s *Server) handlePerson(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Path[len("/api/people/"):]
+	id, sub, _ := strings.Cut(r.URL.Path[len("/api/people/"):], "/")
 	if id == "" {
 		http.Error(w, "missing person ID", http.StatusBadRequest)
 		return
 	}
 
+	switch sub {
+	case "":
+	case "gallery":
+		s.handleGallery(w, r, id)
+		return
+	case "gallery/rollback":
+		s.handleGalleryRollback(w, r, id)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		person := s.db.GetPerson(id)
@@ -144,8 +368,36 @@ func (s *Server) handlePerson(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "person not found", http.StatusNotFound)
 			return
 		}
+		if r.URL.Query().Get("export") != "true" {
+			writeJSON(w, summarizePerson(person))
+			return
+		}
+		if !s.isAdmin(r) {
+			http.Error(w, "exporting embeddings needs the admin token", http.StatusForbidden)
+			return
+		}
 		writeJSON(w, person)
 
+	case http.MethodPatch:
+		var req struct {
+			Name         string       `json:"name"`
+			Relationship Relationship `json:"relationship"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		person, err := s.db.UpdatePerson(id, req.Name, req.Relationship)
+		switch {
+		case errors.Is(err, ErrPersonNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeJSON(w, summarizePerson(person))
+
 	case http.MethodDelete:
 		if err := s.db.RemovePerson(id); err != nil {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -158,6 +410,48 @@ func (s *Server) handlePerson(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleGallery returns the audit log of a person's learned and pruned
+// samples, without the embeddings themselves.
+func (s *Server) handleGallery(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	log, err := s.db.GalleryLog(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	for i := range log {
+		log[i].Embedding = nil
+	}
+	writeJSON(w, log)
+}
+
+// handleGalleryRollback undoes a person's gallery changes since a time.
+func (s *Server) handleGalleryRollback(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Since time.Time `json:"since"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	undone, err := s.db.RollbackGallery(id, req.Since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]int{"undone": undone})
+}
+
 // handleEnrollStart begins a new enrollment session.
 func (s *Server) handleEnrollStart(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -420,6 +714,13 @@ const indexHTML = `<!DOCTYPE html>
         </ul>
     </div>
 
+    <div class="card">
+        <h2>Strangers Koji Remembers</h2>
+        <ul id="stranger-list" class="people-list">
+            <li>Loading...</li>
+        </ul>
+    </div>
+
     <div class="card">
         <h2>Add New Person</h2>
         <input type="text" id="name" placeholder="Name">
@@ -477,14 +778,68 @@ const indexHTML = `<!DOCTYPE html>
                 }
                 list.innerHTML = people.map(p => 
                     '<li><div><strong>' + p.name + '</strong><br>' +
-                    '<span class="relationship">' + p.relationship + '</span></div>' +
-                    '<button class="danger" onclick="removePerson(\'' + p.id + '\')">Remove</button></li>'
+                    '<span class="relationship">' + p.relationship + '</span></div><div>' +
+                    (p.relationship === 'acquaintance'
+                        ? '<button onclick="nameAcquaintance(\'' + p.id + '\')">Name</button> ' +
+                          '<button onclick="enrollAcquaintance(\'' + p.id + '\')">Enroll</button> '
+                        : '') +
+                    '<button class="danger" onclick="removePerson(\'' + p.id + '\')">Remove</button></div></li>'
                 ).join('');
             } catch (e) {
                 document.getElementById('people-list').innerHTML = '<li>Error loading people</li>';
             }
         }
 
+        async function loadStrangers() {
+            try {
+                const res = await fetch('/api/strangers');
+                const strangers = await res.json();
+                const list = document.getElementById('stranger-list');
+                if (strangers.length === 0) {
+                    list.innerHTML = '<li>No strangers remembered</li>';
+                    return;
+                }
+                list.innerHTML = strangers.map(s =>
+                    '<li><div><strong>Seen ' + s.visits + ' time' + (s.visits === 1 ? '' : 's') + '</strong><br>' +
+                    '<span class="relationship">last ' + new Date(s.last_seen).toLocaleString() +
+                    ', interaction ' + s.interaction + '</span></div>' +
+                    '<button class="danger" onclick="forgetStranger(\'' + s.id + '\')">Forget</button></li>'
+                ).join('');
+            } catch (e) {
+                document.getElementById('stranger-list').innerHTML = '<li>Error loading strangers</li>';
+            }
+        }
+
+        async function forgetStranger(id) {
+            await fetch('/api/strangers/' + id, { method: 'DELETE' });
+            loadStrangers();
+        }
+
+        // Acquaintances are strangers Koji has warmed up to. Naming one
+        // keeps what Koji learned; enrolling replaces it with proper samples.
+        async function nameAcquaintance(id) {
+            const name = prompt('Who is this?');
+            if (!name) return;
+            const res = await fetch('/api/people/' + id, {
+                method: 'PATCH',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ name, relationship: 'friend' })
+            });
+            if (!res.ok) alert('Error: ' + await res.text());
+            loadPeople();
+        }
+
+        let replacing = null;
+
+        function enrollAcquaintance(id) {
+            const name = prompt('Who is this?');
+            if (!name) return;
+            document.getElementById('name').value = name;
+            document.getElementById('relationship').value = 'friend';
+            replacing = id;
+            startEnrollment();
+        }
+
         async function removePerson(id) {
             if (!confirm('Remove this person?')) return;
             await fetch('/api/people/' + id, { method: 'DELETE' });
@@ -566,6 +921,9 @@ const indexHTML = `<!DOCTYPE html>
             try {
                 const res = await fetch('/api/enroll/finish', { method: 'POST' });
                 if (res.ok) {
+                    if (replacing) {
+                        await fetch('/api/people/' + replacing, { method: 'DELETE' });
+                    }
                     alert('Enrollment complete!');
                 }
             } catch (e) {
@@ -590,6 +948,7 @@ const indexHTML = `<!DOCTYPE html>
             document.getElementById('camera-container').classList.remove('active');
             document.getElementById('start-btn').disabled = false;
             document.getElementById('name').value = '';
+            replacing = null;
             document.getElementById('progress-bar').style.width = '0%';
             loadPeople();
             loadStatus();
@@ -598,6 +957,7 @@ const indexHTML = `<!DOCTYPE html>
         // Initial load
         loadStatus();
         loadPeople();
+        loadStrangers();
     </script>
 </body>
 </html>
@@ -0,0 +1,261 @@
+package vision
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/alex/koji/internal/atomicfile"
+)
+
+// schemaVersion is the FaceDB file format written by this version. Files
+// from older versions are migrated when loaded.
+//
+//	1: a bare JSON object of people by ID (no header)
+//	2: {"version": 2, "saved_at": ..., "people": {...}}
+const schemaVersion = 2
+
+// StorageConfig controls how the FaceDB is written to disk.
+type StorageConfig struct {
+	FlushEvery  time.Duration // sightings are batched and written this often (default: 10s)
+	Backups     int           // rolling backups kept next to the database (default: 3)
+	BackupEvery time.Duration // minimum age of the newest backup before another is taken (default: 1h)
+}
+
+// DefaultStorageConfig returns sensible defaults.
+func DefaultStorageConfig() StorageConfig {
+	return StorageConfig{
+		FlushEvery:  10 * time.Second,
+		Backups:     3,
+		BackupEvery: time.Hour,
+	}
+}
+
+// dbFile is the on-disk FaceDB.
+type dbFile struct {
+	Version int                `json:"version"`
+	SavedAt time.Time          `json:"saved_at"`
+	People  map[string]*Person `json:"people"`
+}
+
+// migrations upgrade a file from version i to i+1.
+var migrations = map[int]func(data []byte, f *dbFile) error{
+	1: func(data []byte, f *dbFile) error {
+		// No header: the whole file is the people map
+		return json.Unmarshal(data, &f.People)
+	},
+}
+
+// decodeDB parses a FaceDB file of any known version.
+func decodeDB(data []byte) (*dbFile, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+
+	f := &dbFile{Version: header.Version}
+	if f.Version == 0 {
+		f.Version = 1 // written before files had a version
+	}
+	if f.Version > schemaVersion {
+		return nil, fmt.Errorf("schema version %d is newer than this build supports (%d)", f.Version, schemaVersion)
+	}
+	if f.Version == schemaVersion {
+		if err := json.Unmarshal(data, f); err != nil {
+			return nil, err
+		}
+	}
+	for ; f.Version < schemaVersion; f.Version++ {
+		if err := migrations[f.Version](data, f); err != nil {
+			return nil, fmt.Errorf("migrating from version %d: %w", f.Version, err)
+		}
+	}
+
+	if f.People == nil {
+		f.People = make(map[string]*Person)
+	}
+	for id, p := range f.People {
+		if p == nil || p.ID != id {
+			return nil, fmt.Errorf("person %q is damaged", id)
+		}
+	}
+	return f, nil
+}
+
+// readDB reads and decodes one database file, decrypting it with key.
+func readDB(path string, key []byte) (*dbFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := unseal(data, key)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDB(plaintext)
+}
+
+// loadDB reads the database at path, falling back to the newest backup that
+// still parses if it is damaged. The damaged file is kept aside for
+// inspection. A missing or wrong key, or a plaintext file where a key was
+// given, is not damage and is returned as is.
+func loadDB(path string, key []byte) (*dbFile, error) {
+	f, err := readDB(path, key)
+	if err == nil || os.IsNotExist(err) || isKeyError(err) {
+		return f, err
+	}
+	f, backup, ok := readBackup(path, key)
+	if !ok {
+		return nil, fmt.Errorf("%s is damaged and no backup could be loaded: %w", path, err)
+	}
+	aside := fmt.Sprintf("%s.damaged-%d", path, time.Now().Unix())
+	_ = os.Rename(path, aside)
+	log.Printf("Face database %s is damaged (%v); recovered from %s, kept the damaged file as %s", path, err, backup, aside)
+	return f, nil
+}
+
+// readBackup reads the newest backup of path that still parses.
+func readBackup(path string, key []byte) (*dbFile, string, bool) {
+	for i := 1; ; i++ {
+		backup := backupPath(path, i)
+		f, err := readDB(backup, key)
+		if os.IsNotExist(err) {
+			return nil, "", false
+		}
+		if err == nil {
+			return f, backup, true
+		}
+	}
+}
+
+// ReadPeople reads the people in the database at path without opening it
+// as a FaceDB: nothing is written, migrated or set aside, so it is safe on
+// a database a running server owns. A damaged file is read from its newest
+// good backup.
+func ReadPeople(path string, key []byte) ([]*Person, error) {
+	f, err := readDB(path, key)
+	if err != nil && !os.IsNotExist(err) && !isKeyError(err) {
+		var ok bool
+		if f, _, ok = readBackup(path, key); !ok {
+			return nil, fmt.Errorf("%s is damaged and no backup could be loaded: %w", path, err)
+		}
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	people := make([]*Person, 0, len(f.People))
+	for _, p := range f.People {
+		people = append(people, p)
+	}
+	sort.Slice(people, func(i, j int) bool { return people[i].ID < people[j].ID })
+	return people, nil
+}
+
+// writeDB writes people to path atomically, encrypted if key is set, first
+// rotating backups if the newest is old enough.
+func writeDB(path string, people map[string]*Person, cfg StorageConfig, key []byte) error {
+	data, err := json.MarshalIndent(dbFile{Version: schemaVersion, SavedAt: time.Now(), People: people}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data, err = seal(data, key); err != nil {
+		return err
+	}
+	if cfg.Backups > 0 {
+		rotateBackups(path, cfg, key)
+	}
+	return atomicfile.Write(path, data, 0600) // biometric data: owner only
+}
+
+// RotateKey re-encrypts the database at path and its backups from oldKey
+// to newKey, along with the stranger memory at strangersPath (empty or
+// missing is skipped), which is sealed with the same key. A nil oldKey
+// reads plaintext files, so this is also how existing ones are encrypted;
+// a nil newKey decrypts them. Backups that can't be read with oldKey are
+// left alone.
+func RotateKey(path, strangersPath string, oldKey, newKey []byte) error {
+	f, err := loadDB(path, oldKey)
+	if err != nil {
+		return err
+	}
+
+	// Read the strangers before writing anything, so a wrong key for them
+	// doesn't leave the database and the strangers on different keys
+	var strangers []byte
+	if strangersPath != "" {
+		data, err := os.ReadFile(strangersPath)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return err
+		default:
+			if strangers, err = unseal(data, oldKey); err != nil {
+				return fmt.Errorf("%s: %w", strangersPath, err)
+			}
+			if strangers, err = seal(strangers, newKey); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := 1; ; i++ {
+		backup := backupPath(path, i)
+		b, err := readDB(backup, oldKey)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			continue
+		}
+		if err := writeDB(backup, b.People, StorageConfig{}, newKey); err != nil {
+			return fmt.Errorf("re-encrypting %s: %w", backup, err)
+		}
+	}
+	if err := writeDB(path, f.People, StorageConfig{}, newKey); err != nil {
+		return err
+	}
+	if strangers != nil {
+		return atomicfile.Write(strangersPath, strangers, 0600)
+	}
+	return nil
+}
+
+func backupPath(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// rotateBackups shifts path.1..path.N-1 up by one and links the current
+// file as path.1, unless path.1 is newer than BackupEvery. Best effort: a
+// failed backup shouldn't stop the save.
+func rotateBackups(path string, cfg StorageConfig, key []byte) {
+	if _, err := os.Stat(path); err != nil {
+		return // nothing to back up yet
+	}
+	if info, err := os.Stat(backupPath(path, 1)); err == nil && time.Since(info.ModTime()) < cfg.BackupEvery {
+		return
+	}
+	// Don't back up a damaged file over a good backup
+	if _, err := readDB(path, key); err != nil {
+		return
+	}
+
+	for i := cfg.Backups; i > 1; i-- {
+		_ = os.Rename(backupPath(path, i-1), backupPath(path, i))
+	}
+	first := backupPath(path, 1)
+	_ = os.Remove(first)
+	if err := os.Link(path, first); err != nil {
+		// No hard links here: copy instead
+		if data, err := os.ReadFile(path); err == nil {
+			_ = atomicfile.Write(first, data, 0600)
+		}
+	}
+	now := time.Now()
+	_ = os.Chtimes(first, now, now) // age from when it became a backup
+}
@@ -0,0 +1,157 @@
+package vision
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDB opens the face database at path, encrypted with key (nil for
+// plaintext), and closes it when the test ends.
+func testDB(t *testing.T, path string, key []byte) *FaceDB {
+	t.Helper()
+	db, err := NewEncryptedFaceDB(path, key)
+	if err != nil {
+		t.Fatalf("NewEncryptedFaceDB() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestFaceDB_WritesVersionedFileAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "faces.json")
+	db := testDB(t, path, nil)
+	if _, err := db.EnrollOwner("Alex", []Embedding{axis(0, 0), axis(0, 0.05), axis(0, 0.1)}); err != nil {
+		t.Fatalf("EnrollOwner() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil || header.Version != schemaVersion {
+		t.Errorf("version = %d, %v, want %d", header.Version, err, schemaVersion)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("temp file %s left behind", e.Name())
+		}
+	}
+}
+
+func TestFaceDB_MigratesUnversionedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faces.json")
+	legacy := map[string]*Person{
+		"1": {ID: "1", Name: "Alex", Relationship: RelationshipOwner, Embeddings: []Embedding{axis(0, 0), axis(0, 0.05), axis(0, 0.1)}},
+	}
+	data, _ := json.Marshal(legacy)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading without opening leaves the file alone
+	people, err := ReadPeople(path, nil)
+	if err != nil || len(people) != 1 || people[0].Name != "Alex" {
+		t.Fatalf("ReadPeople() = %+v, %v", people, err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(data) {
+		t.Error("ReadPeople() rewrote the file")
+	}
+
+	db := testDB(t, path, nil)
+	if owner := db.GetOwner(); owner == nil || owner.Name != "Alex" {
+		t.Fatalf("GetOwner() = %+v after migrating", owner)
+	}
+	data, _ = os.ReadFile(path)
+	if f, err := decodeDB(data); err != nil || f.SavedAt.IsZero() {
+		t.Errorf("file not rewritten in the current format: %v", err)
+	}
+
+	newer := []byte(`{"version": 99, "people": {}}`)
+	if _, err := decodeDB(newer); err == nil {
+		t.Error("decodeDB() accepted a file from a newer version")
+	}
+}
+
+func TestFaceDB_RecoversFromBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "faces.json")
+	db := testDB(t, path, nil)
+	db.SetStorageConfig(StorageConfig{Backups: 2, BackupEvery: time.Nanosecond})
+
+	db.EnrollOwner("Alex", []Embedding{axis(0, 0), axis(0, 0.05), axis(0, 0.1)})
+	db.Enroll("Sam", RelationshipFriend, []Embedding{axis(2, 0), axis(2, 0.05), axis(2, 0.1)})
+	db.Enroll("Jo", RelationshipFamily, []Embedding{axis(4, 0), axis(4, 0.05), axis(4, 0.1)})
+	db.Enroll("Robin", RelationshipFriend, []Embedding{axis(6, 0), axis(6, 0.05), axis(6, 0.1)})
+
+	if _, err := os.Stat(backupPath(path, 3)); !os.IsNotExist(err) {
+		t.Errorf("kept more backups than configured: %v", err)
+	}
+
+	// A crash under the old writer left half a file
+	data, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if people, err := ReadPeople(path, nil); err != nil || len(people) != 3 {
+		t.Errorf("ReadPeople() = %d people, %v, want 3 from the newest backup", len(people), err)
+	}
+	if damaged, _ := filepath.Glob(path + ".damaged-*"); len(damaged) != 0 {
+		t.Errorf("ReadPeople() set the damaged file aside: %v", damaged)
+	}
+
+	recovered := testDB(t, path, nil)
+	if got := len(recovered.ListPeople()); got != 3 {
+		t.Errorf("recovered %d people, want 3 from the newest backup", got)
+	}
+	damaged, _ := filepath.Glob(path + ".damaged-*")
+	if len(damaged) != 1 {
+		t.Errorf("damaged files = %v, want the broken database kept aside", damaged)
+	}
+
+	// With no good backup, loading fails rather than starting empty
+	os.WriteFile(path, []byte("{"), 0644)
+	os.WriteFile(backupPath(path, 1), []byte("{"), 0644)
+	os.Remove(backupPath(path, 2))
+	if _, err := NewFaceDB(path); err == nil {
+		t.Error("NewFaceDB() loaded a damaged database with no backup")
+	}
+}
+
+func TestFaceDB_BatchesSightings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faces.json")
+	db := testDB(t, path, nil)
+	owner, _ := db.EnrollOwner("Alex", []Embedding{axis(0, 0), axis(0, 0.05), axis(0, 0.1)})
+	before := db.GetPerson(owner.ID).LastSeenAt
+	written, _ := os.ReadFile(path)
+
+	time.Sleep(time.Millisecond)
+	for range 20 {
+		db.Recognize(axis(0, 0.02), EmotionNeutral, 0)
+	}
+	db.RecordVisit(owner.ID)
+	if data, _ := os.ReadFile(path); string(data) != string(written) {
+		t.Error("recognizing a face rewrote the database before the flush")
+	}
+
+	if err := db.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	reloaded := testDB(t, path, nil)
+	if got := reloaded.GetPerson(owner.ID).LastSeenAt; !got.After(before) {
+		t.Errorf("LastSeenAt = %v, want after %v once flushed", got, before)
+	}
+	if got := reloaded.GetPerson(owner.ID).SeenCount; got != 1 {
+		t.Errorf("SeenCount = %d, want the visit once flushed", got)
+	}
+}
@@ -0,0 +1,371 @@
+package vision
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alex/koji/internal/atomicfile"
+	"github.com/alex/koji/internal/personality"
+)
+
+// StrangerConfig controls how unknown faces become acquaintances.
+type StrangerConfig struct {
+	MatchThreshold float64       // similarity to a stranger's samples to be them again (default: 0.6)
+	TooSimilar     float64       // samples closer than this to an existing one add nothing (default: 0.95)
+	MaxSamples     int           // embeddings kept per stranger (default: 10)
+	MinVisits      int           // visits before promotion (default: 3)
+	MinInteraction float64       // positive interaction score before promotion (default: 1)
+	VisitsOnly     bool          // promote on visits alone, without any interaction
+	Retention      time.Duration // strangers not seen this long are forgotten (default: 30 days)
+	FlushEvery     time.Duration // visits and samples are batched and written this often (default: 10s)
+}
+
+// DefaultStrangerConfig returns sensible defaults.
+func DefaultStrangerConfig() StrangerConfig {
+	return StrangerConfig{
+		MatchThreshold: 0.6,
+		TooSimilar:     0.95,
+		MaxSamples:     10,
+		MinVisits:      3,
+		MinInteraction: 1,
+		Retention:      30 * 24 * time.Hour,
+		FlushEvery:     10 * time.Second,
+	}
+}
+
+// interactionScores is how much each event while a stranger is present
+// counts towards them becoming an acquaintance.
+var interactionScores = map[personality.Event]float64{
+	personality.EventPetted:     1,
+	personality.EventPickedUp:   0.5,
+	personality.EventNameCalled: 0.25,
+	personality.EventPoked:      -0.5,
+}
+
+// InteractionScore returns how positive an event is for strangers present
+// when it happens, and whether it counts at all.
+func InteractionScore(event personality.Event) (float64, bool) {
+	score, ok := interactionScores[event]
+	return score, ok
+}
+
+// Stranger is a provisional identity: the same unknown face seen more than
+// once. Once they've visited enough and been nice to Koji, they are
+// promoted to an acquaintance in the FaceDB.
+type Stranger struct {
+	ID          string      `json:"id"`
+	Embeddings  []Embedding `json:"embeddings"`
+	FirstSeen   time.Time   `json:"first_seen"`
+	LastSeen    time.Time   `json:"last_seen"`
+	Visits      int         `json:"visits"`
+	Interaction float64     `json:"interaction"` // sum of interaction scores while present
+}
+
+// StrangerMemory clusters unknown faces so repeat visitors can warm up to
+// acquaintances instead of being strangers forever.
+type StrangerMemory struct {
+	mu        sync.Mutex
+	db        *FaceDB
+	cfg       StrangerConfig
+	strangers map[string]*Stranger
+	dataPath  string
+	now       func() time.Time
+
+	// Visits and samples are batched so a face in view doesn't rewrite the file
+	dirty      bool
+	flushTimer *time.Timer
+	closed     bool
+}
+
+// NewStrangerMemory creates a stranger memory that promotes into db. An
+// empty dataPath keeps it in memory only.
+func NewStrangerMemory(dataPath string, db *FaceDB, cfg StrangerConfig) (*StrangerMemory, error) {
+	defaults := DefaultStrangerConfig()
+	if cfg.MatchThreshold == 0 {
+		cfg.MatchThreshold = defaults.MatchThreshold
+	}
+	if cfg.TooSimilar == 0 {
+		cfg.TooSimilar = defaults.TooSimilar
+	}
+	if cfg.MaxSamples == 0 {
+		cfg.MaxSamples = defaults.MaxSamples
+	}
+	if cfg.MinVisits == 0 {
+		cfg.MinVisits = defaults.MinVisits
+	}
+	if cfg.MinInteraction == 0 {
+		cfg.MinInteraction = defaults.MinInteraction
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = defaults.Retention
+	}
+	if cfg.FlushEvery == 0 {
+		cfg.FlushEvery = defaults.FlushEvery
+	}
+
+	m := &StrangerMemory{
+		db:        db,
+		cfg:       cfg,
+		strangers: make(map[string]*Stranger),
+		dataPath:  dataPath,
+		now:       time.Now,
+	}
+	if err := m.load(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("loading strangers: %w", err)
+	}
+	return m, nil
+}
+
+// Visit records an unknown face coming into view. It's matched to a
+// stranger seen before or starts a new one. Returns the stranger and, if
+// this visit promoted them, the new acquaintance.
+func (m *StrangerMemory) Visit(embedding Embedding) (Stranger, *Person) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.expireLocked(now)
+
+	s := m.matchLocked(embedding)
+	if s == nil {
+		s = &Stranger{ID: generateID(), FirstSeen: now}
+		m.strangers[s.ID] = s
+	}
+	s.LastSeen = now
+	s.Visits++
+	m.addSampleLocked(s, embedding)
+
+	promoted := m.promoteLocked(s)
+	m.changedLocked(promoted != nil)
+	return m.copyOf(s), promoted
+}
+
+// Observe adds another look at a stranger already in view, so their
+// samples cover more angles. Returns the acquaintance if this promoted them.
+func (m *StrangerMemory) Observe(id string, embedding Embedding) *Person {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.strangers[id]
+	if !ok {
+		return nil
+	}
+	s.LastSeen = m.now()
+	if !m.addSampleLocked(s, embedding) {
+		return nil
+	}
+	promoted := m.promoteLocked(s)
+	m.changedLocked(promoted != nil)
+	return promoted
+}
+
+// Interact credits strangers present during an interaction. Returns anyone
+// it promoted.
+func (m *StrangerMemory) Interact(ids []string, score float64) []*Person {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var promoted []*Person
+	changed := false
+	for _, id := range ids {
+		s, ok := m.strangers[id]
+		if !ok {
+			continue
+		}
+		s.Interaction += score
+		changed = true
+		if p := m.promoteLocked(s); p != nil {
+			promoted = append(promoted, p)
+		}
+	}
+	if changed {
+		m.changedLocked(true)
+	}
+	return promoted
+}
+
+// List returns the strangers remembered, most recently seen first, without
+// their embeddings.
+func (m *StrangerMemory) List() []Stranger {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.expireLocked(m.now()) > 0 {
+		m.changedLocked(false)
+	}
+	strangers := make([]Stranger, 0, len(m.strangers))
+	for _, s := range m.strangers {
+		c := m.copyOf(s)
+		c.Embeddings = nil
+		strangers = append(strangers, c)
+	}
+	sort.Slice(strangers, func(i, j int) bool {
+		if !strangers[i].LastSeen.Equal(strangers[j].LastSeen) {
+			return strangers[i].LastSeen.After(strangers[j].LastSeen)
+		}
+		return strangers[i].ID < strangers[j].ID
+	})
+	return strangers
+}
+
+// Forget removes a stranger.
+func (m *StrangerMemory) Forget(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.strangers[id]; !ok {
+		return ErrPersonNotFound
+	}
+	delete(m.strangers, id)
+	m.dirty = false
+	return m.save()
+}
+
+// Flush writes any batched visits and samples now.
+func (m *StrangerMemory) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.flushTimer != nil {
+		m.flushTimer.Stop()
+		m.flushTimer = nil
+	}
+	if !m.dirty {
+		return nil
+	}
+	m.dirty = false
+	return m.save()
+}
+
+// Close writes any batched changes. The memory shouldn't be used after.
+func (m *StrangerMemory) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	return m.Flush()
+}
+
+// changedLocked notes unsaved changes. Promotions and interactions are
+// written at once, as losing them would enroll the same visitor twice or
+// forget a kindness; everything else waits for the next Flush. Caller
+// holds m.mu.
+func (m *StrangerMemory) changedLocked(now bool) {
+	if now {
+		m.dirty = false
+		_ = m.save() // best effort
+		return
+	}
+	m.dirty = true
+	if m.flushTimer == nil && !m.closed {
+		m.flushTimer = time.AfterFunc(m.cfg.FlushEvery, func() { _ = m.Flush() })
+	}
+}
+
+// matchLocked finds the stranger most similar to embedding, or nil if none
+// is similar enough. Caller holds m.mu.
+func (m *StrangerMemory) matchLocked(embedding Embedding) *Stranger {
+	var best *Stranger
+	bestSim := m.cfg.MatchThreshold
+	for _, s := range m.strangers {
+		if sim := m.db.bestSimilarity(embedding, s.Embeddings); sim >= bestSim {
+			best, bestSim = s, sim
+		}
+	}
+	return best
+}
+
+// addSampleLocked keeps embedding if it isn't a near-duplicate, pruning the
+// most redundant sample past the cap. Caller holds m.mu.
+func (m *StrangerMemory) addSampleLocked(s *Stranger, embedding Embedding) bool {
+	if m.db.bestSimilarity(embedding, s.Embeddings) > m.cfg.TooSimilar {
+		return false
+	}
+	s.Embeddings = append(s.Embeddings, embedding)
+	if len(s.Embeddings) > m.cfg.MaxSamples {
+		i := mostRedundant(s.Embeddings)
+		s.Embeddings = slices.Delete(s.Embeddings, i, i+1)
+	}
+	return true
+}
+
+// promoteLocked enrolls s as an acquaintance once they've visited and
+// (unless VisitsOnly) interacted enough and there are enough samples to recognize them.
+// Caller holds m.mu.
+func (m *StrangerMemory) promoteLocked(s *Stranger) *Person {
+	if s.Visits < m.cfg.MinVisits || len(s.Embeddings) < 3 {
+		return nil
+	}
+	if !m.cfg.VisitsOnly && s.Interaction < m.cfg.MinInteraction {
+		return nil
+	}
+
+	// Acquaintances don't have names yet; the web UI offers to add one
+	for n := len(m.db.ListPeople()) + 1; ; n++ {
+		p, err := m.db.Enroll(fmt.Sprintf("Visitor %d", n), RelationshipAcquaintance, slices.Clone(s.Embeddings))
+		if errors.Is(err, ErrPersonExists) {
+			continue
+		}
+		if err != nil {
+			return nil // try again on the next visit
+		}
+		delete(m.strangers, s.ID)
+		return p
+	}
+}
+
+// expireLocked forgets strangers not seen within the retention period.
+// Caller holds m.mu.
+func (m *StrangerMemory) expireLocked(now time.Time) int {
+	expired := 0
+	for id, s := range m.strangers {
+		if now.Sub(s.LastSeen) > m.cfg.Retention {
+			delete(m.strangers, id)
+			expired++
+		}
+	}
+	return expired
+}
+
+func (m *StrangerMemory) copyOf(s *Stranger) Stranger {
+	c := *s
+	c.Embeddings = slices.Clone(s.Embeddings)
+	return c
+}
+
+// save persists the strangers to disk. Caller holds m.mu.
+func (m *StrangerMemory) save() error {
+	if m.dataPath == "" {
+		return nil // in-memory only
+	}
+
+	data, err := json.MarshalIndent(m.strangers, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data, err = seal(data, m.db.sealKey()); err != nil {
+		return err
+	}
+	return atomicfile.Write(m.dataPath, data, 0600) // embeddings, like the FaceDB
+}
+
+// load reads the strangers from disk.
+func (m *StrangerMemory) load() error {
+	if m.dataPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(m.dataPath)
+	if err != nil {
+		return err
+	}
+	if data, err = unseal(data, m.db.sealKey()); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &m.strangers)
+}
@@ -0,0 +1,253 @@
+package vision
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+// testStrangers returns a stranger memory at path ("" for in-memory) on a
+// test clock.
+func testStrangers(t *testing.T, db *FaceDB, path string, cfg StrangerConfig) (*StrangerMemory, *testClock) {
+	t.Helper()
+	m, err := NewStrangerMemory(path, db, cfg)
+	if err != nil {
+		t.Fatalf("NewStrangerMemory() error = %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	clock := newTestClock()
+	m.now = clock.Now
+	return m, clock
+}
+
+func TestStrangerMemory_RepeatVisitorBecomesAcquaintance(t *testing.T) {
+	db := sceneDB(t)
+	m, clock := testStrangers(t, db, "", StrangerConfig{})
+
+	// The same visitor on three days, from slightly different angles
+	var id string
+	for day, e := range []Embedding{axis(5, 0), axis(5, 0.4), axis(5, -0.4)} {
+		clock.Advance(24 * time.Hour)
+		stranger, promoted := m.Visit(e)
+		if promoted != nil {
+			t.Fatalf("promoted on day %d without any interaction", day+1)
+		}
+		if id != "" && stranger.ID != id {
+			t.Fatalf("day %d: new stranger %s, want the same one", day+1, stranger.ID)
+		}
+		id = stranger.ID
+	}
+	clock.Advance(time.Hour)
+	other, _ := m.Visit(axis(7, 0))
+	if other.ID == id {
+		t.Error("a different face joined the visitor's cluster")
+	}
+
+	strangers := m.List()
+	if len(strangers) != 2 || strangers[1].ID != id || strangers[1].Visits != 3 || strangers[1].Embeddings != nil {
+		t.Fatalf("List() = %+v", strangers)
+	}
+
+	// They pet Koji: that's enough
+	if promoted := m.Interact([]string{id}, 0.5); len(promoted) != 0 {
+		t.Errorf("promoted on half an interaction")
+	}
+	promoted := m.Interact([]string{id}, 0.5)
+	if len(promoted) != 1 || promoted[0].Relationship != RelationshipAcquaintance {
+		t.Fatalf("Interact() promoted %+v, want one acquaintance", promoted)
+	}
+	if len(m.List()) != 1 {
+		t.Error("the promoted stranger is still a stranger")
+	}
+
+	person, _ := db.Identify(axis(5, 0.1))
+	if person == nil || person.ID != promoted[0].ID {
+		t.Errorf("Identify() = %+v, want the new acquaintance", person)
+	}
+
+	// The UI names them
+	if _, err := db.UpdatePerson(person.ID, "Sam", ""); err != ErrPersonExists {
+		t.Errorf("UpdatePerson() to a taken name error = %v", err)
+	}
+	named, err := db.UpdatePerson(person.ID, "Robin", RelationshipFriend)
+	if err != nil || named.Name != "Robin" || named.Relationship != RelationshipFriend {
+		t.Errorf("UpdatePerson() = %+v, %v", named, err)
+	}
+}
+
+func TestStrangerMemory_ExpiresAndPersists(t *testing.T) {
+	db := sceneDB(t)
+	path := filepath.Join(t.TempDir(), "strangers.json")
+	m, clock := testStrangers(t, db, path, StrangerConfig{Retention: 7 * 24 * time.Hour})
+
+	m.Visit(axis(5, 0))
+	clock.Advance(6 * 24 * time.Hour)
+	m.Visit(axis(7, 0))
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Error("visits rewrote the file before the flush")
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	reloaded, err := NewStrangerMemory(path, db, StrangerConfig{Retention: 7 * 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("NewStrangerMemory() reload error = %v", err)
+	}
+	reloaded.now = clock.Now
+	if got := len(reloaded.List()); got != 2 {
+		t.Fatalf("reloaded %d strangers, want 2", got)
+	}
+
+	clock.Advance(2 * 24 * time.Hour)
+	strangers := m.List()
+	if len(strangers) != 1 || strangers[0].Visits != 1 {
+		t.Errorf("List() after a week = %+v, want only the recent stranger", strangers)
+	}
+}
+
+func TestTracker_StrangersInViewGetCredit(t *testing.T) {
+	tracker, db, clock := testTracker(t)
+	m, _ := testStrangers(t, db, "", StrangerConfig{})
+	tracker.SetStrangerMemory(m)
+
+	frame := []FaceDetection{face(0, axis(0, 0.02)), face(300, axis(5, 0))}
+	tracker.Update(frame)
+	clock.Advance(250 * time.Millisecond)
+	tracker.Update(frame)
+
+	strangers := m.List()
+	if len(strangers) != 1 || strangers[0].Visits != 1 {
+		t.Fatalf("strangers = %+v, want one visit", strangers)
+	}
+	score, ok := InteractionScore(personality.EventPetted)
+	if !ok {
+		t.Fatal("petting doesn't count as an interaction")
+	}
+	tracker.Interact(score)
+	if got := m.List()[0].Interaction; got != score {
+		t.Errorf("interaction = %v, want %v", got, score)
+	}
+	if _, ok := InteractionScore(personality.EventMusic); ok {
+		t.Error("music counted as an interaction")
+	}
+}
+
+func TestStrangerMemory_VisitsOnly(t *testing.T) {
+	db := sceneDB(t)
+	m, _ := testStrangers(t, db, "", StrangerConfig{VisitsOnly: true})
+	var promoted *Person
+	for _, e := range []Embedding{axis(5, 0), axis(5, 0.4), axis(5, -0.4)} {
+		_, promoted = m.Visit(e)
+	}
+	if promoted == nil || promoted.Relationship != RelationshipAcquaintance {
+		t.Errorf("third visit promoted %+v, want an acquaintance without any interaction", promoted)
+	}
+}
+
+func TestTracker_PromotionIdentifiesTrack(t *testing.T) {
+	tracker, db, clock := testTracker(t)
+	m, _ := testStrangers(t, db, "", StrangerConfig{VisitsOnly: true, MinVisits: 1})
+	tracker.SetStrangerMemory(m)
+
+	// One long visit, rechecked from new angles until there are enough samples
+	looks := []Embedding{axis(5, 0), axis(5, 0.4), axis(5, -0.4)}
+	var identified *TrackEvent
+	for i := 0; i < 20 && identified == nil; i++ {
+		for _, e := range tracker.Update([]FaceDetection{face(300, looks[i/5%len(looks)])}) {
+			if e.Type == TrackIdentified && e.Track.Identity == IdentityKnown {
+				identified = &e
+			}
+		}
+		clock.Advance(time.Second)
+	}
+
+	if identified == nil {
+		t.Fatal("promotion didn't identify the track as someone")
+	}
+	track := identified.Track
+	if track.Relationship != RelationshipAcquaintance || track.StrangerID != "" {
+		t.Errorf("identified track = %+v, want the new acquaintance", track)
+	}
+	if db.GetPerson(track.PersonID) == nil {
+		t.Errorf("track names %q, which isn't enrolled", track.PersonID)
+	}
+}
+
+// TestServer_FramesToAcquaintance follows a visitor from posted camera
+// frames, through petting reported to the brain, to an acquaintance.
+func TestServer_FramesToAcquaintance(t *testing.T) {
+	db := sceneDB(t)
+	detector := &frameDetector{}
+	s := NewServer(":0", db, detector)
+	m, clock := testStrangers(t, db, "", StrangerConfig{})
+	s.tracker.now = clock.Now
+	s.SetStrangerMemory(m)
+	handler := s.Handler()
+
+	post := func() {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/scene", strings.NewReader("frame")))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST /api/scene = %d %s", rec.Code, rec.Body)
+		}
+	}
+	// visit shows the face for two frames, enough to settle as a stranger
+	visit := func(e Embedding) {
+		t.Helper()
+		detector.faces = []FaceDetection{face(300, e)}
+		post()
+		clock.Advance(250 * time.Millisecond)
+		post()
+	}
+	leave := func() {
+		t.Helper()
+		detector.faces = nil
+		clock.Advance(3 * time.Second)
+		post()
+	}
+
+	// The same visitor on three days, from slightly different angles
+	for _, e := range []Embedding{axis(5, 0), axis(5, 0.4), axis(5, -0.4)} {
+		clock.Advance(24 * time.Hour)
+		visit(e)
+		leave()
+	}
+	if strangers := m.List(); len(strangers) != 1 || strangers[0].Visits != 3 {
+		t.Fatalf("strangers = %+v, want one with three visits", strangers)
+	}
+
+	// They come back and pet Koji: the brain hears it and tells vision
+	clock.Advance(24 * time.Hour)
+	visit(axis(5, 0.2))
+	vision := httptest.NewServer(handler)
+	defer vision.Close()
+	brain := &recordingHandler{}
+	forwarder := NewInteractionForwarder(brain, vision.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go forwarder.Run(ctx)
+
+	forwarder.HandleEvent(personality.NewEventContext(personality.EventPetted))
+	forwarder.HandleEvent(personality.NewEventContext(personality.EventMusic)) // not an interaction
+	if len(brain.events) != 2 {
+		t.Errorf("brain got %d events, want both", len(brain.events))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(m.List()) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	person, _ := db.Identify(axis(5, 0.1))
+	if person == nil || person.Relationship != RelationshipAcquaintance {
+		t.Fatalf("Identify() = %+v, want the visitor as an acquaintance", person)
+	}
+}
@@ -0,0 +1,100 @@
+package vision
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+)
+
+// Thresholds are the similarities needed to recognize someone. The most
+// specific one applies: the person's own, then their relationship's, then
+// the default. cmd/calibrate recommends them from labeled data.
+type Thresholds struct {
+	Default       float64                  `json:"default"`
+	Relationships map[Relationship]float64 `json:"relationships,omitempty"`
+	People        map[string]float64       `json:"people,omitempty"` // by person ID or name
+}
+
+// DefaultThresholds returns the thresholds used without calibration. The
+// owner's is stricter: mistaking someone for them matters most.
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		Default:       0.6,
+		Relationships: map[Relationship]float64{RelationshipOwner: 0.7},
+	}
+}
+
+// LoadThresholds reads thresholds from a JSON file. A missing default is
+// taken from DefaultThresholds, and so is the owner's if the file doesn't
+// set one.
+func LoadThresholds(path string) (Thresholds, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Thresholds{}, err
+	}
+
+	var t Thresholds
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Thresholds{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	def := DefaultThresholds()
+	if t.Default == 0 {
+		t.Default = def.Default
+	}
+	if t.Relationships == nil {
+		t.Relationships = make(map[Relationship]float64)
+	}
+	if _, ok := t.Relationships[RelationshipOwner]; !ok {
+		t.Relationships[RelationshipOwner] = def.Relationships[RelationshipOwner]
+	}
+	for name, v := range t.allValues() {
+		if v <= -1 || v > 1 {
+			return Thresholds{}, fmt.Errorf("%s: threshold %s = %v is not a cosine similarity", path, name, v)
+		}
+	}
+	return t, nil
+}
+
+// Save writes the thresholds as JSON.
+func (t Thresholds) Save(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// For returns the threshold that applies to p. A nil p gets the default.
+func (t Thresholds) For(p *Person) float64 {
+	if p == nil {
+		return t.Default
+	}
+	if v, ok := t.People[p.ID]; ok {
+		return v
+	}
+	if v, ok := t.People[p.Name]; ok {
+		return v
+	}
+	if v, ok := t.Relationships[p.Relationship]; ok {
+		return v
+	}
+	return t.Default
+}
+
+func (t Thresholds) allValues() map[string]float64 {
+	values := map[string]float64{"default": t.Default}
+	for r, v := range t.Relationships {
+		values[string(r)] = v
+	}
+	maps.Copy(values, t.People)
+	return values
+}
+
+// SetThresholds changes the similarities needed to recognize people.
+func (db *FaceDB) SetThresholds(t Thresholds) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.thresholds = t
+}
@@ -0,0 +1,60 @@
+package vision
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThresholds_MostSpecificWins(t *testing.T) {
+	th := Thresholds{
+		Default:       0.5,
+		Relationships: map[Relationship]float64{RelationshipOwner: 0.7, RelationshipFriend: 0.55},
+		People:        map[string]float64{"Sam": 0.65, "p1": 0.8},
+	}
+	tests := []struct {
+		person *Person
+		want   float64
+	}{
+		{nil, 0.5},
+		{&Person{ID: "p0", Name: "Alex", Relationship: RelationshipOwner}, 0.7},
+		{&Person{ID: "p1", Name: "Alex", Relationship: RelationshipOwner}, 0.8},
+		{&Person{ID: "p2", Name: "Sam", Relationship: RelationshipFriend}, 0.65},
+		{&Person{ID: "p3", Name: "Riley", Relationship: RelationshipFriend}, 0.55},
+		{&Person{ID: "p4", Name: "Jordan", Relationship: RelationshipFamily}, 0.5},
+	}
+	for _, tt := range tests {
+		if got := th.For(tt.person); got != tt.want {
+			t.Errorf("For(%+v) = %v, want %v", tt.person, got, tt.want)
+		}
+	}
+}
+
+func TestLoadThresholds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "thresholds.json")
+	os.WriteFile(path, []byte(`{"relationships": {"friend": 0.45}}`), 0644)
+
+	th, err := LoadThresholds(path)
+	if err != nil {
+		t.Fatalf("LoadThresholds() error = %v", err)
+	}
+	if th.Default != 0.6 || th.Relationships[RelationshipOwner] != 0.7 || th.Relationships[RelationshipFriend] != 0.45 {
+		t.Errorf("LoadThresholds() = %+v, want defaults filled around the friend threshold", th)
+	}
+
+	os.WriteFile(path, []byte(`{"default": 60}`), 0644)
+	if _, err := LoadThresholds(path); err == nil {
+		t.Error("LoadThresholds() accepted a percentage")
+	}
+
+	// A stricter friend threshold turns a weak match away
+	db := sceneDB(t)
+	probe := axis(2, 1.3) // 0.69 to Sam's closest sample
+	if p, _ := db.Identify(probe); p == nil {
+		t.Fatalf("probe not recognized with defaults, adjust the test")
+	}
+	db.SetThresholds(Thresholds{Default: 0.6, Relationships: map[Relationship]float64{RelationshipFriend: 0.7}})
+	if p, sim := db.Identify(probe); p != nil {
+		t.Errorf("Identify() = %s at %.2f, want no one above a 0.7 friend threshold", p.Name, sim)
+	}
+}
@@ -0,0 +1,395 @@
+package vision
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TrackState is where a track is in its lifecycle.
+type TrackState string
+
+const (
+	TrackAppeared  TrackState = "appeared"  // first frame
+	TrackPersisted TrackState = "persisted" // matched again in a later frame
+	TrackLost      TrackState = "lost"      // not seen for LostAfter, removed
+)
+
+// TrackEventType says what happened to a track.
+type TrackEventType string
+
+const (
+	TrackEntered    TrackEventType = "entered"    // a face came into view
+	TrackIdentified TrackEventType = "identified" // an existing track was recognized as someone (else)
+	TrackExpression TrackEventType = "expression" // the face's smoothed emotion changed
+	TrackExited     TrackEventType = "exited"     // the face left
+)
+
+// TrackerConfig controls how faces are followed across frames.
+type TrackerConfig struct {
+	MinIoU           float64          // bounding box overlap that links a face to a track (default: 0.3)
+	MinSimilarity    float64          // embedding similarity that links a face to a track without overlap (default: 0.7)
+	LostAfter        time.Duration    // a track not seen this long is lost (default: 2s)
+	RecognizeFrames  int              // good frames recognized when a track starts (default: 3)
+	RecheckEvery     time.Duration    // after that, recognize again this often (default: 5s)
+	MinRecognizeConf float64          // detection confidence for a frame to be worth recognizing (default: 0.8)
+	Voting           VotingConfig     // how recognitions add up to an identity
+	Expression       ExpressionConfig // how per-frame emotions are smoothed
+	Learn            bool             // add confident recognitions to the person's gallery
+}
+
+// DefaultTrackerConfig returns sensible defaults for a few frames per second.
+func DefaultTrackerConfig() TrackerConfig {
+	return TrackerConfig{
+		MinIoU:           0.3,
+		MinSimilarity:    0.7,
+		LostAfter:        2 * time.Second,
+		RecognizeFrames:  3,
+		RecheckEvery:     5 * time.Second,
+		MinRecognizeConf: 0.8,
+		Voting:           DefaultVotingConfig(),
+		Expression:       DefaultExpressionConfig(),
+		Learn:            true,
+	}
+}
+
+// Track is one face followed across frames.
+type Track struct {
+	ID           int          `json:"id"`
+	State        TrackState   `json:"state"`
+	BoundingBox  BoundingBox  `json:"bounding_box"`
+	FirstSeen    time.Time    `json:"first_seen"`
+	LastSeen     time.Time    `json:"last_seen"`
+	Frames       int          `json:"frames"`
+	Emotion      Emotion      `json:"emotion,omitempty"` // smoothed over frames; empty until one holds
+	EmotionConf  float64      `json:"emotion_confidence,omitempty"`
+	Identity     Identity     `json:"identity,omitempty"`
+	PersonID     string       `json:"person_id,omitempty"` // set while Identity is known
+	Name         string       `json:"name,omitempty"`
+	Relationship Relationship `json:"relationship"`
+	Confidence   float64      `json:"confidence"` // average similarity of the votes for them
+	IsOwner      bool         `json:"is_owner"`
+	StrangerID   string       `json:"stranger_id,omitempty"` // provisional identity, if stranger memory is on
+
+	embedding     Embedding // latest, for association
+	recognized    int       // good frames recognized so far
+	lastRecognize time.Time
+	ballot        ballot
+	expression    expression
+	visited       string // person a visit was last recorded for
+}
+
+// Face returns the track as a recognized face.
+func (t Track) Face() FaceResult {
+	return FaceResult{
+		BoundingBox:  t.BoundingBox,
+		PersonID:     t.PersonID,
+		Name:         t.Name,
+		Relationship: t.Relationship,
+		Confidence:   t.Confidence,
+		Emotion:      t.Emotion,
+		EmotionConf:  t.EmotionConf,
+		IsOwner:      t.IsOwner,
+		TrackID:      t.ID,
+		Identity:     t.Identity,
+	}
+}
+
+// TrackEvent reports a change in who is in view.
+type TrackEvent struct {
+	Type  TrackEventType `json:"type"`
+	Track Track          `json:"track"`
+}
+
+// Tracker follows faces across frames so each person is recognized on a few
+// good frames rather than every frame, and entering and leaving are events.
+// It is safe for concurrent use.
+type Tracker struct {
+	db        *FaceDB
+	cfg       TrackerConfig
+	strangers *StrangerMemory // optional
+
+	mu     sync.Mutex
+	tracks []*Track
+	nextID int
+	now    func() time.Time
+}
+
+// NewTracker creates a tracker that recognizes faces against db.
+func NewTracker(db *FaceDB, cfg TrackerConfig) *Tracker {
+	defaults := DefaultTrackerConfig()
+	if cfg.MinIoU == 0 {
+		cfg.MinIoU = defaults.MinIoU
+	}
+	if cfg.MinSimilarity == 0 {
+		cfg.MinSimilarity = defaults.MinSimilarity
+	}
+	if cfg.LostAfter == 0 {
+		cfg.LostAfter = defaults.LostAfter
+	}
+	if cfg.RecognizeFrames == 0 {
+		cfg.RecognizeFrames = defaults.RecognizeFrames
+	}
+	if cfg.RecheckEvery == 0 {
+		cfg.RecheckEvery = defaults.RecheckEvery
+	}
+	if cfg.MinRecognizeConf == 0 {
+		cfg.MinRecognizeConf = defaults.MinRecognizeConf
+	}
+	if cfg.Voting.Window == 0 {
+		cfg.Voting.Window = defaults.Voting.Window
+	}
+	if cfg.Voting.MinShare == 0 {
+		cfg.Voting.MinShare = defaults.Voting.MinShare
+	}
+	if cfg.Voting.MinWeight == 0 {
+		cfg.Voting.MinWeight = defaults.Voting.MinWeight
+	}
+	if cfg.Voting.RejectMargin == 0 {
+		cfg.Voting.RejectMargin = defaults.Voting.RejectMargin
+	}
+	if cfg.Expression.Window == 0 {
+		cfg.Expression.Window = defaults.Expression.Window
+	}
+	if cfg.Expression.MinFrames == 0 {
+		cfg.Expression.MinFrames = defaults.Expression.MinFrames
+	}
+	if cfg.Expression.MinShare == 0 {
+		cfg.Expression.MinShare = defaults.Expression.MinShare
+	}
+	if cfg.Expression.MinConf == 0 {
+		cfg.Expression.MinConf = defaults.Expression.MinConf
+	}
+
+	return &Tracker{
+		db:     db,
+		cfg:    cfg,
+		nextID: 1,
+		now:    time.Now,
+	}
+}
+
+// Update feeds the faces detected in a new frame and returns what changed.
+func (t *Tracker) Update(faces []FaceDetection) []TrackEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	var events []TrackEvent
+
+	matched := t.associate(faces)
+	for i, face := range faces {
+		track, ok := matched[i]
+		if !ok {
+			track = &Track{
+				ID:           t.nextID,
+				State:        TrackAppeared,
+				FirstSeen:    now,
+				Relationship: RelationshipStranger,
+				ballot:       ballot{cfg: t.cfg.Voting},
+				expression:   expression{cfg: t.cfg.Expression},
+			}
+			t.nextID++
+			t.tracks = append(t.tracks, track)
+		} else {
+			track.State = TrackPersisted
+		}
+
+		track.BoundingBox = face.BoundingBox
+		track.LastSeen = now
+		track.Frames++
+		track.embedding = face.Embedding
+		expressed := track.expression.add(face.Emotion, face.EmotionConf)
+		track.Emotion, track.EmotionConf = track.expression.emotion, track.expression.conf
+
+		identified := t.recognize(track, face, now)
+		switch {
+		case track.State == TrackAppeared:
+			events = append(events, TrackEvent{Type: TrackEntered, Track: *track})
+		case identified:
+			events = append(events, TrackEvent{Type: TrackIdentified, Track: *track})
+		case expressed:
+			events = append(events, TrackEvent{Type: TrackExpression, Track: *track})
+		}
+	}
+
+	// Drop tracks that haven't been seen for a while
+	live := t.tracks[:0]
+	for _, track := range t.tracks {
+		if now.Sub(track.LastSeen) >= t.cfg.LostAfter {
+			track.State = TrackLost
+			events = append(events, TrackEvent{Type: TrackExited, Track: *track})
+			continue
+		}
+		live = append(live, track)
+	}
+	t.tracks = live
+
+	return events
+}
+
+// associate links detections to existing tracks, best pairs first. A pair
+// qualifies if the boxes overlap enough or the faces look alike, which
+// survives fast movement between frames, but never if the faces clearly
+// differ, which survives people crossing.
+func (t *Tracker) associate(faces []FaceDetection) map[int]*Track {
+	type pair struct {
+		face, track int
+		score       float64
+	}
+	var pairs []pair
+	for fi, face := range faces {
+		for ti, track := range t.tracks {
+			iou := face.BoundingBox.IoU(track.BoundingBox)
+			sim := cosineSimilarity(face.Embedding, track.embedding)
+			if iou < t.cfg.MinIoU && sim < t.cfg.MinSimilarity {
+				continue
+			}
+			if sim < t.cfg.MinSimilarity/2 {
+				continue // clearly a different face, even if it's in the same place
+			}
+			pairs = append(pairs, pair{fi, ti, iou + sim})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].score > pairs[j].score })
+
+	matched := make(map[int]*Track)
+	used := make(map[int]bool)
+	for _, p := range pairs {
+		if _, ok := matched[p.face]; ok || used[p.track] {
+			continue
+		}
+		matched[p.face] = t.tracks[p.track]
+		used[p.track] = true
+	}
+	return matched
+}
+
+// recognize runs recognition on the first good frames of a track, then
+// periodically once it's settled on known or stranger, and votes on who it
+// is. Returns true if the track's identity changed.
+func (t *Tracker) recognize(track *Track, face FaceDetection, now time.Time) bool {
+	if face.Confidence < t.cfg.MinRecognizeConf {
+		return false
+	}
+	settled := track.Identity == IdentityKnown || track.Identity == IdentityStranger
+	if settled && track.recognized >= t.cfg.RecognizeFrames && now.Sub(track.lastRecognize) < t.cfg.RecheckEvery {
+		return false
+	}
+	track.recognized++
+	track.lastRecognize = now
+
+	person, similarity, threshold := t.db.Nearest(face.Embedding)
+	track.ballot.add(vote{person: person, similarity: similarity, threshold: threshold})
+	identity, person, confidence := track.ballot.decide(track.Identity, track.PersonID, t.cfg.RecognizeFrames)
+
+	changed := identity != track.Identity || (person != nil && person.ID != track.PersonID)
+	track.Identity = identity
+	if person == nil {
+		track.PersonID, track.Name, track.IsOwner = "", "", false
+		track.Relationship = RelationshipStranger
+		track.Confidence = similarity
+		if identity == IdentityStranger {
+			person = t.rememberStranger(track, face.Embedding)
+		}
+		if person == nil {
+			return changed
+		}
+
+		// They've just become an acquaintance, so the track is them from now on
+		track.Identity = IdentityKnown
+		track.StrangerID = ""
+		confidence = t.db.bestSimilarity(face.Embedding, person.Embeddings)
+		changed = true
+	}
+
+	track.PersonID = person.ID
+	track.Name = person.Name
+	track.Relationship = person.Relationship
+	track.Confidence = confidence
+	track.IsOwner = person.Relationship == RelationshipOwner
+	if track.visited != person.ID {
+		track.visited = person.ID
+		_ = t.db.RecordVisit(person.ID) // best effort
+	}
+	if latest := track.ballot.latest(); t.cfg.Learn && latest.person != nil && latest.person.ID == person.ID {
+		t.db.Learn(person.ID, face.Embedding) // FaceDB decides if it's worth keeping
+	}
+	return changed
+}
+
+// rememberStranger counts a stranger's visit the first time their track
+// settles, and adds later looks at them as samples. Returns the new
+// acquaintance if this promoted them. Caller holds t.mu.
+func (t *Tracker) rememberStranger(track *Track, embedding Embedding) *Person {
+	if t.strangers == nil {
+		return nil
+	}
+	if track.StrangerID == "" {
+		stranger, promoted := t.strangers.Visit(embedding)
+		track.StrangerID = stranger.ID
+		return promoted
+	}
+	return t.strangers.Observe(track.StrangerID, embedding)
+}
+
+// SetStrangerMemory makes faces settled as strangers count as visits from
+// provisional identities, which can become acquaintances.
+func (t *Tracker) SetStrangerMemory(m *StrangerMemory) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.strangers = m
+}
+
+// Interact credits the strangers in view with an interaction, such as
+// being petted. Returns anyone it promoted to an acquaintance.
+func (t *Tracker) Interact(score float64) []*Person {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.strangers == nil {
+		return nil
+	}
+	var ids []string
+	for _, track := range t.tracks {
+		if track.StrangerID != "" && track.State != TrackLost {
+			ids = append(ids, track.StrangerID)
+		}
+	}
+	return t.strangers.Interact(ids, score)
+}
+
+// Tracks returns the live tracks, oldest first.
+func (t *Tracker) Tracks() []Track {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracks := make([]Track, len(t.tracks))
+	for i, track := range t.tracks {
+		tracks[i] = *track
+	}
+	return tracks
+}
+
+// Scene summarizes the faces currently tracked.
+func (t *Tracker) Scene(cfg SceneConfig) *Scene {
+	tracks := t.Tracks()
+	faces := make([]FaceResult, len(tracks))
+	for i, track := range tracks {
+		faces[i] = track.Face()
+	}
+	return summarizeScene(faces, cfg)
+}
+
+// IoU is the intersection over union of two boxes, 0 if they don't overlap.
+func (b BoundingBox) IoU(other BoundingBox) float64 {
+	x1, y1 := max(b.X, other.X), max(b.Y, other.Y)
+	x2 := min(b.X+b.Width, other.X+other.Width)
+	y2 := min(b.Y+b.Height, other.Y+other.Height)
+	if x2 <= x1 || y2 <= y1 {
+		return 0
+	}
+	inter := float64((x2 - x1) * (y2 - y1))
+	union := float64(b.Width*b.Height+other.Width*other.Height) - inter
+	return inter / union
+}
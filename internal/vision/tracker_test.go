@@ -0,0 +1,169 @@
+package vision
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func testTracker(t *testing.T) (*Tracker, *FaceDB, *testClock) {
+	t.Helper()
+	db := sceneDB(t)
+	tracker := NewTracker(db, TrackerConfig{})
+	clock := newTestClock()
+	tracker.now = clock.Now
+	return tracker, db, clock
+}
+
+func face(x int, e Embedding) FaceDetection {
+	return FaceDetection{BoundingBox: BoundingBox{X: x, Y: 0, Width: 100, Height: 100}, Confidence: 0.95, Embedding: e}
+}
+
+func eventTypes(events []TrackEvent) []TrackEventType {
+	types := make([]TrackEventType, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func TestTracker_StableTrackAndVisits(t *testing.T) {
+	tracker, db, clock := testTracker(t)
+
+	events := tracker.Update([]FaceDetection{face(0, axis(0, 0.02))})
+	if len(events) != 1 || events[0].Type != TrackEntered || events[0].Track.Identity != IdentityPending {
+		t.Fatalf("first frame events = %+v, want someone entering", events)
+	}
+	id := events[0].Track.ID
+
+	// One frame isn't enough to be sure, two good ones are
+	clock.Advance(250 * time.Millisecond)
+	events = tracker.Update([]FaceDetection{face(2, axis(0, 0.02))})
+	if len(events) != 1 || events[0].Type != TrackIdentified || events[0].Track.Name != "Alex" || events[0].Track.ID != id {
+		t.Fatalf("second frame events = %+v, want Alex identified", events)
+	}
+
+	// Alex walks slowly across the frame for ten seconds
+	for i := 2; i <= 40; i++ {
+		clock.Advance(250 * time.Millisecond)
+		if events := tracker.Update([]FaceDetection{face(i*5, axis(0, 0.02))}); len(events) != 0 {
+			t.Fatalf("frame %d events = %v, want none", i, eventTypes(events))
+		}
+	}
+
+	tracks := tracker.Tracks()
+	if len(tracks) != 1 || tracks[0].ID != id || tracks[0].State != TrackPersisted || tracks[0].Frames != 41 {
+		t.Fatalf("tracks = %+v, want one persisted track %d over 41 frames", tracks, id)
+	}
+	// First 3 frames plus a re-check 5s later
+	if got := tracker.tracks[0].recognized; got != 4 {
+		t.Errorf("recognized %d frames, want 4", got)
+	}
+
+	// Leaving and coming back is a second visit
+	clock.Advance(3 * time.Second)
+	events = tracker.Update(nil)
+	if len(events) != 1 || events[0].Type != TrackExited || events[0].Track.ID != id || events[0].Track.State != TrackLost {
+		t.Fatalf("events after leaving = %+v, want track %d exited", events, id)
+	}
+	events = tracker.Update([]FaceDetection{face(0, axis(0, 0.02))})
+	if len(events) != 1 || events[0].Type != TrackEntered || events[0].Track.ID == id {
+		t.Errorf("events on return = %+v, want a new track entering", events)
+	}
+	tracker.Update([]FaceDetection{face(0, axis(0, 0.02))})
+	db.Flush()
+	if owner := db.GetOwner(); owner.SeenCount != 2 {
+		t.Errorf("SeenCount = %d, want 2 visits", owner.SeenCount)
+	}
+}
+
+func TestTracker_IdentifiesOnLaterGoodFrame(t *testing.T) {
+	tracker, _, clock := testTracker(t)
+
+	blurry := face(0, axis(2, 0.02))
+	blurry.Confidence = 0.6
+	events := tracker.Update([]FaceDetection{blurry})
+	if events[0].Type != TrackEntered || events[0].Track.PersonID != "" || events[0].Track.Relationship != RelationshipStranger {
+		t.Fatalf("blurry first frame = %+v, want an unrecognized entry", events[0])
+	}
+
+	clock.Advance(250 * time.Millisecond)
+	if events = tracker.Update([]FaceDetection{face(10, axis(2, 0.02))}); len(events) != 0 {
+		t.Errorf("events after one sharp frame = %v, want none yet", eventTypes(events))
+	}
+	clock.Advance(250 * time.Millisecond)
+	events = tracker.Update([]FaceDetection{face(20, axis(2, 0.02))})
+	if len(events) != 1 || events[0].Type != TrackIdentified || events[0].Track.Name != "Sam" {
+		t.Errorf("sharp frame events = %+v, want Sam identified", events)
+	}
+}
+
+func TestTracker_PeopleCrossing(t *testing.T) {
+	tracker, _, clock := testTracker(t)
+
+	tracker.Update([]FaceDetection{face(0, axis(0, 0.02)), face(300, axis(2, 0.02))})
+	tracker.Update([]FaceDetection{face(0, axis(0, 0.02)), face(300, axis(2, 0.02))})
+	ids := map[string]int{}
+	for _, track := range tracker.Tracks() {
+		ids[track.Name] = track.ID
+	}
+
+	// They swap sides between frames: boxes don't overlap, faces still match
+	clock.Advance(250 * time.Millisecond)
+	if events := tracker.Update([]FaceDetection{face(300, axis(0, 0.03)), face(0, axis(2, 0.03))}); len(events) != 0 {
+		t.Errorf("events = %v, want none", eventTypes(events))
+	}
+	for _, track := range tracker.Tracks() {
+		if track.ID != ids[track.Name] {
+			t.Errorf("%s moved from track %d to %d", track.Name, ids[track.Name], track.ID)
+		}
+	}
+
+	scene := tracker.Scene(SceneConfig{})
+	if !scene.OwnerPresent || len(scene.Known) != 1 || scene.Faces[0].TrackID == 0 {
+		t.Errorf("scene = %+v", scene)
+	}
+}
+
+func TestBridge_TrackEventsOnlyOnEntry(t *testing.T) {
+	tracker, _, clock := testTracker(t)
+	b, handler, bclock := testBridge()
+
+	frame := []FaceDetection{face(0, axis(0, 0.02)), face(300, axis(5, 0))}
+	b.HandleTrackEvents(tracker.Update(frame), tracker.Scene(SceneConfig{}))
+	if len(handler.events) != 0 {
+		t.Fatalf("events = %+v before anyone was recognized", handler.events)
+	}
+	b.HandleTrackEvents(tracker.Update(frame), tracker.Scene(SceneConfig{}))
+	if len(handler.events) != 2 {
+		t.Fatalf("events = %+v, want Alex and a stranger", handler.events)
+	}
+
+	// Minutes later, still there: nothing, even past the cooldowns
+	for range 10 {
+		clock.Advance(time.Second)
+		bclock.Advance(time.Minute)
+		b.HandleTrackEvents(tracker.Update(frame), tracker.Scene(SceneConfig{}))
+	}
+	if len(handler.events) != 2 {
+		t.Errorf("sent %d events while nobody moved", len(handler.events)-2)
+	}
+}
+
+func TestBoundingBox_IoU(t *testing.T) {
+	box := BoundingBox{X: 0, Y: 0, Width: 10, Height: 10}
+	tests := []struct {
+		other BoundingBox
+		want  float64
+	}{
+		{box, 1},
+		{BoundingBox{X: 5, Y: 0, Width: 10, Height: 10}, 50.0 / 150},
+		{BoundingBox{X: 10, Y: 0, Width: 10, Height: 10}, 0},
+		{BoundingBox{X: 2, Y: 2, Width: 5, Height: 5}, 0.25},
+	}
+	for _, tt := range tests {
+		if got := box.IoU(tt.other); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("IoU(%+v) = %v, want %v", tt.other, got, tt.want)
+		}
+	}
+}
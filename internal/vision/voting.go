@@ -0,0 +1,142 @@
+package vision
+
+// Identity is what the tracker has concluded about who a face is.
+type Identity string
+
+const (
+	IdentityPending   Identity = ""          // not enough good frames yet
+	IdentityKnown     Identity = "known"     // an enrolled person
+	IdentityStranger  Identity = "stranger"  // consistently nobody Koji knows
+	IdentityUncertain Identity = "uncertain" // looked enough, still can't tell
+)
+
+// VotingConfig controls how per-frame recognitions add up to an identity.
+type VotingConfig struct {
+	Window       int     // recognitions remembered per track (default: 6)
+	MinShare     float64 // share of the vote weight needed to decide (default: 0.6)
+	MinWeight    float64 // vote weight needed to decide, about two solid frames (default: 1.2)
+	RejectMargin float64 // below threshold-RejectMargin a vote counts against a person (default: 0.08)
+}
+
+// DefaultVotingConfig returns sensible defaults.
+func DefaultVotingConfig() VotingConfig {
+	return VotingConfig{
+		Window:       6,
+		MinShare:     0.6,
+		MinWeight:    1.2,
+		RejectMargin: 0.08,
+	}
+}
+
+// vote is one frame's recognition: the nearest person, how similar, and
+// what similarity recognizing them needs.
+type vote struct {
+	person     *Person
+	similarity float64
+	threshold  float64
+}
+
+// ballot collects a track's recent votes and decides who it is.
+//
+// Each vote at or above its person's threshold counts for them, weighted by
+// similarity. Votes well below it (past RejectMargin) count for "stranger",
+// weighted by how dissimilar they are. Borderline votes in between count
+// for no one but still dilute the total, so a run of 0.61s for a 0.6
+// threshold doesn't add up to a confident identity.
+//
+// Hysteresis: once someone is known, they stay known until their average
+// similarity falls past the reject margin or someone else wins outright. A
+// single borderline frame can't flip Koji between familiar and stranger.
+type ballot struct {
+	cfg   VotingConfig
+	votes []vote
+}
+
+func (b *ballot) add(v vote) {
+	b.votes = append(b.votes, v)
+	if len(b.votes) > b.cfg.Window {
+		b.votes = b.votes[len(b.votes)-b.cfg.Window:]
+	}
+}
+
+// latest returns the most recent vote.
+func (b *ballot) latest() vote {
+	return b.votes[len(b.votes)-1]
+}
+
+// candidate is everything the ballot says about one person.
+type candidate struct {
+	person    *Person
+	threshold float64
+	weight    float64 // from votes at or above threshold
+	simSum    float64 // from all votes for them
+	votes     int
+}
+
+func (c *candidate) mean() float64 {
+	return c.simSum / float64(c.votes)
+}
+
+// decide returns the identity given the current one, with the person and
+// their average similarity if known. minVotes is how many votes it takes
+// to give up and call the face uncertain.
+func (b *ballot) decide(current Identity, currentID string, minVotes int) (Identity, *Person, float64) {
+	candidates := make(map[string]*candidate)
+	var stranger, total float64
+	for _, v := range b.votes {
+		var c *candidate
+		if v.person != nil {
+			if c = candidates[v.person.ID]; c == nil {
+				c = &candidate{person: v.person, threshold: v.threshold}
+				candidates[v.person.ID] = c
+			}
+			c.simSum += v.similarity
+			c.votes++
+		}
+
+		switch {
+		case c != nil && v.similarity >= v.threshold:
+			c.weight += v.similarity
+			total += v.similarity
+		case c != nil && v.similarity >= v.threshold-b.cfg.RejectMargin:
+			total += v.similarity // borderline: counts for no one
+		default:
+			w := 1 - max(v.similarity, 0)
+			stranger += w
+			total += w
+		}
+	}
+	decisive := func(w float64) bool {
+		return total > 0 && w >= b.cfg.MinWeight && w/total >= b.cfg.MinShare
+	}
+
+	var leader *candidate
+	for _, c := range candidates {
+		if leader == nil || c.weight > leader.weight || (c.weight == leader.weight && c.person.ID < leader.person.ID) {
+			leader = c
+		}
+	}
+
+	// Someone has clearly won, and it isn't who we already thought
+	if leader != nil && leader.person.ID != currentID && decisive(leader.weight) {
+		return IdentityKnown, leader.person, leader.mean()
+	}
+
+	// The current person stays until their average slips past the margin
+	if current == IdentityKnown {
+		if c := candidates[currentID]; c != nil && c.mean() >= c.threshold-b.cfg.RejectMargin {
+			return IdentityKnown, c.person, c.mean()
+		}
+	}
+
+	if decisive(stranger) {
+		return IdentityStranger, nil, 0
+	}
+	if current == IdentityStranger && total > 0 && stranger/total >= 1-b.cfg.MinShare {
+		return IdentityStranger, nil, 0 // not convinced otherwise yet
+	}
+	if current != IdentityPending || len(b.votes) >= minVotes {
+		return IdentityUncertain, nil, 0
+	}
+	return IdentityPending, nil, 0
+}
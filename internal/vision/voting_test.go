@@ -0,0 +1,90 @@
+package vision
+
+import "testing"
+
+var (
+	votingOwner  = &Person{ID: "1", Name: "Alex", Relationship: RelationshipOwner}
+	votingFriend = &Person{ID: "2", Name: "Sam", Relationship: RelationshipFriend}
+)
+
+// runBallot feeds votes one at a time like the tracker does and returns the
+// identity after each.
+func runBallot(votes []vote) ([]Identity, []string) {
+	b := ballot{cfg: DefaultVotingConfig()}
+	var identity Identity
+	var personID string
+	var identities []Identity
+	var people []string
+	for _, v := range votes {
+		b.add(v)
+		var p *Person
+		identity, p, _ = b.decide(identity, personID, 3)
+		personID = ""
+		if p != nil {
+			personID = p.ID
+		}
+		identities = append(identities, identity)
+		people = append(people, personID)
+	}
+	return identities, people
+}
+
+func ownerVote(sim float64) vote  { return vote{person: votingOwner, similarity: sim, threshold: 0.7} }
+func friendVote(sim float64) vote { return vote{person: votingFriend, similarity: sim, threshold: 0.6} }
+
+func TestBallot_OwnerSurvivesBorderlineFrames(t *testing.T) {
+	// Two clear frames, then the owner turns their head: 0.66 is under the
+	// 0.7 owner threshold but within the margin
+	identities, people := runBallot([]vote{
+		ownerVote(0.85), ownerVote(0.8), ownerVote(0.66), ownerVote(0.64), ownerVote(0.67), ownerVote(0.65),
+	})
+	for i, identity := range identities[1:] {
+		if identity != IdentityKnown || people[i+1] != "1" {
+			t.Errorf("after vote %d: %s %q, want the owner throughout", i+2, identity, people[i+1])
+		}
+	}
+	if identities[0] != IdentityPending {
+		t.Errorf("after one vote: %s, want pending", identities[0])
+	}
+}
+
+func TestBallot_OneBorderlineFrameIsNotAnIdentity(t *testing.T) {
+	// 0.61 clears the friend threshold once, surrounded by weak frames
+	identities, _ := runBallot([]vote{friendVote(0.55), friendVote(0.61), friendVote(0.54)})
+	if got := identities[len(identities)-1]; got != IdentityUncertain {
+		t.Errorf("identity = %s, want uncertain", got)
+	}
+}
+
+func TestBallot_StrangerAndHysteresis(t *testing.T) {
+	identities, _ := runBallot([]vote{
+		friendVote(0.2), friendVote(0.25),
+		friendVote(0.62), // one lucky frame
+	})
+	want := []Identity{IdentityPending, IdentityStranger, IdentityStranger}
+	for i := range want {
+		if identities[i] != want[i] {
+			t.Errorf("after vote %d: %s, want %s", i+1, identities[i], want[i])
+		}
+	}
+}
+
+func TestBallot_LosingTheOwnerGoesUncertainNotStranger(t *testing.T) {
+	identities, _ := runBallot([]vote{
+		ownerVote(0.8), ownerVote(0.8),
+		ownerVote(0.3), ownerVote(0.3), ownerVote(0.3),
+	})
+	if got := identities[3]; got != IdentityUncertain {
+		t.Errorf("after the owner's average dropped: %s, want uncertain", got)
+	}
+}
+
+func TestBallot_SomeoneElseWinsOutright(t *testing.T) {
+	_, people := runBallot([]vote{
+		ownerVote(0.8), ownerVote(0.8),
+		friendVote(0.9), friendVote(0.9), friendVote(0.9), friendVote(0.9),
+	})
+	if got := people[len(people)-1]; got != "2" {
+		t.Errorf("person = %q, want the friend once they dominate the window", got)
+	}
+}
@@ -0,0 +1,311 @@
+// Package wire implements Koji's compact binary protocol for microcontrollers.
+//
+// JSON over HTTP costs an ESP32 tens of milliseconds and a lot of heap per poll.
+// Instead the brain sends a fixed 13-byte state frame over UDP on every change
+// and on a keepalive interval, each followed by a cue frame for displays that
+// animate the face themselves, and sensors can send small event frames back.
+//
+// All frames share a 4-byte header and end with a CRC-8 (poly 0x07, init 0x00)
+// over every preceding byte. Multi-byte integers are big-endian.
+//
+// State frame (brain -> display), 13 bytes:
+//
+//	0  magic     'K' (0x4B)
+//	1  version   1
+//	2  type      0x01
+//	3  flags     reserved, 0
+//	4  sequence  uint32, increments on every frame sent
+//	8  mood      mood ID (see MoodID), 0 = unknown
+//	9  emotion   ESP32 eEmotions index
+//	10 intensity 0-255 (intensity * 255)
+//	11 action    action ID (see ActionID), 0 = none
+//	12 crc
+//
+// Cue frame (brain -> display), 12 bytes. Displays that don't animate the
+// face drop it by its type:
+//
+//	0  magic     'K' (0x4B)
+//	1  version   1
+//	2  type      0x03
+//	3  flags     bit 0: look target present
+//	4  sequence  uint32, same as the state frame it follows
+//	8  modifier  action modifier ID (see ModifierID), 0 = derive from mood
+//	9  look x    int8, -127 (right) to 127 (left)
+//	10 look y    int8, -127 (down) to 127 (up)
+//	11 crc
+//
+// Event frame (sensor -> brain), 10 + len(source) bytes:
+//
+//	0  magic     'K' (0x4B)
+//	1  version   1
+//	2  type      0x02
+//	3  flags     reserved, 0
+//	4  sequence  uint16, per-sender; repeats are dropped as retransmits
+//	6  event     event ID (see EventID)
+//	7  intensity 0-255, 0 = default (0.5)
+//	8  srclen    length of source, at most MaxSourceLen
+//	9  source    ASCII source name
+//	.. crc
+//
+// IDs are part of the wire contract: new moods, actions, modifiers and events are only
+// ever appended to the tables in ids.go.
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+// Protocol constants.
+const (
+	Magic   byte = 'K'
+	Version byte = 1
+
+	TypeState byte = 0x01
+	TypeEvent byte = 0x02
+	TypeCues  byte = 0x03
+
+	FlagLook byte = 0x01 // cue frame carries a look target
+
+	headerLen     = 4
+	StateFrameLen = headerLen + 4 + 4 + 1
+	CueFrameLen   = headerLen + 4 + 3 + 1
+	MaxSourceLen  = 32
+	minEventLen   = headerLen + 2 + 3 + 1
+)
+
+// Decoding errors.
+var (
+	ErrShortFrame  = errors.New("wire: frame too short")
+	ErrBadMagic    = errors.New("wire: bad magic byte")
+	ErrBadVersion  = errors.New("wire: unsupported protocol version")
+	ErrBadType     = errors.New("wire: unexpected frame type")
+	ErrBadChecksum = errors.New("wire: checksum mismatch")
+	ErrBadLength   = errors.New("wire: length field does not match frame")
+)
+
+// StateFrame is Koji's state as seen by a display.
+type StateFrame struct {
+	Sequence  uint32
+	Mood      uint8 // mood ID
+	Emotion   uint8 // ESP32 eEmotions index
+	Intensity uint8 // 0-255
+	Action    uint8 // action ID, 0 = none
+}
+
+// CueFrame carries the face cues a state frame can't: how fast to move the
+// eyes and where to look.
+type CueFrame struct {
+	Sequence uint32
+	Modifier uint8 // action modifier ID, 0 = derive from mood
+	Look     bool  // LookX and LookY are set
+	LookX    int8
+	LookY    int8
+}
+
+// EventFrame is a sensor event sent to the brain.
+type EventFrame struct {
+	Sequence  uint16
+	Event     uint8 // event ID
+	Intensity uint8 // 0-255, 0 = default
+	Source    string
+}
+
+// NewStateFrame builds a state frame from an emotional state and the last action.
+func NewStateFrame(seq uint32, state *personality.EmotionalState, action string) StateFrame {
+	return StateFrame{
+		Sequence:  seq,
+		Mood:      MoodID(state.CurrentMood),
+		Emotion:   uint8(state.ToFaceEmotion().Index()),
+		Intensity: IntensityByte(float64(state.Intensity)),
+		Action:    ActionID(personality.Action(action)),
+	}
+}
+
+// EncodeState serializes a state frame.
+func EncodeState(f StateFrame) []byte {
+	buf := make([]byte, 0, StateFrameLen)
+	buf = append(buf, Magic, Version, TypeState, 0)
+	buf = binary.BigEndian.AppendUint32(buf, f.Sequence)
+	buf = append(buf, f.Mood, f.Emotion, f.Intensity, f.Action)
+	return append(buf, crc8(buf))
+}
+
+// DecodeState parses a state frame.
+func DecodeState(b []byte) (StateFrame, error) {
+	if err := checkFrame(b, TypeState); err != nil {
+		return StateFrame{}, err
+	}
+	if len(b) != StateFrameLen {
+		return StateFrame{}, ErrBadLength
+	}
+
+	return StateFrame{
+		Sequence:  binary.BigEndian.Uint32(b[4:8]),
+		Mood:      b[8],
+		Emotion:   b[9],
+		Intensity: b[10],
+		Action:    b[11],
+	}, nil
+}
+
+// NewCueFrame builds a cue frame from the last action modifier and look hint
+// (nil for none).
+func NewCueFrame(seq uint32, modifier personality.ActionModifier, look *personality.LookHint) CueFrame {
+	f := CueFrame{Sequence: seq, Modifier: ModifierID(modifier)}
+	if look != nil {
+		f.Look = true
+		f.LookX = LookByte(look.X)
+		f.LookY = LookByte(look.Y)
+	}
+	return f
+}
+
+// EncodeCues serializes a cue frame.
+func EncodeCues(f CueFrame) []byte {
+	var flags byte
+	if f.Look {
+		flags |= FlagLook
+	}
+
+	buf := make([]byte, 0, CueFrameLen)
+	buf = append(buf, Magic, Version, TypeCues, flags)
+	buf = binary.BigEndian.AppendUint32(buf, f.Sequence)
+	buf = append(buf, f.Modifier, byte(f.LookX), byte(f.LookY))
+	return append(buf, crc8(buf))
+}
+
+// DecodeCues parses a cue frame.
+func DecodeCues(b []byte) (CueFrame, error) {
+	if err := checkFrame(b, TypeCues); err != nil {
+		return CueFrame{}, err
+	}
+	if len(b) != CueFrameLen {
+		return CueFrame{}, ErrBadLength
+	}
+
+	return CueFrame{
+		Sequence: binary.BigEndian.Uint32(b[4:8]),
+		Modifier: b[8],
+		Look:     b[3]&FlagLook != 0,
+		LookX:    int8(b[9]),
+		LookY:    int8(b[10]),
+	}, nil
+}
+
+// EncodeEvent serializes an event frame. Sources longer than MaxSourceLen are truncated.
+func EncodeEvent(f EventFrame) []byte {
+	source := f.Source
+	if len(source) > MaxSourceLen {
+		source = source[:MaxSourceLen]
+	}
+
+	buf := make([]byte, 0, minEventLen+len(source))
+	buf = append(buf, Magic, Version, TypeEvent, 0)
+	buf = binary.BigEndian.AppendUint16(buf, f.Sequence)
+	buf = append(buf, f.Event, f.Intensity, byte(len(source)))
+	buf = append(buf, source...)
+	return append(buf, crc8(buf))
+}
+
+// DecodeEvent parses an event frame.
+func DecodeEvent(b []byte) (EventFrame, error) {
+	if err := checkFrame(b, TypeEvent); err != nil {
+		return EventFrame{}, err
+	}
+	if len(b) < minEventLen {
+		return EventFrame{}, ErrShortFrame
+	}
+
+	srcLen := int(b[8])
+	if srcLen > MaxSourceLen || len(b) != minEventLen+srcLen {
+		return EventFrame{}, ErrBadLength
+	}
+
+	return EventFrame{
+		Sequence:  binary.BigEndian.Uint16(b[4:6]),
+		Event:     b[6],
+		Intensity: b[7],
+		Source:    string(b[9 : 9+srcLen]),
+	}, nil
+}
+
+// FrameType returns the type byte of a frame after validating its header and checksum.
+func FrameType(b []byte) (byte, error) {
+	if err := checkHeader(b); err != nil {
+		return 0, err
+	}
+	return b[2], nil
+}
+
+// checkFrame validates header, type and checksum.
+func checkFrame(b []byte, want byte) error {
+	if err := checkHeader(b); err != nil {
+		return err
+	}
+	if b[2] != want {
+		return ErrBadType
+	}
+	return nil
+}
+
+func checkHeader(b []byte) error {
+	if len(b) < headerLen+1 {
+		return ErrShortFrame
+	}
+	if b[0] != Magic {
+		return ErrBadMagic
+	}
+	if b[1] != Version {
+		return ErrBadVersion
+	}
+	if crc8(b[:len(b)-1]) != b[len(b)-1] {
+		return ErrBadChecksum
+	}
+	return nil
+}
+
+// IntensityByte scales a 0.0-1.0 intensity to a byte.
+func IntensityByte(intensity float64) uint8 {
+	if intensity <= 0 {
+		return 0
+	}
+	if intensity >= 1 {
+		return 255
+	}
+	return uint8(math.Round(intensity * 255))
+}
+
+// IntensityFloat scales an intensity byte back to 0.0-1.0.
+func IntensityFloat(b uint8) float64 {
+	return float64(b) / 255
+}
+
+// LookByte scales a -1.0 to 1.0 look coordinate to a signed byte.
+func LookByte(v float64) int8 {
+	return int8(math.Round(math.Max(-1, math.Min(1, v)) * 127))
+}
+
+// LookFloat scales a look byte back to -1.0 to 1.0.
+func LookFloat(b int8) float64 {
+	return math.Max(-1, float64(b)/127)
+}
+
+// crc8 computes CRC-8 (poly 0x07, init 0x00), cheap to implement on the ESP32.
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
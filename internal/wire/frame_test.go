@@ -0,0 +1,220 @@
+package wire
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+func TestStateFrame_RoundTrip(t *testing.T) {
+	state := personality.NewEmotionalState()
+	state.SetMood(personality.MoodFrightened, personality.IntensityHigh)
+
+	frame := NewStateFrame(42, state, string(personality.ActionFlee))
+	data := EncodeState(frame)
+
+	if len(data) != StateFrameLen {
+		t.Fatalf("len = %d, want %d", len(data), StateFrameLen)
+	}
+
+	got, err := DecodeState(data)
+	if err != nil {
+		t.Fatalf("DecodeState() error = %v", err)
+	}
+	if got != frame {
+		t.Errorf("got %+v, want %+v", got, frame)
+	}
+
+	if mood, _ := MoodFromID(got.Mood); mood != personality.MoodFrightened {
+		t.Errorf("mood = %s, want frightened", mood)
+	}
+	if action, _ := ActionFromID(got.Action); action != personality.ActionFlee {
+		t.Errorf("action = %s, want flee", action)
+	}
+	if int(got.Emotion) != personality.FaceFurious.Index() {
+		t.Errorf("emotion = %d, want %d", got.Emotion, personality.FaceFurious.Index())
+	}
+}
+
+func TestStateFrame_NoAction(t *testing.T) {
+	frame := NewStateFrame(1, personality.NewEmotionalState(), "")
+	if frame.Action != 0 {
+		t.Errorf("action = %d, want 0 for no action", frame.Action)
+	}
+}
+
+func TestCueFrame_RoundTrip(t *testing.T) {
+	frame := NewCueFrame(42, personality.ModifierHesitant, &personality.LookHint{X: 0.5, Y: -2})
+	data := EncodeCues(frame)
+
+	if len(data) != CueFrameLen {
+		t.Fatalf("len = %d, want %d", len(data), CueFrameLen)
+	}
+
+	got, err := DecodeCues(data)
+	if err != nil {
+		t.Fatalf("DecodeCues() error = %v", err)
+	}
+	if got != frame {
+		t.Errorf("got %+v, want %+v", got, frame)
+	}
+	if modifier, _ := ModifierFromID(got.Modifier); modifier != personality.ModifierHesitant {
+		t.Errorf("modifier = %s, want hesitant", modifier)
+	}
+	if !got.Look || got.LookX != 64 || LookFloat(got.LookY) != -1 {
+		t.Errorf("look = %+v, want (0.5, -1)", got)
+	}
+
+	// No modifier or look target: the display falls back to the mood's own
+	if none := NewCueFrame(1, "", nil); none.Modifier != 0 || none.Look {
+		t.Errorf("NewCueFrame(none) = %+v", none)
+	}
+	if _, err := DecodeState(data); !errors.Is(err, ErrBadType) {
+		t.Errorf("DecodeState(cue frame) error = %v, want %v", err, ErrBadType)
+	}
+}
+
+func TestEventFrame_RoundTrip(t *testing.T) {
+	frame := EventFrame{
+		Sequence:  7,
+		Event:     EventID(personality.EventPetted),
+		Intensity: IntensityByte(0.8),
+		Source:    "touch-left",
+	}
+
+	got, err := DecodeEvent(EncodeEvent(frame))
+	if err != nil {
+		t.Fatalf("DecodeEvent() error = %v", err)
+	}
+	if got != frame {
+		t.Errorf("got %+v, want %+v", got, frame)
+	}
+}
+
+func TestEventFrame_TruncatesLongSource(t *testing.T) {
+	long := string(bytes.Repeat([]byte("x"), MaxSourceLen+10))
+	got, err := DecodeEvent(EncodeEvent(EventFrame{Event: 1, Source: long}))
+	if err != nil {
+		t.Fatalf("DecodeEvent() error = %v", err)
+	}
+	if len(got.Source) != MaxSourceLen {
+		t.Errorf("source length = %d, want %d", len(got.Source), MaxSourceLen)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	valid := EncodeState(StateFrame{Sequence: 1, Mood: 2})
+
+	corrupt := func(i int, b byte) []byte {
+		d := append([]byte{}, valid...)
+		d[i] = b
+		return d
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrShortFrame},
+		{"bad magic", corrupt(0, 'X'), ErrBadMagic},
+		{"future version", corrupt(1, Version+1), ErrBadVersion},
+		{"flipped bit", corrupt(8, valid[8]^0x01), ErrBadChecksum},
+		{"truncated", valid[:len(valid)-2], ErrBadChecksum},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeState(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("DecodeState() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := DecodeEvent(valid); !errors.Is(err, ErrBadType) {
+		t.Errorf("DecodeEvent(state frame) error = %v, want %v", err, ErrBadType)
+	}
+}
+
+func TestIDs_Stable(t *testing.T) {
+	// These IDs are compiled into firmware; changing them breaks deployed displays.
+	if MoodID(personality.MoodCurious) != 1 || MoodID(personality.MoodCautious) != 7 {
+		t.Error("mood IDs changed")
+	}
+	if _, ok := MoodFromID(0); ok || MoodID("grumpy") != 0 {
+		t.Error("mood ID 0 should be reserved for unknown moods")
+	}
+	if ActionID(personality.ActionStay) != 1 || ActionID(personality.ActionSniff) != 25 {
+		t.Error("action IDs changed")
+	}
+	if EventID(personality.EventLoudNoise) != 1 || EventID(personality.EventTimePassedLong) != 17 || EventID(personality.EventCrowd) != 18 || EventID(personality.EventUncertainFace) != 19 ||
+		EventID(personality.EventPersonSad) != 20 || EventID(personality.EventPersonSurprised) != 23 {
+		t.Error("event IDs changed")
+	}
+	if ModifierID(personality.ModifierSlow) != 1 || ModifierID(personality.ModifierEager) != 7 {
+		t.Error("modifier IDs changed")
+	}
+	if _, ok := EventFromID(0); ok {
+		t.Error("event ID 0 should be invalid")
+	}
+}
+
+func FuzzDecodeState(f *testing.F) {
+	f.Add(EncodeState(StateFrame{Sequence: 1, Mood: 3, Emotion: 16, Intensity: 230, Action: 3}))
+	f.Add([]byte{Magic, Version, TypeState})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		frame, err := DecodeState(data)
+		if err != nil {
+			return
+		}
+		// Anything that decodes must re-encode to the same bytes
+		if !bytes.Equal(EncodeState(frame), data) {
+			t.Errorf("round trip mismatch for %x", data)
+		}
+	})
+}
+
+func FuzzDecodeCues(f *testing.F) {
+	f.Add(EncodeCues(CueFrame{Sequence: 7, Modifier: 2, Look: true, LookX: -40, LookY: 100}))
+	f.Add(EncodeCues(CueFrame{Sequence: 1}))
+	f.Add([]byte{Magic, Version, TypeCues, FlagLook})
+	unknownFlag := EncodeCues(CueFrame{Sequence: 3, Look: true, LookX: 5})
+	unknownFlag[3] |= 0x80
+	unknownFlag[len(unknownFlag)-1] = crc8(unknownFlag[:len(unknownFlag)-1])
+	f.Add(unknownFlag)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		frame, err := DecodeCues(data)
+		if err != nil {
+			return
+		}
+		// Flags it doesn't know are ignored, so compare frames, not bytes
+		again, err := DecodeCues(EncodeCues(frame))
+		if err != nil || again != frame {
+			t.Errorf("round trip mismatch for %x: %+v, then %+v (%v)", data, frame, again, err)
+		}
+	})
+}
+
+func FuzzDecodeEvent(f *testing.F) {
+	f.Add(EncodeEvent(EventFrame{Sequence: 9, Event: 12, Intensity: 200, Source: "touch"}))
+	f.Add(EncodeEvent(EventFrame{Event: 1}))
+	f.Add([]byte{Magic, Version, TypeEvent, 0, 0, 0, 1, 0, 255})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		frame, err := DecodeEvent(data)
+		if err != nil {
+			return
+		}
+		if len(frame.Source) > MaxSourceLen {
+			t.Fatalf("source longer than MaxSourceLen: %d", len(frame.Source))
+		}
+		if !bytes.Equal(EncodeEvent(frame), data) {
+			t.Errorf("round trip mismatch for %x", data)
+		}
+	})
+}
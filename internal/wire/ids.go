@@ -0,0 +1,150 @@
+package wire
+
+import "github.com/alex/koji/internal/personality"
+
+// moodIDs assigns wire IDs to moods. Index is the ID; ID 0 means "unknown";
+// append only.
+var moodIDs = []personality.Mood{
+	"", // unknown
+	personality.MoodCurious,
+	personality.MoodExcited,
+	personality.MoodStartled,
+	personality.MoodFrightened,
+	personality.MoodHappy,
+	personality.MoodSleepy,
+	personality.MoodCautious,
+}
+
+// actionIDs assigns wire IDs to actions. ID 0 means "no action"; append only.
+var actionIDs = []personality.Action{
+	"", // none
+	personality.ActionStay,
+	personality.ActionExplore,
+	personality.ActionFlee,
+	personality.ActionApproach,
+	personality.ActionRetreat,
+	personality.ActionFreeze,
+	personality.ActionWagTail,
+	personality.ActionPerkEars,
+	personality.ActionFlattenEars,
+	personality.ActionTiltHead,
+	personality.ActionCrouch,
+	personality.ActionBounce,
+	personality.ActionSpin,
+	personality.ActionCurl,
+	personality.ActionPeek,
+	personality.ActionNuzzle,
+	personality.ActionWhimper,
+	personality.ActionChirp,
+	personality.ActionBark,
+	personality.ActionGrowl,
+	personality.ActionYawn,
+	personality.ActionPurr,
+	personality.ActionHeadBob,
+	personality.ActionFlinch,
+	personality.ActionSniff,
+}
+
+// modifierIDs assigns wire IDs to action modifiers. ID 0 means "none, derive
+// it from the mood"; append only.
+var modifierIDs = []personality.ActionModifier{
+	"", // none
+	personality.ModifierSlow,
+	personality.ModifierNormal,
+	personality.ModifierFast,
+	personality.ModifierFrantic,
+	personality.ModifierGentle,
+	personality.ModifierHesitant,
+	personality.ModifierEager,
+}
+
+// eventIDs assigns wire IDs to events. ID 0 is reserved; append only.
+var eventIDs = []personality.Event{
+	"", // reserved
+	personality.EventLoudNoise,
+	personality.EventMusic,
+	personality.EventSpeech,
+	personality.EventSilence,
+	personality.EventRhythm,
+	personality.EventNameCalled,
+	personality.EventFamiliarFace,
+	personality.EventUnknownFace,
+	personality.EventMotionDetected,
+	personality.EventNoMotion,
+	personality.EventUnknownObject,
+	personality.EventPetted,
+	personality.EventPoked,
+	personality.EventPickedUp,
+	personality.EventTimePassedShort,
+	personality.EventTimePassedMedium,
+	personality.EventTimePassedLong,
+	personality.EventCrowd,
+	personality.EventUncertainFace,
+	personality.EventPersonSad,
+	personality.EventPersonHappy,
+	personality.EventPersonAngry,
+	personality.EventPersonSurprised,
+}
+
+// MoodID returns the wire ID for a mood (0 if unknown).
+func MoodID(mood personality.Mood) uint8 {
+	return indexOf(moodIDs, mood)
+}
+
+// MoodFromID returns the mood for a wire ID. ID 0 is never a valid mood.
+func MoodFromID(id uint8) (personality.Mood, bool) {
+	if id == 0 {
+		return "", false
+	}
+	return lookup(moodIDs, id)
+}
+
+// ActionID returns the wire ID for an action (0 if none or unknown).
+func ActionID(action personality.Action) uint8 {
+	return indexOf(actionIDs, action)
+}
+
+// ActionFromID returns the action for a wire ID.
+func ActionFromID(id uint8) (personality.Action, bool) {
+	return lookup(actionIDs, id)
+}
+
+// ModifierID returns the wire ID for an action modifier (0 if none or unknown).
+func ModifierID(modifier personality.ActionModifier) uint8 {
+	return indexOf(modifierIDs, modifier)
+}
+
+// ModifierFromID returns the action modifier for a wire ID.
+func ModifierFromID(id uint8) (personality.ActionModifier, bool) {
+	return lookup(modifierIDs, id)
+}
+
+// EventID returns the wire ID for an event (0 if unknown).
+func EventID(event personality.Event) uint8 {
+	return indexOf(eventIDs, event)
+}
+
+// EventFromID returns the event for a wire ID. ID 0 is never a valid event.
+func EventFromID(id uint8) (personality.Event, bool) {
+	if id == 0 {
+		return "", false
+	}
+	return lookup(eventIDs, id)
+}
+
+func indexOf[T comparable](table []T, v T) uint8 {
+	for i, t := range table {
+		if t == v {
+			return uint8(i)
+		}
+	}
+	return 0
+}
+
+func lookup[T any](table []T, id uint8) (T, bool) {
+	if int(id) >= len(table) {
+		var zero T
+		return zero, false
+	}
+	return table[id], true
+}
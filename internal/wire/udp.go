@@ -0,0 +1,230 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/alex/koji/internal/api"
+	"github.com/alex/koji/internal/personality"
+)
+
+// BroadcasterConfig holds configuration for the state broadcaster.
+type BroadcasterConfig struct {
+	Targets      []string      // UDP destinations, e.g. "255.255.255.255:4210" or a display's IP
+	Keepalive    time.Duration // resend unchanged state this often (default: 1s)
+	PollInterval time.Duration // how often to check for state changes (default: 50ms)
+}
+
+// Broadcaster sends state and cue frames over UDP on every change and on a
+// keepalive interval.
+type Broadcaster struct {
+	cfg      BroadcasterConfig
+	provider api.StateProvider
+
+	seq uint32
+}
+
+// NewBroadcaster creates a new UDP state broadcaster.
+func NewBroadcaster(cfg BroadcasterConfig, provider api.StateProvider) *Broadcaster {
+	if cfg.Keepalive == 0 {
+		cfg.Keepalive = 1 * time.Second
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = 50 * time.Millisecond
+	}
+	return &Broadcaster{cfg: cfg, provider: provider}
+}
+
+// Run sends state frames until the context is cancelled.
+func (b *Broadcaster) Run(ctx context.Context) error {
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return fmt.Errorf("opening UDP socket: %w", err)
+	}
+	defer conn.Close()
+
+	targets := make([]*net.UDPAddr, 0, len(b.cfg.Targets))
+	for _, t := range b.cfg.Targets {
+		addr, err := net.ResolveUDPAddr("udp", t)
+		if err != nil {
+			return fmt.Errorf("resolving %s: %w", t, err)
+		}
+		targets = append(targets, addr)
+	}
+
+	ticker := time.NewTicker(b.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var last StateFrame
+	var lastCues CueFrame
+	var lastSent time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		state := b.provider.GetState()
+		if state == nil {
+			continue
+		}
+		frame := NewStateFrame(0, state, b.provider.GetRecentAction())
+		cues := NewCueFrame(0, b.provider.GetRecentModifier(), b.provider.GetLookHint())
+
+		// Sequence is excluded from the comparison; it changes on every send
+		frame.Sequence = last.Sequence
+		cues.Sequence = lastCues.Sequence
+		changed := frame != last || cues != lastCues || lastSent.IsZero()
+		if !changed && time.Since(lastSent) < b.cfg.Keepalive {
+			continue
+		}
+
+		b.seq++
+		frame.Sequence = b.seq
+		cues.Sequence = b.seq
+		for _, data := range [][]byte{EncodeState(frame), EncodeCues(cues)} {
+			for _, addr := range targets {
+				if _, err := conn.WriteToUDP(data, addr); err != nil {
+					log.Printf("UDP: sending state to %s: %v", addr, err)
+				}
+			}
+		}
+		last = frame
+		lastCues = cues
+		lastSent = time.Now()
+	}
+}
+
+// senderIdle is how long a sender's last sequence number is kept. Sensors
+// come and go with DHCP and reboots, so the map mustn't grow forever.
+const senderIdle = 10 * time.Minute
+
+// Listener receives UDP event frames from sensors and feeds them into the brain.
+type Listener struct {
+	addr    string
+	handler api.EventHandler
+	now     func() time.Time
+
+	mu        sync.Mutex
+	lastSeq   map[string]senderSeq // per-sender, to drop retransmits
+	lastPrune time.Time
+	conn      *net.UDPConn
+}
+
+// senderSeq is the last sequence number seen from a sender, and when.
+type senderSeq struct {
+	seq uint16
+	at  time.Time
+}
+
+// NewListener creates a new UDP event listener.
+func NewListener(addr string, handler api.EventHandler) *Listener {
+	return &Listener{
+		addr:    addr,
+		handler: handler,
+		now:     time.Now,
+		lastSeq: make(map[string]senderSeq),
+	}
+}
+
+// Addr returns the bound address once Run has started listening.
+func (l *Listener) Addr() net.Addr {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	return l.conn.LocalAddr()
+}
+
+// Run listens for event frames until the context is cancelled.
+func (l *Listener) Run(ctx context.Context) error {
+	laddr, err := net.ResolveUDPAddr("udp", l.addr)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", l.addr, err)
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", l.addr, err)
+	}
+
+	l.mu.Lock()
+	l.conn = conn
+	l.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 256)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("reading UDP: %w", err)
+		}
+		l.handleFrame(buf[:n], from)
+	}
+}
+
+// handleFrame decodes one datagram and dispatches it as an event.
+func (l *Listener) handleFrame(data []byte, from *net.UDPAddr) {
+	frame, err := DecodeEvent(data)
+	if err != nil {
+		log.Printf("UDP: dropping frame from %s: %v", from, err)
+		return
+	}
+
+	event, ok := EventFromID(frame.Event)
+	if !ok {
+		log.Printf("UDP: unknown event ID %d from %s", frame.Event, from)
+		return
+	}
+
+	sender := from.String()
+	now := l.now()
+	l.mu.Lock()
+	l.pruneLocked(now)
+	last, seen := l.lastSeq[sender]
+	l.lastSeq[sender] = senderSeq{seq: frame.Sequence, at: now}
+	l.mu.Unlock()
+	if seen && last.seq == frame.Sequence {
+		return // retransmit
+	}
+
+	source := frame.Source
+	if source == "" {
+		source = from.IP.String()
+	}
+
+	ctx := personality.NewEventContext(event).WithSource(source)
+	if frame.Intensity != 0 {
+		ctx = ctx.WithIntensity(IntensityFloat(frame.Intensity))
+	}
+
+	moodChanged := l.handler.HandleEvent(ctx)
+	log.Printf("UDP event received: %s (intensity=%.2f, source=%s) -> mood_changed=%v",
+		ctx.Event, ctx.Intensity, ctx.Source, moodChanged)
+}
+
+// pruneLocked forgets senders idle for longer than senderIdle, at most once
+// per senderIdle. Caller holds l.mu.
+func (l *Listener) pruneLocked(now time.Time) {
+	if now.Sub(l.lastPrune) < senderIdle {
+		return
+	}
+	l.lastPrune = now
+	for sender, last := range l.lastSeq {
+		if now.Sub(last.at) > senderIdle {
+			delete(l.lastSeq, sender)
+		}
+	}
+}
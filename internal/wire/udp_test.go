@@ -0,0 +1,213 @@
+package wire
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alex/koji/internal/personality"
+)
+
+type fakeBrain struct {
+	mu       sync.Mutex
+	state    personality.EmotionalState
+	action   string
+	modifier personality.ActionModifier
+	look     *personality.LookHint
+	events   []personality.EventContext
+}
+
+func (f *fakeBrain) GetState() *personality.EmotionalState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state := f.state
+	return &state
+}
+
+func (f *fakeBrain) GetRecentAction() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.action
+}
+
+func (f *fakeBrain) GetRecentModifier() personality.ActionModifier {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.modifier
+}
+
+func (f *fakeBrain) GetLookHint() *personality.LookHint {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.look
+}
+
+func (f *fakeBrain) HandleEvent(ctx personality.EventContext) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, ctx)
+	return false
+}
+
+func TestBroadcaster_SendsOnChangeAndKeepalive(t *testing.T) {
+	display, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer display.Close()
+
+	brain := &fakeBrain{state: *personality.NewEmotionalState()}
+	b := NewBroadcaster(BroadcasterConfig{
+		Targets:      []string{display.LocalAddr().String()},
+		Keepalive:    100 * time.Millisecond,
+		PollInterval: 5 * time.Millisecond,
+	}, brain)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+
+	readFrame := func() []byte {
+		t.Helper()
+		buf := make([]byte, 64)
+		display.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := display.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("reading frame: %v", err)
+		}
+		return buf[:n]
+	}
+	// Each state frame is followed by its cue frame
+	read := func() (StateFrame, CueFrame) {
+		t.Helper()
+		frame, err := DecodeState(readFrame())
+		if err != nil {
+			t.Fatalf("DecodeState() error = %v", err)
+		}
+		cues, err := DecodeCues(readFrame())
+		if err != nil {
+			t.Fatalf("DecodeCues() error = %v", err)
+		}
+		if cues.Sequence != frame.Sequence {
+			t.Errorf("cue sequence = %d, want %d", cues.Sequence, frame.Sequence)
+		}
+		return frame, cues
+	}
+
+	first, firstCues := read()
+	if mood, _ := MoodFromID(first.Mood); mood != personality.MoodCurious {
+		t.Errorf("first frame mood = %s, want curious", mood)
+	}
+	if firstCues.Modifier != 0 || firstCues.Look {
+		t.Errorf("first cues = %+v, want none", firstCues)
+	}
+
+	// Unchanged state: next frame is a keepalive with a new sequence number
+	keepalive, _ := read()
+	if keepalive.Sequence != first.Sequence+1 || keepalive.Mood != first.Mood {
+		t.Errorf("keepalive = %+v after %+v", keepalive, first)
+	}
+
+	brain.mu.Lock()
+	brain.state.SetMood(personality.MoodHappy, personality.IntensityMedium)
+	brain.action = string(personality.ActionWagTail)
+	brain.modifier = personality.ModifierEager
+	brain.look = &personality.LookHint{X: -1, Y: 0.5}
+	brain.mu.Unlock()
+
+	changed, cues := read()
+	if mood, _ := MoodFromID(changed.Mood); mood != personality.MoodHappy {
+		t.Errorf("mood = %s, want happy", mood)
+	}
+	if action, _ := ActionFromID(changed.Action); action != personality.ActionWagTail {
+		t.Errorf("action = %s, want wag_tail", action)
+	}
+	if modifier, _ := ModifierFromID(cues.Modifier); modifier != personality.ModifierEager {
+		t.Errorf("modifier = %s, want eager", modifier)
+	}
+	if !cues.Look || LookFloat(cues.LookX) != -1 || cues.LookY != 64 {
+		t.Errorf("cues = %+v, want a look at (-1, 0.5)", cues)
+	}
+}
+
+func TestListener_IngestsEventsAndDropsRetransmits(t *testing.T) {
+	brain := &fakeBrain{}
+	l := NewListener("127.0.0.1:0", brain)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Run(ctx)
+
+	var addr net.Addr
+	for i := 0; i < 100 && addr == nil; i++ {
+		time.Sleep(5 * time.Millisecond)
+		addr = l.Addr()
+	}
+	if addr == nil {
+		t.Fatal("listener did not start")
+	}
+
+	sensor, err := net.DialUDP("udp", nil, addr.(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer sensor.Close()
+
+	petted := EncodeEvent(EventFrame{Sequence: 1, Event: EventID(personality.EventPetted), Intensity: 255, Source: "touch"})
+	sensor.Write(petted)
+	sensor.Write(petted) // retransmit
+	sensor.Write([]byte("garbage"))
+	sensor.Write(EncodeEvent(EventFrame{Sequence: 2, Event: EventID(personality.EventLoudNoise)}))
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		brain.mu.Lock()
+		n := len(brain.events)
+		brain.mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond) // let any stray duplicate arrive
+
+	brain.mu.Lock()
+	defer brain.mu.Unlock()
+	if len(brain.events) != 2 {
+		t.Fatalf("expected 2 events, got %d: %+v", len(brain.events), brain.events)
+	}
+	if brain.events[0].Event != personality.EventPetted || brain.events[0].Source != "touch" || brain.events[0].Intensity != 1 {
+		t.Errorf("event[0] = %+v", brain.events[0])
+	}
+	if brain.events[1].Event != personality.EventLoudNoise || brain.events[1].Intensity != 0.5 {
+		t.Errorf("event[1] = %+v", brain.events[1])
+	}
+	if brain.events[1].Source != "127.0.0.1" {
+		t.Errorf("event[1] source = %q, want sender IP", brain.events[1].Source)
+	}
+}
+
+func TestListener_ForgetsIdleSenders(t *testing.T) {
+	brain := &fakeBrain{}
+	l := NewListener("127.0.0.1:0", brain)
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	petted := EncodeEvent(EventFrame{Sequence: 1, Event: EventID(personality.EventPetted)})
+	for i := 1; i <= 3; i++ {
+		l.handleFrame(petted, &net.UDPAddr{IP: net.IPv4(10, 0, 0, byte(i)), Port: 4211})
+	}
+	if len(l.lastSeq) != 3 {
+		t.Fatalf("tracking %d senders, want 3", len(l.lastSeq))
+	}
+
+	// One sensor keeps talking; the others went away
+	now = now.Add(senderIdle + time.Second)
+	active := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4211}
+	l.handleFrame(EncodeEvent(EventFrame{Sequence: 2, Event: EventID(personality.EventPetted)}), active)
+	if _, ok := l.lastSeq[active.String()]; len(l.lastSeq) != 1 || !ok {
+		t.Errorf("senders after idle timeout = %v, want only the active one", l.lastSeq)
+	}
+}